@@ -0,0 +1,146 @@
+// Package httprule reads the google.api.http annotations of proto methods for the protoc plugins
+// in cmd.
+package httprule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pathParam matches the {field} segments of a path template
+var pathParam = regexp.MustCompile(`\{([a-z_][a-z0-9_]*)\}`)
+
+// Binding is the REST mapping of one RPC
+type Binding struct {
+	File    *protogen.File
+	Service *protogen.Service
+	Method  *protogen.Method
+
+	// Verb is the HTTP method, e.g. GET
+	Verb string
+
+	// Path is the path template
+	Path string
+
+	// Body is "*", the name of the field sent as the body, or empty
+	Body string
+
+	// Params are the fields named by the path template, in order
+	Params []string
+}
+
+// Of reads the google.api.http annotation of a method. It reports false for methods without
+// an annotation and for streaming methods, which have no plain REST mapping.
+func Of(file *protogen.File, service *protogen.Service, method *protogen.Method) (Binding, bool, error) {
+	rule, ok := proto.GetExtension(method.Desc.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil || rule.GetPattern() == nil {
+		return Binding{}, false, nil
+	}
+	if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+		return Binding{}, false, nil
+	}
+
+	b := Binding{File: file, Service: service, Method: method, Body: rule.GetBody()}
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		b.Verb, b.Path = "GET", pattern.Get
+	case *annotations.HttpRule_Put:
+		b.Verb, b.Path = "PUT", pattern.Put
+	case *annotations.HttpRule_Post:
+		b.Verb, b.Path = "POST", pattern.Post
+	case *annotations.HttpRule_Delete:
+		b.Verb, b.Path = "DELETE", pattern.Delete
+	case *annotations.HttpRule_Patch:
+		b.Verb, b.Path = "PATCH", pattern.Patch
+	case *annotations.HttpRule_Custom:
+		b.Verb, b.Path = strings.ToUpper(pattern.Custom.GetKind()), pattern.Custom.GetPath()
+	}
+	if len(rule.GetAdditionalBindings()) > 0 {
+		return b, false, fmt.Errorf("%s: additional_bindings are not supported", method.Desc.FullName())
+	}
+
+	fields := method.Input.Desc.Fields()
+	for _, match := range pathParam.FindAllStringSubmatch(b.Path, -1) {
+		field := fields.ByName(protoreflect.Name(match[1]))
+		if field == nil || field.IsList() || field.Message() != nil {
+			return b, false, fmt.Errorf("%s: path parameter {%s} must be a scalar field of %s",
+				method.Desc.FullName(), match[1], method.Input.Desc.FullName())
+		}
+		b.Params = append(b.Params, match[1])
+	}
+	if b.Body != "" && b.Body != "*" && fields.ByName(protoreflect.Name(b.Body)) == nil {
+		return b, false, fmt.Errorf("%s: body field %q does not exist", method.Desc.FullName(), b.Body)
+	}
+	return b, true, nil
+}
+
+// Collect returns the bindings of the files to generate, grouped by service in declaration order
+func Collect(plugin *protogen.Plugin) ([]*protogen.Service, map[*protogen.Service][]Binding, error) {
+	var services []*protogen.Service
+	bindings := map[*protogen.Service][]Binding{}
+	for _, file := range plugin.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, service := range file.Services {
+			for _, method := range service.Methods {
+				b, ok, err := Of(file, service, method)
+				if err != nil {
+					return nil, nil, err
+				}
+				if ok {
+					bindings[service] = append(bindings[service], b)
+				}
+			}
+			if len(bindings[service]) > 0 {
+				services = append(services, service)
+			}
+		}
+	}
+	return services, bindings, nil
+}
+
+// Sources lists the proto files that declare the services, without duplicates
+func Sources(services []*protogen.Service, bindings map[*protogen.Service][]Binding) []string {
+	var sources []string
+	seen := map[string]bool{}
+	for _, service := range services {
+		path := bindings[service][0].File.Desc.Path()
+		if !seen[path] {
+			seen[path] = true
+			sources = append(sources, path)
+		}
+	}
+	return sources
+}
+
+// IsParam reports whether a field is taken from the path template
+func (b Binding) IsParam(name string) bool {
+	for _, param := range b.Params {
+		if param == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitPath splits the path template into literal segments and parameter names: the parameter
+// names are at the odd indexes
+func (b Binding) SplitPath() []string {
+	var parts []string
+	rest := b.Path
+	for {
+		loc := pathParam.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			return append(parts, rest)
+		}
+		parts = append(parts, rest[:loc[0]], rest[loc[2]:loc[3]])
+		rest = rest[loc[1]:]
+	}
+}
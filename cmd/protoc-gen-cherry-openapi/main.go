@@ -0,0 +1,392 @@
+// protoc-gen-cherry-openapi generates an OpenAPI 3 document of the REST API from the
+// google.api.http annotations of the services in proto/*.proto.
+//
+// The document describes the same routes as protoc-gen-cherry-rest: one operation per
+// annotated, non-streaming RPC. Request and response schemas follow the JSON the server and the
+// generated clients exchange, which is encoding/json of the generated messages: fields use
+// their proto names and enums are numbers. Query parameters name enum values by their short
+// lower-case name, as api.EncodeQuery does. Errors use the server's error envelope.
+//
+// Parameters:
+//
+//	file=<path>     output file (default cherry_backend/pkg/api/openapi/openapi.json)
+//	title=<title>   info.title of the document (default Cherry Backend API)
+//	module=<module> strip this module prefix from output paths, as protoc-gen-go does
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"cherry_backend/cmd/internal/httprule"
+)
+
+// Document is the subset of an OpenAPI 3 document that the generator writes
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Tags       []Tag                           `json:"tags,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups the operations of a service
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation is one HTTP method on a path
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Explode     *bool   `json:"explode,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the JSON body of a request
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the shared schemas
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is a JSON schema
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// errorSchemas describe the error envelope written by the server for every non-2xx response
+var errorSchemas = map[string]*Schema{
+	"Error": {
+		Type:        "object",
+		Description: "Error describes why a request failed",
+		Required:    []string{"code", "message"},
+		Properties: map[string]*Schema{
+			"code": {Type: "string", Description: "machine-readable error code", Enum: []interface{}{
+				"bad_request", "invalid_argument", "unauthenticated", "permission_denied", "not_found",
+				"method_not_allowed", "conflict", "rate_limited", "internal", "unavailable",
+			}},
+			"message":    {Type: "string", Description: "human-readable description of the error"},
+			"request_id": {Type: "string", Description: "ID of the request, also sent as the X-Request-Id header"},
+		},
+	},
+	"ErrorEnvelope": {
+		Type:       "object",
+		Required:   []string{"error"},
+		Properties: map[string]*Schema{"error": {Ref: "#/components/schemas/Error"}},
+	},
+}
+
+func main() {
+	var flags flag.FlagSet
+	file := flags.String("file", "cherry_backend/pkg/api/openapi/openapi.json", "output file")
+	title := flags.String("title", "Cherry Backend API", "info.title of the document")
+
+	protogen.Options{ParamFunc: flags.Set}.Run(func(plugin *protogen.Plugin) error {
+		plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+
+		services, bindings, err := httprule.Collect(plugin)
+		if err != nil || len(services) == 0 {
+			return err
+		}
+
+		doc := newDocument(*title, services, bindings)
+		var out bytes.Buffer
+		encoder := json.NewEncoder(&out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+		plugin.NewGeneratedFile(*file, "").Write(out.Bytes())
+		return nil
+	})
+}
+
+// generator accumulates the schemas referenced by the operations
+type generator struct {
+	schemas map[string]*Schema
+}
+
+// newDocument builds the document of the annotated services
+func newDocument(title string, services []*protogen.Service, bindings map[*protogen.Service][]httprule.Binding) *Document {
+	gen := &generator{schemas: map[string]*Schema{}}
+	for name, schema := range errorSchemas {
+		gen.schemas[name] = schema
+	}
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       title,
+			Version:     version(services[0]),
+			Description: "Generated by protoc-gen-cherry-openapi from " + strings.Join(httprule.Sources(services, bindings), ", ") + ".",
+		},
+		Paths: map[string]map[string]Operation{},
+	}
+	for _, service := range services {
+		doc.Tags = append(doc.Tags, Tag{Name: service.GoName, Description: comment(service.Comments.Leading)})
+		for _, b := range bindings[service] {
+			if doc.Paths[b.Path] == nil {
+				doc.Paths[b.Path] = map[string]Operation{}
+			}
+			doc.Paths[b.Path][strings.ToLower(b.Verb)] = gen.operation(b)
+		}
+	}
+	doc.Components.Schemas = gen.schemas
+	return doc
+}
+
+// version takes the API version from the proto package, e.g. v1 from cherry.api.v1
+func version(service *protogen.Service) string {
+	pkg := string(service.Desc.ParentFile().Package())
+	return pkg[strings.LastIndex(pkg, ".")+1:]
+}
+
+// operation describes one RPC
+func (gen *generator) operation(b httprule.Binding) Operation {
+	description := comment(b.Method.Comments.Leading)
+	summary, _, _ := strings.Cut(description, "\n")
+	if summary == description {
+		description = ""
+	}
+	op := Operation{
+		OperationID: b.Service.GoName + "_" + b.Method.GoName,
+		Summary:     summary,
+		Description: description,
+		Tags:        []string{b.Service.GoName},
+		Responses: map[string]Response{
+			"200": {
+				Description: "OK",
+				Content:     jsonContent(gen.messageRef(b.Method.Output)),
+			},
+			"default": {
+				Description: "Error",
+				Content:     jsonContent(&Schema{Ref: "#/components/schemas/ErrorEnvelope"}),
+			},
+		},
+	}
+
+	for _, field := range b.Method.Input.Fields {
+		name := string(field.Desc.Name())
+		switch {
+		case b.IsParam(name):
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        name,
+				In:          "path",
+				Description: comment(field.Comments.Leading),
+				Required:    true,
+				Schema:      gen.querySchema(field),
+			})
+		case b.Body == "*" || b.Body == name || field.Message != nil:
+		default:
+			param := Parameter{
+				Name:        name,
+				In:          "query",
+				Description: comment(field.Comments.Leading),
+				Schema:      gen.querySchema(field),
+			}
+			if field.Desc.IsList() {
+				explode := true
+				param.Explode = &explode
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+	}
+
+	switch b.Body {
+	case "":
+	case "*":
+		op.RequestBody = &RequestBody{Required: true, Content: jsonContent(gen.messageRef(b.Method.Input))}
+	default:
+		for _, field := range b.Method.Input.Fields {
+			if string(field.Desc.Name()) == b.Body {
+				op.RequestBody = &RequestBody{Required: true, Content: jsonContent(gen.fieldSchema(field))}
+			}
+		}
+	}
+	return op
+}
+
+// messageRef returns a reference to the schema of a message, adding it to the components
+func (gen *generator) messageRef(message *protogen.Message) *Schema {
+	name := schemaName(message)
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := gen.schemas[name]; ok {
+		return ref
+	}
+
+	schema := &Schema{Type: "object", Description: comment(message.Comments.Leading), Properties: map[string]*Schema{}}
+	gen.schemas[name] = schema
+	for _, field := range message.Fields {
+		schema.Properties[string(field.Desc.Name())] = gen.fieldSchema(field)
+	}
+	return ref
+}
+
+// fieldSchema describes a field in a JSON body
+func (gen *generator) fieldSchema(field *protogen.Field) *Schema {
+	var schema *Schema
+	switch {
+	case field.Desc.IsMap():
+		schema = &Schema{Type: "object", AdditionalProperties: gen.valueSchema(field.Message.Fields[1], false)}
+	case field.Desc.IsList():
+		schema = &Schema{Type: "array", Items: gen.valueSchema(field, false)}
+	default:
+		schema = gen.valueSchema(field, false)
+	}
+	if schema.Ref == "" {
+		schema.Description = comment(field.Comments.Leading)
+	}
+	return schema
+}
+
+// querySchema describes a field sent as a path or query parameter
+func (gen *generator) querySchema(field *protogen.Field) *Schema {
+	if field.Desc.IsList() {
+		return &Schema{Type: "array", Items: gen.valueSchema(field, true)}
+	}
+	return gen.valueSchema(field, true)
+}
+
+// valueSchema describes a single value of a field; enums are names in queries and numbers in bodies
+func (gen *generator) valueSchema(field *protogen.Field, query bool) *Schema {
+	zero := 0
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &Schema{Type: "integer", Format: "int64"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer", Format: "int64", Minimum: &zero}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Type: "integer", Format: "uint64", Minimum: &zero}
+	case protoreflect.FloatKind:
+		return &Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: "number", Format: "double"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		return enumSchema(field.Enum, query)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return gen.messageRef(field.Message)
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// enumSchema lists the values of an enum
+func enumSchema(enum *protogen.Enum, query bool) *Schema {
+	prefix := enumPrefix(string(enum.Desc.Name()))
+	schema := &Schema{Type: "integer", Format: "int32"}
+	if query {
+		schema = &Schema{Type: "string"}
+	}
+	var names []string
+	for _, value := range enum.Values {
+		name := string(value.Desc.Name())
+		names = append(names, fmt.Sprintf("%d: %s", value.Desc.Number(), name))
+		if query {
+			if value.Desc.Number() != 0 {
+				schema.Enum = append(schema.Enum, strings.ToLower(strings.TrimPrefix(name, prefix)))
+			}
+		} else {
+			schema.Enum = append(schema.Enum, int(value.Desc.Number()))
+		}
+	}
+	schema.Description = comment(enum.Comments.Leading)
+	if !query {
+		schema.Description = strings.TrimSpace(schema.Description + "\n\n" + strings.Join(names, "\n"))
+	}
+	return schema
+}
+
+// enumPrefix converts an enum type name to the upper snake case prefix of its values
+func enumPrefix(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String()) + "_"
+}
+
+// schemaName names the schema of a message after its name within the proto package
+func schemaName(message *protogen.Message) string {
+	name := string(message.Desc.FullName())
+	if pkg := string(message.Desc.ParentFile().Package()); pkg != "" {
+		name = strings.TrimPrefix(name, pkg+".")
+	}
+	return strings.ReplaceAll(name, ".", "_")
+}
+
+// comment turns a proto comment into plain text
+func comment(c protogen.Comments) string {
+	lines := strings.Split(strings.TrimSpace(string(c)), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// jsonContent wraps a schema as an application/json body
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
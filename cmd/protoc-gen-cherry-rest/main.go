@@ -0,0 +1,170 @@
+// protoc-gen-cherry-rest generates the REST routes and typed REST clients of the services in
+// proto/*.proto from their google.api.http annotations.
+//
+// All files of one protoc run are generated into a single rest.pb.go in the client package:
+// a <Name>Client per service (TodoistService becomes TodoistClient) built on ClientGenerator,
+// and the Routes table that the server registers its handlers from. RPCs without an HTTP
+// annotation and streaming RPCs are left out.
+//
+// Parameters:
+//
+//	package=<import path>  Go package of the generated clients (default cherry_backend/pkg/api)
+//	module=<module>        strip this module prefix from output paths, as protoc-gen-go does
+package main
+
+import (
+	"flag"
+	"path"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"cherry_backend/cmd/internal/httprule"
+)
+
+func main() {
+	var flags flag.FlagSet
+	clientPackage := flags.String("package", "cherry_backend/pkg/api", "Go package of the generated clients")
+
+	protogen.Options{ParamFunc: flags.Set}.Run(func(plugin *protogen.Plugin) error {
+		plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+
+		services, bindings, err := httprule.Collect(plugin)
+		if err != nil || len(services) == 0 {
+			return err
+		}
+
+		importPath := protogen.GoImportPath(*clientPackage)
+		g := plugin.NewGeneratedFile(path.Join(*clientPackage, "rest.pb.go"), importPath)
+		generate(g, path.Base(*clientPackage), services, bindings)
+		return nil
+	})
+}
+
+// generate writes the clients and the route table
+func generate(g *protogen.GeneratedFile, packageName string, services []*protogen.Service, bindings map[*protogen.Service][]httprule.Binding) {
+	g.P("// Code generated by protoc-gen-cherry-rest. DO NOT EDIT.")
+	g.P("// sources: ", strings.Join(httprule.Sources(services, bindings), ", "))
+	g.P()
+	g.P("package ", packageName)
+	g.P()
+
+	for _, service := range services {
+		name := clientName(service)
+		g.P("// ", name, " is the REST client of ", service.GoName)
+		g.P("type ", name, " struct {")
+		g.P("// Generator sends the requests; configure retries, timeouts and middleware on it")
+		g.P("Generator *ClientGenerator")
+		g.P("}")
+		g.P()
+		g.P("// New", name, " creates a REST client of ", service.GoName)
+		g.P("func New", name, "(baseURL string) *", name, " {")
+		g.P("return &", name, "{Generator: NewClientGenerator(baseURL)}")
+		g.P("}")
+		g.P()
+		for _, b := range bindings[service] {
+			generateMethod(g, name, b)
+		}
+	}
+
+	g.P("// Routes lists the REST routes of all services, in the order they are declared")
+	g.P("var Routes = []Route{")
+	for _, service := range services {
+		for _, b := range bindings[service] {
+			g.P("{")
+			g.P("FullMethod: ", g.QualifiedGoIdent(b.File.GoImportPath.Ident(
+				service.GoName+"_"+b.Method.GoName+"_FullMethodName")), ",")
+			g.P("Method: ", strconv.Quote(b.Verb), ",")
+			g.P("Path: ", strconv.Quote(b.Path), ",")
+			if b.Body != "" {
+				g.P("Body: ", strconv.Quote(b.Body), ",")
+			}
+			g.P("},")
+		}
+	}
+	g.P("}")
+}
+
+// generateMethod writes the client method of one RPC
+func generateMethod(g *protogen.GeneratedFile, client string, b httprule.Binding) {
+	input := g.QualifiedGoIdent(b.Method.Input.GoIdent)
+	output := g.QualifiedGoIdent(b.Method.Output.GoIdent)
+	contextType := g.QualifiedGoIdent(protogen.GoIdent{GoName: "Context", GoImportPath: "context"})
+
+	if b.Method.Comments.Leading != "" {
+		g.P(strings.TrimSuffix(b.Method.Comments.Leading.String(), "\n"))
+	} else {
+		g.P("// ", b.Method.GoName, " calls ", b.Method.Desc.FullName())
+	}
+	g.P("//")
+	g.P("// ", b.Verb, " ", b.Path)
+	g.P("func (c *", client, ") ", b.Method.GoName, "(ctx ", contextType, ", in *", input, ") (*", output, ", error) {")
+
+	g.P("request := &Request{")
+	g.P("Method: ", strconv.Quote(b.Verb), ",")
+	g.P("Path: ", pathExpr(g, b), ",")
+	switch b.Body {
+	case "":
+		g.P("QueryValues: EncodeQuery(in", quotedList(b.Params), "),")
+	case "*":
+		g.P("Body: in,")
+	default:
+		g.P("Body: in.Get", goFieldName(b.Method, b.Body), "(),")
+		g.P("QueryValues: EncodeQuery(in", quotedList(append(append([]string{}, b.Params...), b.Body)), "),")
+	}
+	g.P("}")
+	g.P("out := new(", output, ")")
+	g.P("if err := c.Generator.invoke(ctx, request, out); err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return out, nil")
+	g.P("}")
+	g.P()
+}
+
+// pathExpr builds the Go expression of the request path, escaping the path parameters
+func pathExpr(g *protogen.GeneratedFile, b httprule.Binding) string {
+	if len(b.Params) == 0 {
+		return strconv.Quote(b.Path)
+	}
+	escape := g.QualifiedGoIdent(protogen.GoIdent{GoName: "PathEscape", GoImportPath: "net/url"})
+	sprint := g.QualifiedGoIdent(protogen.GoIdent{GoName: "Sprint", GoImportPath: "fmt"})
+	var parts []string
+	for i, part := range b.SplitPath() {
+		switch {
+		case i%2 == 1:
+			parts = append(parts, escape+"("+sprint+"(in.Get"+goFieldName(b.Method, part)+"()))")
+		case part != "":
+			parts = append(parts, strconv.Quote(part))
+		}
+	}
+	return strings.Join(parts, " + ")
+}
+
+// goFieldName returns the Go name of a field of the method input
+func goFieldName(method *protogen.Method, name string) string {
+	for _, f := range method.Input.Fields {
+		if f.Desc.Name() == protoreflect.Name(name) {
+			return f.GoName
+		}
+	}
+	return name
+}
+
+// clientName names the client of a service: TodoistService becomes TodoistClient
+func clientName(service *protogen.Service) string {
+	return strings.TrimSuffix(service.GoName, "Service") + "Client"
+}
+
+// quotedList formats names as additional string arguments
+func quotedList(names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(", ")
+		b.WriteString(strconv.Quote(name))
+	}
+	return b.String()
+}
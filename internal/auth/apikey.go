@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned for API keys that do not exist
+var ErrKeyNotFound = errors.New("API key not found")
+
+// apiKeyPrefix starts every API key, which tells them apart from other bearer tokens and makes
+// leaked keys easy to find
+const apiKeyPrefix = "chk_"
+
+// Key is a stored API key. Only the SHA-256 hash of its secret is kept; the key itself is shown
+// once, when it is created. Keys are long random strings, so a fast hash is enough.
+type Key struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Hash      string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the key has expired at the given time
+func (k *Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// KeyStore stores API keys
+type KeyStore interface {
+	// Create stores a new key
+	Create(ctx context.Context, key *Key) error
+
+	// Get returns a key by ID, or ErrKeyNotFound
+	Get(ctx context.Context, id string) (*Key, error)
+
+	// List returns the keys of a user ordered by creation
+	List(ctx context.Context, userID string) ([]Key, error)
+
+	// Delete removes a key of a user, or returns ErrKeyNotFound
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// NewKey generates an API key for a user and returns it with the record to store
+func NewKey(userID, name string, scopes []string, expiresAt *time.Time, now time.Time) (string, *Key, error) {
+	scopes, err := ValidateScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("%w: an API key needs at least one scope", ErrInvalidScope)
+	}
+	// The ID is hex, so the first underscore after the prefix ends it
+	idBytes := make([]byte, 6)
+	rand.Read(idBytes)
+	id := hex.EncodeToString(idBytes)
+	secret := randomString(24)
+	return apiKeyPrefix + id + "_" + secret, &Key{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      hashSecret(secret),
+		CreatedAt: now.UTC(),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// parseKey splits an API key into its ID and secret
+func parseKey(token string) (id, secret string, ok bool) {
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), "_")
+	return id, secret, ok && id != "" && secret != ""
+}
+
+// matches reports whether a secret belongs to the key
+func (k *Key) matches(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.Hash)) == 1
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString returns n random bytes encoded for use in tokens
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// MemoryKeyStore implements KeyStore in memory
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryKeyStore creates an empty in-memory key store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[string]Key)}
+}
+
+// Create stores a new key
+func (s *MemoryKeyStore) Create(ctx context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key.ID]; ok {
+		return fmt.Errorf("API key %s already exists", key.ID)
+	}
+	s.keys[key.ID] = *key
+	return nil
+}
+
+// Get returns a key by ID
+func (s *MemoryKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return &key, nil
+}
+
+// List returns the keys of a user ordered by creation
+func (s *MemoryKeyStore) List(ctx context.Context, userID string) ([]Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := []Key{}
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// Delete removes a key of a user
+func (s *MemoryKeyStore) Delete(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[id]; !ok || key.UserID != userID {
+		return ErrKeyNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
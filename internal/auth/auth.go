@@ -0,0 +1,123 @@
+// Package auth authenticates client requests and says what they may do. Clients present a
+// bearer token: an API key, a JWT session token issued after Todoist OAuth login, or, for
+// users configured in TODOIST_API_TOKENS, their Todoist API token. Each resolves to a Principal,
+// the user the request acts for and the scopes it was granted.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUnauthenticated is returned when a request carries no valid credentials
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrInsufficientScope is returned when valid credentials lack the scope a request needs
+	ErrInsufficientScope = errors.New("insufficient scope")
+
+	// ErrInvalidScope is returned for unknown scope names
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Scopes grant access to groups of endpoints
+const (
+	// ScopeTasksRead lists the user's mirrored tasks
+	ScopeTasksRead = "tasks:read"
+
+	// ScopeEventsRead streams the user's events
+	ScopeEventsRead = "events:read"
+
+	// ScopeStatsRead reads the user's productivity statistics
+	ScopeStatsRead = "stats:read"
+
+	// ScopeKeysWrite creates and revokes the user's API keys
+	ScopeKeysWrite = "keys:write"
+
+	// ScopeWebhooksWrite submits webhooks over gRPC, where there is no Todoist signature
+	ScopeWebhooksWrite = "webhooks:write"
+)
+
+// Scopes lists every scope
+var Scopes = []string{ScopeTasksRead, ScopeEventsRead, ScopeStatsRead, ScopeKeysWrite, ScopeWebhooksWrite}
+
+// SessionScopes are the scopes of the session tokens issued after a Todoist login
+var SessionScopes = []string{ScopeTasksRead, ScopeEventsRead, ScopeStatsRead, ScopeKeysWrite}
+
+// TodoistTokenScopes are the scopes of requests authenticated with a Todoist API token
+var TodoistTokenScopes = []string{ScopeTasksRead, ScopeEventsRead, ScopeStatsRead}
+
+// ValidateScopes checks that every scope is known and returns them sorted without duplicates
+func ValidateScopes(scopes []string) ([]string, error) {
+	seen := map[string]bool{}
+	var result []string
+	for _, scope := range scopes {
+		if !contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w %q (want one of %s)", ErrInvalidScope, scope, strings.Join(Scopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Credential kinds of Principal.Method
+const (
+	MethodAPIKey       = "api_key"
+	MethodSession      = "session"
+	MethodTodoistToken = "todoist_token"
+)
+
+// Principal is the authenticated user of a request
+type Principal struct {
+	// UserID is the Todoist user ID the request acts for
+	UserID string
+
+	// Scopes are the scopes the credentials grant
+	Scopes []string
+
+	// Method is the kind of credentials, and KeyID the API key if they are one
+	Method string
+	KeyID  string
+}
+
+// HasScope reports whether the principal was granted a scope
+func (p *Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+// Require returns ErrInsufficientScope unless the principal was granted the scope
+func (p *Principal) Require(scope string) error {
+	if scope != "" && !p.HasScope(scope) {
+		return fmt.Errorf("%w: %s requires %q", ErrInsufficientScope, p.Method, scope)
+	}
+	return nil
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying the principal of a request
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of a request, if it was authenticated
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
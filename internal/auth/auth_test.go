@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"cherry_backend/internal/mirror"
+)
+
+// TestValidateScopes tests sorting and deduplicating scopes and rejecting unknown ones
+func TestValidateScopes(t *testing.T) {
+	scopes, err := ValidateScopes([]string{ScopeTasksRead, ScopeEventsRead, ScopeTasksRead})
+	if err != nil || strings.Join(scopes, " ") != "events:read tasks:read" {
+		t.Errorf("ValidateScopes() = %v, %v, want [events:read tasks:read]", scopes, err)
+	}
+	if _, err := ValidateScopes([]string{"tasks:write"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("ValidateScopes() of an unknown scope = %v, want ErrInvalidScope", err)
+	}
+}
+
+// TestAPIKeys tests creating, authenticating and expiring API keys
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	keys := NewMemoryKeyStore()
+	authenticator := &Authenticator{Keys: keys, Now: func() time.Time { return now }}
+
+	if _, _, err := NewKey("1", "empty", nil, nil, now); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("NewKey() without scopes = %v, want ErrInvalidScope", err)
+	}
+
+	expires := now.Add(time.Hour)
+	plaintext, key, err := NewKey("1", "ci", []string{ScopeTasksRead}, &expires, now)
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, apiKeyPrefix+key.ID+"_") {
+		t.Errorf("key %q does not start with its ID %s", plaintext, key.ID)
+	}
+	keys.Create(ctx, key)
+
+	principal, err := authenticator.Authenticate(ctx, plaintext)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if principal.UserID != "1" || principal.Method != MethodAPIKey || principal.KeyID != key.ID || !principal.HasScope(ScopeTasksRead) {
+		t.Errorf("principal = %+v, want user 1 with tasks:read from key %s", principal, key.ID)
+	}
+	if err := principal.Require(ScopeEventsRead); !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("Require(events:read) = %v, want ErrInsufficientScope", err)
+	}
+
+	for _, token := range []string{"", plaintext + "x", apiKeyPrefix + "000000000000_secret", apiKeyPrefix + "broken"} {
+		if _, err := authenticator.Authenticate(ctx, token); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Authenticate(%q) = %v, want ErrUnauthenticated", token, err)
+		}
+	}
+
+	now = expires
+	if _, err := authenticator.Authenticate(ctx, plaintext); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Authenticate() of an expired key = %v, want ErrUnauthenticated", err)
+	}
+
+	if err := keys.Delete(ctx, "2", key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Delete() of another user's key = %v, want ErrKeyNotFound", err)
+	}
+	if err := keys.Delete(ctx, "1", key.ID); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+}
+
+// TestSessions tests issuing and verifying session tokens
+func TestSessions(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	issuer := NewIssuer([]byte("0123456789abcdef0123456789abcdef"))
+	issuer.Now = func() time.Time { return now }
+
+	token, expires, err := issuer.Issue("1", SessionScopes)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if !expires.Equal(now.Add(DefaultSessionTTL)) {
+		t.Errorf("expires = %v, want %v", expires, now.Add(DefaultSessionTTL))
+	}
+	principal, err := issuer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if principal.UserID != "1" || principal.Method != MethodSession || !principal.HasScope(ScopeKeysWrite) {
+		t.Errorf("principal = %+v, want user 1 with the session scopes", principal)
+	}
+
+	if _, _, err := issuer.Issue("1", []string{"admin"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Issue() with an unknown scope = %v, want ErrInvalidScope", err)
+	}
+	other := NewIssuer([]byte("another secret of thirty-two byte"))
+	other.Now = issuer.Now
+	if _, err := other.Verify(token); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Verify() with another secret = %v, want ErrUnauthenticated", err)
+	}
+
+	now = expires
+	if _, err := issuer.Verify(token); !errors.Is(err, ErrUnauthenticated) || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Verify() of an expired token = %v, want an expired session", err)
+	}
+}
+
+// TestAuthenticateTodoistToken tests accepting configured Todoist API tokens
+func TestAuthenticateTodoistToken(t *testing.T) {
+	authenticator := &Authenticator{Todoist: mirror.StaticTokenSource{"1": "token-1"}}
+	principal, err := authenticator.Authenticate(context.Background(), "token-1")
+	if err != nil || principal.UserID != "1" || principal.Method != MethodTodoistToken || principal.HasScope(ScopeKeysWrite) {
+		t.Errorf("Authenticate() = %+v, %v, want user 1 with the Todoist token scopes", principal, err)
+	}
+	if _, err := authenticator.Authenticate(context.Background(), "a.b.c"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Authenticate() of a JWT without an issuer = %v, want ErrUnauthenticated", err)
+	}
+}
+
+// TestUnaryServerInterceptor tests that calls are authorized with the scope of their method
+func TestUnaryServerInterceptor(t *testing.T) {
+	scopes := MethodScopes{"/svc/Public": "", "/svc/Read": ScopeTasksRead}
+	authorize := func(ctx context.Context, token, scope string) (context.Context, error) {
+		switch token {
+		case "":
+			return nil, ErrUnauthenticated
+		case "reader":
+			principal := &Principal{UserID: "1", Scopes: []string{ScopeTasksRead}}
+			if err := principal.Require(scope); err != nil {
+				return nil, err
+			}
+			return NewContext(ctx, principal), nil
+		}
+		return nil, errors.New("store down")
+	}
+	interceptor := UnaryServerInterceptor(scopes, authorize)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if principal, ok := FromContext(ctx); ok {
+			return principal.UserID, nil
+		}
+		return "anonymous", nil
+	}
+
+	testCases := []struct {
+		method string
+		token  string
+		want   interface{}
+		code   codes.Code
+	}{
+		{"/svc/Public", "", "anonymous", codes.OK},
+		{"/svc/Read", "reader", "1", codes.OK},
+		{"/svc/Read", "", nil, codes.Unauthenticated},
+		{"/svc/Read", "broken", nil, codes.Unknown},
+		{"/svc/Hidden", "reader", nil, codes.PermissionDenied},
+	}
+	for _, tc := range testCases {
+		ctx := context.Background()
+		if tc.token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tc.token))
+		}
+		got, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+		if got != tc.want || status.Code(err) != tc.code {
+			t.Errorf("%s with %q = %v, %v; want %v, %v", tc.method, tc.token, got, err, tc.want, tc.code)
+		}
+	}
+
+	// Scopes a principal lacks are refused with PermissionDenied
+	scopes["/svc/Keys"] = ScopeKeysWrite
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer reader"))
+	if _, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Keys"}, handler); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("call without the scope = %v, want PermissionDenied", err)
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"cherry_backend/internal/mirror"
+)
+
+// Authenticator resolves bearer tokens to principals
+type Authenticator struct {
+	// Keys holds the API keys
+	Keys KeyStore
+
+	// Sessions verifies session tokens
+	Sessions *Issuer
+
+	// Todoist holds the Todoist API tokens accepted as credentials with TodoistTokenScopes
+	Todoist mirror.StaticTokenSource
+
+	// Now returns the current time for key expiry; defaults to time.Now
+	Now func() time.Time
+}
+
+// Authenticate returns the principal of a bearer token, or an error wrapping ErrUnauthenticated
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: no credentials", ErrUnauthenticated)
+	}
+	if id, secret, ok := parseKey(token); ok {
+		return a.authenticateKey(ctx, id, secret)
+	}
+	if looksLikeJWT(token) && a.Sessions != nil {
+		return a.Sessions.Verify(token)
+	}
+	for userID, userToken := range a.Todoist {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(userToken)) == 1 {
+			return &Principal{UserID: userID, Scopes: TodoistTokenScopes, Method: MethodTodoistToken}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown credentials", ErrUnauthenticated)
+}
+
+// authenticateKey checks an API key against its stored hash and expiry
+func (a *Authenticator) authenticateKey(ctx context.Context, id, secret string) (*Principal, error) {
+	if a.Keys == nil {
+		return nil, fmt.Errorf("%w: API keys are not accepted", ErrUnauthenticated)
+	}
+	key, err := a.Keys.Get(ctx, id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !key.matches(secret) {
+		return nil, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	if key.Expired(a.now()) {
+		return nil, fmt.Errorf("%w: API key %s expired", ErrUnauthenticated, key.ID)
+	}
+	return &Principal{UserID: key.UserID, Scopes: key.Scopes, Method: MethodAPIKey, KeyID: key.ID}, nil
+}
+
+func (a *Authenticator) now() time.Time {
+	if a.Now == nil {
+		return time.Now()
+	}
+	return a.Now()
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultSessionTTL is how long session tokens are valid
+const DefaultSessionTTL = 24 * time.Hour
+
+// sessionIssuer names the backend in the iss claim of its session tokens
+const sessionIssuer = "cherry_backend"
+
+// Issuer issues and verifies JWT session tokens, signed with HMAC-SHA256
+type Issuer struct {
+	// TTL is how long issued tokens are valid
+	TTL time.Duration
+
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+
+	secret []byte
+}
+
+// sessionClaims are the claims of a session token; the scopes are space-separated as in OAuth
+type sessionClaims struct {
+	Scope string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// NewIssuer creates an issuer signing with secret, which should be at least 32 random bytes
+func NewIssuer(secret []byte) *Issuer {
+	return &Issuer{TTL: DefaultSessionTTL, Now: time.Now, secret: secret}
+}
+
+// Issue returns a session token of a user with the given scopes and when it expires
+func (i *Issuer) Issue(userID string, scopes []string) (string, time.Time, error) {
+	scopes, err := ValidateScopes(scopes)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := i.Now().UTC().Truncate(time.Second)
+	expires := now.Add(i.TTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, sessionClaims{
+		Scope: strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    sessionIssuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+			ID:        randomString(12),
+		},
+	})
+	signed, err := token.SignedString(i.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign session token: %w", err)
+	}
+	return signed, expires, nil
+}
+
+// Verify checks the signature and expiry of a session token and returns its principal
+func (i *Issuer) Verify(token string) (*Principal, error) {
+	var claims sessionClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) { return i.secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(sessionIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(i.Now))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, fmt.Errorf("%w: session expired", ErrUnauthenticated)
+		}
+		return nil, fmt.Errorf("%w: invalid session token", ErrUnauthenticated)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: session token has no subject", ErrUnauthenticated)
+	}
+	return &Principal{UserID: claims.Subject, Scopes: strings.Fields(claims.Scope), Method: MethodSession}, nil
+}
+
+// looksLikeJWT reports whether a bearer token has the three dot-separated parts of a JWT
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
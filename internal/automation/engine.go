@@ -0,0 +1,428 @@
+package automation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"cherry_backend/internal/filter"
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/models"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+const (
+	// DefaultLoopWindow is how long a rule ignores events on items it changed itself
+	DefaultLoopWindow = 10 * time.Minute
+
+	// DefaultMaxFiringsPerMinute caps how often a single rule may fire
+	DefaultMaxFiringsPerMinute = 30
+)
+
+// TaskClient is the part of the Todoist REST client used by rule actions
+type TaskClient interface {
+	GetTask(ctx context.Context, id string) (*api.Task, error)
+	CreateTask(ctx context.Context, args *api.CreateTaskArgs) (*api.Task, error)
+	UpdateTask(ctx context.Context, id string, args *api.UpdateTaskArgs) (*api.Task, error)
+	CloseTask(ctx context.Context, id string) error
+	ReopenTask(ctx context.Context, id string) error
+	CreateComment(ctx context.Context, args *api.CreateCommentArgs) (*api.Comment, error)
+}
+
+// ClientFactory returns a Todoist client acting on behalf of a user
+type ClientFactory func(ctx context.Context, userID string) (TaskClient, error)
+
+// TokenClientFactory creates REST clients from the API tokens of a token source
+func TokenClientFactory(tokens mirror.TokenSource, baseURL string) ClientFactory {
+	return func(ctx context.Context, userID string) (TaskClient, error) {
+		token, err := tokens.Token(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return api.NewTodoistRESTClient(baseURL, token), nil
+	}
+}
+
+// Firing records what a rule did, or would have done, for one event
+type Firing struct {
+	Rule    string
+	Actions []string
+	DryRun  bool
+	Err     error
+}
+
+// Engine evaluates rules against webhook events and executes their actions
+type Engine struct {
+	Rules   []*Rule
+	Clients ClientFactory
+	Logger  logging.Logger
+
+	// Store is used to resolve project and section names in rule conditions; optional
+	Store mirror.Store
+
+	// DryRun logs the actions of every rule instead of executing them
+	DryRun bool
+
+	// LoopWindow is how long a rule ignores events on items it changed itself
+	LoopWindow time.Duration
+
+	// MaxFiringsPerMinute caps how often a single rule may fire; zero disables the cap
+	MaxFiringsPerMinute int
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	touched map[string]time.Time
+	firings map[string][]time.Time
+}
+
+// NewEngine creates a rule engine with default loop protection
+func NewEngine(rules []*Rule, clients ClientFactory, logger logging.Logger) *Engine {
+	return &Engine{
+		Rules:               rules,
+		Clients:             clients,
+		Logger:              logger,
+		LoopWindow:          DefaultLoopWindow,
+		MaxFiringsPerMinute: DefaultMaxFiringsPerMinute,
+		now:                 time.Now,
+		touched:             make(map[string]time.Time),
+		firings:             make(map[string][]time.Time),
+	}
+}
+
+// OnWebhook evaluates the rules for a processed webhook
+func (e *Engine) OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error {
+	event, err := models.DecodeWebhookEvent(request)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, firing := range e.Evaluate(ctx, event) {
+		if firing.Err != nil {
+			failed = append(failed, fmt.Sprintf("rule %s: %v", firing.Rule, firing.Err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d rule(s) failed: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Evaluate runs every matching rule for an event and returns what each of them did
+func (e *Engine) Evaluate(ctx context.Context, event *models.WebhookEvent) []Firing {
+	var env *filter.Env
+	var firings []Firing
+	for _, rule := range e.Rules {
+		if rule.Disabled || !models.MatchesEventName(rule.On, event.Name) {
+			continue
+		}
+
+		if rule.condition != nil {
+			if event.Item == nil {
+				continue
+			}
+			if env == nil {
+				env = e.filterEnv(ctx, event.UserID)
+			}
+			if !rule.condition.Match(env, event.Item) {
+				continue
+			}
+		}
+
+		if reason := e.suppressed(rule, event); reason != "" {
+			e.Logger.Warn("[rule %s] Skipping %s for user %s: %s", rule.Name, event.Name, event.UserID, reason)
+			continue
+		}
+
+		firings = append(firings, e.fire(ctx, rule, event))
+	}
+	return firings
+}
+
+// filterEnv builds the filter environment of a user from the mirror, if one is configured.
+// Relative dates are in the time zone of the account in the context.
+func (e *Engine) filterEnv(ctx context.Context, userID string) *filter.Env {
+	at := e.now()
+	if account, ok := tenant.FromContext(ctx); ok {
+		at = at.In(account.Location())
+	}
+	if e.Store == nil {
+		return filter.NewEnv(at, nil, nil)
+	}
+	snapshot, err := e.Store.Snapshot(ctx, userID)
+	if err != nil {
+		e.Logger.Warn("Could not load mirror of user %s for rule conditions: %v", userID, err)
+		return filter.NewEnv(at, nil, nil)
+	}
+	return filter.NewEnv(at, snapshot.Projects, snapshot.Sections)
+}
+
+// suppressed returns why a rule must not fire for an event, or "" if it may
+func (e *Engine) suppressed(rule *Rule, event *models.WebhookEvent) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if event.Item != nil && e.LoopWindow > 0 {
+		key := touchKey(rule.Name, event.UserID, event.Item.ID)
+		if at, ok := e.touched[key]; ok {
+			if now.Sub(at) < e.LoopWindow {
+				return fmt.Sprintf("item %s was changed by this rule %s ago", event.Item.ID, now.Sub(at).Round(time.Second))
+			}
+			delete(e.touched, key)
+		}
+	}
+
+	if e.MaxFiringsPerMinute > 0 {
+		recent := e.firings[rule.Name][:0]
+		for _, at := range e.firings[rule.Name] {
+			if now.Sub(at) < time.Minute {
+				recent = append(recent, at)
+			}
+		}
+		e.firings[rule.Name] = recent
+		if len(recent) >= e.MaxFiringsPerMinute {
+			return fmt.Sprintf("rule fired %d times in the last minute", len(recent))
+		}
+	}
+	return ""
+}
+
+// fire executes the actions of a rule in order, stopping at the first error
+func (e *Engine) fire(ctx context.Context, rule *Rule, event *models.WebhookEvent) Firing {
+	firing := Firing{Rule: rule.Name, DryRun: e.DryRun || rule.DryRun}
+
+	e.mu.Lock()
+	e.firings[rule.Name] = append(e.firings[rule.Name], e.now())
+	e.mu.Unlock()
+
+	var client TaskClient
+	if !firing.DryRun {
+		var err error
+		if client, err = e.Clients(ctx, event.UserID); err != nil {
+			firing.Err = fmt.Errorf("failed to create Todoist client: %w", err)
+			e.Logger.Error("[rule %s] %v", rule.Name, firing.Err)
+			return firing
+		}
+	}
+
+	for i := range rule.Actions {
+		description, touchedID, err := e.run(ctx, client, &rule.Actions[i], event)
+		if err != nil {
+			firing.Err = fmt.Errorf("action %d: %w", i+1, err)
+			e.Logger.Error("[rule %s] %s failed: %v", rule.Name, description, err)
+			return firing
+		}
+		firing.Actions = append(firing.Actions, description)
+
+		if firing.DryRun {
+			e.Logger.Info("[rule %s] Dry run: would %s", rule.Name, description)
+			continue
+		}
+		e.Logger.Info("[rule %s] %s", rule.Name, description)
+		if touchedID != "" {
+			e.mu.Lock()
+			e.touched[touchKey(rule.Name, event.UserID, touchedID)] = e.now()
+			e.mu.Unlock()
+		}
+	}
+	return firing
+}
+
+// run renders and, unless client is nil, executes a single action.
+// It returns a description of the action and the ID of the task it changed.
+func (e *Engine) run(ctx context.Context, client TaskClient, action *Action, event *models.WebhookEvent) (string, string, error) {
+	r := &renderer{event: event}
+	switch {
+	case action.CreateTask != nil:
+		a := action.CreateTask
+		args := &api.CreateTaskArgs{
+			Content:     r.render(a.Content),
+			Description: r.render(a.Description),
+			ProjectID:   r.render(a.ProjectID),
+			SectionID:   r.render(a.SectionID),
+			ParentID:    r.render(a.ParentID),
+			Labels:      r.renderAll(a.Labels),
+			Priority:    a.Priority,
+			DueString:   r.render(a.DueString),
+		}
+		description := fmt.Sprintf("create task %q", args.Content)
+		if r.err != nil || client == nil {
+			return description, "", r.err
+		}
+		task, err := client.CreateTask(ctx, args)
+		if err != nil {
+			return description, "", err
+		}
+		return fmt.Sprintf("created task %s %q", task.ID, task.Content), task.ID, nil
+
+	case action.UpdateTask != nil:
+		a := action.UpdateTask
+		id := r.taskID(a.TaskID)
+		args := &api.UpdateTaskArgs{}
+		var changes []string
+		if a.Content != "" {
+			content := r.render(a.Content)
+			args.Content = &content
+			changes = append(changes, fmt.Sprintf("content=%q", content))
+		}
+		if a.DueString != "" {
+			due := r.render(a.DueString)
+			args.DueString = &due
+			changes = append(changes, fmt.Sprintf("due=%q", due))
+		}
+		if a.Priority != 0 {
+			priority := a.Priority
+			args.Priority = &priority
+			changes = append(changes, fmt.Sprintf("priority=%d", priority))
+		}
+		add, remove := r.renderAll(a.AddLabels), r.renderAll(a.RemoveLabels)
+		for _, label := range add {
+			changes = append(changes, "+@"+label)
+		}
+		for _, label := range remove {
+			changes = append(changes, "-@"+label)
+		}
+		description := fmt.Sprintf("update task %s (%s)", id, strings.Join(changes, ", "))
+		if r.err != nil || client == nil {
+			return description, id, r.err
+		}
+
+		if len(add) > 0 || len(remove) > 0 {
+			labels, err := currentLabels(ctx, client, event, id)
+			if err != nil {
+				return description, id, err
+			}
+			labels = editLabels(labels, add, remove)
+			args.Labels = &labels
+		}
+		if _, err := client.UpdateTask(ctx, id, args); err != nil {
+			return description, id, err
+		}
+		return "updated task " + strings.TrimPrefix(description, "update task "), id, nil
+
+	case action.CompleteTask != nil:
+		id := r.taskID(action.CompleteTask.TaskID)
+		if r.err != nil || client == nil {
+			return "complete task " + id, id, r.err
+		}
+		return "completed task " + id, id, client.CloseTask(ctx, id)
+
+	case action.ReopenTask != nil:
+		id := r.taskID(action.ReopenTask.TaskID)
+		if r.err != nil || client == nil {
+			return "reopen task " + id, id, r.err
+		}
+		return "reopened task " + id, id, client.ReopenTask(ctx, id)
+
+	case action.AddComment != nil:
+		id := r.taskID(action.AddComment.TaskID)
+		content := r.render(action.AddComment.Content)
+		description := fmt.Sprintf("comment %q on task %s", content, id)
+		if r.err != nil || client == nil {
+			return description, id, r.err
+		}
+		_, err := client.CreateComment(ctx, &api.CreateCommentArgs{Content: content, TaskID: id})
+		return "added " + description, id, err
+	}
+	return "", "", fmt.Errorf("empty action")
+}
+
+// currentLabels returns the labels of a task, taken from the event when it is about the same task
+func currentLabels(ctx context.Context, client TaskClient, event *models.WebhookEvent, id string) ([]string, error) {
+	if event.Item != nil && event.Item.ID == id {
+		return event.Item.Labels, nil
+	}
+	task, err := client.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return task.Labels, nil
+}
+
+// editLabels adds and removes labels, keeping the original order and avoiding duplicates
+func editLabels(labels, add, remove []string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, label := range remove {
+		drop[strings.ToLower(label)] = true
+	}
+
+	result := make([]string, 0, len(labels)+len(add))
+	seen := make(map[string]bool)
+	for _, label := range append(append([]string(nil), labels...), add...) {
+		key := strings.ToLower(label)
+		if drop[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, label)
+	}
+	return result
+}
+
+// touchKey identifies an item changed by a rule
+func touchKey(rule, userID, itemID string) string {
+	return rule + "\x00" + userID + "\x00" + itemID
+}
+
+// renderer renders action templates against an event, keeping the first error
+type renderer struct {
+	event *models.WebhookEvent
+	err   error
+}
+
+// render executes a template; an empty template renders as ""
+func (r *renderer) render(text string) string {
+	if text == "" || r.err != nil {
+		return ""
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		r.err = err
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r.event); err != nil {
+		r.err = fmt.Errorf("failed to render %q: %w", text, err)
+		return ""
+	}
+	return buf.String()
+}
+
+// renderAll renders every template of a list
+func (r *renderer) renderAll(texts []string) []string {
+	if len(texts) == 0 {
+		return nil
+	}
+	rendered := make([]string, len(texts))
+	for i, text := range texts {
+		rendered[i] = r.render(text)
+	}
+	return rendered
+}
+
+// taskID renders the target task of an action, defaulting to the task of the event
+func (r *renderer) taskID(text string) string {
+	if text == "" {
+		if r.event.Item == nil {
+			if r.err == nil {
+				r.err = fmt.Errorf("task_id is required for %s events", r.event.Name)
+			}
+			return ""
+		}
+		text = defaultTaskID
+	}
+	id := r.render(text)
+	if id == "" && r.err == nil {
+		r.err = fmt.Errorf("task_id %q rendered empty", text)
+	}
+	return id
+}
@@ -0,0 +1,217 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/models"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	"cherry_backend/pkg/api/todoisttest"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+const testRules = `
+rules:
+  - name: follow-up
+    on: [item:completed]
+    when: "@waiting"
+    actions:
+      - create_task:
+          content: "Follow up on {{.Item.Content}}"
+          labels: [followup]
+      - add_comment:
+          content: "Follow-up created"
+  - name: tag-urgent
+    on: ["item:*"]
+    when: p1
+    actions:
+      - update_task:
+          add_labels: [urgent]
+`
+
+// newTestEngine starts a fake Todoist server and returns an engine acting for user "1" plus a client for that server
+func newTestEngine(t *testing.T, rulesYAML string) (*Engine, *api.TodoistRESTClient, *todoisttest.Server) {
+	server := todoisttest.NewServer("token-1")
+	t.Cleanup(server.Close)
+
+	rules, err := ParseRules([]byte(rulesYAML), "yaml")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	tokens := mirror.StaticTokenSource{"1": "token-1"}
+	engine := NewEngine(rules, TokenClientFactory(tokens, server.URL), logging.NewStdLogger())
+	return engine, api.NewTodoistRESTClient(server.URL, "token-1"), server
+}
+
+// webhook builds a webhook request for an item event
+func webhook(t *testing.T, name string, task *api.Task) *apiv1.TodoistWebhookRequest {
+	data, err := json.Marshal(task)
+	if err != nil {
+		t.Fatalf("failed to encode task: %v", err)
+	}
+	return &apiv1.TodoistWebhookRequest{EventName: name, UserId: "1", EventData: string(data), Version: "9"}
+}
+
+// TestRulesFireActions tests that matching rules execute their actions through the Todoist API
+func TestRulesFireActions(t *testing.T) {
+	engine, client, _ := newTestEngine(t, testRules)
+	ctx := context.Background()
+
+	task, _ := client.CreateTask(ctx, &api.CreateTaskArgs{Content: "Contract", Labels: []string{"waiting"}})
+	client.CloseTask(ctx, task.ID)
+	task, _ = client.GetTask(ctx, task.ID)
+
+	if err := engine.OnWebhook(ctx, webhook(t, "item:completed", task)); err != nil {
+		t.Fatalf("OnWebhook failed: %v", err)
+	}
+
+	tasks, _ := client.ListTasks(ctx, &api.ListTasksParams{Label: "followup"})
+	if len(tasks) != 1 || tasks[0].Content != "Follow up on Contract" {
+		t.Fatalf("follow-up tasks = %+v, want one rendered follow-up", tasks)
+	}
+	comments, _ := client.ListComments(ctx, task.ID, "")
+	if len(comments) != 1 || comments[0].Content != "Follow-up created" {
+		t.Errorf("comments = %+v, want the follow-up comment", comments)
+	}
+
+	// A task without the label does not match the condition
+	other, _ := client.CreateTask(ctx, &api.CreateTaskArgs{Content: "Groceries"})
+	if firings := engine.Evaluate(ctx, &models.WebhookEvent{Name: "item:completed", UserID: "1", Item: other}); len(firings) != 0 {
+		t.Errorf("Evaluate() = %+v, want no firings", firings)
+	}
+}
+
+// TestUpdateTaskLabels tests adding a label to the task of the event
+func TestUpdateTaskLabels(t *testing.T) {
+	engine, client, server := newTestEngine(t, testRules)
+
+	task, _ := client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Fix outage", Priority: 4, Labels: []string{"ops"}})
+	if err := engine.OnWebhook(context.Background(), webhook(t, "item:added", task)); err != nil {
+		t.Fatalf("OnWebhook failed: %v", err)
+	}
+
+	labels := server.Task(task.ID).Labels
+	if len(labels) != 2 || labels[0] != "ops" || labels[1] != "urgent" {
+		t.Errorf("labels = %v, want [ops urgent]", labels)
+	}
+}
+
+// TestLoopProtection tests that a rule ignores events caused by its own changes and respects its firing budget
+func TestLoopProtection(t *testing.T) {
+	engine, client, server := newTestEngine(t, testRules)
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	task, _ := client.CreateTask(ctx, &api.CreateTaskArgs{Content: "Fix outage", Priority: 4})
+	engine.OnWebhook(ctx, webhook(t, "item:added", task))
+
+	// The update above triggers an item:updated webhook for the same task
+	updated, _ := client.GetTask(ctx, task.ID)
+	requests := server.Requests()
+	if firings := engine.Evaluate(ctx, &models.WebhookEvent{Name: "item:updated", UserID: "1", Item: updated}); len(firings) != 0 {
+		t.Errorf("Evaluate() = %+v, want the rule to skip its own change", firings)
+	}
+	if got := server.Requests(); got != requests {
+		t.Errorf("engine sent %d requests to Todoist, want 0", got-requests)
+	}
+
+	// Once the loop window has passed the rule may fire again
+	now = now.Add(DefaultLoopWindow)
+	if firings := engine.Evaluate(ctx, &models.WebhookEvent{Name: "item:updated", UserID: "1", Item: updated}); len(firings) != 1 {
+		t.Errorf("Evaluate() returned %d firings after the loop window, want 1", len(firings))
+	}
+
+	// The firing budget caps a rule across items
+	engine.MaxFiringsPerMinute = 2
+	fired := 0
+	for i := 0; i < 5; i++ {
+		item := &api.Task{ID: "other-" + string(rune('a'+i)), Priority: 4}
+		fired += len(engine.Evaluate(ctx, &models.WebhookEvent{Name: "item:added", UserID: "1", Item: item}))
+	}
+	if fired != 1 {
+		t.Errorf("rule fired %d more times, want 1 within the budget of 2 per minute", fired)
+	}
+}
+
+// TestConditionTimeZone tests that rule conditions see dates in the time zone of the account
+func TestConditionTimeZone(t *testing.T) {
+	engine, _, _ := newTestEngine(t, `
+rules:
+  - name: due-today
+    on: [item:updated]
+    when: today
+    actions:
+      - update_task:
+          add_labels: [today]
+`)
+	engine.DryRun = true
+	// 06:00 UTC on March 10 is still March 9 in Los Angeles
+	engine.now = func() time.Time { return time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC) }
+	event := &models.WebhookEvent{Name: "item:updated", UserID: "1", Item: &api.Task{ID: "1", Due: &api.Due{Date: "2024-03-10"}}}
+
+	if firings := engine.Evaluate(context.Background(), event); len(firings) != 1 {
+		t.Errorf("Evaluate() without an account = %d firings, want 1 on the UTC day", len(firings))
+	}
+	ctx := tenant.NewContext(context.Background(), &tenant.Account{UserID: "1", Settings: tenant.Settings{Timezone: "America/Los_Angeles"}})
+	if firings := engine.Evaluate(ctx, event); len(firings) != 0 {
+		t.Errorf("Evaluate() in Los Angeles = %d firings, want none before the day starts there", len(firings))
+	}
+}
+
+// TestDryRun tests that dry-run rules describe their actions without calling Todoist
+func TestDryRun(t *testing.T) {
+	engine, client, server := newTestEngine(t, testRules)
+	engine.DryRun = true
+
+	task, _ := client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Contract", Labels: []string{"waiting"}})
+	requests := server.Requests()
+
+	firings := engine.Evaluate(context.Background(), &models.WebhookEvent{Name: "item:completed", UserID: "1", Item: task})
+	if len(firings) != 1 || !firings[0].DryRun {
+		t.Fatalf("Evaluate() = %+v, want one dry-run firing", firings)
+	}
+	want := []string{`create task "Follow up on Contract"`, `comment "Follow-up created" on task ` + task.ID}
+	if len(firings[0].Actions) != len(want) {
+		t.Fatalf("actions = %q, want %q", firings[0].Actions, want)
+	}
+	for i := range want {
+		if firings[0].Actions[i] != want[i] {
+			t.Errorf("action %d = %q, want %q", i, firings[0].Actions[i], want[i])
+		}
+	}
+	if got := server.Requests(); got != requests {
+		t.Errorf("dry run sent %d requests to Todoist, want 0", got-requests)
+	}
+}
+
+// TestParseRulesErrors tests that invalid rules are rejected when loading
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{"missing name", `{"rules": [{"on": ["item:added"], "actions": [{"complete_task": {}}]}]}`, "json"},
+		{"no actions", `{"rules": [{"name": "a", "on": ["item:added"]}]}`, "json"},
+		{"two actions in one", `{"rules": [{"name": "a", "on": ["item:added"], "actions": [{"complete_task": {}, "reopen_task": {}}]}]}`, "json"},
+		{"bad condition", "rules:\n  - name: a\n    on: [item:added]\n    when: \"p1 &\"\n    actions:\n      - complete_task: {}\n", "yaml"},
+		{"bad template", "rules:\n  - name: a\n    on: [item:added]\n    actions:\n      - add_comment:\n          content: \"{{.Item\"\n", "yaml"},
+		{"unknown field", "rules:\n  - name: a\n    on: [item:added]\n    actions:\n      - close_task: {}\n", "yaml"},
+		{"duplicate name", `{"rules": [{"name": "a", "on": ["*"], "actions": [{"complete_task": {}}]}, {"name": "a", "on": ["*"], "actions": [{"complete_task": {}}]}]}`, "json"},
+		{"unknown format", `rules = []`, "toml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRules([]byte(tt.data), tt.format); err == nil {
+				t.Error("ParseRules succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Package automation runs user-declared "when X happens in Todoist, do Y" rules
+// against incoming webhook events
+package automation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"cherry_backend/internal/filter"
+)
+
+// RuleSet is the top-level structure of a rules file
+type RuleSet struct {
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule fires its actions when a webhook event matches one of its event names and its condition
+type Rule struct {
+	// Name identifies the rule in logs
+	Name string `json:"name" yaml:"name"`
+
+	// On lists the event names the rule reacts to; "item:*" matches every item event
+	On []string `json:"on" yaml:"on"`
+
+	// When is an optional filter query evaluated against the item of the event, e.g. "@waiting"
+	When string `json:"when,omitempty" yaml:"when,omitempty"`
+
+	// Actions are executed in order when the rule fires
+	Actions []Action `json:"actions" yaml:"actions"`
+
+	// DryRun logs the actions of this rule instead of executing them
+	DryRun bool `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+
+	// Disabled turns the rule off without removing it
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+
+	condition filter.Expr
+}
+
+// Action is a single step of a rule; exactly one field must be set.
+// String fields are Go templates rendered against the models.WebhookEvent, e.g. "{{.Item.Content}}".
+type Action struct {
+	CreateTask   *CreateTaskAction `json:"create_task,omitempty" yaml:"create_task,omitempty"`
+	UpdateTask   *UpdateTaskAction `json:"update_task,omitempty" yaml:"update_task,omitempty"`
+	CompleteTask *TaskAction       `json:"complete_task,omitempty" yaml:"complete_task,omitempty"`
+	ReopenTask   *TaskAction       `json:"reopen_task,omitempty" yaml:"reopen_task,omitempty"`
+	AddComment   *AddCommentAction `json:"add_comment,omitempty" yaml:"add_comment,omitempty"`
+}
+
+// CreateTaskAction creates a new task
+type CreateTaskAction struct {
+	Content     string   `json:"content" yaml:"content"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	ProjectID   string   `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+	SectionID   string   `json:"section_id,omitempty" yaml:"section_id,omitempty"`
+	ParentID    string   `json:"parent_id,omitempty" yaml:"parent_id,omitempty"`
+	Labels      []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Priority    int      `json:"priority,omitempty" yaml:"priority,omitempty"`
+	DueString   string   `json:"due_string,omitempty" yaml:"due_string,omitempty"`
+}
+
+// UpdateTaskAction changes an existing task, by default the task of the event
+type UpdateTaskAction struct {
+	TaskID       string   `json:"task_id,omitempty" yaml:"task_id,omitempty"`
+	Content      string   `json:"content,omitempty" yaml:"content,omitempty"`
+	DueString    string   `json:"due_string,omitempty" yaml:"due_string,omitempty"`
+	Priority     int      `json:"priority,omitempty" yaml:"priority,omitempty"`
+	AddLabels    []string `json:"add_labels,omitempty" yaml:"add_labels,omitempty"`
+	RemoveLabels []string `json:"remove_labels,omitempty" yaml:"remove_labels,omitempty"`
+}
+
+// TaskAction completes or reopens a task, by default the task of the event
+type TaskAction struct {
+	TaskID string `json:"task_id,omitempty" yaml:"task_id,omitempty"`
+}
+
+// AddCommentAction comments on a task, by default the task of the event
+type AddCommentAction struct {
+	TaskID  string `json:"task_id,omitempty" yaml:"task_id,omitempty"`
+	Content string `json:"content" yaml:"content"`
+}
+
+// defaultTaskID targets the task of the event
+const defaultTaskID = "{{.Item.ID}}"
+
+// LoadRules reads rules from a YAML (.yaml, .yml) or JSON (.json) file
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	rules, err := ParseRules(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRules decodes and validates rules in the given format ("yaml", "yml" or "json")
+func ParseRules(data []byte, format string) ([]*Rule, error) {
+	var set RuleSet
+	switch format {
+	case "yaml", "yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&set); err != nil {
+			return nil, fmt.Errorf("failed to decode rules: %w", err)
+		}
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&set); err != nil {
+			return nil, fmt.Errorf("failed to decode rules: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rules format %q", format)
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range set.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, rule.Name, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %d: duplicate rule name %q", i+1, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return set.Rules, nil
+}
+
+// validate checks a rule and compiles its condition
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.On) == 0 {
+		return fmt.Errorf("on must list at least one event name")
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("actions must not be empty")
+	}
+
+	if r.When != "" {
+		condition, err := filter.Parse(r.When)
+		if err != nil {
+			return fmt.Errorf("when: %w", err)
+		}
+		r.condition = condition
+	}
+
+	for i, action := range r.Actions {
+		if err := action.validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validate checks that exactly one action is set and that its templates parse
+func (a *Action) validate() error {
+	var templates []string
+	set := 0
+	if a.CreateTask != nil {
+		set++
+		if a.CreateTask.Content == "" {
+			return fmt.Errorf("create_task: content is required")
+		}
+		templates = append(templates, a.CreateTask.Content, a.CreateTask.Description, a.CreateTask.ProjectID,
+			a.CreateTask.SectionID, a.CreateTask.ParentID, a.CreateTask.DueString)
+		templates = append(templates, a.CreateTask.Labels...)
+	}
+	if a.UpdateTask != nil {
+		set++
+		templates = append(templates, a.UpdateTask.TaskID, a.UpdateTask.Content, a.UpdateTask.DueString)
+		templates = append(templates, a.UpdateTask.AddLabels...)
+		templates = append(templates, a.UpdateTask.RemoveLabels...)
+	}
+	if a.CompleteTask != nil {
+		set++
+		templates = append(templates, a.CompleteTask.TaskID)
+	}
+	if a.ReopenTask != nil {
+		set++
+		templates = append(templates, a.ReopenTask.TaskID)
+	}
+	if a.AddComment != nil {
+		set++
+		if a.AddComment.Content == "" {
+			return fmt.Errorf("add_comment: content is required")
+		}
+		templates = append(templates, a.AddComment.TaskID, a.AddComment.Content)
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of create_task, update_task, complete_task, reopen_task or add_comment must be set")
+	}
+
+	for _, text := range templates {
+		if _, err := template.New("").Option("missingkey=error").Parse(text); err != nil {
+			return fmt.Errorf("invalid template %q: %w", text, err)
+		}
+	}
+	return nil
+}
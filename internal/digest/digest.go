@@ -0,0 +1,197 @@
+// Package digest builds daily and weekly summaries of a user's tasks: what was completed, what
+// is due today, what is overdue and how the completion streak stands. Completions are read from
+// the event log and open tasks from the local mirror, both fed by the Todoist webhooks. Digests
+// are rendered to Markdown, HTML and plain text with Go templates and handed to a Notifier.
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/filter"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// eventPageSize is how many events Build reads from the event log at a time
+const eventPageSize = 500
+
+var (
+	// dueToday and overdue select the open tasks a digest lists
+	dueToday, _ = filter.Parse("today & !overdue")
+	overdue, _  = filter.Parse("overdue")
+)
+
+// Digest is the summary of a user's tasks at the time it was built
+type Digest struct {
+	UserID string
+	Email  string
+
+	// Frequency is tenant.DigestDaily or tenant.DigestWeekly
+	Frequency string
+
+	// Date is the day the digest was built on in the user's time zone, and From and To bound
+	// the completions it reports: the last day or week
+	Date     time.Time
+	From, To time.Time
+
+	Completed []Task
+	DueToday  []Task
+	Overdue   []Task
+
+	// Streak is the completion streak as of Date; HasStreak is false when no statistics are kept
+	Streak    stats.Streak
+	HasStreak bool
+}
+
+// Task is a task as listed in a digest
+type Task struct {
+	ID       string
+	Content  string
+	Project  string
+	Priority int
+
+	// Due is the due date as Todoist sends it; CompletedAt is set for completed tasks, in the
+	// user's time zone
+	Due         string
+	CompletedAt time.Time
+}
+
+// Urgent reports whether the task has the highest priority, shown as p1 in Todoist
+func (t Task) Urgent() bool {
+	return t.Priority == 4
+}
+
+// Period returns how many days the completions of a digest of a frequency cover
+func Period(frequency string) int {
+	if frequency == tenant.DigestWeekly {
+		return 7
+	}
+	return 1
+}
+
+// Builder builds digests from the event log and the mirror
+type Builder struct {
+	Events events.Store
+	Mirror mirror.Store
+
+	// Stats provides the completion streak; nil leaves the streak out
+	Stats stats.Store
+}
+
+// Build builds the digest of an account at now, in the account's time zone
+func (b *Builder) Build(ctx context.Context, account *tenant.Account, frequency string, now time.Time) (*Digest, error) {
+	location := account.Location()
+	now = now.In(location)
+	d := &Digest{
+		UserID:    account.UserID,
+		Email:     account.Email,
+		Frequency: frequency,
+		Date:      now,
+		From:      now.AddDate(0, 0, -Period(frequency)),
+		To:        now,
+	}
+
+	snapshot, err := b.Mirror.Snapshot(ctx, account.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the tasks of user %s: %w", account.UserID, err)
+	}
+	projects := make(map[string]string, len(snapshot.Projects))
+	for _, project := range snapshot.Projects {
+		projects[project.ID] = project.Name
+	}
+
+	if d.Completed, err = b.completed(ctx, account.UserID, d.From, d.To, projects); err != nil {
+		return nil, err
+	}
+
+	env := filter.NewEnv(now, snapshot.Projects, snapshot.Sections)
+	for i := range snapshot.Tasks {
+		task := &snapshot.Tasks[i]
+		if task.Checked || task.IsDeleted {
+			continue
+		}
+		switch {
+		case overdue.Match(env, task):
+			d.Overdue = append(d.Overdue, newTask(task, projects))
+		case dueToday.Match(env, task):
+			d.DueToday = append(d.DueToday, newTask(task, projects))
+		}
+	}
+	sortByDue(d.Overdue)
+	sortByDue(d.DueToday)
+
+	if b.Stats != nil {
+		streak, err := b.Stats.Streak(ctx, account.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the streak of user %s: %w", account.UserID, err)
+		}
+		d.Streak = streak.On(stats.DateOf(now, location))
+		d.HasStreak = true
+	}
+	return d, nil
+}
+
+// completed returns the tasks of the item:completed events of a user completed from from to
+// to, oldest first. Events are read in ID order from the start of the log, which keeps a
+// bounded window in memory.
+func (b *Builder) completed(ctx context.Context, userID string, from, to time.Time, projects map[string]string) ([]Task, error) {
+	var completed []Task
+	var after uint64
+	for {
+		page, err := b.Events.Since(ctx, userID, after, eventPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the events of user %s: %w", userID, err)
+		}
+		for _, event := range page {
+			after = event.ID
+			if event.Name != stats.EventCompleted {
+				continue
+			}
+			var task api.Task
+			if err := json.Unmarshal(event.Data, &task); err != nil {
+				continue
+			}
+			at := event.ReceivedAt
+			if t, err := time.Parse(time.RFC3339Nano, task.CompletedAt); err == nil {
+				at = t
+			}
+			if at.Before(from) || !at.Before(to) {
+				continue
+			}
+			listed := newTask(&task, projects)
+			listed.CompletedAt = at.In(to.Location())
+			completed = append(completed, listed)
+		}
+		if len(page) < eventPageSize {
+			break
+		}
+	}
+	sort.SliceStable(completed, func(i, j int) bool { return completed[i].CompletedAt.Before(completed[j].CompletedAt) })
+	return completed, nil
+}
+
+// newTask lists a task with the name of its project
+func newTask(task *api.Task, projects map[string]string) Task {
+	listed := Task{ID: task.ID, Content: task.Content, Project: projects[task.ProjectID], Priority: task.Priority}
+	if task.Due != nil {
+		listed.Due = task.Due.Date
+	}
+	return listed
+}
+
+// sortByDue orders tasks by due date, then by priority, most urgent first
+func sortByDue(tasks []Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Due != tasks[j].Due {
+			return tasks[i].Due < tasks[j].Due
+		}
+		return tasks[i].Priority > tasks[j].Priority
+	})
+}
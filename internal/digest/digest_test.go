@@ -0,0 +1,186 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// newTestBuilder returns a builder with a mirrored snapshot and completions of user 1
+func newTestBuilder(t *testing.T) *Builder {
+	t.Helper()
+	ctx := context.Background()
+	b := &Builder{Events: events.NewMemoryStore(100), Mirror: mirror.NewMemoryStore(), Stats: stats.NewMemoryStore()}
+
+	err := b.Mirror.Apply(ctx, "1", "", &api.SyncResponse{
+		SyncToken: "t1",
+		FullSync:  true,
+		Projects:  []api.Project{{ID: "work", Name: "Work"}},
+		Items: []api.Task{
+			{ID: "1", Content: "File taxes", ProjectID: "work", Due: &api.Due{Date: "2024-03-01"}},
+			{ID: "2", Content: "Call mom", Priority: 4, Due: &api.Due{Date: "2024-03-04"}},
+			{ID: "3", Content: "Water plants", Due: &api.Due{Date: "2024-03-04"}},
+			{ID: "4", Content: "Someday", Due: &api.Due{Date: "2024-03-10"}},
+			{ID: "5", Content: "Done already", Checked: true, Due: &api.Due{Date: "2024-02-01"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to seed mirror: %v", err)
+	}
+
+	for _, task := range []api.Task{
+		{ID: "6", Content: "Write report", ProjectID: "work", CompletedAt: "2024-03-03T18:00:00Z"},
+		{ID: "7", Content: "Last week", CompletedAt: "2024-02-27T18:00:00Z"},
+		{ID: "8", Content: "Book flights", CompletedAt: "2024-03-04T07:00:00Z"},
+	} {
+		data, _ := json.Marshal(task)
+		b.Events.Append(ctx, &events.Event{Name: stats.EventCompleted, UserID: "1", Data: data})
+		b.Stats.Record(ctx, "1", stats.NewEntry(stats.EventCompleted, &task, time.Now(), time.UTC))
+	}
+	b.Events.Append(ctx, &events.Event{Name: "item:added", UserID: "1", Data: []byte(`{"id":"9"}`)})
+	return b
+}
+
+// TestBuild tests collecting completions, due and overdue tasks and the streak of a digest
+func TestBuild(t *testing.T) {
+	b := newTestBuilder(t)
+	account := &tenant.Account{UserID: "1", Email: "ada@example.com"}
+	now := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+
+	d, err := b.Build(context.Background(), account, tenant.DigestDaily, now)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if len(d.Completed) != 2 || d.Completed[0].Content != "Write report" || d.Completed[0].Project != "Work" || d.Completed[1].ID != "8" {
+		t.Errorf("completed = %+v, want the report and the flights", d.Completed)
+	}
+	if len(d.DueToday) != 2 || !d.DueToday[0].Urgent() || d.DueToday[1].ID != "3" {
+		t.Errorf("due today = %+v, want the urgent call first", d.DueToday)
+	}
+	if len(d.Overdue) != 1 || d.Overdue[0].ID != "1" || d.Overdue[0].Due != "2024-03-01" {
+		t.Errorf("overdue = %+v, want the taxes", d.Overdue)
+	}
+	if !d.HasStreak || d.Streak.Current != 2 {
+		t.Errorf("streak = %+v, want 2 days", d.Streak)
+	}
+
+	weekly, err := b.Build(context.Background(), account, tenant.DigestWeekly, now)
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if len(weekly.Completed) != 3 {
+		t.Errorf("weekly completed = %+v, want all three completions", weekly.Completed)
+	}
+}
+
+// TestRender tests rendering a digest in every format
+func TestRender(t *testing.T) {
+	d := &Digest{
+		UserID:    "1",
+		Frequency: tenant.DigestDaily,
+		Date:      time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC),
+		DueToday:  []Task{{Content: "<b>Call</b> mom", Priority: 4}},
+		Streak:    stats.Streak{Current: 2, Longest: 5},
+		HasStreak: true,
+	}
+	message, err := Render(d)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if message.Subject != "Your daily digest for Monday, March 4" {
+		t.Errorf("subject = %q", message.Subject)
+	}
+	if !strings.Contains(message.Markdown, "**<b>Call</b> mom**") || !strings.Contains(message.Markdown, "_Nothing completed in the last day._") {
+		t.Errorf("markdown = %q", message.Markdown)
+	}
+	if !strings.Contains(message.HTML, "&lt;b&gt;Call&lt;/b&gt; mom") {
+		t.Errorf("html does not escape task content: %q", message.HTML)
+	}
+	if !strings.Contains(message.Text, "You are on a 2-day streak. Your longest streak is 5 days.") {
+		t.Errorf("text = %q", message.Text)
+	}
+	if _, err := message.Body("pdf"); err == nil {
+		t.Errorf("Body(pdf) succeeded")
+	}
+}
+
+// TestSender tests when digests are due and that each is sent once a day
+func TestSender(t *testing.T) {
+	ctx := context.Background()
+	registry := tenant.NewMemoryRegistry()
+	registry.Put(ctx, &tenant.Account{UserID: "1", Settings: tenant.Settings{Notifications: tenant.Notifications{Digest: tenant.DigestDaily}}})
+	registry.Put(ctx, &tenant.Account{UserID: "2", Settings: tenant.Settings{Timezone: "America/New_York", Notifications: tenant.Notifications{Digest: tenant.DigestDaily}}})
+	registry.Put(ctx, &tenant.Account{UserID: "3", Settings: tenant.Settings{Notifications: tenant.Notifications{Digest: tenant.DigestWeekly}}})
+	registry.Put(ctx, &tenant.Account{UserID: "4", Status: tenant.StatusDisabled, Settings: tenant.Settings{Notifications: tenant.Notifications{Digest: tenant.DigestDaily}}})
+	registry.Put(ctx, &tenant.Account{UserID: "5"})
+
+	var out bytes.Buffer
+	sender := NewSender(newTestBuilder(t), registry, &WriterNotifier{W: &out, Format: FormatText}, logging.NewStdLogger())
+	// Tuesday 08:30 UTC is 03:30 in New York, and not the Monday of weekly digests
+	now := time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC)
+	sender.Now = func() time.Time { return now }
+
+	if sent, err := sender.SendDue(ctx); err != nil || sent != 1 {
+		t.Fatalf("SendDue() = %d, %v, want the digest of user 1", sent, err)
+	}
+	if !strings.HasPrefix(out.String(), "To: user 1\nSubject: Your daily digest for Tuesday, March 5\n\n") {
+		t.Errorf("output = %q", out.String())
+	}
+	if sent, _ := sender.SendDue(ctx); sent != 0 {
+		t.Errorf("SendDue() sent %d digest(s) again", sent)
+	}
+
+	// 08:30 in New York the same day
+	now = now.Add(5 * time.Hour)
+	if sent, _ := sender.SendDue(ctx); sent != 1 {
+		t.Errorf("SendDue() = %d, want the digest of user 2", sent)
+	}
+
+	// The next Monday
+	now = time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)
+	account, _ := registry.Get(ctx, "3")
+	if got := sender.Due(account, now); got != tenant.DigestWeekly {
+		t.Errorf("Due() = %q on a Monday, want weekly", got)
+	}
+
+	sender.Notifier = nil
+	if _, err := sender.Send(ctx, account, tenant.DigestWeekly); !errors.Is(err, ErrNoNotifier) {
+		t.Errorf("Send() without notifier = %v, want ErrNoNotifier", err)
+	}
+}
+
+// TestNotifiers tests parsing notifier specs and writing digests to files
+func TestNotifiers(t *testing.T) {
+	for spec, ok := range map[string]bool{"stdout": true, "stdout:html": true, "stdout:pdf": false, "file:": false, "smtp:host": false} {
+		if _, err := ParseNotifier(spec, &bytes.Buffer{}); (err == nil) != ok {
+			t.Errorf("ParseNotifier(%q) = %v, want ok %v", spec, err, ok)
+		} else if err != nil && !errors.Is(err, ErrInvalidNotifier) {
+			t.Errorf("ParseNotifier(%q) = %v, want ErrInvalidNotifier", spec, err)
+		}
+	}
+
+	dir := t.TempDir()
+	notifier, _ := ParseNotifier("file:"+dir, nil)
+	message, _ := Render(&Digest{UserID: "1", Frequency: tenant.DigestWeekly, Date: time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)})
+	if err := notifier.Notify(context.Background(), message); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+	for _, name := range []string{"2024-03-04-weekly.md", "2024-03-04-weekly.html", "2024-03-04-weekly.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, "1", name)); err != nil {
+			t.Errorf("digest file missing: %v", err)
+		}
+	}
+}
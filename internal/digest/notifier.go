@@ -0,0 +1,99 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidNotifier is returned for notifier specs that cannot be parsed
+var ErrInvalidNotifier = errors.New("invalid digest notifier")
+
+// extensions are the file extensions of the formats
+var extensions = map[string]string{FormatMarkdown: ".md", FormatHTML: ".html", FormatText: ".txt"}
+
+// Notifier delivers rendered digests to their users. Implement it to send digests by email,
+// push notification or chat.
+type Notifier interface {
+	Notify(ctx context.Context, message *Message) error
+}
+
+// WriterNotifier writes digests in one format to a writer, such as stdout, for local testing
+type WriterNotifier struct {
+	W      io.Writer
+	Format string
+
+	mu sync.Mutex
+}
+
+// Notify writes a digest with a mail-like header
+func (n *WriterNotifier) Notify(ctx context.Context, message *Message) error {
+	body, err := message.Body(n.Format)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err = fmt.Fprintf(n.W, "To: %s\nSubject: %s\n\n%s\n", recipient(message.Digest), message.Subject, body)
+	return err
+}
+
+// FileNotifier writes every digest in every format to DIR/<user_id>/<date>-<frequency>.<ext>,
+// replacing the digest of the same day
+type FileNotifier struct {
+	Dir string
+}
+
+// Notify writes the files of a digest
+func (n *FileNotifier) Notify(ctx context.Context, message *Message) error {
+	d := message.Digest
+	dir := filepath.Join(n.Dir, url.PathEscape(d.UserID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create digest directory: %w", err)
+	}
+	for _, format := range Formats {
+		body, _ := message.Body(format)
+		path := filepath.Join(dir, d.Date.Format("2006-01-02")+"-"+d.Frequency+extensions[format])
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			return fmt.Errorf("failed to write digest: %w", err)
+		}
+	}
+	return nil
+}
+
+// ParseNotifier creates the notifier a spec names: "stdout" writes plain text digests to
+// stdout, "stdout:FORMAT" digests in another format, and "file:DIR" writes digests to files
+// in DIR
+func ParseNotifier(spec string, stdout io.Writer) (Notifier, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	switch kind {
+	case "stdout":
+		if arg == "" {
+			arg = FormatText
+		}
+		if _, ok := extensions[arg]; !ok {
+			return nil, fmt.Errorf("%w %q: unknown format %q (want one of %s)", ErrInvalidNotifier, spec, arg, strings.Join(Formats, ", "))
+		}
+		return &WriterNotifier{W: stdout, Format: arg}, nil
+	case "file":
+		if arg == "" {
+			return nil, fmt.Errorf("%w %q: want file:DIR", ErrInvalidNotifier, spec)
+		}
+		return &FileNotifier{Dir: arg}, nil
+	}
+	return nil, fmt.Errorf("%w %q: want stdout, stdout:FORMAT or file:DIR", ErrInvalidNotifier, spec)
+}
+
+// recipient names the user a digest is for
+func recipient(d *Digest) string {
+	if d.Email != "" {
+		return d.Email
+	}
+	return "user " + d.UserID
+}
@@ -0,0 +1,112 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var (
+	markdownTemplate = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/digest.md.tmpl"))
+	textTemplate     = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/digest.txt.tmpl"))
+	htmlTemplate     = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/digest.html.tmpl"))
+)
+
+// Formats a digest is rendered in
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatText     = "text"
+)
+
+// Formats lists every format
+var Formats = []string{FormatMarkdown, FormatHTML, FormatText}
+
+// Message is a digest rendered in every format, ready to be delivered
+type Message struct {
+	Digest  *Digest
+	Subject string
+
+	Markdown string
+	HTML     string
+	Text     string
+}
+
+// Body returns the message in a format
+func (m *Message) Body(format string) (string, error) {
+	switch format {
+	case FormatMarkdown:
+		return m.Markdown, nil
+	case FormatHTML:
+		return m.HTML, nil
+	case FormatText:
+		return m.Text, nil
+	}
+	return "", fmt.Errorf("unknown digest format %q (want one of %s)", format, strings.Join(Formats, ", "))
+}
+
+// Render renders a digest in every format
+func Render(d *Digest) (*Message, error) {
+	message := &Message{Digest: d, Subject: d.Subject()}
+	for _, output := range []struct {
+		target   *string
+		template interface {
+			Execute(w io.Writer, data interface{}) error
+		}
+	}{
+		{&message.Markdown, markdownTemplate},
+		{&message.HTML, htmlTemplate},
+		{&message.Text, textTemplate},
+	} {
+		var buf bytes.Buffer
+		if err := output.template.Execute(&buf, d); err != nil {
+			return nil, fmt.Errorf("failed to render the digest of user %s: %w", d.UserID, err)
+		}
+		*output.target = buf.String()
+	}
+	return message, nil
+}
+
+// Subject returns the title of the digest
+func (d *Digest) Subject() string {
+	if Period(d.Frequency) > 1 {
+		return "Your weekly digest for the week to " + d.Date.Format("Monday, January 2")
+	}
+	return "Your daily digest for " + d.Date.Format("Monday, January 2")
+}
+
+// PeriodName describes the period the completions of the digest cover
+func (d *Digest) PeriodName() string {
+	if Period(d.Frequency) > 1 {
+		return "in the last 7 days"
+	}
+	return "in the last day"
+}
+
+// StreakText describes the completion streak
+func (d *Digest) StreakText() string {
+	switch {
+	case d.Streak.Current > 0 && d.Streak.Current == d.Streak.Longest:
+		return fmt.Sprintf("You are on a %d-day streak, your longest yet.", d.Streak.Current)
+	case d.Streak.Current > 0:
+		return fmt.Sprintf("You are on a %d-day streak. Your longest streak is %s.", d.Streak.Current, days(d.Streak.Longest))
+	case d.Streak.Longest > 0:
+		return fmt.Sprintf("Complete a task today to start a new streak. Your longest streak is %s.", days(d.Streak.Longest))
+	}
+	return "Complete a task today to start a streak."
+}
+
+// days formats a number of days
+func days(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
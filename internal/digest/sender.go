@@ -0,0 +1,140 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/tenant"
+)
+
+// DefaultHour is the hour of the day digests are sent at
+const DefaultHour = 8
+
+// ErrNoNotifier is returned when a digest is sent without a notifier
+var ErrNoNotifier = errors.New("no digest notifier configured")
+
+// Sender sends the digests of the accounts that asked for one in their notification settings.
+// A daily digest is due during Hour in the user's time zone, and a weekly one during Hour on
+// Weekday; each is sent once per day. Call SendDue at least hourly, e.g. from a scheduled job.
+type Sender struct {
+	Builder  *Builder
+	Accounts tenant.Registry
+
+	// Notifier delivers the digests; without one nothing is sent
+	Notifier Notifier
+	Logger   logging.Logger
+
+	Hour    int
+	Weekday time.Weekday
+
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+
+	mu   sync.Mutex
+	sent map[string]string
+}
+
+// NewSender creates a sender that sends digests at DefaultHour, weekly ones on Mondays
+func NewSender(builder *Builder, accounts tenant.Registry, notifier Notifier, logger logging.Logger) *Sender {
+	return &Sender{
+		Builder:  builder,
+		Accounts: accounts,
+		Notifier: notifier,
+		Logger:   logger,
+		Hour:     DefaultHour,
+		Weekday:  time.Monday,
+		Now:      time.Now,
+		sent:     make(map[string]string),
+	}
+}
+
+// Due returns the frequency of the digest of an account that is due at now, or "" if none is
+func (s *Sender) Due(account *tenant.Account, now time.Time) string {
+	if !account.Active() {
+		return ""
+	}
+	local := now.In(account.Location())
+	frequency := account.Settings.Notifications.Digest
+	switch {
+	case frequency != tenant.DigestDaily && frequency != tenant.DigestWeekly:
+		return ""
+	case frequency == tenant.DigestWeekly && local.Weekday() != s.Weekday:
+		return ""
+	case local.Hour() != s.Hour:
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent[account.UserID] == local.Format("2006-01-02") {
+		return ""
+	}
+	return frequency
+}
+
+// SendDue sends every digest that is due and returns how many were sent. Failing digests are
+// logged, reported in the error and retried on the next call within the hour.
+func (s *Sender) SendDue(ctx context.Context) (int, error) {
+	if s.Notifier == nil {
+		return 0, nil
+	}
+	accounts, err := s.Accounts.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	now := s.Now()
+	sent, failed := 0, 0
+	for i := range accounts {
+		account := &accounts[i]
+		frequency := s.Due(account, now)
+		if frequency == "" {
+			continue
+		}
+		if _, err := s.Send(ctx, account, frequency); err != nil {
+			s.Logger.Error("Failed to send the %s digest of user %s: %v", frequency, account.UserID, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		s.Logger.Info("Sent %d digest(s)", sent)
+	}
+	if failed > 0 {
+		return sent, fmt.Errorf("failed to send %d of %d digest(s)", failed, sent+failed)
+	}
+	return sent, nil
+}
+
+// Send builds the digest of an account now and delivers it, whether it is due or not
+func (s *Sender) Send(ctx context.Context, account *tenant.Account, frequency string) (*Message, error) {
+	if s.Notifier == nil {
+		return nil, ErrNoNotifier
+	}
+	message, err := s.Preview(ctx, account, frequency)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Notifier.Notify(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to deliver digest: %w", err)
+	}
+
+	s.mu.Lock()
+	s.sent[account.UserID] = message.Digest.Date.Format("2006-01-02")
+	s.mu.Unlock()
+	return message, nil
+}
+
+// Preview builds and renders the digest of an account now without delivering it
+func (s *Sender) Preview(ctx context.Context, account *tenant.Account, frequency string) (*Message, error) {
+	d, err := s.Builder.Build(ctx, account, frequency, s.Now())
+	if err != nil {
+		return nil, err
+	}
+	return Render(d)
+}
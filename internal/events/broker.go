@@ -0,0 +1,107 @@
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"cherry_backend/internal/models"
+)
+
+// DefaultBufferSize is how many events a subscription buffers before it is treated as a slow consumer
+const DefaultBufferSize = 64
+
+// ErrSlowConsumer is the error of a subscription that was dropped because its buffer was full.
+// The consumer can catch up by reading the store after the last event it handled.
+var ErrSlowConsumer = errors.New("subscription dropped: consumer too slow")
+
+// Broker fans out published events to in-process subscribers. Publishing never blocks: a
+// subscriber that cannot keep up is disconnected instead of delaying webhooks or other subscribers.
+type Broker struct {
+	// BufferSize is the channel capacity of new subscriptions
+	BufferSize int
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives the events of one user
+type Subscription struct {
+	// C receives matching events; it is closed when the subscription ends
+	C <-chan *Event
+
+	broker *Broker
+	ch     chan *Event
+	userID string
+	filter []string
+	err    error
+}
+
+// NewBroker creates a broker with the default buffer size
+func NewBroker() *Broker {
+	return &Broker{
+		BufferSize: DefaultBufferSize,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe starts receiving the events of a user whose names match the filter; an empty filter matches every event
+func (b *Broker) Subscribe(userID string, filter []string) *Subscription {
+	ch := make(chan *Event, b.BufferSize)
+	sub := &Subscription{C: ch, broker: b, ch: ch, userID: userID, filter: filter}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish delivers an event to every matching subscription, dropping those whose buffer is full
+func (b *Broker) Publish(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if sub.userID != event.UserID {
+			continue
+		}
+		if len(sub.filter) > 0 && !models.MatchesEventName(sub.filter, event.Name) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			b.removeLocked(sub, ErrSlowConsumer)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close ends the subscription; buffered events are discarded
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.removeLocked(s, nil)
+}
+
+// Err returns ErrSlowConsumer if the broker dropped the subscription, or nil
+func (s *Subscription) Err() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.err
+}
+
+// removeLocked ends a subscription once
+func (b *Broker) removeLocked(sub *Subscription, err error) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	sub.err = err
+	close(sub.ch)
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBrokerSlowConsumer tests that a full subscription is dropped without blocking the publisher
+func TestBrokerSlowConsumer(t *testing.T) {
+	broker := NewBroker()
+	broker.BufferSize = 2
+
+	slow := broker.Subscribe("1", nil)
+	other := broker.Subscribe("2", nil)
+	for i := 1; i <= 3; i++ {
+		broker.Publish(&Event{ID: uint64(i), Name: "item:added", UserID: "1"})
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != 2 || !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("slow subscription got %d events and error %v, want 2 and ErrSlowConsumer", received, slow.Err())
+	}
+	if broker.Subscribers() != 1 {
+		t.Errorf("Subscribers() = %d, want only the other user's subscription", broker.Subscribers())
+	}
+
+	other.Close()
+	if _, ok := <-other.C; ok || other.Err() != nil {
+		t.Error("closed subscription should end without an error")
+	}
+}
+
+// TestTailCatchesUpAfterSlowConsumer tests that a reader dropped by the broker still sees every event once
+func TestTailCatchesUpAfterSlowConsumer(t *testing.T) {
+	log := NewLog(NewMemoryStore(0))
+	log.Broker.BufferSize = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	unblock := make(chan struct{})
+	received := make(chan uint64, 100)
+	go log.Tail(ctx, "1", 0, nil, 0, func(event *Event) error {
+		if event.ID == 1 {
+			<-unblock
+		}
+		received <- event.ID
+		return nil
+	}, nil)
+
+	// The reader blocks on the first event while more are published, overflowing its buffer
+	log.Append(ctx, &Event{Name: "item:added", UserID: "1"})
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		log.Append(ctx, &Event{Name: "item:added", UserID: "1"})
+	}
+	close(unblock)
+
+	for want := uint64(1); want <= 6; want++ {
+		select {
+		case id := <-received:
+			if id != want {
+				t.Fatalf("received event %d, want %d", id, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %d", want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// DeliveryIDHeader carries the ID Todoist gives a webhook delivery, which its retries share
+const DeliveryIDHeader = "X-Todoist-Delivery-ID"
+
+// Delivery is the raw HTTP request a webhook arrived in, kept so that real traffic can be
+// recorded and replayed
+type Delivery struct {
+	// Header holds the first value of every request header except credentials and
+	// transport headers
+	Header map[string]string
+
+	// Body is the request body exactly as received, which is what its signature covers
+	Body []byte
+}
+
+// unrecordedHeaders are not kept: they carry credentials or describe a single connection
+var unrecordedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Connection":          true,
+	"Content-Length":      true,
+	"Accept-Encoding":     true,
+	"Transfer-Encoding":   true,
+	"Keep-Alive":          true,
+	"Upgrade":             true,
+}
+
+// NewDelivery copies the recordable headers and the body of a request
+func NewDelivery(header http.Header, body []byte) *Delivery {
+	delivery := &Delivery{Header: map[string]string{}, Body: append([]byte(nil), body...)}
+	for name, values := range header {
+		if len(values) == 0 || unrecordedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		delivery.Header[http.CanonicalHeaderKey(name)] = values[0]
+	}
+	return delivery
+}
+
+// ID identifies the webhook a delivery carries: the ID in DeliveryIDHeader, or a hash of the
+// body without one. Retries and replays of a webhook have the same ID; a nil delivery has none.
+func (d *Delivery) ID() string {
+	if d == nil {
+		return ""
+	}
+	if id := d.Header[http.CanonicalHeaderKey(DeliveryIDHeader)]; id != "" {
+		return id
+	}
+	sum := sha256.Sum256(d.Body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// deliveryKey is the context key of the raw delivery of a webhook
+type deliveryKey struct{}
+
+// WithDelivery attaches the raw delivery of a webhook to the context it is processed in
+func WithDelivery(ctx context.Context, delivery *Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, delivery)
+}
+
+// DeliveryFrom returns the raw delivery attached to the context, or nil
+func DeliveryFrom(ctx context.Context) *Delivery {
+	delivery, _ := ctx.Value(deliveryKey{}).(*Delivery)
+	return delivery
+}
@@ -0,0 +1,72 @@
+// Package events keeps a log of normalized Todoist webhook events that clients can tail and resume
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cherry_backend/internal/models"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// Event is a webhook normalized for clients. IDs increase monotonically across all users,
+// so the ID of the last event a client saw is a resume cursor.
+type Event struct {
+	ID         uint64          `json:"id"`
+	Name       string          `json:"event_name"`
+	UserID     string          `json:"user_id"`
+	Resource   string          `json:"resource"`
+	ResourceID string          `json:"resource_id,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Version    string          `json:"version,omitempty"`
+	ReceivedAt time.Time       `json:"received_at"`
+
+	// Delivery is the raw request the webhook arrived in, if known; it is never sent to clients
+	Delivery *Delivery `json:"-"`
+}
+
+// Store persists events in ID order
+type Store interface {
+	// Append assigns the next ID to an event and stores it
+	Append(ctx context.Context, event *Event) error
+
+	// Since returns up to limit events of a user with an ID greater than after, oldest first.
+	// An empty userID returns the events of all users.
+	Since(ctx context.Context, userID string, after uint64, limit int) ([]*Event, error)
+
+	// LastID returns the ID of the newest event, or 0 if there is none
+	LastID(ctx context.Context) (uint64, error)
+}
+
+// Normalize converts a webhook into an event without an ID
+func Normalize(request *apiv1.TodoistWebhookRequest, receivedAt time.Time) (*Event, error) {
+	decoded, err := models.DecodeWebhookEvent(request)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &Event{
+		Name:       request.EventName,
+		UserID:     request.UserId,
+		Resource:   decoded.Resource(),
+		Version:    request.Version,
+		ReceivedAt: receivedAt,
+	}
+	switch {
+	case decoded.Item != nil:
+		event.ResourceID = decoded.Item.ID
+	case decoded.Note != nil:
+		event.ResourceID = decoded.Note.ID
+	case decoded.Project != nil:
+		event.ResourceID = decoded.Project.ID
+	case decoded.Section != nil:
+		event.ResourceID = decoded.Section.ID
+	case decoded.Label != nil:
+		event.ResourceID = decoded.Label.ID
+	}
+	if json.Valid([]byte(request.EventData)) {
+		event.Data = json.RawMessage(request.EventData)
+	}
+	return event, nil
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cherry_backend/internal/models"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// replayBatchSize is how many stored events are read at once when a reader catches up
+const replayBatchSize = 100
+
+// Log appends normalized webhooks to a Store and publishes them to live subscribers
+type Log struct {
+	Store  Store
+	Broker *Broker
+
+	now func() time.Time
+
+	// mu makes events reach the broker in the order of their IDs, as readers following it
+	// skip events with an ID below the last one they sent
+	mu sync.Mutex
+}
+
+// NewLog creates an event log backed by a store
+func NewLog(store Store) *Log {
+	return &Log{
+		Store:  store,
+		Broker: NewBroker(),
+		now:    time.Now,
+	}
+}
+
+// OnWebhook normalizes and appends a processed webhook, with its raw delivery if the context
+// carries one
+func (l *Log) OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error {
+	event, err := Normalize(request, l.now())
+	if err != nil {
+		return err
+	}
+	event.Delivery = DeliveryFrom(ctx)
+	return l.Append(ctx, event)
+}
+
+// Append stores an event and publishes it to the broker
+func (l *Log) Append(ctx context.Context, event *Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.Store.Append(ctx, event); err != nil {
+		return err
+	}
+	l.Broker.Publish(event)
+	return nil
+}
+
+// Tail sends the events of a user after a cursor, then every new event as it is published,
+// until the context is cancelled or send fails. Idle is called after every interval without events,
+// e.g. to send keep-alives; a zero interval disables it.
+//
+// A reader too slow for the broker is dropped by it; Tail then catches up from the store
+// and subscribes again, so slow readers see every retained event exactly once.
+func (l *Log) Tail(ctx context.Context, userID string, after uint64, filter []string, interval time.Duration, send func(*Event) error, idle func() error) error {
+	var ticker <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		ticker = t.C
+	}
+
+	for {
+		// Subscribe before reading the store, so that nothing published in between is missed
+		sub := l.Broker.Subscribe(userID, filter)
+		err := l.replay(ctx, userID, &after, filter, send)
+		if err == nil {
+			err = l.follow(ctx, sub, &after, ticker, send, idle)
+		}
+		sub.Close()
+		if !errors.Is(err, ErrSlowConsumer) {
+			return err
+		}
+	}
+}
+
+// replay sends the stored events after the cursor, advancing it
+func (l *Log) replay(ctx context.Context, userID string, after *uint64, filter []string, send func(*Event) error) error {
+	for {
+		batch, err := l.Store.Since(ctx, userID, *after, replayBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range batch {
+			*after = event.ID
+			if len(filter) > 0 && !models.MatchesEventName(filter, event.Name) {
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+		if len(batch) < replayBatchSize {
+			return nil
+		}
+	}
+}
+
+// follow sends live events of a subscription, skipping those already replayed
+func (l *Log) follow(ctx context.Context, sub *Subscription, after *uint64, ticker <-chan time.Time, send func(*Event) error, idle func() error) error {
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return sub.Err()
+			}
+			if event.ID <= *after {
+				continue
+			}
+			*after = event.ID
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-ticker:
+			if idle != nil {
+				if err := idle(); err != nil {
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
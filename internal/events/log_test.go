@@ -0,0 +1,140 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+func webhook(name, userID, data string) *apiv1.TodoistWebhookRequest {
+	return &apiv1.TodoistWebhookRequest{EventName: name, UserId: userID, EventData: data, Version: "9"}
+}
+
+// TestNormalize tests that webhooks are normalized into events with their resource
+func TestNormalize(t *testing.T) {
+	event, err := Normalize(webhook("note:added", "1", `{"id": "3", "item_id": "7", "content": "Hi"}`), time.Now())
+	if err != nil {
+		t.Fatalf("Normalize failed: %v", err)
+	}
+	if event.Resource != "note" || event.ResourceID != "3" || string(event.Data) == "" {
+		t.Errorf("Normalize() = %+v, want note 3 with data", event)
+	}
+}
+
+// TestMemoryStoreSince tests per-user reads after a cursor and retention
+func TestMemoryStoreSince(t *testing.T) {
+	store := NewMemoryStore(3)
+	ctx := context.Background()
+	for _, userID := range []string{"1", "2", "1", "1"} {
+		store.Append(ctx, &Event{Name: "item:added", UserID: userID})
+	}
+
+	got, _ := store.Since(ctx, "1", 0, 0)
+	if len(got) != 2 || got[0].ID != 3 || got[1].ID != 4 {
+		t.Errorf("Since(0) = %v, want events 3 and 4 after evicting event 1", got)
+	}
+	got, _ = store.Since(ctx, "1", 3, 0)
+	if len(got) != 1 || got[0].ID != 4 {
+		t.Errorf("Since(3) = %v, want event 4", got)
+	}
+	if last, _ := store.LastID(ctx); last != 4 {
+		t.Errorf("LastID() = %d, want 4", last)
+	}
+}
+
+// TestTail tests that Tail replays events after the cursor, then waits for new ones
+func TestTail(t *testing.T) {
+	log := NewLog(NewMemoryStore(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log.OnWebhook(ctx, webhook("item:added", "1", `{"id": "1"}`))
+	log.OnWebhook(ctx, webhook("item:added", "2", `{"id": "2"}`))
+	log.OnWebhook(ctx, webhook("item:completed", "1", `{"id": "1"}`))
+
+	received := make(chan *Event, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- log.Tail(ctx, "1", 0, []string{"item:completed", "note:*"}, 0, func(event *Event) error {
+			received <- event
+			return nil
+		}, nil)
+	}()
+
+	if event := <-received; event.ID != 3 {
+		t.Errorf("first event = %d, want the stored item:completed event 3", event.ID)
+	}
+
+	log.OnWebhook(ctx, webhook("item:added", "1", `{"id": "4"}`))
+	log.OnWebhook(ctx, webhook("note:added", "1", `{"id": "5"}`))
+	select {
+	case event := <-received:
+		if event.Name != "note:added" {
+			t.Errorf("live event = %s, want note:added", event.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the live event")
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Tail() error = %v, want context.Canceled", err)
+	}
+}
+
+// slowStore is a MemoryStore that takes a while to return from Append, like a database would
+type slowStore struct {
+	*MemoryStore
+}
+
+func (s slowStore) Append(ctx context.Context, event *Event) error {
+	err := s.MemoryStore.Append(ctx, event)
+	time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+	return err
+}
+
+// TestTailConcurrentAppends tests that a live reader gets every event when webhooks of a user
+// are appended concurrently
+func TestTailConcurrentAppends(t *testing.T) {
+	const writers, perWriter = 8, 50
+	log := NewLog(slowStore{NewMemoryStore(0)})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan uint64, writers*perWriter)
+	go log.Tail(ctx, "1", 0, nil, 0, func(event *Event) error {
+		received <- event.ID
+		return nil
+	}, nil)
+	for log.Broker.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				log.OnWebhook(ctx, webhook("item:added", "1", `{"id": "1"}`))
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool)
+	timeout := time.After(5 * time.Second)
+	for len(seen) < writers*perWriter {
+		select {
+		case id := <-received:
+			seen[id] = true
+		case <-timeout:
+			t.Fatalf("received %d of %d events", len(seen), writers*perWriter)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DefaultRetention is how many events MemoryStore keeps
+const DefaultRetention = 10000
+
+// MemoryStore keeps the most recent events in memory
+type MemoryStore struct {
+	mu        sync.RWMutex
+	events    []*Event
+	lastID    uint64
+	retention int
+}
+
+// NewMemoryStore creates an empty in-memory event store keeping the last retention events
+func NewMemoryStore(retention int) *MemoryStore {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &MemoryStore{retention: retention}
+}
+
+// Append assigns the next ID to an event and stores it, evicting the oldest event when full
+func (s *MemoryStore) Append(ctx context.Context, event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	event.ID = s.lastID
+	s.events = append(s.events, event)
+	if len(s.events) > s.retention {
+		s.events = append(s.events[:0:0], s.events[len(s.events)-s.retention:]...)
+	}
+	return nil
+}
+
+// Since returns up to limit events of a user with an ID greater than after, oldest first.
+// An empty userID returns the events of all users.
+func (s *MemoryStore) Since(ctx context.Context, userID string, after uint64, limit int) ([]*Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := sort.Search(len(s.events), func(i int) bool { return s.events[i].ID > after })
+	var result []*Event
+	for _, event := range s.events[start:] {
+		if userID != "" && event.UserID != userID {
+			continue
+		}
+		result = append(result, event)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// LastID returns the ID of the newest event, or 0 if there is none
+func (s *MemoryStore) LastID(ctx context.Context) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastID, nil
+}
@@ -0,0 +1,417 @@
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/models"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+const (
+	// DefaultMaxAttempts is how often a delivery is tried before it is marked as failed
+	DefaultMaxAttempts = 6
+
+	// DefaultBackoff is the wait before the first retry; it doubles with every attempt
+	DefaultBackoff = time.Second
+
+	// DefaultMaxBackoff caps the wait between retries
+	DefaultMaxBackoff = 5 * time.Minute
+
+	// DefaultLogSize is how many deliveries are kept per subscriber
+	DefaultLogSize = 100
+
+	// DefaultQueueSize is how many deliveries may wait per subscriber before the oldest are dropped
+	DefaultQueueSize = 1000
+)
+
+// Dispatcher delivers webhooks to subscribers. Each subscriber has its own queue and worker,
+// so a slow or failing subscriber never delays the others, and events reach a subscriber in order.
+type Dispatcher struct {
+	Client *http.Client
+	Logger logging.Logger
+
+	// Store keeps the subscribers across restarts; without one they only live in memory
+	Store Store
+
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	LogSize     int
+	QueueSize   int
+
+	now func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu          sync.Mutex
+	subscribers map[string]*subscription
+}
+
+// subscription is the runtime state of a subscriber
+type subscription struct {
+	Subscriber
+	queue   []*Delivery
+	log     []*Delivery
+	wake    chan struct{}
+	removed bool
+}
+
+// NewDispatcher creates a dispatcher with default retry settings
+func NewDispatcher(logger logging.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Logger:      logger,
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		LogSize:     DefaultLogSize,
+		QueueSize:   DefaultQueueSize,
+		now:         time.Now,
+		ctx:         ctx,
+		cancel:      cancel,
+		subscribers: make(map[string]*subscription),
+	}
+}
+
+// Close stops all delivery workers; queued deliveries are abandoned
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Load starts delivering to the subscribers in Store that are not registered yet
+func (d *Dispatcher) Load(ctx context.Context) error {
+	if d.Store == nil {
+		return nil
+	}
+	subscribers, err := d.Store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load subscribers: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, subscriber := range subscribers {
+		if _, ok := d.subscribers[subscriber.ID]; !ok {
+			d.start(subscriber)
+		}
+	}
+	d.Logger.Info("Loaded %d webhook subscriber(s)", len(subscribers))
+	return nil
+}
+
+// Subscribe registers a subscriber and returns it, including its secret
+func (d *Dispatcher) Subscribe(subscriber Subscriber) (*Subscriber, error) {
+	if err := subscriber.validate(); err != nil {
+		return nil, err
+	}
+	subscriber.ID = newID(8)
+	subscriber.CreatedAt = d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.Store != nil {
+		if err := d.Store.Put(d.ctx, &subscriber); err != nil {
+			return nil, err
+		}
+	}
+	d.start(subscriber)
+
+	d.Logger.Info("Registered webhook subscriber %s for %s", subscriber.ID, subscriber.URL)
+	return &subscriber, nil
+}
+
+// start adds the runtime state of a subscriber and its worker; d.mu must be held
+func (d *Dispatcher) start(subscriber Subscriber) {
+	sub := &subscription{Subscriber: subscriber, wake: make(chan struct{}, 1)}
+	d.subscribers[sub.ID] = sub
+	d.wg.Add(1)
+	go d.work(sub)
+}
+
+// Unsubscribe removes a subscriber and discards its queued deliveries
+func (d *Dispatcher) Unsubscribe(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sub, ok := d.subscribers[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if d.Store != nil {
+		if err := d.Store.Delete(d.ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	sub.removed = true
+	delete(d.subscribers, id)
+	notify(sub)
+	d.Logger.Info("Removed webhook subscriber %s", id)
+	return nil
+}
+
+// Subscriber returns a subscriber without its secret
+func (d *Dispatcher) Subscriber(id string) (*Subscriber, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sub, ok := d.subscribers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return sub.public(), nil
+}
+
+// Subscribers returns every subscriber without its secret, oldest first
+func (d *Dispatcher) Subscribers() []*Subscriber {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subscribers := make([]*Subscriber, 0, len(d.subscribers))
+	for _, sub := range d.subscribers {
+		subscribers = append(subscribers, sub.public())
+	}
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].CreatedAt.Before(subscribers[j].CreatedAt)
+	})
+	return subscribers
+}
+
+// Pause stops deliveries to a subscriber; new events are queued until it is resumed
+func (d *Dispatcher) Pause(id string) error {
+	return d.setPaused(id, true)
+}
+
+// Resume restarts deliveries to a paused subscriber, starting with the queued events
+func (d *Dispatcher) Resume(id string) error {
+	return d.setPaused(id, false)
+}
+
+func (d *Dispatcher) setPaused(id string, paused bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sub, ok := d.subscribers[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if d.Store != nil {
+		updated := sub.Subscriber
+		updated.Paused = paused
+		if err := d.Store.Put(d.ctx, &updated); err != nil {
+			return err
+		}
+	}
+	sub.Paused = paused
+	notify(sub)
+	d.Logger.Info("Webhook subscriber %s paused: %v", id, paused)
+	return nil
+}
+
+// Deliveries returns the delivery log of a subscriber, newest first
+func (d *Dispatcher) Deliveries(id string) ([]Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sub, ok := d.subscribers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	deliveries := make([]Delivery, len(sub.log))
+	for i, delivery := range sub.log {
+		deliveries[len(sub.log)-1-i] = *delivery
+	}
+	return deliveries, nil
+}
+
+// OnWebhook queues a processed webhook for every subscriber interested in its event
+func (d *Dispatcher) OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error {
+	body, err := encodeWebhook(request)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, sub := range d.subscribers {
+		if len(sub.Events) > 0 && !models.MatchesEventName(sub.Events, request.EventName) {
+			continue
+		}
+
+		delivery := &Delivery{
+			ID:           newID(8),
+			SubscriberID: sub.ID,
+			EventName:    request.EventName,
+			UserID:       request.UserId,
+			Status:       DeliveryPending,
+			CreatedAt:    d.now(),
+			body:         body,
+		}
+		if len(sub.queue) >= d.QueueSize {
+			dropped := sub.queue[0]
+			sub.queue = sub.queue[1:]
+			dropped.Status = DeliveryDropped
+			dropped.LastError = "queue full"
+			d.Logger.Warn("Webhook subscriber %s queue full, dropped delivery %s", sub.ID, dropped.ID)
+		}
+		sub.queue = append(sub.queue, delivery)
+		sub.log = append(sub.log, delivery)
+		if len(sub.log) > d.LogSize {
+			sub.log = sub.log[len(sub.log)-d.LogSize:]
+		}
+		notify(sub)
+	}
+	return nil
+}
+
+// encodeWebhook renders a webhook in the shape Todoist sends it, with event_data as a JSON object
+func encodeWebhook(request *apiv1.TodoistWebhookRequest) ([]byte, error) {
+	var eventData interface{} = request.EventData
+	if json.Valid([]byte(request.EventData)) {
+		eventData = json.RawMessage(request.EventData)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"event_name": request.EventName,
+		"user_id":    request.UserId,
+		"event_data": eventData,
+		"version":    request.Version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook for subscribers: %w", err)
+	}
+	return body, nil
+}
+
+// public returns a copy of the subscriber without its secret
+func (s *subscription) public() *Subscriber {
+	subscriber := s.Subscriber
+	subscriber.Secret = ""
+	return &subscriber
+}
+
+// notify wakes the worker of a subscription without blocking
+func notify(sub *subscription) {
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work delivers the queue of a subscriber in order until it is removed or the dispatcher is closed
+func (d *Dispatcher) work(sub *subscription) {
+	defer d.wg.Done()
+
+	for {
+		d.mu.Lock()
+		for !sub.removed && (sub.Paused || len(sub.queue) == 0) {
+			d.mu.Unlock()
+			select {
+			case <-sub.wake:
+			case <-d.ctx.Done():
+				return
+			}
+			d.mu.Lock()
+		}
+		if sub.removed {
+			d.mu.Unlock()
+			return
+		}
+		delivery := sub.queue[0]
+		sub.queue = sub.queue[1:]
+		d.mu.Unlock()
+
+		d.deliver(sub, delivery)
+	}
+}
+
+// deliver posts a delivery, retrying with exponential backoff until it succeeds or attempts run out
+func (d *Dispatcher) deliver(sub *subscription, delivery *Delivery) {
+	backoff := d.Backoff
+	for {
+		statusCode, err := d.post(sub, delivery)
+
+		d.mu.Lock()
+		delivery.Attempts++
+		delivery.StatusCode = statusCode
+		if err == nil {
+			now := d.now()
+			delivery.Status = DeliverySucceeded
+			delivery.LastError = ""
+			delivery.CompletedAt = &now
+			d.mu.Unlock()
+			return
+		}
+		delivery.LastError = err.Error()
+		retry := retryable(statusCode) && delivery.Attempts < d.MaxAttempts && !sub.removed
+		if !retry {
+			now := d.now()
+			delivery.Status = DeliveryFailed
+			delivery.CompletedAt = &now
+		}
+		attempts := delivery.Attempts
+		d.mu.Unlock()
+
+		if !retry {
+			d.Logger.Error("Delivery %s of %s to subscriber %s failed after %d attempt(s): %v",
+				delivery.ID, delivery.EventName, sub.ID, attempts, err)
+			return
+		}
+		d.Logger.Warn("Delivery %s to subscriber %s failed, retrying in %v: %v", delivery.ID, sub.ID, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+}
+
+// post sends a delivery once and returns the response status code
+func (d *Dispatcher) post(sub *subscription, delivery *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cherry-backend-fanout")
+	req.Header.Set(SignatureHeader, Sign(delivery.body, sub.Secret))
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(EventHeader, delivery.EventName)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether a failed delivery may succeed when retried.
+// Transport errors (status 0), timeouts, rate limits and server errors are retried; other client errors are not.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
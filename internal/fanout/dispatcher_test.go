@@ -0,0 +1,261 @@
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/logging"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// receiver is a subscriber endpoint that records deliveries and can be told to fail
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failNext int
+	bodies   [][]byte
+	headers  []http.Header
+	received chan struct{}
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{received: make(chan struct{}, 100)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.failNext > 0 {
+			r.failNext--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.bodies = append(r.bodies, body)
+		r.headers = append(r.headers, req.Header.Clone())
+		r.received <- struct{}{}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// wait blocks until n deliveries have been received
+func (r *receiver) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for delivery %d", i+1)
+		}
+	}
+}
+
+func newTestDispatcher(t *testing.T) *Dispatcher {
+	d := NewDispatcher(logging.NewStdLogger())
+	d.Backoff = time.Millisecond
+	t.Cleanup(d.Close)
+	return d
+}
+
+func webhook(name string) *apiv1.TodoistWebhookRequest {
+	return &apiv1.TodoistWebhookRequest{EventName: name, UserId: "1", EventData: `{"id":"7","content":"Buy milk"}`, Version: "9"}
+}
+
+// TestSignedDelivery tests that deliveries carry the Todoist payload signed with the subscriber secret
+func TestSignedDelivery(t *testing.T) {
+	d := newTestDispatcher(t)
+	r := newReceiver(t)
+
+	subscriber, err := d.Subscribe(Subscriber{URL: r.URL, Events: []string{"item:*"}})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if subscriber.Secret == "" {
+		t.Fatal("Subscribe did not generate a secret")
+	}
+
+	d.OnWebhook(context.Background(), webhook("project:added"))
+	d.OnWebhook(context.Background(), webhook("item:added"))
+	r.wait(t, 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.bodies) != 1 {
+		t.Fatalf("received %d deliveries, want only the item event", len(r.bodies))
+	}
+	if !Verify(r.bodies[0], r.headers[0].Get(SignatureHeader), subscriber.Secret) {
+		t.Error("delivery signature does not verify with the subscriber secret")
+	}
+	if got := r.headers[0].Get(EventHeader); got != "item:added" {
+		t.Errorf("%s = %s, want item:added", EventHeader, got)
+	}
+
+	var payload struct {
+		EventName string `json:"event_name"`
+		EventData struct {
+			Content string `json:"content"`
+		} `json:"event_data"`
+	}
+	if err := json.Unmarshal(r.bodies[0], &payload); err != nil {
+		t.Fatalf("failed to decode delivery: %v", err)
+	}
+	if payload.EventName != "item:added" || payload.EventData.Content != "Buy milk" {
+		t.Errorf("payload = %+v, want the original event with event_data as an object", payload)
+	}
+}
+
+// TestDeliveryRetry tests that failed deliveries are retried and logged
+func TestDeliveryRetry(t *testing.T) {
+	d := newTestDispatcher(t)
+	r := newReceiver(t)
+	r.failNext = 2
+
+	subscriber, _ := d.Subscribe(Subscriber{URL: r.URL})
+	d.OnWebhook(context.Background(), webhook("item:added"))
+	r.wait(t, 1)
+
+	deliveries := waitForStatus(t, d, subscriber.ID, DeliverySucceeded)
+	if deliveries[0].Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", deliveries[0].Attempts)
+	}
+
+	// A subscriber that keeps failing gives up after MaxAttempts
+	d.MaxAttempts = 2
+	r.mu.Lock()
+	r.failNext = 10
+	r.mu.Unlock()
+	d.OnWebhook(context.Background(), webhook("item:updated"))
+
+	deliveries = waitForStatus(t, d, subscriber.ID, DeliveryFailed)
+	if deliveries[0].EventName != "item:updated" || deliveries[0].Attempts != 2 || deliveries[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("delivery = %+v, want item:updated failed after 2 attempts with 503", deliveries[0])
+	}
+}
+
+// TestPauseResume tests that a paused subscriber queues deliveries and receives them in order once resumed
+func TestPauseResume(t *testing.T) {
+	d := newTestDispatcher(t)
+	r := newReceiver(t)
+
+	subscriber, _ := d.Subscribe(Subscriber{URL: r.URL})
+	if err := d.Pause(subscriber.ID); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	d.OnWebhook(context.Background(), webhook("item:added"))
+	d.OnWebhook(context.Background(), webhook("item:completed"))
+
+	time.Sleep(20 * time.Millisecond)
+	deliveries, _ := d.Deliveries(subscriber.ID)
+	if len(deliveries) != 2 || deliveries[0].Status != DeliveryPending || deliveries[1].Status != DeliveryPending {
+		t.Fatalf("deliveries while paused = %+v, want two pending", deliveries)
+	}
+
+	if err := d.Resume(subscriber.ID); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	r.wait(t, 2)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, want := range []string{"item:added", "item:completed"} {
+		if got := r.headers[i].Get(EventHeader); got != want {
+			t.Errorf("delivery %d event = %s, want %s", i, got, want)
+		}
+	}
+
+	if err := d.Pause("missing"); err != ErrNotFound {
+		t.Errorf("Pause(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+// TestSubscribeValidation tests that subscribers need an absolute http(s) URL
+func TestSubscribeValidation(t *testing.T) {
+	d := newTestDispatcher(t)
+	for _, url := range []string{"", "/relative", "ftp://example.com/hook"} {
+		if _, err := d.Subscribe(Subscriber{URL: url}); err == nil {
+			t.Errorf("Subscribe(%q) succeeded, want an error", url)
+		}
+	}
+}
+
+// memoryStore is a Store keeping subscribers in a map
+type memoryStore struct {
+	mu          sync.Mutex
+	subscribers map[string]Subscriber
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]Subscriber, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subscribers := []Subscriber{}
+	for _, subscriber := range s.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers, nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, subscriber *Subscriber) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers[subscriber.ID] = *subscriber
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subscribers, id)
+	return nil
+}
+
+// TestStore tests that subscribers are kept in the store and loaded by a new dispatcher
+func TestStore(t *testing.T) {
+	store := &memoryStore{subscribers: make(map[string]Subscriber)}
+	first := newTestDispatcher(t)
+	first.Store = store
+	kept, _ := first.Subscribe(Subscriber{URL: "https://kept.example.com/hook", Secret: "kept"})
+	removed, _ := first.Subscribe(Subscriber{URL: "https://removed.example.com/hook"})
+	first.Pause(kept.ID)
+	first.Unsubscribe(removed.ID)
+
+	second := newTestDispatcher(t)
+	second.Store = store
+	if err := second.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	subscribers := second.Subscribers()
+	if len(subscribers) != 1 || subscribers[0].ID != kept.ID || !subscribers[0].Paused {
+		t.Fatalf("loaded subscribers = %+v, want the paused subscriber %s", subscribers, kept.ID)
+	}
+	if stored := store.subscribers[kept.ID]; stored.Secret != "kept" {
+		t.Errorf("stored secret = %q, want kept", stored.Secret)
+	}
+}
+
+// waitForStatus polls the delivery log until the newest delivery has the given status
+func waitForStatus(t *testing.T, d *Dispatcher, id string, status DeliveryStatus) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := d.Deliveries(id)
+		if err != nil {
+			t.Fatalf("Deliveries failed: %v", err)
+		}
+		if len(deliveries) > 0 && deliveries[0].Status == status {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for status %s, deliveries = %+v", status, deliveries)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,123 @@
+// Package fanout re-delivers accepted Todoist webhooks to downstream subscribers
+package fanout
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the hex-encoded HMAC-SHA256 of the delivered body, keyed with the subscriber secret
+	SignatureHeader = "X-Cherry-Hmac-SHA256"
+
+	// DeliveryHeader carries the ID of the delivery, which stays the same across retries
+	DeliveryHeader = "X-Cherry-Delivery"
+
+	// EventHeader carries the Todoist event name of the delivery
+	EventHeader = "X-Cherry-Event"
+)
+
+var (
+	// ErrNotFound is returned for unknown subscriber IDs
+	ErrNotFound = errors.New("subscriber not found")
+
+	// ErrInvalidSubscriber is returned when a subscriber registration is invalid
+	ErrInvalidSubscriber = errors.New("invalid subscriber")
+)
+
+// Subscriber is a downstream service receiving re-signed Todoist webhooks
+type Subscriber struct {
+	ID string `json:"id"`
+
+	// URL receives a POST for every matching event
+	URL string `json:"url"`
+
+	// Events are the event names to deliver, e.g. "item:completed" or "item:*"; empty means every event
+	Events []string `json:"events,omitempty"`
+
+	// Secret signs every delivery; it is only returned when the subscriber is created
+	Secret string `json:"secret,omitempty"`
+
+	// Paused subscribers queue their deliveries until resumed
+	Paused bool `json:"paused"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps subscribers, including their secrets, across restarts
+type Store interface {
+	// List returns every stored subscriber
+	List(ctx context.Context) ([]Subscriber, error)
+
+	// Put creates a subscriber or replaces its URL, events, secret and paused state
+	Put(ctx context.Context, subscriber *Subscriber) error
+
+	// Delete removes a subscriber, or returns ErrNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+// DeliveryStatus is the state of a delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+	DeliveryDropped   DeliveryStatus = "dropped"
+)
+
+// Delivery is one event sent, or to be sent, to a subscriber
+type Delivery struct {
+	ID           string         `json:"id"`
+	SubscriberID string         `json:"subscriber_id"`
+	EventName    string         `json:"event_name"`
+	UserID       string         `json:"user_id"`
+	Status       DeliveryStatus `json:"status"`
+	Attempts     int            `json:"attempts"`
+	StatusCode   int            `json:"status_code,omitempty"`
+	LastError    string         `json:"last_error,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	CompletedAt  *time.Time     `json:"completed_at,omitempty"`
+
+	body []byte
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of a body, as sent in SignatureHeader
+func Sign(body []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify reports whether a signature matches a body, for use by subscribers
+func Verify(body []byte, signature, secret string) bool {
+	return hmac.Equal([]byte(Sign(body, secret)), []byte(signature))
+}
+
+// validate checks a new subscriber, generating a secret if none was given
+func (s *Subscriber) validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscriber)
+	}
+	if s.Secret == "" {
+		s.Secret = newID(32)
+	}
+	return nil
+}
+
+// newID returns a random hex string of n bytes
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,321 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cherry_backend/pkg/api"
+)
+
+// Expr is a parsed filter expression
+type Expr interface {
+	// Match reports whether a task satisfies the expression
+	Match(env *Env, task *api.Task) bool
+}
+
+// Env holds the context a filter is evaluated in: the current time and the
+// projects and sections that names in the filter refer to
+type Env struct {
+	Now      time.Time
+	Location *time.Location
+
+	projects map[string]api.Project
+	sections map[string]api.Section
+}
+
+// NewEnv creates an evaluation environment. Relative dates such as "today" are
+// computed from now in now's location.
+func NewEnv(now time.Time, projects []api.Project, sections []api.Section) *Env {
+	env := &Env{
+		Now:      now,
+		Location: now.Location(),
+		projects: make(map[string]api.Project, len(projects)),
+		sections: make(map[string]api.Section, len(sections)),
+	}
+	for _, project := range projects {
+		env.projects[project.ID] = project
+	}
+	for _, section := range sections {
+		env.sections[section.ID] = section
+	}
+	return env
+}
+
+// today returns the current civil date
+func (e *Env) today() time.Time {
+	return civil(e.Now.In(e.Location))
+}
+
+// civil truncates a time to its calendar date, represented as midnight UTC
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dueDate returns the civil due date of a task and, for tasks due at a specific time, that instant
+func (e *Env) dueDate(task *api.Task) (date time.Time, instant *time.Time, ok bool) {
+	if task.Due == nil || task.Due.Date == "" {
+		return time.Time{}, nil, false
+	}
+	value := task.Due.Date
+
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil, true
+	}
+	// Floating date-times are in the user's local time
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", value, e.Location); err == nil {
+		return civil(t), &t, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		local := t.In(e.Location)
+		return civil(local), &local, true
+	}
+	return time.Time{}, nil, false
+}
+
+type andExpr struct{ left, right Expr }
+
+func (x *andExpr) Match(env *Env, task *api.Task) bool {
+	return x.left.Match(env, task) && x.right.Match(env, task)
+}
+
+type orExpr struct{ left, right Expr }
+
+func (x *orExpr) Match(env *Env, task *api.Task) bool {
+	return x.left.Match(env, task) || x.right.Match(env, task)
+}
+
+type notExpr struct{ operand Expr }
+
+func (x *notExpr) Match(env *Env, task *api.Task) bool {
+	return !x.operand.Match(env, task)
+}
+
+// termFunc adapts a function to the Expr interface
+type termFunc func(env *Env, task *api.Task) bool
+
+func (f termFunc) Match(env *Env, task *api.Task) bool {
+	return f(env, task)
+}
+
+var (
+	daysPattern  = regexp.MustCompile(`^(?:next )?(\d+) days?$`)
+	spacePattern = regexp.MustCompile(`\s+`)
+)
+
+// parseTerm turns the text of a single term into an expression
+func parseTerm(text string) (Expr, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty filter term")
+	}
+	lower := strings.ToLower(spacePattern.ReplaceAllString(text, " "))
+
+	switch {
+	case strings.HasPrefix(text, "##"):
+		return projectTree(strings.TrimSpace(text[2:])), nil
+	case strings.HasPrefix(text, "#"):
+		return project(strings.TrimSpace(text[1:])), nil
+	case strings.HasPrefix(text, "/"):
+		return section(strings.TrimSpace(text[1:])), nil
+	case strings.HasPrefix(text, "@"):
+		return label(strings.TrimSpace(text[1:])), nil
+	case strings.HasPrefix(lower, "search:"):
+		return search(strings.TrimSpace(text[len("search:"):])), nil
+	}
+
+	for _, prefix := range []string{"date before:", "due before:", "date after:", "due after:", "date:", "due:"} {
+		if !strings.HasPrefix(lower, prefix) {
+			continue
+		}
+		date, err := parseDateArg(strings.TrimSpace(lower[len(prefix):]))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.Contains(prefix, "before"):
+			return dueBetween(date, func(d, arg time.Time) bool { return d.Before(arg) }), nil
+		case strings.Contains(prefix, "after"):
+			return dueBetween(date, func(d, arg time.Time) bool { return d.After(arg) }), nil
+		default:
+			return dueBetween(date, func(d, arg time.Time) bool { return d.Equal(arg) }), nil
+		}
+	}
+
+	if m := daysPattern.FindStringSubmatch(lower); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid number of days in %q", text)
+		}
+		return nextDays(n), nil
+	}
+
+	switch lower {
+	case "today", "tomorrow", "yesterday":
+		date, _ := parseDateArg(lower)
+		return dueBetween(date, func(d, arg time.Time) bool { return d.Equal(arg) }), nil
+	case "overdue", "od":
+		return termFunc(overdue), nil
+	case "no date", "no due date":
+		return termFunc(func(env *Env, task *api.Task) bool {
+			_, _, ok := env.dueDate(task)
+			return !ok
+		}), nil
+	case "recurring":
+		return termFunc(func(env *Env, task *api.Task) bool {
+			return task.Due != nil && task.Due.IsRecurring
+		}), nil
+	case "p1", "p2", "p3", "p4":
+		// Todoist shows the API priority 4 as p1
+		return priority(5 - int(lower[1]-'0')), nil
+	case "no priority":
+		return priority(1), nil
+	case "no labels":
+		return termFunc(func(env *Env, task *api.Task) bool {
+			return len(task.Labels) == 0
+		}), nil
+	case "subtask":
+		return termFunc(func(env *Env, task *api.Task) bool {
+			return task.ParentID != ""
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unsupported filter term %q", text)
+}
+
+// dateArg is a date argument that may be relative to the evaluation time
+type dateArg func(env *Env) time.Time
+
+// parseDateArg parses a date argument of a date term
+func parseDateArg(value string) (dateArg, error) {
+	switch value {
+	case "today":
+		return func(env *Env) time.Time { return env.today() }, nil
+	case "tomorrow":
+		return func(env *Env) time.Time { return env.today().AddDate(0, 0, 1) }, nil
+	case "yesterday":
+		return func(env *Env) time.Time { return env.today().AddDate(0, 0, -1) }, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: use YYYY-MM-DD, today, tomorrow or yesterday", value)
+	}
+	return func(env *Env) time.Time { return t }, nil
+}
+
+// dueBetween matches tasks whose civil due date compares true against the argument date
+func dueBetween(arg dateArg, compare func(due, arg time.Time) bool) Expr {
+	return termFunc(func(env *Env, task *api.Task) bool {
+		date, _, ok := env.dueDate(task)
+		return ok && compare(date, arg(env))
+	})
+}
+
+// overdue matches tasks due before now: date-only tasks from yesterday back, timed tasks once their time has passed
+func overdue(env *Env, task *api.Task) bool {
+	date, instant, ok := env.dueDate(task)
+	if !ok {
+		return false
+	}
+	if instant != nil {
+		return instant.Before(env.Now)
+	}
+	return date.Before(env.today())
+}
+
+// nextDays matches tasks due from today up to n-1 days ahead
+func nextDays(n int) Expr {
+	return termFunc(func(env *Env, task *api.Task) bool {
+		date, _, ok := env.dueDate(task)
+		today := env.today()
+		return ok && !date.Before(today) && date.Before(today.AddDate(0, 0, n))
+	})
+}
+
+// priority matches tasks with the given API priority
+func priority(p int) Expr {
+	return termFunc(func(env *Env, task *api.Task) bool {
+		return task.Priority == p
+	})
+}
+
+// project matches tasks directly in a project with a matching name
+func project(pattern string) Expr {
+	return termFunc(func(env *Env, task *api.Task) bool {
+		p, ok := env.projects[task.ProjectID]
+		return ok && wildcardMatch(pattern, p.Name)
+	})
+}
+
+// projectTree matches tasks in a project with a matching name or in any of its subprojects
+func projectTree(pattern string) Expr {
+	return termFunc(func(env *Env, task *api.Task) bool {
+		id := task.ProjectID
+		// Walk up the project tree; the bound guards against cycles in corrupt data
+		for depth := 0; id != "" && depth < len(env.projects)+1; depth++ {
+			p, ok := env.projects[id]
+			if !ok {
+				return false
+			}
+			if wildcardMatch(pattern, p.Name) {
+				return true
+			}
+			id = p.ParentID
+		}
+		return false
+	})
+}
+
+// section matches tasks in a section with a matching name
+func section(pattern string) Expr {
+	return termFunc(func(env *Env, task *api.Task) bool {
+		s, ok := env.sections[task.SectionID]
+		return ok && wildcardMatch(pattern, s.Name)
+	})
+}
+
+// label matches tasks carrying a label with a matching name
+func label(pattern string) Expr {
+	return termFunc(func(env *Env, task *api.Task) bool {
+		for _, l := range task.Labels {
+			if wildcardMatch(pattern, l) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// search matches tasks whose content or description contains the text
+func search(text string) Expr {
+	needle := strings.ToLower(text)
+	return termFunc(func(env *Env, task *api.Task) bool {
+		return strings.Contains(strings.ToLower(task.Content), needle) ||
+			strings.Contains(strings.ToLower(task.Description), needle)
+	})
+}
+
+// wildcardMatch matches a name against a case-insensitive pattern where * matches any run of characters
+func wildcardMatch(pattern, name string) bool {
+	pattern = strings.ToLower(pattern)
+	name = strings.ToLower(name)
+
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, parts[len(parts)-1])
+}
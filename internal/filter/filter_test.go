@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cherry_backend/pkg/api"
+)
+
+// testEnv returns an environment at 2024-03-10 15:00 UTC with a small project tree
+func testEnv() *Env {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	projects := []api.Project{
+		{ID: "work", Name: "Work"},
+		{ID: "meetings", Name: "Meetings", ParentID: "work"},
+		{ID: "home", Name: "Home Stuff"},
+	}
+	sections := []api.Section{
+		{ID: "backlog", ProjectID: "work", Name: "Backlog"},
+	}
+	return NewEnv(now, projects, sections)
+}
+
+// testTasks are the tasks every test case is evaluated against
+var testTasks = []api.Task{
+	{ID: "today", ProjectID: "work", Content: "Ship release", Priority: 4, Labels: []string{"urgent"}, Due: &api.Due{Date: "2024-03-10"}},
+	{ID: "overdue", ProjectID: "home", Content: "Pay rent", Priority: 3, Due: &api.Due{Date: "2024-03-08", IsRecurring: true}},
+	{ID: "timed-past", ProjectID: "meetings", Content: "Standup", Priority: 1, Due: &api.Due{Date: "2024-03-10T09:00:00Z"}},
+	{ID: "tomorrow", ProjectID: "work", SectionID: "backlog", Content: "Plan sprint", Description: "Use the roadmap", Priority: 2, Labels: []string{"urgent-ish"}, Due: &api.Due{Date: "2024-03-11"}},
+	{ID: "next-week", ProjectID: "meetings", Content: "Retro", Priority: 1, Due: &api.Due{Date: "2024-03-17"}},
+	{ID: "no-date", ProjectID: "home", ParentID: "overdue", Content: "Find receipt", Priority: 1},
+}
+
+// TestMatch tests the supported terms and operators
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		query string
+		want  []string
+	}{
+		{"today", []string{"today", "timed-past"}},
+		{"tomorrow", []string{"tomorrow"}},
+		{"yesterday", []string{}},
+		{"overdue", []string{"overdue", "timed-past"}},
+		{"od & !recurring", []string{"timed-past"}},
+		{"no date", []string{"no-date"}},
+		{"overdue | no date", []string{"overdue", "timed-past", "no-date"}},
+		{"today & p1", []string{"today"}},
+		{"p2 | p3", []string{"overdue", "tomorrow"}},
+		{"no priority", []string{"timed-past", "next-week", "no-date"}},
+		{"#Work", []string{"today", "tomorrow"}},
+		{"##Work", []string{"today", "timed-past", "tomorrow", "next-week"}},
+		{"#home*", []string{"overdue", "no-date"}},
+		{"#Home Stuff & subtask", []string{"no-date"}},
+		{"/Backlog", []string{"tomorrow"}},
+		{"@urgent", []string{"today"}},
+		{"@urgent*", []string{"today", "tomorrow"}},
+		{"#Work & @urgent", []string{"today"}},
+		{"no labels & !no date", []string{"overdue", "timed-past", "next-week"}},
+		{"7 days", []string{"today", "timed-past", "tomorrow"}},
+		{"next 8 days", []string{"today", "timed-past", "tomorrow", "next-week"}},
+		{"due before: 2024-03-10", []string{"overdue"}},
+		{"date after: tomorrow", []string{"next-week"}},
+		{"date: 2024-03-17", []string{"next-week"}},
+		{"search: roadmap", []string{"tomorrow"}},
+		{"!(today | overdue) & ##work", []string{"tomorrow", "next-week"}},
+		{"(p1 | p3) & (#Work | #Meetings)", []string{"today", "tomorrow"}},
+	}
+
+	env := testEnv()
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := Parse(tc.query)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned an error: %v", tc.query, err)
+			}
+
+			got := []string{}
+			for i := range testTasks {
+				if expr.Match(env, &testTasks[i]) {
+					got = append(got, testTasks[i].ID)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("Match(%q) = %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestParseErrors tests that malformed and unsupported queries are rejected
+func TestParseErrors(t *testing.T) {
+	queries := []string{
+		"",
+		"today &",
+		"(today",
+		"today)",
+		"today, overdue",
+		"p5",
+		"assigned to: me",
+		"due before: next friday",
+		"& today",
+	}
+	for _, query := range queries {
+		if _, err := Parse(query); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", query)
+		}
+	}
+}
+
+// TestEscapedOperator tests that a backslash escapes operators inside names
+func TestEscapedOperator(t *testing.T) {
+	expr, err := Parse(`#R\&D`)
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+
+	env := NewEnv(time.Now(), []api.Project{{ID: "rd", Name: "R&D"}}, nil)
+	if !expr.Match(env, &api.Task{ProjectID: "rd"}) {
+		t.Error(`#R\&D did not match a task in project R&D`)
+	}
+}
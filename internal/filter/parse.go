@@ -0,0 +1,226 @@
+// Package filter parses and evaluates a subset of the Todoist filter query language
+// against locally mirrored tasks.
+//
+// The supported grammar is:
+//
+//	expr    = and { "|" and }
+//	and     = unary { "&" unary }
+//	unary   = "!" unary | "(" expr ")" | term
+//
+// Terms are matched case-insensitively:
+//
+//	today, tomorrow, yesterday      due on that day
+//	overdue, od                     due before now
+//	N days, next N days             due from today up to N-1 days ahead
+//	date: D, due: D                 due on date D
+//	date before: D, due before: D   due strictly before date D
+//	date after: D, due after: D     due strictly after date D
+//	no date, no due date            no due date
+//	recurring                       recurring due date
+//	p1, p2, p3, p4                  priority (p1 is the most urgent)
+//	no priority                     priority p4
+//	#Project                        in the named project
+//	##Project                       in the named project or one of its subprojects
+//	/Section                        in the named section
+//	@label                          carries the label
+//	no labels                       carries no labels
+//	subtask                         has a parent task
+//	search: text                    content or description contains text
+//
+// Dates D are YYYY-MM-DD, today, tomorrow or yesterday. Names may use * as a wildcard,
+// and \ escapes an operator character inside a name.
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenKind identifies a lexical token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+	tokenTerm
+)
+
+// token is a lexical token and its position in the query
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators are the characters that end a term
+const operators = "&|()"
+
+// lex splits a query into tokens
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			i++
+		case r == '&':
+			tokens = append(tokens, token{kind: tokenAnd, pos: i})
+			i++
+		case r == '|':
+			tokens = append(tokens, token{kind: tokenOr, pos: i})
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, pos: i})
+			i++
+		case r == '!':
+			tokens = append(tokens, token{kind: tokenNot, pos: i})
+			i++
+		case r == ',':
+			return nil, fmt.Errorf("position %d: comma-separated filter lists are not supported", i)
+		default:
+			// A term runs until the next unescaped operator
+			start := i
+			var text strings.Builder
+			for i < len(runes) && !strings.ContainsRune(operators, runes[i]) && runes[i] != ',' {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				text.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenTerm, text: strings.TrimSpace(text.String()), pos: start})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// parser is a recursive-descent parser over a token list
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// Parse parses a filter query into an expression
+func Parse(query string) (Expr, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, fmt.Errorf("invalid filter: empty query")
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid filter: position %d: unexpected %s", t.pos, describe(t))
+	}
+	return expr, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNot:
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{operand: operand}, nil
+	case tokenOpen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenClose {
+			return nil, fmt.Errorf("position %d: expected ) but found %s", closing.pos, describe(closing))
+		}
+		return expr, nil
+	case tokenTerm:
+		term, err := parseTerm(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: %w", t.pos, err)
+		}
+		return term, nil
+	default:
+		return nil, fmt.Errorf("position %d: expected a filter term but found %s", t.pos, describe(t))
+	}
+}
+
+// describe names a token for error messages
+func describe(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenAnd:
+		return "&"
+	case tokenOr:
+		return "|"
+	case tokenNot:
+		return "!"
+	case tokenOpen:
+		return "("
+	case tokenClose:
+		return ")"
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
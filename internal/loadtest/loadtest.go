@@ -0,0 +1,306 @@
+// Package loadtest generates webhook load against a server and reports client-side latency and
+// error rates alongside the server's own metrics. In soak mode it also watches the server for
+// goroutine and file descriptor leaks.
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cherry_backend/internal/replay"
+)
+
+// ErrInvalidConfig is returned for configurations that cannot be run
+var ErrInvalidConfig = errors.New("invalid load test configuration")
+
+// DefaultMix is the event mix used when none is configured, roughly that of an active account
+var DefaultMix = map[string]int{
+	"item:added":     40,
+	"item:updated":   30,
+	"item:completed": 20,
+	"item:deleted":   5,
+	"note:added":     5,
+}
+
+// Defaults of Config
+const (
+	DefaultConcurrency        = 10
+	DefaultDuration           = 10 * time.Second
+	DefaultUsers              = 100
+	DefaultTimeout            = 10 * time.Second
+	DefaultSampleInterval     = time.Second
+	DefaultSettleTime         = 5 * time.Second
+	DefaultGoroutineTolerance = 10
+	DefaultFDTolerance        = 10
+)
+
+// Config describes a load test. Zero values are replaced with the defaults above.
+type Config struct {
+	// URL is the webhook endpoint, e.g. http://localhost:8080/webhooks/todoist
+	URL string
+
+	// Concurrency is the number of deliveries in flight at any time
+	Concurrency int
+
+	Duration time.Duration
+
+	// Mix weighs the event names sent, e.g. {"item:added": 3, "item:completed": 1}
+	Mix map[string]int
+
+	// Sign signs every body with Secret; unsigned deliveries measure the rejection path
+	Sign   bool
+	Secret string
+
+	// Users is the number of distinct user IDs the deliveries are spread over
+	Users int
+
+	// Timeout bounds every delivery
+	Timeout time.Duration
+
+	// MetricsURL is the GET /admin/metrics endpoint of the server, read with AdminToken before,
+	// during and after the run; empty to skip server-side metrics
+	MetricsURL     string
+	AdminToken     string
+	SampleInterval time.Duration
+
+	// Soak waits SettleTime after the load stops and reports a leak when the server keeps more
+	// than the tolerated number of extra goroutines or file descriptors. It requires MetricsURL.
+	Soak               bool
+	SettleTime         time.Duration
+	GoroutineTolerance int
+	FDTolerance        int
+}
+
+// withDefaults validates a configuration and fills in its defaults
+func (c Config) withDefaults() (Config, error) {
+	if c.URL == "" {
+		return c, fmt.Errorf("%w: no URL", ErrInvalidConfig)
+	}
+	if c.Soak && c.MetricsURL == "" {
+		return c, fmt.Errorf("%w: soak mode needs the metrics URL of the server", ErrInvalidConfig)
+	}
+	if c.Sign && c.Secret == "" {
+		return c, fmt.Errorf("%w: signing needs a secret", ErrInvalidConfig)
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = DefaultConcurrency
+	}
+	if c.Duration <= 0 {
+		c.Duration = DefaultDuration
+	}
+	if len(c.Mix) == 0 {
+		c.Mix = DefaultMix
+	}
+	for event, weight := range c.Mix {
+		if weight < 0 {
+			return c, fmt.Errorf("%w: negative weight for %s", ErrInvalidConfig, event)
+		}
+	}
+	if c.Users <= 0 {
+		c.Users = DefaultUsers
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.SampleInterval <= 0 {
+		c.SampleInterval = DefaultSampleInterval
+	}
+	if c.SettleTime <= 0 {
+		c.SettleTime = DefaultSettleTime
+	}
+	if c.GoroutineTolerance <= 0 {
+		c.GoroutineTolerance = DefaultGoroutineTolerance
+	}
+	if c.FDTolerance <= 0 {
+		c.FDTolerance = DefaultFDTolerance
+	}
+	return c, nil
+}
+
+// ParseMix parses an event mix such as "item:added=3,item:completed=1"; an event without a
+// weight counts once
+func ParseMix(value string) (map[string]int, error) {
+	mix := map[string]int{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		event, weight, found := strings.Cut(part, "=")
+		if !found {
+			mix[event] = 1
+			continue
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: bad weight %q for %s", ErrInvalidConfig, weight, event)
+		}
+		mix[event] = n
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("%w: empty event mix", ErrInvalidConfig)
+	}
+	return mix, nil
+}
+
+// sample is the outcome of one delivery
+type sample struct {
+	event   string
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// Run sends webhooks for the configured duration, or until the context is done, and reports
+// the results
+func Run(ctx context.Context, config Config) (*Report, error) {
+	config, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	events, err := newPicker(config.Mix)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{MaxIdleConnsPerHost: config.Concurrency}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: config.Timeout}
+
+	var watcher *watcher
+	if config.MetricsURL != "" {
+		watcher = newWatcher(config.MetricsURL, config.AdminToken)
+		if err := watcher.start(ctx, config.SampleInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{Started: time.Now().UTC()}
+	runCtx, cancel := context.WithTimeout(ctx, config.Duration)
+	defer cancel()
+
+	results := make([][]sample, config.Concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			for runCtx.Err() == nil {
+				s := deliver(runCtx, client, config, events.pick(random), random)
+				if runCtx.Err() != nil && s.err != nil {
+					// Deliveries cut short by the end of the run are not failures
+					break
+				}
+				results[worker] = append(results[worker], s)
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(report.Started)
+
+	var samples []sample
+	for _, worker := range results {
+		samples = append(samples, worker...)
+	}
+	report.summarize(samples, elapsed)
+
+	if watcher != nil {
+		// Drop the client's connections so the server can release theirs before the last sample
+		transport.CloseIdleConnections()
+		if config.Soak {
+			select {
+			case <-ctx.Done():
+			case <-time.After(config.SettleTime):
+			}
+		}
+		report.Server, err = watcher.stop(ctx)
+		if err != nil {
+			return report, err
+		}
+		if config.Soak {
+			report.Server.checkLeaks(config.GoroutineTolerance, config.FDTolerance)
+		}
+	}
+	return report, nil
+}
+
+// deliver sends one webhook
+func deliver(ctx context.Context, client *http.Client, config Config, event string, random *rand.Rand) sample {
+	body, err := json.Marshal(map[string]interface{}{
+		"event_name": event,
+		"user_id":    strconv.Itoa(1 + random.Intn(config.Users)),
+		"event_data": map[string]interface{}{
+			"id":         strconv.FormatInt(random.Int63(), 10),
+			"project_id": strconv.Itoa(1 + random.Intn(20)),
+			"content":    "Load test task",
+			"priority":   1 + random.Intn(4),
+		},
+		"version": "9",
+	})
+	if err != nil {
+		return sample{event: event, err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return sample{event: event, err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Todoist-Webhooks")
+	if config.Sign {
+		req.Header.Set(replay.SignatureHeader, replay.Sign(body, config.Secret))
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return sample{event: event, latency: time.Since(start), err: err}
+	}
+	// Read the body so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return sample{event: event, status: resp.StatusCode, latency: time.Since(start)}
+}
+
+// picker chooses events according to their weights
+type picker struct {
+	events     []string
+	cumulative []int
+}
+
+// newPicker creates a picker for a mix, in a stable order
+func newPicker(mix map[string]int) (*picker, error) {
+	p := &picker{}
+	for event := range mix {
+		p.events = append(p.events, event)
+	}
+	sort.Strings(p.events)
+	total := 0
+	for _, event := range p.events {
+		total += mix[event]
+		p.cumulative = append(p.cumulative, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: every event has weight 0", ErrInvalidConfig)
+	}
+	return p, nil
+}
+
+// pick returns a random event
+func (p *picker) pick(random *rand.Rand) string {
+	n := random.Intn(p.cumulative[len(p.cumulative)-1])
+	i := sort.SearchInts(p.cumulative, n+1)
+	return p.events[i]
+}
@@ -0,0 +1,145 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/metrics"
+	"cherry_backend/internal/server/servertest"
+)
+
+// newTestServer starts a server that verifies webhook signatures with servertest.ClientSecret.
+// The load comes from one address, so the webhook rate limit is off.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv("CHERRY_RATE_LIMITS", "webhook=off")
+	return servertest.NewTestServer(t).HTTP
+}
+
+// TestRun runs a short signed load test with server metrics
+func TestRun(t *testing.T) {
+	target := newTestServer(t)
+	report, err := Run(context.Background(), Config{
+		URL:            target.URL + "/webhooks/todoist",
+		Concurrency:    4,
+		Duration:       300 * time.Millisecond,
+		Mix:            map[string]int{"item:added": 3, "item:completed": 1},
+		Sign:           true,
+		Secret:         servertest.ClientSecret,
+		MetricsURL:     target.URL + "/admin/metrics",
+		AdminToken:     servertest.AdminToken,
+		SampleInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if report.Requests == 0 || report.ByStatus["200"] != report.Requests || report.ErrorRate != 0 {
+		t.Fatalf("report = %+v, want only successful deliveries", report)
+	}
+	if len(report.ByEvent) != 2 || report.ByEvent["item:added"] == 0 {
+		t.Errorf("events = %v, want the configured mix", report.ByEvent)
+	}
+	latency := report.Latency
+	if latency.P50 <= 0 || latency.P50 > latency.P99 || latency.P99 > latency.Max {
+		t.Errorf("latency = %+v, want ordered percentiles", latency)
+	}
+
+	if report.Server == nil || len(report.Server.Samples) == 0 {
+		t.Fatalf("server report = %+v, want samples", report.Server)
+	}
+	// Deliveries cut short by the end of the run may still reach the server
+	handled := int(report.Server.After.Webhooks.Requests - report.Server.Before.Webhooks.Requests)
+	if handled < report.Requests || handled > report.Requests+4 {
+		t.Errorf("server handled %d deliveries, client completed %d", handled, report.Requests)
+	}
+}
+
+// TestRunUnsigned tests that rejected deliveries count as errors
+func TestRunUnsigned(t *testing.T) {
+	target := newTestServer(t)
+	report, err := Run(context.Background(), Config{
+		URL:         target.URL + "/webhooks/todoist",
+		Concurrency: 2,
+		Duration:    100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Requests == 0 || report.ByStatus["401"] != report.Requests || report.ErrorRate != 1 {
+		t.Errorf("report = %+v, want only 401 responses", report)
+	}
+}
+
+// TestSoakDetectsLeaks tests that resources a server keeps after the load are reported
+func TestSoakDetectsLeaks(t *testing.T) {
+	started := time.Now()
+	release := make(chan struct{})
+	defer close(release)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
+		// Every delivery leaves a goroutine behind
+		go func() { <-release }()
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(metrics.Read(started))
+	})
+	target := httptest.NewServer(mux)
+	defer target.Close()
+
+	report, err := Run(context.Background(), Config{
+		URL:         target.URL + "/webhook",
+		Concurrency: 2,
+		Duration:    100 * time.Millisecond,
+		MetricsURL:  target.URL + "/metrics",
+		Soak:        true,
+		SettleTime:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Requests <= DefaultGoroutineTolerance || len(report.Server.Leaks) == 0 {
+		t.Errorf("report = %d deliveries, leaks %v, want a goroutine leak", report.Requests, report.Server.Leaks)
+	}
+}
+
+// TestParseMix tests parsing event mixes
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("item:added=3, item:completed=1,note:added")
+	if err != nil {
+		t.Fatalf("ParseMix failed: %v", err)
+	}
+	if len(mix) != 3 || mix["item:added"] != 3 || mix["item:completed"] != 1 || mix["note:added"] != 1 {
+		t.Errorf("ParseMix() = %v", mix)
+	}
+	for _, value := range []string{"", "item:added=x", "item:added=-1"} {
+		if _, err := ParseMix(value); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("ParseMix(%q) = %v, want ErrInvalidConfig", value, err)
+		}
+	}
+}
+
+// TestPicker tests that events are picked according to their weights
+func TestPicker(t *testing.T) {
+	p, err := newPicker(map[string]int{"a": 3, "b": 1, "never": 0})
+	if err != nil {
+		t.Fatalf("newPicker failed: %v", err)
+	}
+	counts := map[string]int{}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		counts[p.pick(random)]++
+	}
+	if counts["never"] != 0 || counts["a"] < 2700 || counts["a"] > 3300 {
+		t.Errorf("counts = %v, want about 3000 a, 1000 b", counts)
+	}
+	if _, err := newPicker(map[string]int{"a": 0}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("newPicker() with no weight = %v, want ErrInvalidConfig", err)
+	}
+}
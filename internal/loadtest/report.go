@@ -0,0 +1,178 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"cherry_backend/internal/metrics"
+)
+
+// Report is the outcome of a load test
+type Report struct {
+	Started         time.Time `json:"started"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Requests        int       `json:"requests"`
+
+	// Throughput is the number of deliveries per second
+	Throughput float64 `json:"throughput"`
+
+	// ByStatus counts the responses by status code; ByEvent the deliveries by event name
+	ByStatus map[string]int `json:"by_status"`
+	ByEvent  map[string]int `json:"by_event"`
+
+	// TransportErrors counts deliveries that got no response, e.g. timeouts
+	TransportErrors int `json:"transport_errors"`
+
+	// ErrorRate is the share of deliveries without a 2xx response
+	ErrorRate float64 `json:"error_rate"`
+
+	Latency Latency `json:"latency"`
+
+	// Server holds the server-side metrics, when a metrics URL was configured
+	Server *ServerReport `json:"server,omitempty"`
+}
+
+// Latency summarizes the latencies of the deliveries that got a response, in milliseconds
+type Latency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// ServerReport holds the metrics of the server before, during and after a run
+type ServerReport struct {
+	Before  metrics.Snapshot   `json:"before"`
+	After   metrics.Snapshot   `json:"after"`
+	Samples []metrics.Snapshot `json:"samples"`
+
+	PeakGoroutines int `json:"peak_goroutines"`
+	PeakOpenFDs    int `json:"peak_open_fds"`
+
+	// Leaks describes the resources still held by the server once the load stopped, in soak mode
+	Leaks []string `json:"leaks,omitempty"`
+}
+
+// summarize computes the statistics of the deliveries
+func (r *Report) summarize(samples []sample, elapsed time.Duration) {
+	r.DurationSeconds = elapsed.Seconds()
+	r.Requests = len(samples)
+	r.ByStatus = map[string]int{}
+	r.ByEvent = map[string]int{}
+	if elapsed > 0 {
+		r.Throughput = float64(len(samples)) / elapsed.Seconds()
+	}
+
+	var latencies []time.Duration
+	var total time.Duration
+	failed := 0
+	for _, s := range samples {
+		r.ByEvent[s.event]++
+		if s.err != nil {
+			r.TransportErrors++
+			failed++
+			continue
+		}
+		r.ByStatus[strconv.Itoa(s.status)]++
+		if s.status < 200 || s.status > 299 {
+			failed++
+		}
+		latencies = append(latencies, s.latency)
+		total += s.latency
+	}
+	if len(samples) > 0 {
+		r.ErrorRate = float64(failed) / float64(len(samples))
+	}
+	if len(latencies) == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.Latency = Latency{
+		Mean: milliseconds(total / time.Duration(len(latencies))),
+		P50:  milliseconds(percentile(latencies, 50)),
+		P90:  milliseconds(percentile(latencies, 90)),
+		P95:  milliseconds(percentile(latencies, 95)),
+		P99:  milliseconds(percentile(latencies, 99)),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// checkLeaks compares the settled state of the server with its state before the run
+func (s *ServerReport) checkLeaks(goroutineTolerance, fdTolerance int) {
+	if extra := s.After.Goroutines - s.Before.Goroutines; extra > goroutineTolerance {
+		s.Leaks = append(s.Leaks, fmt.Sprintf("%d goroutines more than before the run (%d -> %d, peak %d)",
+			extra, s.Before.Goroutines, s.After.Goroutines, s.PeakGoroutines))
+	}
+	if s.Before.OpenFDs >= 0 && s.After.OpenFDs >= 0 {
+		if extra := s.After.OpenFDs - s.Before.OpenFDs; extra > fdTolerance {
+			s.Leaks = append(s.Leaks, fmt.Sprintf("%d file descriptors more than before the run (%d -> %d, peak %d)",
+				extra, s.Before.OpenFDs, s.After.OpenFDs, s.PeakOpenFDs))
+		}
+	}
+}
+
+// Print writes a human-readable summary of the report
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Deliveries:   %d in %.1fs (%.1f/s)\n", r.Requests, r.DurationSeconds, r.Throughput)
+	fmt.Fprintf(w, "Error rate:   %.2f%% (%d transport errors)\n", r.ErrorRate*100, r.TransportErrors)
+	fmt.Fprintf(w, "Statuses:     %s\n", formatCounts(r.ByStatus))
+	fmt.Fprintf(w, "Events:       %s\n", formatCounts(r.ByEvent))
+	fmt.Fprintf(w, "Latency (ms): mean %.2f  p50 %.2f  p90 %.2f  p95 %.2f  p99 %.2f  max %.2f\n",
+		r.Latency.Mean, r.Latency.P50, r.Latency.P90, r.Latency.P95, r.Latency.P99, r.Latency.Max)
+	if r.Server == nil {
+		return
+	}
+
+	before, after := r.Server.Before, r.Server.After
+	handled := after.Webhooks.Requests - before.Webhooks.Requests
+	fmt.Fprintf(w, "Server:       %d deliveries handled, mean handler time %.2fms since start\n", handled, after.Webhooks.MeanLatencyMS)
+	fmt.Fprintf(w, "Goroutines:   %d -> %d (peak %d)\n", before.Goroutines, after.Goroutines, r.Server.PeakGoroutines)
+	if before.OpenFDs >= 0 {
+		fmt.Fprintf(w, "Open FDs:     %d -> %d (peak %d)\n", before.OpenFDs, after.OpenFDs, r.Server.PeakOpenFDs)
+	}
+	fmt.Fprintf(w, "Heap:         %.1fMB -> %.1fMB, %d GCs\n",
+		float64(before.HeapAllocBytes)/(1<<20), float64(after.HeapAllocBytes)/(1<<20), after.NumGC-before.NumGC)
+	for _, leak := range r.Server.Leaks {
+		fmt.Fprintf(w, "LEAK:         %s\n", leak)
+	}
+}
+
+// formatCounts renders counts in key order
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	text := ""
+	for i, key := range keys {
+		if i > 0 {
+			text += "  "
+		}
+		text += fmt.Sprintf("%s=%d", key, counts[key])
+	}
+	if text == "" {
+		return "-"
+	}
+	return text
+}
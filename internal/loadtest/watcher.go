@@ -0,0 +1,107 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"cherry_backend/internal/metrics"
+	"cherry_backend/pkg/api"
+)
+
+// watcher samples the metrics of the server during a run
+type watcher struct {
+	generator  *api.ClientGenerator
+	adminToken string
+
+	mu      sync.Mutex
+	before  metrics.Snapshot
+	samples []metrics.Snapshot
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newWatcher creates a watcher of the GET /admin/metrics endpoint at url
+func newWatcher(url, adminToken string) *watcher {
+	return &watcher{generator: api.NewClientGenerator(url), adminToken: adminToken}
+}
+
+// start reads the baseline and samples the metrics every interval until stop
+func (w *watcher) start(ctx context.Context, interval time.Duration) error {
+	before, err := w.read(ctx)
+	if err != nil {
+		return err
+	}
+	w.before = before
+	w.done = make(chan struct{})
+	w.stopped = make(chan struct{})
+
+	go func() {
+		defer close(w.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.done:
+				return
+			case <-ticker.C:
+				// A failed sample is skipped rather than failing the run: the server may be
+				// too busy to answer, which the load report shows anyway
+				if snapshot, err := w.read(ctx); err == nil {
+					w.mu.Lock()
+					w.samples = append(w.samples, snapshot)
+					w.mu.Unlock()
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// stop ends the sampling and reads the final metrics
+func (w *watcher) stop(ctx context.Context) (*ServerReport, error) {
+	close(w.done)
+	<-w.stopped
+	after, err := w.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	report := &ServerReport{Before: w.before, After: after, Samples: w.samples}
+	report.PeakGoroutines, report.PeakOpenFDs = after.Goroutines, after.OpenFDs
+	for _, snapshot := range w.samples {
+		if snapshot.Goroutines > report.PeakGoroutines {
+			report.PeakGoroutines = snapshot.Goroutines
+		}
+		if snapshot.OpenFDs > report.PeakOpenFDs {
+			report.PeakOpenFDs = snapshot.OpenFDs
+		}
+	}
+	return report, nil
+}
+
+// read fetches the current metrics of the server
+func (w *watcher) read(ctx context.Context) (metrics.Snapshot, error) {
+	var snapshot metrics.Snapshot
+	resp, err := w.generator.DoContext(ctx, &api.Request{
+		Method: http.MethodGet,
+		Header: map[string]string{"Authorization": "Bearer " + w.adminToken},
+	})
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to read server metrics: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return snapshot, fmt.Errorf("failed to read server metrics: %w", api.NewAPIError(resp))
+	}
+	if err := json.Unmarshal(resp.Body, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("failed to decode server metrics: %w", err)
+	}
+	return snapshot, nil
+}
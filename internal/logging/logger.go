@@ -5,10 +5,14 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
 
+// DefaultRetention is how long Prune keeps daily log files by default
+const DefaultRetention = 30 * 24 * time.Hour
+
 // Logger is the interface that wraps the basic logging methods
 type Logger interface {
 	Info(format string, args ...interface{})
@@ -147,3 +151,37 @@ func (l *LoggerImpl) Close() error {
 
 	return nil
 }
+
+// Prune removes the daily log files in the log directory from days more than retention before
+// now and returns the names of the removed files. Other files are left alone.
+func Prune(now time.Time, retention time.Duration) ([]string, error) {
+	basePath := getLogPath()
+	entries, err := os.ReadDir(basePath)
+	if os.IsNotExist(err) {
+		// Nothing was logged to files yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log directory: %w", err)
+	}
+
+	// Keep every file from the day the retention period starts on
+	cutoff := now.Add(-retention).Format("2006-01-02")
+
+	var removed []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "cherry-") || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		date := strings.TrimSuffix(strings.TrimPrefix(name, "cherry-"), ".log")
+		if _, err := time.Parse("2006-01-02", date); err != nil || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(filepath.Join(basePath, name)); err != nil {
+			return removed, fmt.Errorf("failed to remove log file: %w", err)
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
@@ -140,6 +140,38 @@ func TestLogRotation(t *testing.T) {
 	t.Logf("Log rotation test passed. Both log files exist.")
 }
 
+// TestPrune tests that only daily log files older than the retention period are removed
+func TestPrune(t *testing.T) {
+	// Setup test environment
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	// Create log files of several days and an unrelated file
+	names := []string{"cherry-2024-01-30.log", "cherry-2024-01-31.log", "cherry-2024-03-01.log", "cherry-notes.log", "other.txt"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(getLogPath(), name), []byte("log\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	// Keep 30 days before March 1, which starts on January 31
+	now := time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC)
+	removed, err := Prune(now, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "cherry-2024-01-30.log" {
+		t.Errorf("Removed %v, want only the log of January 30", removed)
+	}
+
+	// Check that the other files are still there
+	for _, name := range names[1:] {
+		if _, err := os.Stat(filepath.Join(getLogPath(), name)); err != nil {
+			t.Errorf("%s was removed: %v", name, err)
+		}
+	}
+}
+
 // setupTestEnv sets up the test environment
 func setupTestEnv(t *testing.T) {
 	// Override the getLogPath function for testing
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"log"
+)
+
+// StdLogger implements the Logger interface on top of the standard log package.
+// It is used as a fallback when the log directory is not writable.
+type StdLogger struct{}
+
+// NewStdLogger creates a new logger that writes through the standard log package
+func NewStdLogger() *StdLogger {
+	return &StdLogger{}
+}
+
+// Info logs an informational message
+func (l *StdLogger) Info(format string, args ...interface{}) {
+	log.Printf("[INFO] "+format, args...)
+}
+
+// Error logs an error message
+func (l *StdLogger) Error(format string, args ...interface{}) {
+	log.Printf("[ERROR] "+format, args...)
+}
+
+// Debug logs a debug message
+func (l *StdLogger) Debug(format string, args ...interface{}) {
+	log.Printf("[DEBUG] "+format, args...)
+}
+
+// Warn logs a warning message
+func (l *StdLogger) Warn(format string, args ...interface{}) {
+	log.Printf("[WARN] "+format, args...)
+}
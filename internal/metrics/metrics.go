@@ -0,0 +1,127 @@
+// Package metrics collects the request and runtime metrics the server exposes to operators and
+// to the load testing harness
+package metrics
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Snapshot is the state of a server at one point in time, as served by GET /admin/metrics
+type Snapshot struct {
+	Time          time.Time `json:"time"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	Goroutines    int       `json:"goroutines"`
+
+	// OpenFDs is the number of open file descriptors, or -1 where they cannot be counted
+	OpenFDs int `json:"open_fds"`
+
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+
+	// Webhooks are the statistics of the webhook endpoint
+	Webhooks EndpointStats `json:"webhooks"`
+}
+
+// EndpointStats are the statistics of the requests to one endpoint since the server started
+type EndpointStats struct {
+	Requests uint64 `json:"requests"`
+
+	// ByStatus counts the responses by status code
+	ByStatus map[string]uint64 `json:"by_status"`
+
+	// MeanLatencyMS is the mean time spent in the handler
+	MeanLatencyMS float64 `json:"mean_latency_ms"`
+}
+
+// Endpoint counts the requests to an endpoint and the time spent handling them
+type Endpoint struct {
+	mu       sync.Mutex
+	requests uint64
+	byStatus map[int]uint64
+	total    time.Duration
+}
+
+// Observe records a response
+func (e *Endpoint) Observe(status int, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.byStatus == nil {
+		e.byStatus = map[int]uint64{}
+	}
+	e.requests++
+	e.byStatus[status]++
+	e.total += latency
+}
+
+// Stats returns the statistics recorded so far
+func (e *Endpoint) Stats() EndpointStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := EndpointStats{Requests: e.requests, ByStatus: map[string]uint64{}}
+	for status, count := range e.byStatus {
+		stats.ByStatus[strconv.Itoa(status)] = count
+	}
+	if e.requests > 0 {
+		stats.MeanLatencyMS = float64(e.total) / float64(e.requests) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// Middleware observes every response of a handler
+func (e *Endpoint) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		e.Observe(recorder.status, time.Since(start))
+	})
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the first status code written
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Read returns the runtime metrics of the process, started at started
+func Read(started time.Time) Snapshot {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	now := time.Now()
+	return Snapshot{
+		Time:           now.UTC(),
+		UptimeSeconds:  now.Sub(started).Seconds(),
+		Goroutines:     runtime.NumGoroutine(),
+		OpenFDs:        OpenFDs(),
+		HeapAllocBytes: memStats.HeapAlloc,
+		NumGC:          memStats.NumGC,
+	}
+}
+
+// OpenFDs counts the open file descriptors of the process, or returns -1 on systems without
+// /proc/self/fd or /dev/fd, such as Windows
+func OpenFDs() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		entries, err := os.ReadDir(dir)
+		if err == nil {
+			// Reading the directory opens one descriptor of its own
+			return len(entries) - 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,291 @@
+// Package mirror keeps a local copy of each connected user's Todoist state using the
+// incremental sync_token protocol of the Todoist Sync API
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"cherry_backend/internal/logging"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// maxConflictRetries bounds how often a sync restarts after losing a race to another sync
+const maxConflictRetries = 3
+
+// ErrNoToken is returned by a TokenSource for users without a Todoist API token
+var ErrNoToken = errors.New("no Todoist API token for user")
+
+// TokenSource provides the Todoist API token of each connected user
+type TokenSource interface {
+	// Token returns the API token of a user, or ErrNoToken
+	Token(ctx context.Context, userID string) (string, error)
+
+	// Users returns the IDs of all connected users
+	Users(ctx context.Context) ([]string, error)
+}
+
+// StaticTokenSource is a TokenSource backed by a fixed map of user ID to API token
+type StaticTokenSource map[string]string
+
+// Token returns the API token of a user
+func (s StaticTokenSource) Token(ctx context.Context, userID string) (string, error) {
+	token, ok := s[userID]
+	if !ok {
+		return "", ErrNoToken
+	}
+	return token, nil
+}
+
+// Users returns the IDs of all users with a token
+func (s StaticTokenSource) Users(ctx context.Context) ([]string, error) {
+	users := make([]string, 0, len(s))
+	for userID := range s {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// TokensFromEnv reads TODOIST_API_TOKENS, a comma-separated list of user_id:token pairs
+func TokensFromEnv() StaticTokenSource {
+	tokens := StaticTokenSource{}
+	for _, pair := range strings.Split(os.Getenv("TODOIST_API_TOKENS"), ",") {
+		userID, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && userID != "" && token != "" {
+			tokens[userID] = token
+		}
+	}
+	return tokens
+}
+
+// RefreshingTokens is a TokenSource of fixed tokens plus tokens loaded from elsewhere, such as
+// those saved by Todoist logins. The loaded tokens are replaced by Refresh; fixed tokens take
+// precedence over loaded ones.
+type RefreshingTokens struct {
+	Static StaticTokenSource
+
+	// Load returns the current tokens by user ID; nil loads nothing
+	Load func(ctx context.Context) (map[string]string, error)
+
+	mu     sync.RWMutex
+	loaded map[string]string
+}
+
+// Token returns the API token of a user
+func (t *RefreshingTokens) Token(ctx context.Context, userID string) (string, error) {
+	if token, ok := t.Static[userID]; ok {
+		return token, nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	token, ok := t.loaded[userID]
+	if !ok {
+		return "", ErrNoToken
+	}
+	return token, nil
+}
+
+// Users returns the IDs of all users with a fixed or loaded token
+func (t *RefreshingTokens) Users(ctx context.Context) ([]string, error) {
+	users, _ := t.Static.Users(ctx)
+	t.mu.RLock()
+	for userID := range t.loaded {
+		if _, ok := t.Static[userID]; !ok {
+			users = append(users, userID)
+		}
+	}
+	t.mu.RUnlock()
+	sort.Strings(users)
+	return users, nil
+}
+
+// Refresh reloads the loaded tokens and returns how many there are. The previous tokens are
+// kept if loading fails.
+func (t *RefreshingTokens) Refresh(ctx context.Context) (int, error) {
+	if t.Load == nil {
+		return 0, nil
+	}
+	tokens, err := t.Load(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load tokens: %w", err)
+	}
+	t.mu.Lock()
+	t.loaded = tokens
+	t.mu.Unlock()
+	return len(tokens), nil
+}
+
+// Mirror keeps the Store in sync with Todoist for every user of the TokenSource
+type Mirror struct {
+	Store  Store
+	Tokens TokenSource
+	Logger logging.Logger
+
+	// BaseURL is the Todoist API base URL
+	BaseURL string
+
+	mu       sync.Mutex
+	inFlight map[string]*pendingSync
+	wg       sync.WaitGroup
+
+	// ctx bounds the background syncs; Close cancels it
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// pendingSync tracks a background sync of one user; again is set when a webhook arrives mid-sync
+type pendingSync struct {
+	again bool
+}
+
+// NewMirror creates a new mirror with default settings
+func NewMirror(store Store, tokens TokenSource, logger logging.Logger) *Mirror {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Mirror{
+		Store:    store,
+		Tokens:   tokens,
+		Logger:   logger,
+		BaseURL:  api.TodoistRESTBaseURL,
+		inFlight: make(map[string]*pendingSync),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Sync brings the mirror of a user up to date. Users that were never synced get a full sync.
+func (m *Mirror) Sync(ctx context.Context, userID string) error {
+	return m.sync(ctx, userID, false)
+}
+
+// FullSync replaces the mirror of a user with a fresh full sync
+func (m *Mirror) FullSync(ctx context.Context, userID string) error {
+	return m.sync(ctx, userID, true)
+}
+
+// sync reads changes from Todoist and applies them, restarting if another sync won the race
+func (m *Mirror) sync(ctx context.Context, userID string, full bool) error {
+	token, err := m.Tokens.Token(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get token for user %s: %w", userID, err)
+	}
+	client := api.NewTodoistRESTClient(m.BaseURL, token)
+
+	for attempt := 0; ; attempt++ {
+		base, err := m.Store.SyncToken(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to read sync token for user %s: %w", userID, err)
+		}
+
+		syncToken := base
+		if full || syncToken == "" {
+			syncToken = api.FullSyncToken
+		}
+
+		delta, err := client.Sync(ctx, syncToken)
+		if err != nil {
+			return err
+		}
+
+		err = m.Store.Apply(ctx, userID, base, delta)
+		if errors.Is(err, ErrSyncTokenConflict) && attempt < maxConflictRetries {
+			m.Logger.Debug("Sync token conflict for user %s, retrying", userID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply sync for user %s: %w", userID, err)
+		}
+
+		m.Logger.Info("Synced user %s (full=%v, items=%d, projects=%d)", userID, delta.FullSync, len(delta.Items), len(delta.Projects))
+		return nil
+	}
+}
+
+// OnWebhook triggers a background incremental sync for the user who caused the event
+func (m *Mirror) OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error {
+	if request.UserId == "" {
+		return nil
+	}
+
+	// Only users with a token can be mirrored
+	if _, err := m.Tokens.Token(ctx, request.UserId); err != nil {
+		if errors.Is(err, ErrNoToken) {
+			m.Logger.Debug("Not mirroring user %s: no API token", request.UserId)
+			return nil
+		}
+		return err
+	}
+
+	m.Trigger(request.UserId)
+	return nil
+}
+
+// Trigger starts a background incremental sync of a user. Triggers that arrive while a sync
+// of the same user is running are coalesced into a single follow-up sync.
+func (m *Mirror) Trigger(userID string) {
+	m.mu.Lock()
+	if pending, ok := m.inFlight[userID]; ok {
+		pending.again = true
+		m.mu.Unlock()
+		return
+	}
+	pending := &pendingSync{}
+	m.inFlight[userID] = pending
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for {
+			if err := m.Sync(m.ctx, userID); err != nil {
+				m.Logger.Error("Error syncing user %s: %v", userID, err)
+			}
+
+			m.mu.Lock()
+			if !pending.again {
+				delete(m.inFlight, userID)
+				m.mu.Unlock()
+				return
+			}
+			pending.again = false
+			m.mu.Unlock()
+		}
+	}()
+}
+
+// Wait blocks until all background syncs have finished
+func (m *Mirror) Wait() {
+	m.wg.Wait()
+}
+
+// Close cancels the background syncs and waits for them to return
+func (m *Mirror) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Reconcile syncs every connected user, performing full syncs when full is set
+func (m *Mirror) Reconcile(ctx context.Context, full bool) error {
+	users, err := m.Tokens.Users(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var errs []string
+	for _, userID := range users {
+		if err := m.sync(ctx, userID, full); err != nil {
+			m.Logger.Error("Error reconciling user %s: %v", userID, err)
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("reconciliation failed for %d of %d users: %s", len(errs), len(users), strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/logging"
+	"cherry_backend/pkg/api"
+	"cherry_backend/pkg/api/todoisttest"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// newTestMirror starts a fake Todoist server and returns a mirror of user "1" plus a client for that server
+func newTestMirror(t *testing.T) (*Mirror, *api.TodoistRESTClient) {
+	server := todoisttest.NewServer("token-1")
+	t.Cleanup(server.Close)
+
+	m := NewMirror(NewMemoryStore(), StaticTokenSource{"1": "token-1"}, logging.NewStdLogger())
+	m.BaseURL = server.URL
+	return m, api.NewTodoistRESTClient(server.URL, "token-1")
+}
+
+// TestSyncIncremental tests a full sync followed by incremental syncs with updates and deletions
+func TestSyncIncremental(t *testing.T) {
+	m, client := newTestMirror(t)
+	ctx := context.Background()
+
+	project, _ := client.CreateProject(ctx, &api.CreateProjectArgs{Name: "Work"})
+	first, _ := client.CreateTask(ctx, &api.CreateTaskArgs{Content: "First", ProjectID: project.ID})
+
+	if err := m.Sync(ctx, "1"); err != nil {
+		t.Fatalf("initial Sync failed: %v", err)
+	}
+	snapshot, _ := m.Store.Snapshot(ctx, "1")
+	if len(snapshot.Tasks) != 1 || len(snapshot.Projects) != 1 {
+		t.Fatalf("after full sync got %d tasks and %d projects, want 1 and 1", len(snapshot.Tasks), len(snapshot.Projects))
+	}
+
+	// Change Todoist behind the mirror's back
+	content := "First, renamed"
+	client.UpdateTask(ctx, first.ID, &api.UpdateTaskArgs{Content: &content})
+	second, _ := client.CreateTask(ctx, &api.CreateTaskArgs{Content: "Second", ProjectID: project.ID})
+	client.CreateComment(ctx, &api.CreateCommentArgs{Content: "Note", TaskID: second.ID})
+
+	if err := m.Sync(ctx, "1"); err != nil {
+		t.Fatalf("incremental Sync failed: %v", err)
+	}
+	snapshot, _ = m.Store.Snapshot(ctx, "1")
+	if len(snapshot.Tasks) != 2 || len(snapshot.Notes) != 1 {
+		t.Fatalf("after incremental sync got %d tasks and %d notes, want 2 and 1", len(snapshot.Tasks), len(snapshot.Notes))
+	}
+	for _, task := range snapshot.Tasks {
+		if task.ID == first.ID && task.Content != content {
+			t.Errorf("task content = %s, want %s", task.Content, content)
+		}
+	}
+
+	// Deleting the project removes its tasks and their comments
+	client.DeleteProject(ctx, project.ID)
+	if err := m.Sync(ctx, "1"); err != nil {
+		t.Fatalf("incremental Sync failed: %v", err)
+	}
+	snapshot, _ = m.Store.Snapshot(ctx, "1")
+	if len(snapshot.Tasks) != 0 || len(snapshot.Projects) != 0 || len(snapshot.Notes) != 0 {
+		t.Errorf("after deletion got %d tasks, %d projects and %d notes, want none", len(snapshot.Tasks), len(snapshot.Projects), len(snapshot.Notes))
+	}
+}
+
+// TestApplyConflict tests that a delta based on a stale sync token is rejected
+func TestApplyConflict(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	full := &api.SyncResponse{SyncToken: "5", FullSync: true, Items: []api.Task{{ID: "1", Content: "A"}}}
+	if err := store.Apply(ctx, "1", "", full); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	stale := &api.SyncResponse{SyncToken: "6", Items: []api.Task{{ID: "1", IsDeleted: true}}}
+	if err := store.Apply(ctx, "1", "4", stale); !errors.Is(err, ErrSyncTokenConflict) {
+		t.Fatalf("Apply with stale base = %v, want ErrSyncTokenConflict", err)
+	}
+
+	snapshot, _ := store.Snapshot(ctx, "1")
+	if snapshot.SyncToken != "5" || len(snapshot.Tasks) != 1 {
+		t.Errorf("rejected Apply changed the state: token %s, %d tasks", snapshot.SyncToken, len(snapshot.Tasks))
+	}
+}
+
+// TestOnWebhookTriggersSync tests that a webhook for a connected user updates the mirror
+func TestOnWebhookTriggersSync(t *testing.T) {
+	m, client := newTestMirror(t)
+	ctx := context.Background()
+
+	client.CreateTask(ctx, &api.CreateTaskArgs{Content: "From webhook"})
+
+	request := &apiv1.TodoistWebhookRequest{EventName: "item:added", UserId: "1"}
+	if err := m.OnWebhook(ctx, request); err != nil {
+		t.Fatalf("OnWebhook failed: %v", err)
+	}
+	m.Wait()
+
+	snapshot, _ := m.Store.Snapshot(ctx, "1")
+	if len(snapshot.Tasks) != 1 {
+		t.Errorf("after webhook got %d tasks, want 1", len(snapshot.Tasks))
+	}
+
+	// Webhooks for users without a token are ignored
+	request.UserId = "unknown"
+	if err := m.OnWebhook(ctx, request); err != nil {
+		t.Fatalf("OnWebhook for unknown user failed: %v", err)
+	}
+	m.Wait()
+	if users, _ := m.Store.Users(ctx); len(users) != 1 {
+		t.Errorf("Users() = %v, want only the connected user", users)
+	}
+}
+
+// TestReconcileHealsDrift tests that a full reconciliation discards state Todoist no longer has
+func TestReconcileHealsDrift(t *testing.T) {
+	m, client := newTestMirror(t)
+	ctx := context.Background()
+
+	client.CreateTask(ctx, &api.CreateTaskArgs{Content: "Real"})
+	if err := m.Sync(ctx, "1"); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Inject a task that Todoist never reported
+	token, _ := m.Store.SyncToken(ctx, "1")
+	bogus := &api.SyncResponse{SyncToken: token, Items: []api.Task{{ID: "999", Content: "Bogus"}}}
+	if err := m.Store.Apply(ctx, "1", token, bogus); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if err := m.Reconcile(ctx, true); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	snapshot, _ := m.Store.Snapshot(ctx, "1")
+	if len(snapshot.Tasks) != 1 || snapshot.Tasks[0].Content != "Real" {
+		t.Errorf("after reconciliation got tasks %+v, want only the real task", snapshot.Tasks)
+	}
+}
+
+// TestRefreshingTokens tests merging fixed tokens with reloaded ones
+func TestRefreshingTokens(t *testing.T) {
+	ctx := context.Background()
+	stored := map[string]string{"1": "stored-1", "2": "stored-2"}
+	tokens := &RefreshingTokens{
+		Static: StaticTokenSource{"1": "token-1"},
+		Load:   func(ctx context.Context) (map[string]string, error) { return stored, nil },
+	}
+
+	if _, err := tokens.Token(ctx, "2"); !errors.Is(err, ErrNoToken) {
+		t.Errorf("Token() before Refresh = %v, want ErrNoToken", err)
+	}
+	if n, err := tokens.Refresh(ctx); err != nil || n != 2 {
+		t.Fatalf("Refresh() = %d, %v", n, err)
+	}
+	if token, _ := tokens.Token(ctx, "1"); token != "token-1" {
+		t.Errorf("Token(1) = %q, want the fixed token", token)
+	}
+	if token, _ := tokens.Token(ctx, "2"); token != "stored-2" {
+		t.Errorf("Token(2) = %q, want the loaded token", token)
+	}
+	if users, _ := tokens.Users(ctx); len(users) != 2 || users[0] != "1" || users[1] != "2" {
+		t.Errorf("Users() = %v, want 1 and 2", users)
+	}
+
+	tokens.Load = func(ctx context.Context) (map[string]string, error) { return nil, errors.New("database is down") }
+	if _, err := tokens.Refresh(ctx); err == nil {
+		t.Errorf("Refresh() succeeded with a failing loader")
+	}
+	if token, _ := tokens.Token(ctx, "2"); token != "stored-2" {
+		t.Errorf("Token(2) = %q after a failed refresh, want the previous token", token)
+	}
+}
+
+// TestClose tests that closing the mirror cancels a background sync waiting on Todoist
+func TestClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	m := NewMirror(NewMemoryStore(), StaticTokenSource{"1": "token-1"}, logging.NewStdLogger())
+	m.BaseURL = server.URL
+
+	m.Trigger("1")
+	closed := make(chan struct{})
+	go func() {
+		m.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not cancel the running sync")
+	}
+}
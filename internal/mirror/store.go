@@ -0,0 +1,182 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"cherry_backend/pkg/api"
+)
+
+// ErrSyncTokenConflict is returned by Store.Apply when the stored sync token moved on
+// since the sync started, meaning another sync for the same user won the race
+var ErrSyncTokenConflict = errors.New("sync token conflict")
+
+// Snapshot is a copy of the mirrored Todoist state of a single user
+type Snapshot struct {
+	SyncToken string
+	Tasks     []api.Task
+	Projects  []api.Project
+	Sections  []api.Section
+	Labels    []api.Label
+	Notes     []api.Comment
+}
+
+// Store persists the mirrored Todoist state of every connected user
+type Store interface {
+	// SyncToken returns the last applied sync token of a user, or "" if the user was never synced
+	SyncToken(ctx context.Context, userID string) (string, error)
+
+	// Apply applies a sync response on top of the user's state if the stored sync token still
+	// equals base. A full sync replaces the user's state. Resources flagged as deleted are removed.
+	// ErrSyncTokenConflict is returned, and nothing is written, if the token no longer matches.
+	Apply(ctx context.Context, userID, base string, delta *api.SyncResponse) error
+
+	// Snapshot returns a copy of the mirrored state of a user
+	Snapshot(ctx context.Context, userID string) (*Snapshot, error)
+
+	// Users returns the IDs of all users with mirrored state
+	Users(ctx context.Context) ([]string, error)
+}
+
+// userState is the mirrored state of a single user, keyed by resource ID
+type userState struct {
+	syncToken string
+	tasks     map[string]api.Task
+	projects  map[string]api.Project
+	sections  map[string]api.Section
+	labels    map[string]api.Label
+	notes     map[string]api.Comment
+}
+
+func newUserState() *userState {
+	return &userState{
+		tasks:    make(map[string]api.Task),
+		projects: make(map[string]api.Project),
+		sections: make(map[string]api.Section),
+		labels:   make(map[string]api.Label),
+		notes:    make(map[string]api.Comment),
+	}
+}
+
+// MemoryStore implements Store in memory
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[string]*userState
+}
+
+// NewMemoryStore creates a new empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users: make(map[string]*userState),
+	}
+}
+
+// SyncToken returns the last applied sync token of a user
+func (s *MemoryStore) SyncToken(ctx context.Context, userID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.users[userID]
+	if !ok {
+		return "", nil
+	}
+	return state.syncToken, nil
+}
+
+// Apply applies a sync response on top of the user's state
+func (s *MemoryStore) Apply(ctx context.Context, userID, base string, delta *api.SyncResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.users[userID]
+	if !ok {
+		state = newUserState()
+	}
+	if state.syncToken != base {
+		return ErrSyncTokenConflict
+	}
+
+	// A full sync is authoritative: start from an empty state
+	if delta.FullSync {
+		state = newUserState()
+	}
+
+	for _, task := range delta.Items {
+		upsert(state.tasks, task.ID, task, task.IsDeleted)
+	}
+	for _, project := range delta.Projects {
+		upsert(state.projects, project.ID, project, project.IsDeleted)
+	}
+	for _, section := range delta.Sections {
+		upsert(state.sections, section.ID, section, section.IsDeleted)
+	}
+	for _, label := range delta.Labels {
+		upsert(state.labels, label.ID, label, label.IsDeleted)
+	}
+	for _, note := range delta.Notes {
+		upsert(state.notes, note.ID, note, note.IsDeleted)
+	}
+
+	state.syncToken = delta.SyncToken
+	s.users[userID] = state
+	return nil
+}
+
+// upsert stores or removes a single resource
+func upsert[T any](m map[string]T, id string, value T, deleted bool) {
+	if deleted {
+		delete(m, id)
+		return
+	}
+	m[id] = value
+}
+
+// Snapshot returns a copy of the mirrored state of a user
+func (s *MemoryStore) Snapshot(ctx context.Context, userID string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.users[userID]
+	if !ok {
+		state = newUserState()
+	}
+
+	return &Snapshot{
+		SyncToken: state.syncToken,
+		Tasks:     values(state.tasks),
+		Projects:  values(state.projects),
+		Sections:  values(state.sections),
+		Labels:    values(state.labels),
+		Notes:     values(state.notes),
+	}, nil
+}
+
+// values returns the values of a map ordered by key
+func values[T any](m map[string]T) []T {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]T, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, m[k])
+	}
+	return result
+}
+
+// Users returns the IDs of all users with mirrored state
+func (s *MemoryStore) Users(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]string, 0, len(s.users))
+	for userID := range s.users {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+	return users, nil
+}
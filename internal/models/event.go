@@ -0,0 +1,149 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// WebhookEvent is a Todoist webhook with its event data decoded into the matching resource.
+// Exactly one of the resource fields is set for known event types.
+type WebhookEvent struct {
+	Name    string
+	UserID  string
+	Version string
+
+	Item    *api.Task
+	Note    *api.Comment
+	Project *api.Project
+	Section *api.Section
+	Label   *api.Label
+}
+
+// Resource returns the resource part of the event name, e.g. "item" for "item:added"
+func (e *WebhookEvent) Resource() string {
+	resource, _, _ := strings.Cut(e.Name, ":")
+	return resource
+}
+
+// MatchesEventName reports whether an event name matches one of the patterns.
+// A pattern is an exact event name, a prefix ending in "*" such as "item:*", or "*" for every event.
+func MatchesEventName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWebhookRequest decodes a webhook body. Todoist sends event_data as a JSON object,
+// while our own clients send it as a JSON-encoded string; both are accepted and the
+// event data is normalized to a JSON string.
+func ParseWebhookRequest(body []byte) (*apiv1.TodoistWebhookRequest, error) {
+	var payload struct {
+		EventName string          `json:"event_name"`
+		UserID    json.RawMessage `json:"user_id"`
+		EventData json.RawMessage `json:"event_data"`
+		Version   string          `json:"version"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook payload: %w", err)
+	}
+
+	userID, err := stringOrNumber(payload.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode user_id: %w", err)
+	}
+
+	eventData, err := stringOrRaw(payload.EventData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode event_data: %w", err)
+	}
+
+	return &apiv1.TodoistWebhookRequest{
+		EventName: payload.EventName,
+		UserId:    userID,
+		EventData: eventData,
+		Version:   payload.Version,
+	}, nil
+}
+
+// stringOrNumber decodes a JSON string or number into a string
+func stringOrNumber(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", err
+	}
+	return n.String(), nil
+}
+
+// stringOrRaw decodes a JSON string, or returns any other JSON value verbatim
+func stringOrRaw(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	return string(raw), nil
+}
+
+// DecodeWebhookEvent decodes the event data of a webhook according to its event type.
+// Unknown event types are returned without a resource.
+func DecodeWebhookEvent(request *apiv1.TodoistWebhookRequest) (*WebhookEvent, error) {
+	event := &WebhookEvent{
+		Name:    request.EventName,
+		UserID:  request.UserId,
+		Version: request.Version,
+	}
+	if request.EventData == "" {
+		return event, nil
+	}
+
+	var target interface{}
+	switch event.Resource() {
+	case "item":
+		event.Item = &api.Task{}
+		target = event.Item
+	case "note":
+		event.Note = &api.Comment{}
+		target = event.Note
+	case "project":
+		event.Project = &api.Project{}
+		target = event.Project
+	case "section":
+		event.Section = &api.Section{}
+		target = event.Section
+	case "label":
+		event.Label = &api.Label{}
+		target = event.Label
+	}
+	if target == nil {
+		return event, nil
+	}
+
+	if err := json.Unmarshal([]byte(request.EventData), target); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event data: %w", event.Name, err)
+	}
+	return event, nil
+}
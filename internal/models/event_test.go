@@ -0,0 +1,50 @@
+package models
+
+import "testing"
+
+// TestParseWebhookRequest tests that event_data is accepted both as an object and as a JSON string
+func TestParseWebhookRequest(t *testing.T) {
+	bodies := []string{
+		`{"event_name": "item:added", "user_id": 42, "event_data": {"id": "7", "content": "Buy milk", "labels": ["shop"]}, "version": "9"}`,
+		`{"event_name": "item:added", "user_id": "42", "event_data": "{\"id\": \"7\", \"content\": \"Buy milk\", \"labels\": [\"shop\"]}", "version": "9"}`,
+	}
+
+	for _, body := range bodies {
+		request, err := ParseWebhookRequest([]byte(body))
+		if err != nil {
+			t.Fatalf("ParseWebhookRequest failed: %v", err)
+		}
+		if request.UserId != "42" {
+			t.Errorf("UserId = %s, want 42", request.UserId)
+		}
+
+		event, err := DecodeWebhookEvent(request)
+		if err != nil {
+			t.Fatalf("DecodeWebhookEvent failed: %v", err)
+		}
+		if event.Item == nil || event.Item.ID != "7" || event.Item.Content != "Buy milk" || len(event.Item.Labels) != 1 {
+			t.Errorf("Item = %+v, want the decoded task", event.Item)
+		}
+	}
+}
+
+// TestDecodeWebhookEventResources tests that each resource type is decoded into its own field
+func TestDecodeWebhookEventResources(t *testing.T) {
+	request, _ := ParseWebhookRequest([]byte(`{"event_name": "note:added", "user_id": "1", "event_data": {"id": "3", "item_id": "7", "content": "Done?"}}`))
+	event, err := DecodeWebhookEvent(request)
+	if err != nil {
+		t.Fatalf("DecodeWebhookEvent failed: %v", err)
+	}
+	if event.Item != nil || event.Note == nil || event.Note.ItemID != "7" {
+		t.Errorf("event = %+v, want only the note decoded", event)
+	}
+
+	request, _ = ParseWebhookRequest([]byte(`{"event_name": "reminder:fired", "user_id": "1", "event_data": {"id": "9"}}`))
+	event, err = DecodeWebhookEvent(request)
+	if err != nil {
+		t.Fatalf("DecodeWebhookEvent failed: %v", err)
+	}
+	if event.Resource() != "reminder" || event.Item != nil || event.Note != nil {
+		t.Errorf("event = %+v, want an unknown event without a resource", event)
+	}
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/quick"
+
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// webhookSeeds are bodies the fuzz targets start from: real deliveries, both event_data forms,
+// numeric and string user IDs, and a few malformed bodies
+var webhookSeeds = []string{
+	`{"event_name": "item:added", "user_id": 42, "event_data": {"id": "7", "content": "Buy milk", "labels": ["shop"]}, "version": "9"}`,
+	`{"event_name": "item:added", "user_id": "42", "event_data": "{\"id\": \"7\", \"content\": \"Buy milk\"}", "version": "9"}`,
+	`{"event_name": "note:added", "user_id": "1", "event_data": {"id": "3", "item_id": "7", "content": "Done?"}}`,
+	`{"event_name": "project:updated", "user_id": 1, "event_data": {"id": "2", "name": "Work", "is_archived": true}}`,
+	`{"event_name": "section:deleted", "event_data": {"id": "5", "project_id": "2"}}`,
+	`{"event_name": "label:added", "user_id": null, "event_data": null}`,
+	`{"event_name": "reminder:fired", "user_id": 1e3, "event_data": [1, 2, 3]}`,
+	`{"event_name": "item:added", "event_data": {"id": 7}}`,
+	`{"event_name": "item:added", "event_data": "not json"}`,
+	`{"user_id": {"id": 1}}`,
+	`[]`,
+	`null`,
+	`{`,
+	``,
+}
+
+// FuzzParseWebhookRequest tests that decoding webhook bodies never panics, only accepts valid
+// JSON, and hands out event data that later decoding can rely on
+func FuzzParseWebhookRequest(f *testing.F) {
+	for _, seed := range webhookSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		request, err := ParseWebhookRequest(body)
+		if err != nil {
+			return
+		}
+		if !json.Valid(body) {
+			t.Fatalf("ParseWebhookRequest accepted invalid JSON %q", body)
+		}
+
+		// Event data given as a JSON value rather than a string is passed on verbatim, so
+		// it is valid JSON
+		var payload struct {
+			EventData json.RawMessage `json:"event_data"`
+		}
+		json.Unmarshal(body, &payload)
+		if len(payload.EventData) > 0 && payload.EventData[0] != '"' && request.EventData != "" && !json.Valid([]byte(request.EventData)) {
+			t.Errorf("event data %q from %q is not JSON", request.EventData, body)
+		}
+
+		// Every parsed request can be decoded without panicking
+		DecodeWebhookEvent(request)
+	})
+}
+
+// FuzzDecodeWebhookEvent tests that event data of any shape decodes into at most the resource
+// its event name selects, and never panics
+func FuzzDecodeWebhookEvent(f *testing.F) {
+	f.Add("item:added", `{"id": "7", "content": "Buy milk", "due": {"date": "2024-03-01"}}`)
+	f.Add("note:updated", `{"id": "3", "item_id": "7"}`)
+	f.Add("project:archived", `{"id": "2", "child_order": 1}`)
+	f.Add("section:added", `{"id": "5"}`)
+	f.Add("label:deleted", `{"id": "9", "is_favorite": "yes"}`)
+	f.Add("item", `{}`)
+	f.Add(":added", `{"id": "1"}`)
+	f.Add("reminder:fired", `{"id": "4"}`)
+	f.Add("item:added", `[]`)
+	f.Add("item:added", `{"id": 7}`)
+	f.Add("item:added", `{"labels": "shop"}`)
+	f.Add("item:added", ``)
+	f.Fuzz(func(t *testing.T, name, data string) {
+		request := &apiv1.TodoistWebhookRequest{EventName: name, UserId: "1", EventData: data}
+		known := map[string]bool{"item": true, "note": true, "project": true, "section": true, "label": true}[(&WebhookEvent{Name: name}).Resource()]
+		event, err := DecodeWebhookEvent(request)
+		if err != nil {
+			// Only the event data of known resources is decoded, so only they can fail
+			if !known || data == "" {
+				t.Errorf("DecodeWebhookEvent(%q, %q) = %v, want no error for an event without a resource", name, data, err)
+			}
+			return
+		}
+
+		decoded := 0
+		for _, set := range []bool{event.Item != nil, event.Note != nil, event.Project != nil, event.Section != nil, event.Label != nil} {
+			if set {
+				decoded++
+			}
+		}
+		switch {
+		case decoded > 1:
+			t.Errorf("DecodeWebhookEvent(%q, %q) decoded %d resources", name, data, decoded)
+		case decoded == 1 && (!known || data == ""):
+			t.Errorf("DecodeWebhookEvent(%q, %q) decoded a resource for an unknown event", name, data)
+		case decoded == 0 && known && data != "":
+			t.Errorf("DecodeWebhookEvent(%q, %q) decoded no resource", name, data)
+		}
+		if event.Name != name || event.UserID != "1" {
+			t.Errorf("DecodeWebhookEvent(%q, %q) = %+v, want the request's name and user", name, data, event)
+		}
+	})
+}
+
+// TestEventDataForms tests the property that event data sent as an object and as a
+// JSON-encoded string parse to the same request
+func TestEventDataForms(t *testing.T) {
+	property := func(name, userID, content string, priority int8, labels []string) bool {
+		data, _ := json.Marshal(map[string]interface{}{"id": "7", "content": content, "priority": priority, "labels": labels})
+		asString, _ := json.Marshal(string(data))
+		envelope := func(eventData []byte) []byte {
+			body, _ := json.Marshal(map[string]interface{}{"event_name": name, "user_id": userID, "event_data": json.RawMessage(eventData), "version": "9"})
+			return body
+		}
+
+		fromObject, err := ParseWebhookRequest(envelope(data))
+		if err != nil {
+			t.Logf("object form: %v", err)
+			return false
+		}
+		fromString, err := ParseWebhookRequest(envelope(asString))
+		if err != nil {
+			t.Logf("string form: %v", err)
+			return false
+		}
+		return fromObject.EventName == name && fromObject.UserId == userID &&
+			fromObject.EventData == fromString.EventData &&
+			fromObject.UserId == fromString.UserId
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// CheckFunc takes a token for a call of a full gRPC method and returns the decision. Calls
+// that are not limited are allowed.
+type CheckFunc func(ctx context.Context, method string) Decision
+
+// UnaryServerInterceptor refuses unary calls that check does not allow
+func UnaryServerInterceptor(check CheckFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkCall(ctx, info.FullMethod, check); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor refuses streaming calls that check does not allow
+func StreamServerInterceptor(check CheckFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkCall(stream.Context(), info.FullMethod, check); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// checkCall returns RESOURCE_EXHAUSTED for refused calls, with the wait in a retry-after header
+// and as RetryInfo in the status details
+func checkCall(ctx context.Context, method string, check CheckFunc) error {
+	decision := check(ctx, method)
+	if decision.Allowed {
+		return nil
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(decision.RetryAfterSeconds())))
+	st := status.Newf(codes.ResourceExhausted, "rate limit exceeded; retry in %ds", decision.RetryAfterSeconds())
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(decision.RetryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many calls MemoryLimiter handles between sweeps of idle buckets
+const sweepEvery = 1024
+
+// MemoryLimiter implements Limiter with token buckets in memory
+type MemoryLimiter struct {
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// NewMemoryLimiter creates an in-memory limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{Now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from the bucket of key
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.Now()
+
+	m.calls++
+	if m.calls%sweepEvery == 0 {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		m.buckets[key] = b
+	}
+	b.refill(now)
+
+	decision := Decision{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) / limit.Rate() * float64(time.Second))
+	}
+	decision.Remaining = int(math.Floor(b.tokens))
+	return decision, nil
+}
+
+// refill adds the tokens accrued since the last update
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed.Seconds()*b.limit.Rate())
+		b.updated = now
+	}
+}
+
+// sweep drops the buckets that have refilled completely, which are the same as new ones
+func (m *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if b.refill(now); b.tokens >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of buckets held
+func (m *MemoryLimiter) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
@@ -0,0 +1,142 @@
+// Package ratelimit throttles clients with token buckets. A Limiter keeps one bucket per key,
+// such as an API key, user or IP address, and each route class has its own Limit. The in-memory
+// MemoryLimiter serves a single instance; a distributed backend implements Limiter to share
+// buckets between instances.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidLimit is returned for limits that cannot be parsed
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit is a token bucket: it holds up to Burst tokens and refills at Events per Per. Every
+// request takes a token.
+type Limit struct {
+	Events int
+	Per    time.Duration
+	Burst  int
+}
+
+// Rate returns the number of tokens added per second
+func (l Limit) Rate() float64 {
+	return float64(l.Events) / l.Per.Seconds()
+}
+
+// String formats the limit as ParseLimit reads it
+func (l Limit) String() string {
+	unit := map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h"}[l.Per]
+	if unit == "" {
+		unit = l.Per.String()
+	}
+	return fmt.Sprintf("%d/%s:%d", l.Events, unit, l.Burst)
+}
+
+// ParseLimit parses a limit written as events/unit with an optional burst, e.g. "10/s", "60/m:20"
+// or "1000/h". The unit is s, m, h or a Go duration; the burst defaults to the events per unit.
+func ParseLimit(value string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+	events, per, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w %q: want events/unit, e.g. 10/s", ErrInvalidLimit, value)
+	}
+
+	var limit Limit
+	var err error
+	if limit.Events, err = strconv.Atoi(events); err != nil || limit.Events <= 0 {
+		return Limit{}, fmt.Errorf("%w %q: events must be a positive number", ErrInvalidLimit, value)
+	}
+	switch per {
+	case "s":
+		limit.Per = time.Second
+	case "m":
+		limit.Per = time.Minute
+	case "h":
+		limit.Per = time.Hour
+	default:
+		if limit.Per, err = time.ParseDuration(per); err != nil || limit.Per <= 0 {
+			return Limit{}, fmt.Errorf("%w %q: unit must be s, m, h or a duration", ErrInvalidLimit, value)
+		}
+	}
+	limit.Burst = limit.Events
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("%w %q: burst must be a positive number", ErrInvalidLimit, value)
+		}
+	}
+	return limit, nil
+}
+
+// Limits maps route classes to their limit. Classes that are not listed are not limited.
+type Limits map[string]Limit
+
+// ParseLimits parses comma-separated class=limit pairs, e.g. "tasks=10/s:20,webhook=200/s". A
+// limit of "off" removes the class. The pairs override base, which is not modified.
+func ParseLimits(value string, base Limits) (Limits, error) {
+	limits := Limits{}
+	for class, limit := range base {
+		limits[class] = limit
+	}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		class, spec, ok := strings.Cut(pair, "=")
+		class = strings.TrimSpace(class)
+		if !ok || class == "" {
+			return nil, fmt.Errorf("%w %q: want class=limit", ErrInvalidLimit, pair)
+		}
+		if strings.TrimSpace(spec) == "off" {
+			delete(limits, class)
+			continue
+		}
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		limits[class] = limit
+	}
+	return limits, nil
+}
+
+// String formats the limits as ParseLimits reads them, ordered by class
+func (l Limits) String() string {
+	pairs := make([]string, 0, len(l))
+	for class, limit := range l {
+		pairs = append(pairs, class+"="+limit.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Decision is the outcome of taking a token
+type Decision struct {
+	// Allowed reports whether the request may proceed
+	Allowed bool
+
+	// Limit is the size of the bucket and Remaining the whole tokens left in it
+	Limit     int
+	Remaining int
+
+	// RetryAfter is how long until a token is available again when the request was refused
+	RetryAfter time.Duration
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, as sent in Retry-After
+func (d Decision) RetryAfterSeconds() int {
+	return int(math.Ceil(d.RetryAfter.Seconds()))
+}
+
+// Limiter takes tokens from the bucket of a key
+type Limiter interface {
+	// Allow takes a token from the bucket of key, which is created full with the given limit
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
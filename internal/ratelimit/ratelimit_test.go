@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestParseLimit tests the events/unit:burst syntax
+func TestParseLimit(t *testing.T) {
+	valid := map[string]Limit{
+		"10/s":    {Events: 10, Per: time.Second, Burst: 10},
+		"60/m:20": {Events: 60, Per: time.Minute, Burst: 20},
+		"1000/h":  {Events: 1000, Per: time.Hour, Burst: 1000},
+		"5/10s:1": {Events: 5, Per: 10 * time.Second, Burst: 1},
+		" 1/s:3 ": {Events: 1, Per: time.Second, Burst: 3},
+	}
+	for value, want := range valid {
+		if got, err := ParseLimit(value); err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %+v, %v, want %+v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "10", "0/s", "-1/s", "10/fortnight", "10/s:0", "ten/s"} {
+		if _, err := ParseLimit(value); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("ParseLimit(%q) = %v, want ErrInvalidLimit", value, err)
+		}
+	}
+}
+
+// TestParseLimits tests overriding and disabling the limits of classes
+func TestParseLimits(t *testing.T) {
+	base := Limits{"tasks": {Events: 10, Per: time.Second, Burst: 20}, "webhook": {Events: 100, Per: time.Second, Burst: 100}}
+	limits, err := ParseLimits("tasks=1/s, webhook=off,auth=5/m:2", base)
+	if err != nil {
+		t.Fatalf("ParseLimits failed: %v", err)
+	}
+	if got := limits.String(); got != "auth=5/m:2,tasks=1/s:1" {
+		t.Errorf("limits = %s, want auth=5/m:2,tasks=1/s:1", got)
+	}
+	if len(base) != 2 {
+		t.Errorf("base was modified: %s", base)
+	}
+	if _, err := ParseLimits("tasks", base); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("ParseLimits() without a limit = %v, want ErrInvalidLimit", err)
+	}
+}
+
+// TestMemoryLimiter tests taking tokens, refilling and keeping keys apart
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.Now = func() time.Time { return now }
+	limit := Limit{Events: 2, Per: time.Second, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if decision, _ := limiter.Allow(ctx, "a", limit); !decision.Allowed || decision.Remaining != 2-i {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i, decision, 2-i)
+		}
+	}
+	decision, _ := limiter.Allow(ctx, "a", limit)
+	if decision.Allowed || decision.RetryAfter != 500*time.Millisecond || decision.RetryAfterSeconds() != 1 {
+		t.Errorf("request over the burst = %+v, want refused for 500ms", decision)
+	}
+	if decision, _ := limiter.Allow(ctx, "b", limit); !decision.Allowed {
+		t.Errorf("another key = %+v, want allowed", decision)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if decision, _ := limiter.Allow(ctx, "a", limit); !decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("request after refilling a token = %+v, want allowed", decision)
+	}
+	if decision, _ := limiter.Allow(ctx, "a", limit); decision.Allowed {
+		t.Errorf("second request after refilling a token = %+v, want refused", decision)
+	}
+}
+
+// TestMemoryLimiterSweep tests that buckets that refilled are dropped
+func TestMemoryLimiterSweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.Now = func() time.Time { return now }
+	limit := Limit{Events: 1, Per: time.Second, Burst: 1}
+
+	for i := 0; i < sweepEvery-1; i++ {
+		limiter.Allow(ctx, fmt.Sprint(i), limit)
+	}
+	now = now.Add(time.Second)
+	limiter.Allow(ctx, "last", limit)
+	if got := limiter.Len(); got != 1 {
+		t.Errorf("%d buckets after the sweep, want only the last", got)
+	}
+}
+
+// TestUnaryServerInterceptor tests that refused calls get RESOURCE_EXHAUSTED with RetryInfo
+func TestUnaryServerInterceptor(t *testing.T) {
+	allowed := true
+	interceptor := UnaryServerInterceptor(func(ctx context.Context, method string) Decision {
+		return Decision{Allowed: allowed, RetryAfter: 1500 * time.Millisecond}
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}
+
+	if got, err := interceptor(context.Background(), nil, info, handler); err != nil || got != "ok" {
+		t.Errorf("allowed call = %v, %v", got, err)
+	}
+
+	allowed = false
+	_, err := interceptor(context.Background(), nil, info, handler)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("refused call = %v, want ResourceExhausted", err)
+	}
+	details := status.Convert(err).Details()
+	if len(details) != 1 {
+		t.Fatalf("details = %v, want RetryInfo", details)
+	}
+	if info, ok := details[0].(*errdetails.RetryInfo); !ok || info.RetryDelay.AsDuration() != 1500*time.Millisecond {
+		t.Errorf("details = %v, want a retry delay of 1.5s", details)
+	}
+}
@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultIgnoredFields are left out when comparing JSON response bodies, since they differ
+// between runs
+var DefaultIgnoredFields = []string{"request_id"}
+
+// Difference is a mismatch between a baseline result and a replayed one
+type Difference struct {
+	ID       uint64 `json:"id"`
+	Field    string `json:"field"`
+	Baseline string `json:"baseline"`
+	Actual   string `json:"actual"`
+}
+
+// String describes the difference on one line
+func (d Difference) String() string {
+	return fmt.Sprintf("delivery %d: %s: baseline %s, got %s", d.ID, d.Field, d.Baseline, d.Actual)
+}
+
+// Diff compares replayed results with a baseline, matching them by delivery ID. Status codes,
+// transport errors and response bodies are compared; JSON bodies are compared as values, without
+// the ignored object fields at any depth.
+func Diff(baseline, actual *Results, ignore []string) []Difference {
+	byID := map[uint64]Result{}
+	for _, result := range actual.Results {
+		byID[result.ID] = result
+	}
+
+	var differences []Difference
+	seen := map[uint64]bool{}
+	for _, want := range baseline.Results {
+		seen[want.ID] = true
+		got, ok := byID[want.ID]
+		if !ok {
+			differences = append(differences, Difference{ID: want.ID, Field: "result", Baseline: "present", Actual: "missing"})
+			continue
+		}
+		if want.StatusCode != got.StatusCode {
+			differences = append(differences, Difference{
+				ID:       want.ID,
+				Field:    "status_code",
+				Baseline: fmt.Sprint(want.StatusCode),
+				Actual:   fmt.Sprint(got.StatusCode),
+			})
+		}
+		if want.Error != got.Error {
+			differences = append(differences, Difference{ID: want.ID, Field: "error", Baseline: quote(want.Error), Actual: quote(got.Error)})
+		}
+		if wantBody, gotBody := normalizeBody(want.Body, ignore), normalizeBody(got.Body, ignore); wantBody != gotBody {
+			differences = append(differences, Difference{ID: want.ID, Field: "body", Baseline: wantBody, Actual: gotBody})
+		}
+	}
+	for _, result := range actual.Results {
+		if !seen[result.ID] {
+			differences = append(differences, Difference{ID: result.ID, Field: "result", Baseline: "missing", Actual: "present"})
+		}
+	}
+	return differences
+}
+
+// normalizeBody re-encodes a JSON body with sorted keys and without the ignored fields;
+// other bodies are returned quoted
+func normalizeBody(body string, ignore []string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return quote(body)
+	}
+	ignored := map[string]bool{}
+	for _, field := range ignore {
+		ignored[field] = true
+	}
+	normalized, _ := json.Marshal(dropFields(value, ignored))
+	return string(normalized)
+}
+
+// dropFields removes the ignored fields from every object in a decoded JSON value
+func dropFields(value interface{}, ignored map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if ignored[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropFields(field, ignored)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = dropFields(item, ignored)
+		}
+	}
+	return value
+}
+
+// quote formats a string for a difference
+func quote(s string) string {
+	return fmt.Sprintf("%q", s)
+}
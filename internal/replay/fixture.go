@@ -0,0 +1,81 @@
+// Package replay records real webhook deliveries from a running server into fixture files and
+// replays them against any server, comparing the responses with a baseline
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"cherry_backend/internal/events"
+)
+
+// Delivery is a recorded webhook request
+type Delivery struct {
+	// ID is the ID of the event in the server's event store
+	ID         uint64            `json:"id"`
+	EventName  string            `json:"event_name"`
+	UserID     string            `json:"user_id"`
+	ReceivedAt time.Time         `json:"received_at"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+// Fixture is a recording of webhook deliveries, oldest first
+type Fixture struct {
+	RecordedAt time.Time  `json:"recorded_at"`
+	Source     string     `json:"source,omitempty"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// DeliveryOf converts a stored event into a recorded delivery. It reports false for events
+// without a raw delivery, e.g. those received before recording was possible.
+func DeliveryOf(event *events.Event) (Delivery, bool) {
+	if event.Delivery == nil {
+		return Delivery{}, false
+	}
+	return Delivery{
+		ID:         event.ID,
+		EventName:  event.Name,
+		UserID:     event.UserID,
+		ReceivedAt: event.ReceivedAt,
+		Headers:    event.Delivery.Header,
+		Body:       string(event.Delivery.Body),
+	}, true
+}
+
+// LoadFixture reads a fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	var fixture Fixture
+	if err := loadJSON(path, &fixture); err != nil {
+		return nil, err
+	}
+	return &fixture, nil
+}
+
+// Save writes the fixture to a file
+func (f *Fixture) Save(path string) error {
+	return saveJSON(path, f)
+}
+
+// loadJSON decodes a JSON file
+func loadJSON(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// saveJSON writes an indented JSON file
+func saveJSON(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
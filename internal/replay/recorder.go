@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cherry_backend/pkg/api"
+)
+
+// recordPageSize is how many deliveries are fetched per request
+const recordPageSize = 100
+
+// WebhooksPage is the response of GET /admin/webhooks
+type WebhooksPage struct {
+	Webhooks []Delivery `json:"webhooks"`
+
+	// NextAfter is the cursor of the next page: the ID of the last event read, which may be
+	// newer than the last webhook if some events had no recorded delivery
+	NextAfter uint64 `json:"next_after"`
+}
+
+// Recorder fetches the deliveries kept in the event store of a running server through the
+// admin API
+type Recorder struct {
+	generator  *api.ClientGenerator
+	adminToken string
+}
+
+// NewRecorder creates a recorder for the server at baseURL
+func NewRecorder(baseURL, adminToken string) *Recorder {
+	return &Recorder{generator: api.NewClientGenerator(baseURL), adminToken: adminToken}
+}
+
+// Record fetches the deliveries after an event ID, of one user or of all users if userID is
+// empty, up to limit deliveries (zero for all)
+func (r *Recorder) Record(ctx context.Context, userID string, after uint64, limit int) (*Fixture, error) {
+	fixture := &Fixture{RecordedAt: time.Now().UTC(), Source: r.generator.BaseURL, Deliveries: []Delivery{}}
+	for limit == 0 || len(fixture.Deliveries) < limit {
+		pageSize := recordPageSize
+		if limit > 0 && limit-len(fixture.Deliveries) < pageSize {
+			pageSize = limit - len(fixture.Deliveries)
+		}
+		page, err := r.page(ctx, userID, after, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		fixture.Deliveries = append(fixture.Deliveries, page.Webhooks...)
+		if page.NextAfter <= after {
+			break
+		}
+		after = page.NextAfter
+	}
+	return fixture, nil
+}
+
+// page fetches one page of deliveries
+func (r *Recorder) page(ctx context.Context, userID string, after uint64, limit int) (*WebhooksPage, error) {
+	request := &api.Request{
+		Method: http.MethodGet,
+		Path:   "/admin/webhooks",
+		Query: map[string]string{
+			"after": strconv.FormatUint(after, 10),
+			"limit": strconv.Itoa(limit),
+		},
+		Header: map[string]string{"Authorization": "Bearer " + r.adminToken},
+	}
+	if userID != "" {
+		request.Query["user_id"] = userID
+	}
+
+	resp, err := r.generator.DoContext(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, api.NewAPIError(resp)
+	}
+	var page WebhooksPage
+	if err := json.Unmarshal(resp.Body, &page); err != nil {
+		return nil, fmt.Errorf("failed to decode webhooks: %w", err)
+	}
+	return &page, nil
+}
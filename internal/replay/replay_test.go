@@ -0,0 +1,157 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/replay"
+	"cherry_backend/internal/server"
+)
+
+// newTestServer starts a server that verifies webhook signatures with secret
+func newTestServer(t *testing.T, secret string) *httptest.Server {
+	t.Helper()
+	t.Setenv("CHERRY_LOG_PATH", t.TempDir())
+	t.Setenv("CHERRY_ADMIN_TOKEN", "admin")
+	t.Setenv("TODOIST_CLIENT_SECRET", secret)
+	s := server.NewServer()
+	t.Cleanup(s.Fanout.Close)
+	httpServer := httptest.NewServer(s.Router)
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+// deliver posts a signed webhook
+func deliver(t *testing.T, url, secret, body string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url+"/webhooks/todoist", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Todoist-Webhooks")
+	req.Header.Set(replay.SignatureHeader, replay.Sign([]byte(body), secret))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /webhooks/todoist failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /webhooks/todoist = %d, want 200", resp.StatusCode)
+	}
+}
+
+// TestRecordAndReplay records deliveries from one server and replays them, re-signed, to another
+func TestRecordAndReplay(t *testing.T) {
+	source := newTestServer(t, "old-secret")
+	bodies := []string{
+		`{"event_name":"item:added","user_id":"1","event_data":{"id":"10","content":"Buy milk"},"version":"9"}`,
+		`{"event_name":"item:completed","user_id":"1","event_data":{"id":"10","content":"Buy milk"},"version":"9"}`,
+		`{"event_name":"item:added","user_id":"2","event_data":{"id":"20","content":"Call mom"},"version":"9"}`,
+	}
+	for _, body := range bodies {
+		deliver(t, source.URL, "old-secret", body)
+	}
+
+	fixture, err := replay.NewRecorder(source.URL, "admin").Record(context.Background(), "1", 0, 0)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if len(fixture.Deliveries) != 2 {
+		t.Fatalf("recorded %d deliveries, want the 2 of user 1", len(fixture.Deliveries))
+	}
+	recorded := fixture.Deliveries[0]
+	if recorded.Body != bodies[0] || recorded.Headers["User-Agent"] != "Todoist-Webhooks" || recorded.Headers[http.CanonicalHeaderKey(replay.SignatureHeader)] == "" {
+		t.Errorf("recorded delivery = %+v, want the exact body and headers", recorded)
+	}
+
+	// Round trip the fixture through a file
+	path := t.TempDir() + "/fixture.json"
+	if err := fixture.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if fixture, err = replay.LoadFixture(path); err != nil {
+		t.Fatalf("LoadFixture failed: %v", err)
+	}
+
+	// The recorded signature is rejected by a server with another secret unless re-signed
+	target := newTestServer(t, "new-secret")
+	replayer := replay.NewReplayer(target.URL + "/webhooks/todoist")
+	replayer.Speed = 0
+	unsigned, err := replayer.Replay(context.Background(), fixture)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if unsigned.Results[0].StatusCode != http.StatusUnauthorized {
+		t.Errorf("replay with the recorded signature = %d, want 401", unsigned.Results[0].StatusCode)
+	}
+
+	replayer.Secret = "new-secret"
+	baseline, err := replayer.Replay(context.Background(), fixture)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	for _, result := range baseline.Results {
+		if result.StatusCode != http.StatusOK {
+			t.Errorf("re-signed replay of %d = %d %s, want 200", result.ID, result.StatusCode, result.Body)
+		}
+	}
+
+	// Replaying again matches the baseline; the unsigned run does not
+	again, _ := replayer.Replay(context.Background(), fixture)
+	if differences := replay.Diff(baseline, again, replay.DefaultIgnoredFields); len(differences) != 0 {
+		t.Errorf("Diff() = %v, want no differences", differences)
+	}
+	if differences := replay.Diff(baseline, unsigned, replay.DefaultIgnoredFields); len(differences) == 0 {
+		t.Error("Diff() of a failing run found no differences")
+	}
+}
+
+// TestReplayTiming tests that gaps between deliveries are scaled and capped
+func TestReplayTiming(t *testing.T) {
+	var received []time.Time
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, time.Now())
+	}))
+	defer target.Close()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fixture := &replay.Fixture{Deliveries: []replay.Delivery{
+		{ID: 1, ReceivedAt: start, Body: "{}"},
+		{ID: 2, ReceivedAt: start.Add(200 * time.Millisecond), Body: "{}"},
+		{ID: 3, ReceivedAt: start.Add(time.Hour), Body: "{}"},
+	}}
+
+	replayer := replay.NewReplayer(target.URL)
+	replayer.Speed = 2
+	replayer.MaxGap = 300 * time.Millisecond
+	if _, err := replayer.Replay(context.Background(), fixture); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(received) != 3 {
+		t.Fatalf("received %d deliveries, want 3", len(received))
+	}
+	if gap := received[1].Sub(received[0]); gap < 100*time.Millisecond || gap > 250*time.Millisecond {
+		t.Errorf("gap at speed 2 = %v, want about 100ms", gap)
+	}
+	if gap := received[2].Sub(received[1]); gap < 300*time.Millisecond || gap > time.Second {
+		t.Errorf("capped gap = %v, want about 300ms", gap)
+	}
+}
+
+// TestDiffIgnoresFields tests that ignored fields do not cause differences
+func TestDiffIgnoresFields(t *testing.T) {
+	baseline := &replay.Results{Results: []replay.Result{
+		{ID: 1, StatusCode: 400, Body: `{"error":{"code":"invalid_argument","request_id":"a"}}`},
+	}}
+	actual := &replay.Results{Results: []replay.Result{
+		{ID: 1, StatusCode: 400, Body: `{"error": {"request_id": "b", "code": "invalid_argument"}}`},
+	}}
+	if differences := replay.Diff(baseline, actual, []string{"request_id"}); len(differences) != 0 {
+		t.Errorf("Diff() = %v, want no differences", differences)
+	}
+	if differences := replay.Diff(baseline, actual, nil); len(differences) != 1 {
+		t.Errorf("Diff() without ignored fields = %v, want one body difference", differences)
+	}
+}
@@ -0,0 +1,171 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature Todoist sends with its webhooks
+const SignatureHeader = "X-Todoist-Hmac-SHA256"
+
+// Result is the response of the server to one replayed delivery
+type Result struct {
+	ID         uint64 `json:"id"`
+	EventName  string `json:"event_name"`
+	StatusCode int    `json:"status_code"`
+	Body       string `json:"body"`
+	Error      string `json:"error,omitempty"`
+
+	// LatencyMS is the time until the response was read, in milliseconds
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Results are the responses of one replay run, saved as a baseline for later runs
+type Results struct {
+	Target  string    `json:"target"`
+	Started time.Time `json:"started"`
+	Results []Result  `json:"results"`
+}
+
+// LoadResults reads a results file
+func LoadResults(path string) (*Results, error) {
+	var results Results
+	if err := loadJSON(path, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// Save writes the results to a file
+func (r *Results) Save(path string) error {
+	return saveJSON(path, r)
+}
+
+// Replayer sends recorded deliveries to a webhook URL
+type Replayer struct {
+	// URL is the webhook endpoint, e.g. http://localhost:8080/webhooks/todoist
+	URL string
+
+	// Secret re-signs every body with this client secret; empty keeps the recorded signature
+	Secret string
+
+	// Speed scales the recorded gaps between deliveries: 1 preserves the original timing,
+	// 10 replays ten times faster and 0 sends the deliveries back to back
+	Speed float64
+
+	// MaxGap caps the wait between two deliveries; zero leaves it uncapped
+	MaxGap time.Duration
+
+	HTTPClient *http.Client
+
+	// sleep waits, returning early if the context is done; replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewReplayer creates a replayer that preserves the original timing
+func NewReplayer(url string) *Replayer {
+	return &Replayer{
+		URL:        url,
+		Speed:      1,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		sleep:      sleepContext,
+	}
+}
+
+// Replay sends the deliveries of a fixture in order. Transport errors are recorded in the
+// results and do not stop the replay; only cancelling the context does.
+func (r *Replayer) Replay(ctx context.Context, fixture *Fixture) (*Results, error) {
+	results := &Results{Target: r.URL, Started: time.Now().UTC(), Results: []Result{}}
+	for i, delivery := range fixture.Deliveries {
+		if i > 0 {
+			if err := r.sleep(ctx, r.gap(fixture.Deliveries[i-1], delivery)); err != nil {
+				return results, err
+			}
+		}
+		results.Results = append(results.Results, r.send(ctx, delivery))
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// gap returns the wait before a delivery
+func (r *Replayer) gap(previous, next Delivery) time.Duration {
+	if r.Speed <= 0 {
+		return 0
+	}
+	gap := time.Duration(float64(next.ReceivedAt.Sub(previous.ReceivedAt)) / r.Speed)
+	if gap < 0 {
+		gap = 0
+	}
+	if r.MaxGap > 0 && gap > r.MaxGap {
+		gap = r.MaxGap
+	}
+	return gap
+}
+
+// send posts one delivery with its recorded headers
+func (r *Replayer) send(ctx context.Context, delivery Delivery) Result {
+	result := Result{ID: delivery.ID, EventName: delivery.EventName}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader([]byte(delivery.Body)))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for name, value := range delivery.Headers {
+		req.Header.Set(name, value)
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign([]byte(delivery.Body), r.Secret))
+	}
+
+	start := time.Now()
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	result.StatusCode = resp.StatusCode
+	result.Body = string(body)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to read response: %v", err)
+	}
+	return result
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of a body, as verified by the webhook handler
+func Sign(body []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sleepContext waits for d or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
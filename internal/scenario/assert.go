@@ -0,0 +1,169 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// matcherKeys are the keys of single-key maps that are matchers rather than expected objects
+var matcherKeys = map[string]bool{"len": true, "contains": true, "exists": true, "not": true}
+
+// matcherOf returns the matcher key and argument of an expected value, if it is a matcher
+func matcherOf(expected interface{}) (string, interface{}, bool) {
+	m, ok := expected.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, false
+	}
+	for key, argument := range m {
+		if matcherKeys[key] {
+			return key, argument, true
+		}
+	}
+	return "", nil, false
+}
+
+// validateMatcher checks the arguments of a matcher
+func validateMatcher(expected interface{}) error {
+	key, argument, ok := matcherOf(expected)
+	if !ok {
+		return nil
+	}
+	switch key {
+	case "len":
+		if _, ok := argument.(int); !ok {
+			return fmt.Errorf("len must be a number")
+		}
+	case "exists":
+		if _, ok := argument.(bool); !ok {
+			return fmt.Errorf("exists must be true or false")
+		}
+	}
+	return nil
+}
+
+// lookup resolves a dotted path such as webhooks.0.event_name in a decoded JSON value. Strings
+// holding JSON documents, like recorded webhook bodies, are decoded when the path continues
+// into them.
+func lookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" || path == "." {
+		return value, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		if s, ok := value.(string); ok {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+				return nil, false
+			}
+			value = decoded
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			field, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = field
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// check asserts that the value at path of a decoded JSON document matches the expectation
+func check(document interface{}, path string, expected interface{}) error {
+	actual, found := lookup(document, path)
+	key, argument, isMatcher := matcherOf(expected)
+	if isMatcher && key == "exists" {
+		if found != argument.(bool) {
+			return fmt.Errorf("%s: exists = %v, want %v", path, found, argument)
+		}
+		return nil
+	}
+	if !found {
+		return fmt.Errorf("%s: not found", path)
+	}
+	if !isMatcher {
+		if !equal(actual, expected) {
+			return fmt.Errorf("%s = %s, want %s", path, format(actual), format(expected))
+		}
+		return nil
+	}
+
+	switch key {
+	case "len":
+		var n int
+		switch v := actual.(type) {
+		case []interface{}:
+			n = len(v)
+		case map[string]interface{}:
+			n = len(v)
+		case string:
+			n = len(v)
+		default:
+			return fmt.Errorf("%s = %s has no length", path, format(actual))
+		}
+		if n != argument.(int) {
+			return fmt.Errorf("%s: len = %d, want %d", path, n, argument)
+		}
+	case "contains":
+		switch v := actual.(type) {
+		case string:
+			if s, ok := argument.(string); !ok || !strings.Contains(v, s) {
+				return fmt.Errorf("%s = %s, want it to contain %s", path, format(actual), format(argument))
+			}
+		case []interface{}:
+			for _, item := range v {
+				if equal(item, argument) {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s = %s, want it to contain %s", path, format(actual), format(argument))
+		default:
+			return fmt.Errorf("%s = %s cannot contain values", path, format(actual))
+		}
+	case "not":
+		if equal(actual, argument) {
+			return fmt.Errorf("%s = %s, want another value", path, format(actual))
+		}
+	}
+	return nil
+}
+
+// equal compares a decoded JSON value with an expected YAML value. Both are converted to JSON
+// first, so that 1 equals 1.0 and string keys compare alike; strings also match numbers and
+// booleans spelled the same, since templates always render strings.
+func equal(actual, expected interface{}) bool {
+	if s, ok := expected.(string); ok {
+		if _, isString := actual.(string); !isString && actual != nil {
+			return format(actual) == s
+		}
+	}
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(actual, normalized)
+}
+
+// format renders a value for messages
+func format(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,268 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cherry_backend/internal/replay"
+	"cherry_backend/pkg/api"
+)
+
+// DefaultWebhookPath is where webhook steps are posted to
+const DefaultWebhookPath = "/webhooks/todoist"
+
+// DefaultPollInterval is how often steps with eventually are retried
+const DefaultPollInterval = 100 * time.Millisecond
+
+// maxBodyInMessage caps how much of a response body is quoted in failures
+const maxBodyInMessage = 300
+
+// Runner runs scenarios against the server at BaseURL
+type Runner struct {
+	BaseURL     string
+	WebhookPath string
+
+	// Secret signs webhook bodies like Todoist does with the client secret; when empty,
+	// webhooks are sent unsigned
+	Secret string
+
+	// AdminToken authenticates request steps marked admin
+	AdminToken string
+
+	PollInterval time.Duration
+
+	now func() time.Time
+}
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	Name       string
+	StatusCode int
+	Attempts   int
+	Duration   time.Duration
+	Err        error
+}
+
+// Report is the outcome of a scenario run. Steps after the first failure are not run.
+type Report struct {
+	Scenario string
+	Steps    []StepResult
+	Duration time.Duration
+}
+
+// Passed reports whether every step of the scenario passed
+func (r *Report) Passed() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRunner creates a runner for the server at baseURL
+func NewRunner(baseURL string) *Runner {
+	return &Runner{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		WebhookPath:  DefaultWebhookPath,
+		PollInterval: DefaultPollInterval,
+		now:          time.Now,
+	}
+}
+
+// Run runs the steps of a scenario in order until one fails
+func (r *Runner) Run(ctx context.Context, scenario *Scenario) *Report {
+	start := r.now()
+	report := &Report{Scenario: scenario.Name}
+	defer func() { report.Duration = time.Since(start) }()
+
+	generator := api.NewClientGenerator(r.BaseURL)
+	state := newTemplateState(start)
+	for name, value := range scenario.Vars {
+		rendered, err := state.render(value)
+		if err != nil {
+			report.Steps = append(report.Steps, StepResult{Name: "vars", Err: fmt.Errorf("%s: %w", name, err)})
+			return report
+		}
+		state.vars[name] = rendered
+	}
+
+	for i := range scenario.Steps {
+		result := r.runStep(ctx, generator, state, &scenario.Steps[i])
+		report.Steps = append(report.Steps, result)
+		if result.Err != nil {
+			break
+		}
+	}
+	return report
+}
+
+// runStep sends a step, retrying it while its expectations fail if it has eventually set
+func (r *Runner) runStep(ctx context.Context, generator *api.ClientGenerator, state *templateState, step *Step) StepResult {
+	start := time.Now()
+	result := StepResult{Name: step.Name}
+	deadline := start.Add(step.Eventually)
+	for {
+		result.Attempts++
+		result.StatusCode, result.Err = r.attempt(ctx, generator, state, step)
+		if result.Err == nil || step.Eventually <= 0 || time.Now().After(deadline) || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.PollInterval):
+		}
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// attempt sends a step once, checks its expectations and captures its vars
+func (r *Runner) attempt(ctx context.Context, generator *api.ClientGenerator, state *templateState, step *Step) (int, error) {
+	var request *api.Request
+	var err error
+	if step.Webhook != nil {
+		request, err = r.webhookRequest(state, step.Webhook)
+	} else {
+		request, err = r.apiRequest(state, step.Request)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := generator.DoContext(ctx, request)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.verify(state, step, resp); err != nil {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookRequest builds a signed webhook delivery
+func (r *Runner) webhookRequest(state *templateState, webhook *WebhookStep) (*api.Request, error) {
+	event, err := state.render(webhook.Event)
+	if err != nil {
+		return nil, fmt.Errorf("event: %w", err)
+	}
+	user, err := state.render(webhook.User)
+	if err != nil {
+		return nil, fmt.Errorf("user: %w", err)
+	}
+	data, err := state.renderValue(webhook.Data)
+	if err != nil {
+		return nil, fmt.Errorf("data: %w", err)
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	version := webhook.Version
+	if version == "" {
+		version = "9"
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"event_name": event,
+		"user_id":    user,
+		"event_data": data,
+		"version":    version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook: %w", err)
+	}
+
+	secret := r.Secret
+	if webhook.Secret != nil {
+		secret = *webhook.Secret
+	}
+	header := map[string]string{"User-Agent": "Todoist-Webhooks"}
+	if secret != "" {
+		header[replay.SignatureHeader] = replay.Sign(body, secret)
+	}
+	return &api.Request{Method: http.MethodPost, Path: r.WebhookPath, Body: json.RawMessage(body), Header: header}, nil
+}
+
+// apiRequest builds an API request
+func (r *Runner) apiRequest(state *templateState, step *RequestStep) (*api.Request, error) {
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	path, err := state.render(step.Path)
+	if err != nil {
+		return nil, fmt.Errorf("path: %w", err)
+	}
+	query, err := state.renderStrings(step.Query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	header, err := state.renderStrings(step.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("headers: %w", err)
+	}
+	if header == nil {
+		header = map[string]string{}
+	}
+	if step.Admin {
+		header["Authorization"] = "Bearer " + r.AdminToken
+	}
+	body, err := state.renderValue(step.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	return &api.Request{Method: method, Path: path, Query: query, Header: header, Body: body}, nil
+}
+
+// verify checks the expectations of a step on a response and captures its vars
+func (r *Runner) verify(state *templateState, step *Step, resp *api.Response) error {
+	if step.Expect.Status != 0 && resp.StatusCode != step.Expect.Status {
+		return fmt.Errorf("status = %d, want %d: %s", resp.StatusCode, step.Expect.Status, quote(resp.Body))
+	}
+	if step.Expect.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("status = %d, want 2xx: %s", resp.StatusCode, quote(resp.Body))
+	}
+	if len(step.Expect.JSON) == 0 && len(step.Capture) == 0 {
+		return nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(resp.Body, &document); err != nil {
+		return fmt.Errorf("response is not JSON: %s", quote(resp.Body))
+	}
+	expectations, err := state.renderValue(step.Expect.JSON)
+	if err != nil {
+		return fmt.Errorf("expect: %w", err)
+	}
+	for path, expected := range expectations.(map[string]interface{}) {
+		if err := check(document, path, expected); err != nil {
+			return err
+		}
+	}
+
+	for name, path := range step.Capture {
+		value, ok := lookup(document, path)
+		if !ok {
+			return fmt.Errorf("capture %s: %s not found", name, path)
+		}
+		if s, ok := value.(string); ok {
+			state.vars[name] = s
+		} else {
+			state.vars[name] = format(value)
+		}
+	}
+	return nil
+}
+
+// quote shortens a response body for failure messages
+func quote(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if len(text) > maxBodyInMessage {
+		text = text[:maxBodyInMessage] + "..."
+	}
+	return text
+}
@@ -0,0 +1,173 @@
+// Package scenario runs user journeys against a running server: YAML files describing
+// sequences of webhooks and API requests, with templated IDs and assertions on the responses
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidScenario is returned for scenario files that cannot be run
+var ErrInvalidScenario = errors.New("invalid scenario")
+
+// Scenario is a named sequence of steps run in order; the first failing step ends it
+type Scenario struct {
+	Name string `yaml:"name"`
+
+	// Vars are available to templates as {{.name}}. Their values are templates themselves,
+	// rendered once before the first step; they may use the template functions but not other vars.
+	Vars map[string]string `yaml:"vars"`
+
+	Steps []Step `yaml:"steps"`
+}
+
+// Step sends a webhook or an API request and checks the response. Exactly one of Webhook and
+// Request is set.
+type Step struct {
+	Name    string       `yaml:"name"`
+	Webhook *WebhookStep `yaml:"webhook"`
+	Request *RequestStep `yaml:"request"`
+	Expect  Expect       `yaml:"expect"`
+
+	// Eventually retries a request step until its expectations hold or this much time has
+	// passed, for state that is updated asynchronously. Webhook steps are never retried.
+	Eventually time.Duration `yaml:"eventually"`
+
+	// Capture stores values of the JSON response in vars, keyed by var name, for later steps
+	Capture map[string]string `yaml:"capture"`
+}
+
+// WebhookStep sends a signed Todoist webhook to the webhook endpoint
+type WebhookStep struct {
+	Event   string                 `yaml:"event"`
+	User    string                 `yaml:"user"`
+	Data    map[string]interface{} `yaml:"data"`
+	Version string                 `yaml:"version"`
+
+	// Secret overrides the secret of the runner for this webhook; an empty string sends it unsigned
+	Secret *string `yaml:"secret"`
+}
+
+// RequestStep sends a request to the API
+type RequestStep struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Query   map[string]string `yaml:"query"`
+	Headers map[string]string `yaml:"headers"`
+	Body    interface{}       `yaml:"body"`
+
+	// Admin authenticates the request with the admin token of the runner
+	Admin bool `yaml:"admin"`
+}
+
+// Expect holds the assertions on a response
+type Expect struct {
+	// Status is the expected status code; zero accepts any 2xx status
+	Status int `yaml:"status"`
+
+	// JSON maps paths in the JSON response (e.g. webhooks.0.event_name) to expected values or
+	// matchers: {len: n}, {contains: value}, {exists: bool} or {not: value}
+	JSON map[string]interface{} `yaml:"json"`
+}
+
+// Load reads a scenario file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scenario, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = path
+	}
+	return scenario, nil
+}
+
+// LoadAll reads scenario files and the .yaml and .yml files of directories, in name order
+// within each directory
+func LoadAll(paths ...string) ([]*Scenario, error) {
+	var scenarios []*Scenario
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			files = files[:0]
+			for _, entry := range entries {
+				ext := strings.ToLower(filepath.Ext(entry.Name()))
+				if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			scenario, err := Load(file)
+			if err != nil {
+				return nil, err
+			}
+			scenarios = append(scenarios, scenario)
+		}
+	}
+	return scenarios, nil
+}
+
+// Parse decodes and validates a YAML scenario. Unknown fields are rejected.
+func Parse(data []byte) (*Scenario, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var scenario Scenario
+	if err := decoder.Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScenario, err)
+	}
+	if err := scenario.validate(); err != nil {
+		return nil, err
+	}
+	return &scenario, nil
+}
+
+// validate checks that every step can be run
+func (s *Scenario) validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("%w: no steps", ErrInvalidScenario)
+	}
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+		switch {
+		case (step.Webhook == nil) == (step.Request == nil):
+			return fmt.Errorf("%w: %s: exactly one of webhook and request must be set", ErrInvalidScenario, step.Name)
+		case step.Webhook != nil && step.Webhook.Event == "":
+			return fmt.Errorf("%w: %s: webhook event is required", ErrInvalidScenario, step.Name)
+		case step.Webhook != nil && step.Eventually > 0:
+			return fmt.Errorf("%w: %s: webhooks cannot be retried with eventually", ErrInvalidScenario, step.Name)
+		case step.Request != nil && step.Request.Path == "":
+			return fmt.Errorf("%w: %s: request path is required", ErrInvalidScenario, step.Name)
+		}
+		for path, expected := range step.Expect.JSON {
+			if err := validateMatcher(expected); err != nil {
+				return fmt.Errorf("%w: %s: %s: %v", ErrInvalidScenario, step.Name, path, err)
+			}
+		}
+	}
+	return nil
+}
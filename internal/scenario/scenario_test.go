@@ -0,0 +1,196 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/server/servertest"
+)
+
+// newTestRunner starts a server and returns a runner for it
+func newTestRunner(t *testing.T) *Runner {
+	t.Helper()
+	ts := servertest.NewTestServer(t)
+	runner := NewRunner(ts.HTTP.URL)
+	runner.Secret = servertest.ClientSecret
+	runner.AdminToken = servertest.AdminToken
+	return runner
+}
+
+// TestExampleScenarios runs the journeys of test/scenarios, which the CI scripts also run
+func TestExampleScenarios(t *testing.T) {
+	scenarios, err := LoadAll("../../test/scenarios")
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no scenarios in test/scenarios")
+	}
+	runner := newTestRunner(t)
+	for _, scenario := range scenarios {
+		report := runner.Run(context.Background(), scenario)
+		for _, step := range report.Steps {
+			if step.Err != nil {
+				t.Errorf("%s: %s: %v", scenario.Name, step.Name, step.Err)
+			}
+		}
+		if len(report.Steps) != len(scenario.Steps) {
+			t.Errorf("%s ran %d of %d steps", scenario.Name, len(report.Steps), len(scenario.Steps))
+		}
+	}
+}
+
+// TestRunStopsAtFirstFailure tests that a failing assertion fails the report and skips later steps
+func TestRunStopsAtFirstFailure(t *testing.T) {
+	scenario, err := Parse([]byte(`
+name: failing
+steps:
+  - name: health
+    request: {path: /health}
+  - name: wrong status
+    request: {path: /admin/webhooks}
+    expect: {status: 200}
+  - name: never run
+    request: {path: /health}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	report := newTestRunner(t).Run(context.Background(), scenario)
+	if report.Passed() {
+		t.Fatal("Passed() = true, want false")
+	}
+	if len(report.Steps) != 2 || report.Steps[1].StatusCode != http.StatusUnauthorized {
+		t.Fatalf("steps = %+v, want to stop at the 401 of the second step", report.Steps)
+	}
+	if !strings.Contains(report.Steps[1].Err.Error(), "status = 401, want 200") {
+		t.Errorf("error = %v, want the status mismatch", report.Steps[1].Err)
+	}
+}
+
+// TestEventually tests that request steps are retried until their expectations hold
+func TestEventually(t *testing.T) {
+	var calls int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Write([]byte(`{"state":"pending"}`))
+			return
+		}
+		w.Write([]byte(`{"state":"done"}`))
+	}))
+	defer target.Close()
+
+	scenario, err := Parse([]byte(`
+steps:
+  - request: {path: /status}
+    eventually: 2s
+    expect:
+      json: {state: done}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	runner := NewRunner(target.URL)
+	runner.PollInterval = 10 * time.Millisecond
+	report := runner.Run(context.Background(), scenario)
+	if !report.Passed() || report.Steps[0].Attempts != 3 {
+		t.Errorf("steps = %+v, want a pass on the third attempt", report.Steps)
+	}
+}
+
+// TestTemplates tests generated IDs, vars and captures
+func TestTemplates(t *testing.T) {
+	var bodies []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodies = append(bodies, r.URL.Path)
+		w.Write([]byte(`{"id":42,"name":"inbox"}`))
+	}))
+	defer target.Close()
+
+	scenario, err := Parse([]byte(`
+vars:
+  project: '{{id "project"}}'
+steps:
+  - request: {path: '/projects/{{.project}}'}
+    capture: {remote: id}
+  - request: {path: '/projects/{{.remote}}/tasks/{{id "task"}}'}
+  - request: {path: '/projects/{{id "project"}}/tasks/{{id "task"}}'}
+    expect:
+      json: {id: '{{.remote}}'}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	report := NewRunner(target.URL).Run(context.Background(), scenario)
+	if !report.Passed() {
+		t.Fatalf("steps = %+v, want a pass", report.Steps)
+	}
+
+	project := strings.TrimPrefix(bodies[0], "/projects/")
+	task := strings.TrimPrefix(bodies[1], "/projects/42/tasks/")
+	if project == "" || task == "" || project == task {
+		t.Errorf("paths = %v, want distinct generated IDs", bodies)
+	}
+	if bodies[2] != "/projects/"+project+"/tasks/"+task {
+		t.Errorf("path = %s, want the IDs of the first requests reused", bodies[2])
+	}
+}
+
+// TestCheck tests the assertions on JSON documents
+func TestCheck(t *testing.T) {
+	document := map[string]interface{}{
+		"count": 2.0,
+		"items": []interface{}{
+			map[string]interface{}{"id": "1", "labels": []interface{}{"work", "urgent"}},
+			map[string]interface{}{"id": "2", "body": `{"event_data":{"priority":4}}`},
+		},
+	}
+	tests := []struct {
+		path     string
+		expected interface{}
+		ok       bool
+	}{
+		{"count", 2, true},
+		{"count", "2", true},
+		{"count", 3, false},
+		{"items", map[string]interface{}{"len": 2}, true},
+		{"items.0.id", "1", true},
+		{"items.0.labels", map[string]interface{}{"contains": "urgent"}, true},
+		{"items.0.labels", map[string]interface{}{"contains": "home"}, false},
+		{"items.1.body.event_data.priority", 4, true},
+		{"items.1.body", map[string]interface{}{"contains": "priority"}, true},
+		{"items.2", map[string]interface{}{"exists": false}, true},
+		{"items.2.id", "3", false},
+		{"items.1.id", map[string]interface{}{"not": "1"}, true},
+	}
+	for _, test := range tests {
+		err := check(document, test.path, test.expected)
+		if (err == nil) != test.ok {
+			t.Errorf("check(%s, %v) = %v, want ok %v", test.path, test.expected, err, test.ok)
+		}
+	}
+}
+
+// TestParseRejectsInvalidScenarios tests the validation of scenario files
+func TestParseRejectsInvalidScenarios(t *testing.T) {
+	tests := map[string]string{
+		"no steps":         `name: empty`,
+		"unknown field":    "steps:\n  - request: {path: /health}\n    retries: 3",
+		"no action":        "steps:\n  - name: nothing",
+		"two actions":      "steps:\n  - request: {path: /health}\n    webhook: {event: item:added}",
+		"retried webhook":  "steps:\n  - webhook: {event: item:added}\n    eventually: 1s",
+		"bad len matcher":  "steps:\n  - request: {path: /health}\n    expect: {json: {items: {len: many}}}",
+		"no webhook event": "steps:\n  - webhook: {user: '1'}",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); !errors.Is(err, ErrInvalidScenario) {
+			t.Errorf("%s: Parse() = %v, want ErrInvalidScenario", name, err)
+		}
+	}
+}
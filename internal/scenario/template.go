@@ -0,0 +1,135 @@
+package scenario
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// templateState holds the vars and generated IDs of one scenario run
+type templateState struct {
+	vars map[string]string
+	ids  map[string]string
+	now  time.Time
+}
+
+// lastID is the last ID generated in this process. IDs start from the run time so that runs
+// against the same server do not collide, and runs of one process never reuse each other's IDs
+// even when they start within the same millisecond.
+var lastID struct {
+	sync.Mutex
+	value int64
+}
+
+// generateID returns a new ID for a run starting at now
+func generateID(now time.Time) int64 {
+	lastID.Lock()
+	defer lastID.Unlock()
+	if start := now.UnixNano() / int64(time.Millisecond); lastID.value < start {
+		lastID.value = start
+	}
+	lastID.value++
+	return lastID.value
+}
+
+// newTemplateState creates the state of a run starting at now
+func newTemplateState(now time.Time) *templateState {
+	return &templateState{
+		vars: map[string]string{},
+		ids:  map[string]string{},
+		now:  now,
+	}
+}
+
+// funcs are the template functions:
+//
+//	{{id "task"}}  a numeric ID, the same for every use of "task" within a run
+//	{{now}}        the start of the run in RFC 3339
+//	{{today 1}}    the date of the run plus a number of days, as YYYY-MM-DD
+func (s *templateState) funcs() template.FuncMap {
+	return template.FuncMap{
+		"id": func(name string) string {
+			if id, ok := s.ids[name]; ok {
+				return id
+			}
+			id := strconv.FormatInt(generateID(s.now), 10)
+			s.ids[name] = id
+			return id
+		},
+		"now": func() string {
+			return s.now.UTC().Format(time.RFC3339)
+		},
+		"today": func(days ...int) string {
+			date := s.now
+			if len(days) > 0 {
+				date = date.AddDate(0, 0, days[0])
+			}
+			return date.Format("2006-01-02")
+		},
+	}
+}
+
+// render expands the templates of a string
+func (s *templateState) render(text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Funcs(s.funcs()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, s.vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderValue expands the templates of every string in a decoded YAML value, including map keys
+func (s *templateState) renderValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return s.render(v)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			renderedKey, err := s.render(key)
+			if err != nil {
+				return nil, err
+			}
+			if rendered[renderedKey], err = s.renderValue(item); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if rendered[i], err = s.renderValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return rendered, nil
+	default:
+		return value, nil
+	}
+}
+
+// renderStrings expands the templates of the values of a string map
+func (s *templateState) renderStrings(values map[string]string) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	rendered := make(map[string]string, len(values))
+	for key, value := range values {
+		var err error
+		if rendered[key], err = s.render(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return rendered, nil
+}
@@ -0,0 +1,228 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned for schedule expressions that cannot be parsed
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// maxSearchYears bounds how far Next looks for a matching time, so impossible dates such as
+// February 30 end the search
+const maxSearchYears = 5
+
+// Schedule decides when a job runs
+type Schedule interface {
+	// Next returns the first time after t the job runs at, or the zero time if it never does
+	Next(t time.Time) time.Time
+}
+
+// descriptors are the named schedules Parse accepts
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is the range of one field of a cron expression
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// Parse parses a schedule: a cron expression of five fields (minute, hour, day of month, month
+// and day of week) with *, lists, ranges, steps and the English names of months and days, one of
+// @yearly, @monthly, @weekly, @daily and @hourly, or "@every DURATION" for a fixed interval.
+// Cron expressions are evaluated in loc; times skipped by daylight saving time changes do not run.
+func Parse(expr string, loc *time.Location) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("%w %q: @every takes a duration of at least 1s", ErrInvalidSchedule, expr)
+		}
+		return Every(d), nil
+	}
+	if spec, ok := descriptors[strings.ToLower(expr)]; ok {
+		return parseCron(spec, loc)
+	}
+	if strings.HasPrefix(expr, "@") {
+		return nil, fmt.Errorf("%w %q: unknown descriptor", ErrInvalidSchedule, expr)
+	}
+	schedule, err := parseCron(expr, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidSchedule, expr, err)
+	}
+	return schedule, nil
+}
+
+// Every returns a schedule that runs every d, at multiples of d since the zero time so that
+// every instance computes the same times
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// cron is a parsed cron expression; each field is a bit set of the values it matches
+type cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// domAny and dowAny are set when a day field is *: a day then only has to match the other
+	// field, otherwise either field, as in Vixie cron
+	domAny, dowAny bool
+
+	loc *time.Location
+}
+
+func parseCron(expr string, loc *time.Location) (*cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("want %d fields, got %d", len(fields), len(parts))
+	}
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	// Sunday is 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &cron{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: parts[2] == "*", dowAny: parts[4] == "*",
+		loc: loc,
+	}, nil
+}
+
+// parseField parses a comma-separated list of *, values, ranges and steps
+func parseField(expr string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepExpr, f.name)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-"); {
+		case rangeExpr == "*":
+		case isRange:
+			var err error
+			if low, err = f.value(lowExpr); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(highExpr); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s", rangeExpr, f.name)
+			}
+		default:
+			value, err := f.value(rangeExpr)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			// A step after a single value runs to the end of the range, as in 5/15
+			if !hasStep {
+				high = value
+			}
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// value parses a number or name within the range of the field
+func (f field) value(expr string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(expr, name) {
+			if f.min == 1 {
+				return i + 1, nil
+			}
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(expr)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s %q (want %d-%d)", f.name, expr, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Next returns the first minute after t that matches the expression, in the schedule's location
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestParse tests the next times of cron expressions, descriptors and intervals
+func TestParse(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	// Friday, March 1 2024
+	from := time.Date(2024, 3, 1, 9, 7, 30, 0, time.UTC)
+
+	testCases := []struct {
+		expr string
+		loc  *time.Location
+		want []time.Time
+	}{
+		{"*/15 * * * *", time.UTC, []time.Time{
+			time.Date(2024, 3, 1, 9, 15, 0, 0, time.UTC),
+			time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		}},
+		{"0 8 * * mon-fri", time.UTC, []time.Time{
+			time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC),
+		}},
+		{"30 2 1,15 * *", time.UTC, []time.Time{
+			time.Date(2024, 3, 15, 2, 30, 0, 0, time.UTC),
+			time.Date(2024, 4, 1, 2, 30, 0, 0, time.UTC),
+		}},
+		// Either day field matches when both are restricted
+		{"0 0 13 * 5", time.UTC, []time.Time{
+			time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 29 feb *", time.UTC, []time.Time{
+			time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@daily", berlin, []time.Time{
+			time.Date(2024, 3, 2, 0, 0, 0, 0, berlin),
+			time.Date(2024, 3, 3, 0, 0, 0, 0, berlin),
+		}},
+		// 02:30 does not exist in Berlin on March 31 2024, when clocks skip to 03:00
+		{"30 2 31 3 *", berlin, []time.Time{
+			time.Date(2025, 3, 31, 2, 30, 0, 0, berlin),
+		}},
+		{"0 7 * * 7", time.UTC, []time.Time{
+			time.Date(2024, 3, 3, 7, 0, 0, 0, time.UTC),
+		}},
+		{"@every 10m", time.UTC, []time.Time{
+			time.Date(2024, 3, 1, 9, 10, 0, 0, time.UTC),
+			time.Date(2024, 3, 1, 9, 20, 0, 0, time.UTC),
+		}},
+	}
+	for _, tc := range testCases {
+		schedule, err := Parse(tc.expr, tc.loc)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tc.expr, err)
+			continue
+		}
+		at := from
+		for _, want := range tc.want {
+			at = schedule.Next(at)
+			if !at.Equal(want) {
+				t.Errorf("%q: next = %s, want %s", tc.expr, at, want)
+				break
+			}
+		}
+	}
+
+	if schedule, _ := Parse("0 0 30 2 *", time.UTC); !schedule.Next(from).IsZero() {
+		t.Errorf("February 30 was scheduled")
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "@fortnightly", "@every 1ms", "@every soon"} {
+		if _, err := Parse(expr, time.UTC); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidSchedule", expr, err)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+// Package scheduler runs periodic jobs on cron schedules inside the server process. Jobs are
+// spread out with random jitter, locked so that only one instance sharing a Locker runs each
+// scheduled run, caught up after downtime according to their missed-run policy, and recorded
+// in a History. Jobs can also be triggered by hand.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"cherry_backend/internal/logging"
+)
+
+const (
+	// DefaultTimeout bounds a run of a job and is how long its lock is held at most
+	DefaultTimeout = time.Hour
+
+	// maxMissedSlots bounds the search for the last missed run of a frequent job after a long
+	// downtime
+	maxMissedSlots = 100000
+)
+
+var (
+	// ErrInvalidJob is returned when adding a job without a name or function, or twice
+	ErrInvalidJob = errors.New("invalid job")
+
+	// ErrUnknownJob is returned for jobs that were never added
+	ErrUnknownJob = errors.New("unknown job")
+
+	// ErrJobRunning is returned when triggering a job that is running in this instance
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// MissedPolicy decides what happens to the runs of a job that were due while no instance ran
+type MissedPolicy string
+
+const (
+	// MissedSkip waits for the next scheduled run
+	MissedSkip MissedPolicy = "skip"
+
+	// MissedRunOnce runs the job once when the scheduler starts if at least one run was missed.
+	// Jobs that never ran, and local jobs, whose state does not outlive the process, run on
+	// every start.
+	MissedRunOnce MissedPolicy = "run-once"
+)
+
+// Job is a function run on a schedule
+type Job struct {
+	Name string
+
+	// Spec is the schedule of the job, see Parse
+	Spec string
+
+	Run func(ctx context.Context) error
+
+	// Jitter delays each scheduled run by a random duration up to Jitter, so that instances and
+	// jobs sharing a schedule do not all start at once
+	Jitter time.Duration
+
+	// Missed is the missed-run policy; defaults to MissedSkip
+	Missed MissedPolicy
+
+	// Timeout bounds a run; defaults to DefaultTimeout
+	Timeout time.Duration
+
+	// Local jobs work on the state of this instance, such as its log files, so every instance
+	// runs them without taking the lock
+	Local bool
+}
+
+// JobStatus describes a job and when it runs next
+type JobStatus struct {
+	Name     string       `json:"name"`
+	Schedule string       `json:"schedule"`
+	Jitter   string       `json:"jitter,omitempty"`
+	Missed   MissedPolicy `json:"missed"`
+	Local    bool         `json:"local"`
+	Running  bool         `json:"running"`
+
+	// Next is the next scheduled run, unset while the scheduler is not running
+	Next *time.Time `json:"next,omitempty"`
+}
+
+// Scheduler runs jobs on their schedules until its context is done
+type Scheduler struct {
+	Locker  Locker
+	History History
+	Logger  logging.Logger
+
+	// Instance identifies this process in locks and runs; defaults to the host name and PID
+	Instance string
+
+	// Location is the time zone of cron expressions; defaults to UTC
+	Location *time.Location
+
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+
+	mu     sync.Mutex
+	jobs   map[string]*entry
+	ctx    context.Context
+	random *rand.Rand
+	wg     sync.WaitGroup
+}
+
+// entry is a job added to the scheduler
+type entry struct {
+	job      Job
+	schedule Schedule
+	running  bool
+	next     time.Time
+}
+
+// New creates a scheduler without jobs
+func New(locker Locker, history History, logger logging.Logger) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		Locker:   locker,
+		History:  history,
+		Logger:   logger,
+		Instance: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		Location: time.UTC,
+		Now:      time.Now,
+		jobs:     make(map[string]*entry),
+		ctx:      context.Background(),
+		random:   rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid()))),
+	}
+}
+
+// Add adds a job; jobs added after Run has started are not scheduled
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("%w: a job needs a name and a function", ErrInvalidJob)
+	}
+	schedule, err := Parse(job.Spec, s.Location)
+	if err != nil {
+		return fmt.Errorf("%w %s: %v", ErrInvalidJob, job.Name, err)
+	}
+	if job.Missed == "" {
+		job.Missed = MissedSkip
+	}
+	if job.Missed != MissedSkip && job.Missed != MissedRunOnce {
+		return fmt.Errorf("%w %s: unknown missed-run policy %q", ErrInvalidJob, job.Name, job.Missed)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = DefaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("%w: job %s already exists", ErrInvalidJob, job.Name)
+	}
+	s.jobs[job.Name] = &entry{job: job, schedule: schedule}
+	return nil
+}
+
+// Jobs describes the jobs ordered by name
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]JobStatus, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		status := JobStatus{Name: e.job.Name, Schedule: e.job.Spec, Missed: e.job.Missed, Local: e.job.Local, Running: e.running}
+		if e.job.Jitter > 0 {
+			status.Jitter = e.job.Jitter.String()
+		}
+		if !e.next.IsZero() {
+			next := e.next
+			status.Next = &next
+		}
+		jobs = append(jobs, status)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// Runs returns up to limit runs of a job, or of all jobs if name is empty, newest first
+func (s *Scheduler) Runs(ctx context.Context, name string, limit int) ([]Run, error) {
+	if name != "" {
+		if _, err := s.entry(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.History.List(ctx, name, limit)
+}
+
+// Run schedules every job until ctx is done, then waits for running jobs to finish
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	entries := make([]*entry, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		s.wg.Add(1)
+		go func(e *entry) {
+			defer s.wg.Done()
+			s.loop(ctx, e)
+		}(e)
+	}
+	<-ctx.Done()
+	s.wg.Wait()
+}
+
+// Trigger starts a run of a job in the background, whatever its schedule. The run still takes
+// the lock of the job, so it waits for nothing but is skipped while another instance runs it.
+func (s *Scheduler) Trigger(name string) error {
+	e, err := s.entry(name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	running, ctx := e.running, s.ctx
+	s.mu.Unlock()
+	if running {
+		return fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx, e, TriggerManual, time.Time{})
+	}()
+	return nil
+}
+
+// Wait blocks until all triggered and scheduled runs have finished
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) entry(name string) (*entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownJob, name)
+	}
+	return e, nil
+}
+
+// loop catches up on a missed run, then runs a job at each of its scheduled times
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	var last time.Time
+	if slot, ok := s.missed(ctx, e); ok {
+		s.run(ctx, e, TriggerMissed, slot)
+		last = slot
+	}
+
+	for {
+		// Never schedule a slot twice, even if the timer fires before the clock reaches it
+		after := s.Now()
+		if after.Before(last) {
+			after = last
+		}
+		next := e.schedule.Next(after)
+		if next.IsZero() {
+			s.Logger.Warn("Job %s is never scheduled again", e.job.Name)
+			return
+		}
+		s.mu.Lock()
+		e.next = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(next.Sub(s.Now()) + s.jitter(e.job.Jitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.run(ctx, e, TriggerSchedule, next)
+		last = next
+	}
+}
+
+// missed returns the slot of the newest run of a job that was missed before the scheduler
+// started, if the job's policy is to catch up on it
+func (s *Scheduler) missed(ctx context.Context, e *entry) (time.Time, bool) {
+	if e.job.Missed != MissedRunOnce {
+		return time.Time{}, false
+	}
+	now := s.Now()
+	if e.job.Local {
+		return now, true
+	}
+	last, err := s.History.Last(ctx, e.job.Name)
+	if err != nil {
+		s.Logger.Error("Failed to read the last run of job %s: %v", e.job.Name, err)
+		return time.Time{}, false
+	}
+	if last == nil {
+		// Instances starting together share the slot, so only one of them runs the job
+		return now.Truncate(time.Minute), true
+	}
+
+	var slot time.Time
+	for t, i := e.schedule.Next(last.Slot), 0; !t.IsZero() && !t.After(now) && i < maxMissedSlots; t, i = e.schedule.Next(t), i+1 {
+		slot = t
+	}
+	return slot, !slot.IsZero()
+}
+
+// run runs a job for a slot unless it is already running here or locked by another instance,
+// and records the run
+func (s *Scheduler) run(ctx context.Context, e *entry, trigger string, slot time.Time) {
+	name := e.job.Name
+	s.mu.Lock()
+	if e.running {
+		s.mu.Unlock()
+		s.Logger.Warn("Skipping %s run of job %s: still running", trigger, name)
+		return
+	}
+	e.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+	}()
+
+	if !e.job.Local {
+		locked, err := s.Locker.Lock(ctx, name, s.Instance, slot, e.job.Timeout)
+		if err != nil {
+			s.Logger.Error("Failed to lock job %s: %v", name, err)
+			return
+		}
+		if !locked {
+			s.Logger.Debug("Skipping %s run of job %s: run by another instance", trigger, name)
+			return
+		}
+		defer func() {
+			if err := s.Locker.Unlock(context.Background(), name, s.Instance); err != nil {
+				s.Logger.Error("Failed to unlock job %s: %v", name, err)
+			}
+		}()
+	}
+
+	run := &Run{Job: name, Trigger: trigger, Instance: s.Instance, Slot: slot, StartedAt: s.Now()}
+	runCtx, cancel := context.WithTimeout(ctx, e.job.Timeout)
+	err := call(runCtx, e.job.Run)
+	cancel()
+	run.FinishedAt = s.Now()
+	run.Status = StatusSucceeded
+	if err != nil {
+		run.Status, run.Error = StatusFailed, err.Error()
+		s.Logger.Error("Job %s failed: %v", name, err)
+	} else {
+		s.Logger.Info("Job %s finished in %s (%s)", name, run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond), trigger)
+	}
+	if err := s.History.Record(context.Background(), run); err != nil {
+		s.Logger.Error("Failed to record run of job %s: %v", name, err)
+	}
+}
+
+// call runs a job function, turning a panic into an error so it does not take the server down
+func call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// jitter returns a random delay up to max
+func (s *Scheduler) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Duration(s.random.Int63n(int64(max)))
+}
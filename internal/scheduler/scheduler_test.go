@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/logging"
+)
+
+// newTestScheduler returns a scheduler named instance at a fixed time
+func newTestScheduler(instance string, locker Locker, history History, now time.Time) *Scheduler {
+	s := New(locker, history, logging.NewStdLogger())
+	s.Instance = instance
+	s.Now = func() time.Time { return now }
+	return s
+}
+
+// TestLocking tests that instances sharing a locker run each scheduled run once
+func TestLocking(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	locker, history := NewMemoryLocker(), NewMemoryHistory()
+	locker.Now = func() time.Time { return now }
+
+	var runs int32
+	job := Job{Name: "digests", Spec: "@hourly", Run: func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}}
+	var instances []*Scheduler
+	for _, name := range []string{"a", "b"} {
+		s := newTestScheduler(name, locker, history, now)
+		if err := s.Add(job); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+		instances = append(instances, s)
+	}
+
+	slot := now
+	for _, s := range instances {
+		e, _ := s.entry("digests")
+		s.run(ctx, e, TriggerSchedule, slot)
+	}
+	if runs != 1 {
+		t.Errorf("slot ran %d times, want once", runs)
+	}
+
+	// A manual run is not tied to a slot
+	e, _ := instances[1].entry("digests")
+	instances[1].run(ctx, e, TriggerManual, time.Time{})
+	if runs != 2 {
+		t.Errorf("manual run did not run")
+	}
+
+	// An unexpired lock of a crashed instance holds off the next slot until it expires
+	locker.Lock(ctx, "digests", "crashed", time.Time{}, time.Hour)
+	instances[0].run(ctx, e, TriggerSchedule, slot.Add(time.Hour))
+	now = now.Add(2 * time.Hour)
+	instances[0].run(ctx, e, TriggerSchedule, slot.Add(2*time.Hour))
+	if runs != 3 {
+		t.Errorf("runs = %d, want the slot after the lock expired to run", runs)
+	}
+
+	recorded, _ := history.List(ctx, "", 0)
+	if len(recorded) != 3 || recorded[0].Instance != "a" || recorded[1].Trigger != TriggerManual || recorded[2].Instance != "a" {
+		t.Errorf("history = %+v, want a, b (manual), a newest first", recorded)
+	}
+}
+
+// TestMissed tests which missed run a scheduler catches up on when it starts
+func TestMissed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 10, 30, 20, 0, time.UTC)
+	history := NewMemoryHistory()
+	s := newTestScheduler("a", NewMemoryLocker(), history, now)
+	noop := func(ctx context.Context) error { return nil }
+	s.Add(Job{Name: "retention", Spec: "@hourly", Run: noop, Missed: MissedRunOnce})
+	s.Add(Job{Name: "reconcile", Spec: "@hourly", Run: noop, Missed: MissedRunOnce, Local: true})
+	s.Add(Job{Name: "digests", Spec: "@hourly", Run: noop})
+
+	missed := func(name string) time.Time {
+		e, _ := s.entry(name)
+		slot, ok := s.missed(ctx, e)
+		if !ok {
+			return time.Time{}
+		}
+		return slot
+	}
+
+	if slot := missed("retention"); !slot.Equal(time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("never run job: missed slot = %s, want the current minute", slot)
+	}
+	history.Record(ctx, &Run{Job: "retention", Slot: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)})
+	history.Record(ctx, &Run{Job: "retention", Trigger: TriggerManual})
+	if slot := missed("retention"); !slot.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("missed slot = %s, want only the newest one at 10:00", slot)
+	}
+	history.Record(ctx, &Run{Job: "retention", Slot: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)})
+	if slot := missed("retention"); !slot.IsZero() {
+		t.Errorf("missed slot = %s after the 10:00 run", slot)
+	}
+	if slot := missed("reconcile"); !slot.Equal(now) {
+		t.Errorf("local job: missed slot = %s, want now", slot)
+	}
+	if slot := missed("digests"); !slot.IsZero() {
+		t.Errorf("skipping job: missed slot = %s", slot)
+	}
+}
+
+// TestTrigger tests running jobs by hand and recording failures
+func TestTrigger(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler("a", NewMemoryLocker(), NewMemoryHistory(), time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+
+	release := make(chan struct{})
+	s.Add(Job{Name: "slow", Spec: "@daily", Run: func(ctx context.Context) error {
+		<-release
+		return errors.New("quota exceeded")
+	}})
+	s.Add(Job{Name: "panics", Spec: "@daily", Run: func(ctx context.Context) error { panic("boom") }})
+	if err := s.Add(Job{Name: "slow", Spec: "@daily", Run: func(ctx context.Context) error { return nil }}); !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("Add() of a duplicate = %v, want ErrInvalidJob", err)
+	}
+	if err := s.Add(Job{Name: "bad", Spec: "@sometimes", Run: func(ctx context.Context) error { return nil }}); !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("Add() of a bad schedule = %v, want ErrInvalidJob", err)
+	}
+
+	if err := s.Trigger("slow"); err != nil {
+		t.Fatalf("Trigger() failed: %v", err)
+	}
+	for !s.Jobs()[1].Running {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Trigger("slow"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Trigger() of a running job = %v, want ErrJobRunning", err)
+	}
+	close(release)
+	s.Trigger("panics")
+	s.Wait()
+	if err := s.Trigger("nightly"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Trigger() of an unknown job = %v, want ErrUnknownJob", err)
+	}
+
+	for name, want := range map[string]string{"slow": "quota exceeded", "panics": "panic: boom"} {
+		runs, err := s.Runs(ctx, name, 10)
+		if err != nil || len(runs) != 1 || runs[0].Status != StatusFailed || runs[0].Error != want || runs[0].Trigger != TriggerManual {
+			t.Errorf("runs of %s = %+v, %v, want a failed manual run with %q", name, runs, err, want)
+		}
+	}
+}
+
+// TestRun tests that Run catches up on missed runs and schedules the next one
+func TestRun(t *testing.T) {
+	s := newTestScheduler("a", NewMemoryLocker(), NewMemoryHistory(), time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	ran := make(chan struct{}, 1)
+	s.Add(Job{Name: "tokens", Spec: "@hourly", Missed: MissedRunOnce, Local: true, Run: func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	<-ran
+	for s.Jobs()[0].Next == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if next := *s.Jobs()[0].Next; !next.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("next = %s, want 10:00", next)
+	}
+	cancel()
+	<-done
+
+	runs, _ := s.Runs(context.Background(), "", 0)
+	if len(runs) != 1 || runs[0].Trigger != TriggerMissed || runs[0].Status != StatusSucceeded {
+		t.Errorf("runs = %+v, want the missed run", runs)
+	}
+}
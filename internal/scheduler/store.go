@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is how many runs of each job MemoryHistory keeps
+const DefaultHistorySize = 100
+
+// How a run was started
+const (
+	TriggerSchedule = "schedule"
+	TriggerMissed   = "missed"
+	TriggerManual   = "manual"
+)
+
+// Outcomes of a run
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Run is one run of a job
+type Run struct {
+	ID  uint64 `json:"id"`
+	Job string `json:"job"`
+
+	// Trigger is TriggerSchedule, TriggerMissed or TriggerManual
+	Trigger string `json:"trigger"`
+
+	// Instance is the scheduler that ran the job
+	Instance string `json:"instance"`
+
+	// Slot is the scheduled time the run is for; it is zero for manual runs
+	Slot time.Time `json:"slot"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Locker makes sure only one instance runs a job at a time, and each scheduled run only once
+type Locker interface {
+	// Lock claims a job for an owner for ttl. It fails when another owner holds an unexpired
+	// lock, or when slot is not after the last slot claimed, so that instances racing for the
+	// same scheduled run only run it once. A zero slot claims a manual run, which only has to
+	// wait for the lock.
+	Lock(ctx context.Context, job, owner string, slot time.Time, ttl time.Duration) (bool, error)
+
+	// Unlock releases a lock held by owner
+	Unlock(ctx context.Context, job, owner string) error
+}
+
+// History records the runs of the jobs
+type History interface {
+	// Record stores a finished run and assigns its ID
+	Record(ctx context.Context, run *Run) error
+
+	// List returns up to limit runs of a job, or of all jobs if job is empty, newest first
+	List(ctx context.Context, job string, limit int) ([]Run, error)
+
+	// Last returns the newest run of a job with a slot, or nil if there is none
+	Last(ctx context.Context, job string) (*Run, error)
+}
+
+// MemoryLocker implements Locker for a single process
+type MemoryLocker struct {
+	// Now returns the current time for lock expiry; defaults to time.Now
+	Now func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*lease
+}
+
+type lease struct {
+	owner string
+	slot  time.Time
+	until time.Time
+}
+
+// NewMemoryLocker creates a locker without locks
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{Now: time.Now, locks: make(map[string]*lease)}
+}
+
+// Lock claims a job
+func (l *MemoryLocker) Lock(ctx context.Context, job, owner string, slot time.Time, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	current, ok := l.locks[job]
+	if !ok {
+		current = &lease{}
+		l.locks[job] = current
+	}
+	if now.Before(current.until) || (!slot.IsZero() && !slot.After(current.slot)) {
+		return false, nil
+	}
+	current.owner = owner
+	current.until = now.Add(ttl)
+	if !slot.IsZero() {
+		current.slot = slot
+	}
+	return true, nil
+}
+
+// Unlock releases a lock
+func (l *MemoryLocker) Unlock(ctx context.Context, job, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if current, ok := l.locks[job]; ok && current.owner == owner {
+		current.until = time.Time{}
+	}
+	return nil
+}
+
+// MemoryHistory implements History in memory, keeping the newest runs of each job
+type MemoryHistory struct {
+	// Size is how many runs of each job are kept
+	Size int
+
+	mu     sync.Mutex
+	nextID uint64
+	runs   map[string][]Run
+}
+
+// NewMemoryHistory creates an empty history keeping DefaultHistorySize runs of each job
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{Size: DefaultHistorySize, runs: make(map[string][]Run)}
+}
+
+// Record stores a run
+func (h *MemoryHistory) Record(ctx context.Context, run *Run) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	run.ID = h.nextID
+	runs := append(h.runs[run.Job], *run)
+	if h.Size > 0 && len(runs) > h.Size {
+		runs = runs[len(runs)-h.Size:]
+	}
+	h.runs[run.Job] = runs
+	return nil
+}
+
+// List returns the newest runs
+func (h *MemoryHistory) List(ctx context.Context, job string, limit int) ([]Run, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var runs []Run
+	for name, jobRuns := range h.runs {
+		if job == "" || name == job {
+			runs = append(runs, jobRuns...)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// Last returns the newest run of a job with a slot
+func (h *MemoryHistory) Last(ctx context.Context, job string) (*Run, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	runs := h.runs[job]
+	for i := len(runs) - 1; i >= 0; i-- {
+		if !runs[i].Slot.IsZero() {
+			run := runs[i]
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
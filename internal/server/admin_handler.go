@@ -0,0 +1,223 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/internal/fanout"
+	"cherry_backend/internal/metrics"
+	"cherry_backend/internal/replay"
+	"cherry_backend/pkg/api"
+)
+
+// requireAdmin only lets requests through that carry CHERRY_ADMIN_TOKEN as a bearer token.
+// The admin API is disabled while the variable is unset.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminToken := os.Getenv("CHERRY_ADMIN_TOKEN")
+		if adminToken == "" {
+			s.logger.Warn("Rejected admin request to %s: CHERRY_ADMIN_TOKEN not set", r.URL.Path)
+			writeError(w, http.StatusForbidden, "Admin API disabled")
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(adminToken)) != 1 {
+			s.logger.Warn("Rejected admin request to %s: invalid token", r.URL.Path)
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// registerAdminRoutes sets up the admin API under /admin
+func (s *Server) registerAdminRoutes() {
+	admin := s.Router.PathPrefix("/admin").Subrouter()
+	admin.Use(func(next http.Handler) http.Handler { return s.limitIP(next.ServeHTTP) }, s.requireAdmin)
+
+	admin.HandleFunc("/subscribers", s.ListSubscribersHandler).Methods("GET")
+	admin.HandleFunc("/subscribers", s.CreateSubscriberHandler).Methods("POST")
+	admin.HandleFunc("/subscribers/{id}", s.GetSubscriberHandler).Methods("GET")
+	admin.HandleFunc("/subscribers/{id}", s.DeleteSubscriberHandler).Methods("DELETE")
+	admin.HandleFunc("/subscribers/{id}/pause", s.PauseSubscriberHandler).Methods("POST")
+	admin.HandleFunc("/subscribers/{id}/resume", s.ResumeSubscriberHandler).Methods("POST")
+	admin.HandleFunc("/subscribers/{id}/deliveries", s.ListDeliveriesHandler).Methods("GET")
+	admin.HandleFunc("/users", s.ListUsersHandler).Methods("GET")
+	admin.HandleFunc("/users/{user_id}", s.GetUserHandler).Methods("GET")
+	admin.HandleFunc("/users/{user_id}", s.PutUserHandler).Methods("PUT")
+	admin.HandleFunc("/users/{user_id}", s.DeleteUserHandler).Methods("DELETE")
+	admin.HandleFunc("/users/{user_id}/disable", s.DisableUserHandler).Methods("POST")
+	admin.HandleFunc("/users/{user_id}/enable", s.EnableUserHandler).Methods("POST")
+	admin.HandleFunc("/users/{user_id}/digest", s.PreviewDigestHandler).Methods("GET")
+	admin.HandleFunc("/users/{user_id}/digest", s.SendDigestHandler).Methods("POST")
+	admin.HandleFunc("/users/{user_id}/keys", s.ListUserKeysHandler).Methods("GET")
+	admin.HandleFunc("/users/{user_id}/keys", s.CreateUserKeyHandler).Methods("POST")
+	admin.HandleFunc("/users/{user_id}/keys/{id}", s.DeleteUserKeyHandler).Methods("DELETE")
+	admin.HandleFunc("/quarantine", s.ListQuarantineHandler).Methods("GET")
+	admin.HandleFunc("/quarantine/{id}/release", s.ReleaseQuarantinedHandler).Methods("POST")
+	admin.HandleFunc("/quarantine/{id}", s.DropQuarantinedHandler).Methods("DELETE")
+	admin.HandleFunc("/webhooks", s.ListWebhooksHandler).Methods("GET")
+	admin.HandleFunc("/metrics", s.MetricsHandler).Methods("GET")
+	admin.HandleFunc("/jobs", s.ListJobsHandler).Methods("GET")
+	admin.HandleFunc("/jobs/{name}/runs", s.ListJobRunsHandler).Methods("GET")
+	admin.HandleFunc("/jobs/{name}/run", s.RunJobHandler).Methods("POST")
+}
+
+// MetricsHandler reports the runtime metrics of the server and the statistics of the webhook
+// endpoint, e.g. to watch for goroutine and file descriptor leaks under load
+func (s *Server) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot := metrics.Read(s.started)
+	snapshot.Webhooks = s.webhookMetrics.Stats()
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// maxWebhooksPage caps the limit of ListWebhooksHandler
+const maxWebhooksPage = 1000
+
+// ListWebhooksHandler lists the raw deliveries of the webhooks kept in the event store, oldest
+// first, for recording them as replay fixtures
+func (s *Server) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var after uint64
+	if value := query.Get("after"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "after must be an event ID")
+			return
+		}
+		after = parsed
+	}
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "limit must be a positive number")
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxWebhooksPage {
+		limit = maxWebhooksPage
+	}
+
+	stored, err := s.Events.Store.Since(r.Context(), query.Get("user_id"), after, limit)
+	if err != nil {
+		s.logger.Error("Error reading events: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	page := replay.WebhooksPage{Webhooks: []replay.Delivery{}, NextAfter: after}
+	for _, event := range stored {
+		if delivery, ok := replay.DeliveryOf(event); ok {
+			page.Webhooks = append(page.Webhooks, delivery)
+		}
+		page.NextAfter = event.ID
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// ListSubscribersHandler lists the webhook subscribers
+func (s *Server) ListSubscribersHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"subscribers": s.Fanout.Subscribers()})
+}
+
+// CreateSubscriberHandler registers a webhook subscriber. The response is the only place its secret is shown.
+func (s *Server) CreateSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "request body must be a JSON object")
+		return
+	}
+
+	subscriber, err := s.Fanout.Subscribe(fanout.Subscriber{URL: request.URL, Events: request.Events, Secret: request.Secret})
+	if errors.Is(err, fanout.ErrInvalidSubscriber) {
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("Error registering subscriber: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusCreated, subscriber)
+}
+
+// GetSubscriberHandler returns a single webhook subscriber
+func (s *Server) GetSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	subscriber, err := s.Fanout.Subscriber(mux.Vars(r)["id"])
+	if err != nil {
+		writeSubscriberError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, subscriber)
+}
+
+// DeleteSubscriberHandler removes a webhook subscriber
+func (s *Server) DeleteSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.Fanout.Unsubscribe(mux.Vars(r)["id"]); err != nil {
+		writeSubscriberError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PauseSubscriberHandler pauses deliveries to a webhook subscriber
+func (s *Server) PauseSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	s.setSubscriberPaused(w, r, true)
+}
+
+// ResumeSubscriberHandler resumes deliveries to a webhook subscriber
+func (s *Server) ResumeSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	s.setSubscriberPaused(w, r, false)
+}
+
+func (s *Server) setSubscriberPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	id := mux.Vars(r)["id"]
+	var err error
+	if paused {
+		err = s.Fanout.Pause(id)
+	} else {
+		err = s.Fanout.Resume(id)
+	}
+	if err != nil {
+		writeSubscriberError(w, err)
+		return
+	}
+	s.GetSubscriberHandler(w, r)
+}
+
+// ListDeliveriesHandler returns the delivery log of a webhook subscriber, newest first
+func (s *Server) ListDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := s.Fanout.Deliveries(mux.Vars(r)["id"])
+	if err != nil {
+		writeSubscriberError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+// writeSubscriberError maps subscriber lookup errors to HTTP responses
+func writeSubscriberError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fanout.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "Internal Server Error")
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
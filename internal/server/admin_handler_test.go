@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cherry_backend/internal/fanout"
+	"cherry_backend/internal/metrics"
+)
+
+// adminRequest sends an admin API request with the given bearer token
+func adminRequest(t *testing.T, s *Server, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestSubscriberAdminAPI tests registering, pausing and removing subscribers through the admin API
+func TestSubscriberAdminAPI(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+	t.Setenv("CHERRY_ADMIN_TOKEN", "admin")
+
+	s := NewServer()
+	defer s.Fanout.Close()
+
+	rec := adminRequest(t, s, "POST", "/admin/subscribers", "admin", map[string]interface{}{
+		"url":    "http://localhost:9999/hook",
+		"events": []string{"item:*"},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	var created fanout.Subscriber
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.ID == "" || created.Secret == "" {
+		t.Fatalf("created subscriber = %+v, want an ID and a secret", created)
+	}
+
+	rec = adminRequest(t, s, "POST", "/admin/subscribers/"+created.ID+"/pause", "admin", nil)
+	var paused fanout.Subscriber
+	json.NewDecoder(rec.Body).Decode(&paused)
+	if rec.Code != http.StatusOK || !paused.Paused || paused.Secret != "" {
+		t.Errorf("pause = %d %+v, want a paused subscriber without its secret", rec.Code, paused)
+	}
+
+	rec = adminRequest(t, s, "GET", "/admin/subscribers/"+created.ID+"/deliveries", "admin", nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("deliveries status = %d, want 200", rec.Code)
+	}
+
+	if rec := adminRequest(t, s, "DELETE", "/admin/subscribers/"+created.ID, "admin", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", rec.Code)
+	}
+	if rec := adminRequest(t, s, "GET", "/admin/subscribers/"+created.ID, "admin", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", rec.Code)
+	}
+
+	if rec := adminRequest(t, s, "POST", "/admin/subscribers", "admin", map[string]interface{}{"url": "not a url"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("create with invalid url status = %d, want 400", rec.Code)
+	}
+}
+
+// TestAdminAuth tests that the admin API requires CHERRY_ADMIN_TOKEN
+func TestAdminAuth(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	s := NewServer()
+	defer s.Fanout.Close()
+
+	t.Setenv("CHERRY_ADMIN_TOKEN", "")
+	if rec := adminRequest(t, s, "GET", "/admin/subscribers", "anything", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("status without CHERRY_ADMIN_TOKEN = %d, want 403", rec.Code)
+	}
+
+	t.Setenv("CHERRY_ADMIN_TOKEN", "admin")
+	if rec := adminRequest(t, s, "GET", "/admin/subscribers", "wrong", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status with a wrong token = %d, want 401", rec.Code)
+	}
+	bare := httptest.NewRequest("GET", "/admin/subscribers", nil)
+	bare.Header.Set("Authorization", "admin")
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, bare)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status with the admin token but no Bearer scheme = %d, want 401", rec.Code)
+	}
+	if rec := adminRequest(t, s, "GET", "/admin/subscribers", "admin", nil); rec.Code != http.StatusOK {
+		t.Errorf("status with the admin token = %d, want 200", rec.Code)
+	}
+}
+
+// TestMetricsAdminAPI tests that webhook responses and runtime metrics are reported
+func TestMetricsAdminAPI(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+	t.Setenv("CHERRY_ADMIN_TOKEN", "admin")
+	t.Setenv("TODOIST_CLIENT_SECRET", "")
+
+	s := NewServer()
+	defer s.Fanout.Close()
+
+	for _, body := range []string{`{"event_name":"item:added","user_id":"1","event_data":{}}`, `not json`} {
+		req := httptest.NewRequest("POST", "/webhooks/todoist", bytes.NewBufferString(body))
+		s.Router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := adminRequest(t, s, "GET", "/admin/metrics", "admin", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("metrics status = %d, want 200", rec.Code)
+	}
+	var snapshot metrics.Snapshot
+	json.NewDecoder(rec.Body).Decode(&snapshot)
+	if snapshot.Webhooks.Requests != 2 || snapshot.Webhooks.ByStatus["200"] != 1 || snapshot.Webhooks.ByStatus["400"] != 1 {
+		t.Errorf("webhook stats = %+v, want one 200 and one 400", snapshot.Webhooks)
+	}
+	if snapshot.Goroutines == 0 || snapshot.OpenFDs == 0 || snapshot.HeapAllocBytes == 0 {
+		t.Errorf("snapshot = %+v, want runtime metrics", snapshot)
+	}
+}
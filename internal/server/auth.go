@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/storage"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// methodScopes is the scope each RPC needs. Over REST the webhook route is authenticated by its
+// Todoist signature instead, so webhooks:write only applies to gRPC.
+var methodScopes = auth.MethodScopes{
+	apiv1.HealthService_Check_FullMethodName:            "",
+	apiv1.TodoistService_ProcessWebhook_FullMethodName:  auth.ScopeWebhooksWrite,
+	apiv1.TodoistService_SubscribeEvents_FullMethodName: auth.ScopeEventsRead,
+	apiv1.TaskService_ListTasks_FullMethodName:          auth.ScopeTasksRead,
+	apiv1.StatsService_GetStats_FullMethodName:          auth.ScopeStatsRead,
+}
+
+// newAuthFromEnv creates the authenticator of client requests. API keys are kept in the database
+// if there is one; session tokens are signed with CHERRY_JWT_SECRET, or with a random secret
+// that does not survive restarts when it is unset.
+func newAuthFromEnv(db *storage.DB, tokens mirror.StaticTokenSource, logger logging.Logger) *auth.Authenticator {
+	var keys auth.KeyStore = auth.NewMemoryKeyStore()
+	if db != nil {
+		keys = db.APIKeys()
+	}
+
+	secret := []byte(os.Getenv("CHERRY_JWT_SECRET"))
+	if len(secret) == 0 {
+		logger.Warn("CHERRY_JWT_SECRET not set; session tokens are invalidated on restart")
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	sessions := auth.NewIssuer(secret)
+	if ttl := os.Getenv("CHERRY_SESSION_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			sessions.TTL = d
+		} else {
+			logger.Warn("Ignoring invalid CHERRY_SESSION_TTL %q", ttl)
+		}
+	}
+
+	return &auth.Authenticator{Keys: keys, Sessions: sessions, Todoist: tokens}
+}
+
+// newOAuthFromEnv creates the Todoist OAuth client for logins; nil when TODOIST_CLIENT_ID is unset.
+// The client secret is TODOIST_CLIENT_SECRET, which also signs the integration's webhooks.
+func newOAuthFromEnv() *api.TodoistOAuth {
+	clientID := os.Getenv("TODOIST_CLIENT_ID")
+	if clientID == "" {
+		return nil
+	}
+	oauth := api.NewTodoistOAuth(clientID, os.Getenv("TODOIST_CLIENT_SECRET"))
+	if baseURL := os.Getenv("TODOIST_OAUTH_URL"); baseURL != "" {
+		oauth.BaseURL = baseURL
+	}
+	oauth.RedirectURI = os.Getenv("TODOIST_OAUTH_REDIRECT_URI")
+	return oauth
+}
+
+// authorize authenticates a bearer token, checks that it grants scope and admits the user's
+// account. The returned context carries the principal and the account. Disabled accounts are
+// refused, and so are users without an account when the tenant policy is enforced.
+func (s *Server) authorize(ctx context.Context, token, scope string) (context.Context, error) {
+	principal, err := s.Auth.Authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := principal.Require(scope); err != nil {
+		return nil, err
+	}
+	account, action, err := s.TenantPolicy.Admit(ctx, s.Accounts, principal.UserID)
+	if action != tenant.Accept {
+		if errors.Is(err, tenant.ErrUnknownUser) || errors.Is(err, tenant.ErrDisabled) {
+			return nil, fmt.Errorf("%w: %v", ErrPermissionDenied, err)
+		}
+		return nil, err
+	}
+	return auth.NewContext(tenant.NewContext(ctx, account), principal), nil
+}
+
+// grpcAuthorize is authorize for the gRPC interceptors, which report tenant denials as PermissionDenied
+func (s *Server) grpcAuthorize(ctx context.Context, token, scope string) (context.Context, error) {
+	ctx, err := s.authorize(ctx, token, scope)
+	if errors.Is(err, ErrPermissionDenied) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return ctx, err
+}
+
+// grpcErrors is a unary interceptor reporting the errors services share with the REST handlers
+// with their gRPC codes
+func grpcErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrInvalidArgument):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return resp, err
+}
+
+// requireScope only lets requests through whose bearer token grants scope, with the principal
+// and account in their context
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := s.authorize(r.Context(), bearerToken(r), scope)
+		if err != nil {
+			s.writeAuthError(w, r, err)
+			return
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// writeAuthError answers a request that authorize refused
+func (s *Server) writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		s.logger.Warn("Rejected request to %s: %v", r.URL.Path, err)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+	case errors.Is(err, auth.ErrInsufficientScope), errors.Is(err, ErrPermissionDenied):
+		s.logger.Warn("Refused request to %s: %v", r.URL.Path, err)
+		writeErrorCode(w, http.StatusForbidden, api.CodePermission, err.Error())
+	default:
+		s.logger.Error("Error authenticating request to %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// scopeUserID returns the user a request acts for. Requests authenticated as an account may
+// only act for that account's user, which is also the default when they name no user.
+func scopeUserID(ctx context.Context, requested string) (string, error) {
+	account, ok := tenant.FromContext(ctx)
+	if !ok {
+		return requested, nil
+	}
+	if requested != "" && requested != account.UserID {
+		return "", fmt.Errorf("%w: authenticated as user %s, not %s", ErrPermissionDenied, account.UserID, requested)
+	}
+	return account.UserID, nil
+}
+
+// bearerToken returns the bearer token of a request. Browsers cannot set headers on
+// EventSource and WebSocket connections, so the access_token query parameter is accepted too.
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return r.URL.Query().Get("access_token")
+}
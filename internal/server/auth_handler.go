@@ -0,0 +1,269 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// oauthStateCookie holds the state of a Todoist login between the redirect and the callback
+const oauthStateCookie = "cherry_oauth_state"
+
+// oauthStateTTL is how long a Todoist login may take
+const oauthStateTTL = 10 * time.Minute
+
+// registerAuthRoutes sets up the Todoist login under /auth/todoist and the self-service API key
+// endpoints under /auth/keys. The login is rate limited per IP address.
+func (s *Server) registerAuthRoutes() {
+	s.Router.HandleFunc("/auth/todoist/login", s.limit(rateClassAuth, s.TodoistLoginHandler)).Methods("GET")
+	s.Router.HandleFunc("/auth/todoist/callback", s.limit(rateClassAuth, s.TodoistCallbackHandler)).Methods("GET")
+	s.Router.HandleFunc("/auth/keys", s.limitIP(s.requireScope(auth.ScopeKeysWrite, s.limit(rateClassAuth, s.ListKeysHandler)))).Methods("GET")
+	s.Router.HandleFunc("/auth/keys", s.limitIP(s.requireScope(auth.ScopeKeysWrite, s.limit(rateClassAuth, s.CreateKeyHandler)))).Methods("POST")
+	s.Router.HandleFunc("/auth/keys/{id}", s.limitIP(s.requireScope(auth.ScopeKeysWrite, s.limit(rateClassAuth, s.DeleteKeyHandler)))).Methods("DELETE")
+}
+
+// SessionResponse is the body of a successful Todoist login
+type SessionResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UserID      string    `json:"user_id"`
+	Scopes      []string  `json:"scopes"`
+}
+
+// TodoistLoginHandler starts a Todoist login by redirecting to the Todoist authorization page.
+// The state is also kept in a cookie, so the callback can tell that it answers this browser.
+func (s *Server) TodoistLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if s.OAuth == nil {
+		writeError(w, http.StatusNotFound, "Todoist login is not configured")
+		return
+	}
+	stateBytes := make([]byte, 16)
+	rand.Read(stateBytes)
+	state := hex.EncodeToString(stateBytes)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/auth/todoist",
+		MaxAge:   int(oauthStateTTL / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, s.OAuth.AuthorizeURL(state), http.StatusFound)
+}
+
+// TodoistCallbackHandler completes a Todoist login: it trades the code for the user's Todoist
+// token, creates the user's account on first login and answers with a session token
+func (s *Server) TodoistCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if s.OAuth == nil {
+		writeError(w, http.StatusNotFound, "Todoist login is not configured")
+		return
+	}
+	query := r.URL.Query()
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || query.Get("state") == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		s.logger.Warn("Rejected Todoist login: state mismatch")
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "login state does not match; start again at /auth/todoist/login")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/auth/todoist", MaxAge: -1})
+	if denied := query.Get("error"); denied != "" {
+		writeErrorCode(w, http.StatusForbidden, api.CodePermission, "Todoist login failed: "+denied)
+		return
+	}
+	if query.Get("code") == "" {
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "code is required")
+		return
+	}
+
+	token, err := s.OAuth.Exchange(r.Context(), query.Get("code"))
+	if err != nil {
+		s.logger.Warn("Todoist login failed: %v", err)
+		writeErrorCode(w, http.StatusBadGateway, api.CodeUnavailable, "Todoist login failed")
+		return
+	}
+	baseURL := os.Getenv("TODOIST_API_URL")
+	if baseURL == "" {
+		baseURL = api.TodoistRESTBaseURL
+	}
+	user, err := api.NewTodoistRESTClient(baseURL, token).GetUser(r.Context())
+	if err != nil {
+		s.logger.Warn("Todoist login failed: %v", err)
+		writeErrorCode(w, http.StatusBadGateway, api.CodeUnavailable, "Todoist login failed")
+		return
+	}
+
+	// Create the account on first login and keep its email up to date
+	account, err := s.Accounts.Get(r.Context(), user.ID)
+	if errors.Is(err, tenant.ErrUnknownUser) {
+		account = &tenant.Account{UserID: user.ID, Status: tenant.StatusActive}
+		if _, err := time.LoadLocation(user.Timezone); err == nil {
+			account.Settings.Timezone = user.Timezone
+		}
+		err = nil
+	}
+	if err != nil {
+		s.writeAccountError(w, err)
+		return
+	}
+	if !account.Active() {
+		writeErrorCode(w, http.StatusForbidden, api.CodePermission, "the account of user "+user.ID+" is disabled")
+		return
+	}
+	if account.CreatedAt.IsZero() || account.Email != user.Email {
+		account.Email = user.Email
+		if err := s.Accounts.Put(r.Context(), account); err != nil {
+			s.writeAccountError(w, err)
+			return
+		}
+	}
+	if s.Storage != nil {
+		if err := s.Storage.Tokens().Put(r.Context(), user.ID, token); err != nil {
+			s.logger.Error("Failed to store Todoist token of user %s: %v", user.ID, err)
+		}
+	}
+
+	session, expires, err := s.Auth.Sessions.Issue(user.ID, auth.SessionScopes)
+	if err != nil {
+		s.logger.Error("Error issuing session token: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	s.logger.Info("User %s logged in with Todoist", user.ID)
+	writeJSON(w, http.StatusOK, SessionResponse{
+		AccessToken: session,
+		TokenType:   "Bearer",
+		ExpiresAt:   expires,
+		UserID:      user.ID,
+		Scopes:      auth.SessionScopes,
+	})
+}
+
+// CreateKeyRequest is the body of the API key creation endpoints
+type CreateKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateKeyResponse returns a new API key; the key itself is only ever shown here
+type CreateKeyResponse struct {
+	auth.Key
+	Token string `json:"key"`
+}
+
+// ListKeysHandler lists the API keys of the authenticated user
+func (s *Server) ListKeysHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	s.listKeys(w, r, principal.UserID)
+}
+
+// CreateKeyHandler creates an API key of the authenticated user. A key may only be granted
+// scopes the credentials creating it have.
+func (s *Server) CreateKeyHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	s.createKey(w, r, principal.UserID, principal)
+}
+
+// DeleteKeyHandler revokes an API key of the authenticated user
+func (s *Server) DeleteKeyHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	s.deleteKey(w, r, principal.UserID)
+}
+
+// ListUserKeysHandler lists the API keys of a user
+func (s *Server) ListUserKeysHandler(w http.ResponseWriter, r *http.Request) {
+	s.listKeys(w, r, mux.Vars(r)["user_id"])
+}
+
+// CreateUserKeyHandler creates an API key of a user with any scopes
+func (s *Server) CreateUserKeyHandler(w http.ResponseWriter, r *http.Request) {
+	s.createKey(w, r, mux.Vars(r)["user_id"], nil)
+}
+
+// DeleteUserKeyHandler revokes an API key of a user
+func (s *Server) DeleteUserKeyHandler(w http.ResponseWriter, r *http.Request) {
+	s.deleteKey(w, r, mux.Vars(r)["user_id"])
+}
+
+func (s *Server) listKeys(w http.ResponseWriter, r *http.Request, userID string) {
+	keys, err := s.Auth.Keys.List(r.Context(), userID)
+	if err != nil {
+		s.logger.Error("Error listing API keys: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// createKey creates an API key of a user with an account. When creator is not nil, the key may
+// only have scopes the creator has.
+func (s *Server) createKey(w http.ResponseWriter, r *http.Request, userID string, creator *auth.Principal) {
+	var request CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "request body must be a JSON object")
+		return
+	}
+	if _, err := auth.ValidateScopes(request.Scopes); err != nil {
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+		return
+	}
+	if creator != nil {
+		for _, scope := range request.Scopes {
+			if err := creator.Require(scope); err != nil {
+				writeErrorCode(w, http.StatusForbidden, api.CodePermission, "cannot grant a scope the credentials lack: "+err.Error())
+				return
+			}
+		}
+	}
+	now := s.Now()
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "expires_at must be in the future")
+		return
+	}
+	if _, err := s.Accounts.Get(r.Context(), userID); err != nil {
+		s.writeAccountError(w, err)
+		return
+	}
+
+	plaintext, key, err := auth.NewKey(userID, request.Name, request.Scopes, request.ExpiresAt, now)
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+		return
+	}
+	if err := s.Auth.Keys.Create(r.Context(), key); err != nil {
+		s.logger.Error("Error storing API key: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	s.logger.Info("Created API key %s of user %s with scopes %v", key.ID, userID, key.Scopes)
+	writeJSON(w, http.StatusCreated, CreateKeyResponse{Key: *key, Token: plaintext})
+}
+
+func (s *Server) deleteKey(w http.ResponseWriter, r *http.Request, userID string) {
+	id := mux.Vars(r)["id"]
+	err := s.Auth.Keys.Delete(r.Context(), userID, id)
+	if errors.Is(err, auth.ErrKeyNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("Error deleting API key: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	s.logger.Info("Revoked API key %s of user %s", id, userID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,224 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/server"
+	"cherry_backend/internal/server/servertest"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/testing/faketodoist"
+)
+
+// newLoginTestServer starts a test server whose Todoist login goes to a fake platform
+func newLoginTestServer(t *testing.T) (*servertest.TestServer, *faketodoist.Platform) {
+	platform := faketodoist.NewPlatform()
+	t.Cleanup(platform.Close)
+	t.Setenv("TODOIST_CLIENT_ID", platform.ClientID)
+	t.Setenv("TODOIST_OAUTH_URL", platform.URL+"/oauth")
+	t.Setenv("TODOIST_API_URL", platform.APIURL())
+	ts := servertest.NewTestServer(t)
+	// Test servers sign webhooks with servertest.ClientSecret
+	ts.OAuth.ClientSecret = platform.ClientSecret
+	ts.OAuth.RedirectURI = ts.URL("/auth/todoist/callback")
+	return ts, platform
+}
+
+// login runs the Todoist login of a user in a browser with a cookie jar and returns the response
+// of the callback
+func login(t *testing.T, ts *servertest.TestServer, user *faketodoist.User) *http.Response {
+	t.Helper()
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := browser.Get(ts.URL("/auth/todoist/login"))
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("login status = %d, want a redirect", resp.StatusCode)
+	}
+
+	// The fake platform approves at once for the user named in the authorization URL
+	authorize, _ := url.Parse(resp.Header.Get("Location"))
+	query := authorize.Query()
+	query.Set("user_id", user.ID)
+	authorize.RawQuery = query.Encode()
+	if resp, err = browser.Get(authorize.String()); err != nil {
+		t.Fatalf("authorize failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp, err = browser.Get(resp.Header.Get("Location")); err != nil {
+		t.Fatalf("callback failed: %v", err)
+	}
+	servertest.Body(t, resp)
+	return resp
+}
+
+// TestTodoistLogin tests logging in with Todoist and managing API keys with the session token
+func TestTodoistLogin(t *testing.T) {
+	ts, platform := newLoginTestServer(t)
+	user := platform.AddUser("ada@example.com")
+	ts.Seed(t, user.ID, servertest.State{})
+
+	resp := login(t, ts, user)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("callback status = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	var session server.SessionResponse
+	servertest.DecodeJSON(t, resp, &session)
+	if session.UserID != user.ID || session.TokenType != "Bearer" || session.AccessToken == "" {
+		t.Fatalf("session = %+v", session)
+	}
+	account, err := ts.Accounts.Get(context.Background(), user.ID)
+	if err != nil || account.Email != "ada@example.com" {
+		t.Errorf("account after login = %+v, %v, want one with the Todoist email", account, err)
+	}
+
+	// The session token lists tasks and creates API keys with a subset of its scopes
+	sessionHeader := servertest.Bearer(session.AccessToken)
+	if resp := ts.Do(t, http.MethodGet, "/tasks", nil, sessionHeader); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /tasks with the session = %d, want 200", resp.StatusCode)
+	}
+	resp = ts.Do(t, http.MethodPost, "/auth/keys", server.CreateKeyRequest{Name: "ci", Scopes: []string{auth.ScopeTasksRead}}, sessionHeader)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /auth/keys = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	var created server.CreateKeyResponse
+	servertest.DecodeJSON(t, resp, &created)
+	if created.Token == "" || created.UserID != user.ID || created.Name != "ci" {
+		t.Errorf("created key = %+v", created)
+	}
+	if resp := ts.Do(t, http.MethodPost, "/auth/keys", server.CreateKeyRequest{Scopes: []string{auth.ScopeWebhooksWrite}}, sessionHeader); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /auth/keys with a scope the session lacks = %d, want 403", resp.StatusCode)
+	}
+
+	// The key only grants its own scopes
+	keyHeader := servertest.Bearer(created.Token)
+	if resp := ts.Do(t, http.MethodGet, "/tasks", nil, keyHeader); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /tasks with the key = %d, want 200", resp.StatusCode)
+	}
+	if resp := ts.Do(t, http.MethodGet, "/auth/keys", nil, keyHeader); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET /auth/keys with the key = %d, want 403", resp.StatusCode)
+	}
+
+	var list struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	servertest.DecodeJSON(t, ts.Do(t, http.MethodGet, "/auth/keys", nil, sessionHeader), &list)
+	if len(list.Keys) != 1 {
+		t.Errorf("listed %d keys, want 1", len(list.Keys))
+	}
+	if resp := ts.Do(t, http.MethodDelete, "/auth/keys/"+created.ID, nil, sessionHeader); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE /auth/keys/%s = %d, want 204", created.ID, resp.StatusCode)
+	}
+	if resp := ts.Do(t, http.MethodGet, "/tasks", nil, keyHeader); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /tasks with a revoked key = %d, want 401", resp.StatusCode)
+	}
+}
+
+// TestTodoistLoginErrors tests logins that must not issue a session
+func TestTodoistLoginErrors(t *testing.T) {
+	ts, platform := newLoginTestServer(t)
+
+	// The callback only answers the browser that started the login
+	resp := ts.Do(t, http.MethodGet, "/auth/todoist/callback?code=abc&state=forged", nil, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("callback with a forged state = %d, want 400", resp.StatusCode)
+	}
+
+	disabled := platform.AddUser("mallory@example.com")
+	ts.Accounts.Put(context.Background(), &tenant.Account{UserID: disabled.ID, Status: tenant.StatusDisabled})
+	if resp := login(t, ts, disabled); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("login of a disabled account = %d, want 403", resp.StatusCode)
+	}
+
+	ts.OAuth.ClientSecret = "wrong"
+	if resp := login(t, ts, platform.AddUser("ada@example.com")); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("login with a rejected code exchange = %d, want 502", resp.StatusCode)
+	}
+
+	ts.OAuth = nil
+	if resp := ts.Do(t, http.MethodGet, "/auth/todoist/login", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("login without TODOIST_CLIENT_ID = %d, want 404", resp.StatusCode)
+	}
+}
+
+// TestRouteScopes tests that client routes need credentials with their scope
+func TestRouteScopes(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	tasks := servertest.Bearer(ts.Token(t, "1", auth.ScopeTasksRead))
+	events := servertest.Bearer(ts.Token(t, "1", auth.ScopeEventsRead))
+
+	testCases := []struct {
+		method     string
+		path       string
+		header     http.Header
+		wantStatus int
+	}{
+		{http.MethodGet, "/tasks", nil, http.StatusUnauthorized},
+		{http.MethodGet, "/tasks", servertest.Bearer("not-a-token"), http.StatusUnauthorized},
+		{http.MethodGet, "/tasks", events, http.StatusForbidden},
+		{http.MethodGet, "/tasks", tasks, http.StatusOK},
+		{http.MethodGet, "/events/stream", nil, http.StatusUnauthorized},
+		{http.MethodGet, "/events/stream", tasks, http.StatusForbidden},
+		{http.MethodGet, "/events/ws", tasks, http.StatusForbidden},
+		{http.MethodGet, "/auth/keys", tasks, http.StatusForbidden},
+		{http.MethodGet, "/health", nil, http.StatusOK},
+	}
+	for _, tc := range testCases {
+		resp := ts.Do(t, tc.method, tc.path, nil, tc.header)
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, resp.StatusCode, tc.wantStatus, servertest.Body(t, resp))
+		}
+		if tc.wantStatus == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s %s did not ask for a bearer token", tc.method, tc.path)
+		}
+	}
+}
+
+// TestUserKeysAdmin tests managing the API keys of a user through the admin API
+func TestUserKeysAdmin(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "1"})
+
+	if resp := ts.Admin(t, http.MethodPost, "/admin/users/2/keys", server.CreateKeyRequest{Scopes: []string{auth.ScopeTasksRead}}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("creating a key of an unknown user = %d, want 404", resp.StatusCode)
+	}
+	if resp := ts.Admin(t, http.MethodPost, "/admin/users/1/keys", server.CreateKeyRequest{Scopes: []string{"tasks:write"}}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("creating a key with an unknown scope = %d, want 400", resp.StatusCode)
+	}
+
+	// Admins may grant any scope
+	resp := ts.Admin(t, http.MethodPost, "/admin/users/1/keys", server.CreateKeyRequest{Name: "ingest", Scopes: []string{auth.ScopeWebhooksWrite}})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("creating a key = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	var created server.CreateKeyResponse
+	servertest.DecodeJSON(t, resp, &created)
+	principal, err := ts.Auth.Authenticate(context.Background(), created.Token)
+	if err != nil || !principal.HasScope(auth.ScopeWebhooksWrite) {
+		t.Errorf("Authenticate() = %+v, %v, want webhooks:write", principal, err)
+	}
+
+	var list struct {
+		Keys []auth.Key `json:"keys"`
+	}
+	servertest.DecodeJSON(t, ts.Admin(t, http.MethodGet, "/admin/users/1/keys", nil), &list)
+	if len(list.Keys) != 1 || list.Keys[0].ID != created.ID {
+		t.Errorf("keys = %+v, want [%s]", list.Keys, created.ID)
+	}
+	if resp := ts.Admin(t, http.MethodDelete, "/admin/users/1/keys/"+created.ID, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("deleting the key = %d, want 204", resp.StatusCode)
+	}
+	if resp := ts.Admin(t, http.MethodDelete, "/admin/users/1/keys/"+created.ID, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleting the key again = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/internal/digest"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// digestContentTypes are the content types of the digest formats
+var digestContentTypes = map[string]string{
+	digest.FormatMarkdown: "text/markdown; charset=utf-8",
+	digest.FormatHTML:     "text/html; charset=utf-8",
+	digest.FormatText:     "text/plain; charset=utf-8",
+}
+
+// PreviewDigestHandler renders the digest of a user as it would be sent now, without sending it.
+// The frequency defaults to the user's digest setting, or daily when digests are off, and the
+// format to HTML.
+func (s *Server) PreviewDigestHandler(w http.ResponseWriter, r *http.Request) {
+	account, frequency, ok := s.digestAccount(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = digest.FormatHTML
+	}
+	contentType, ok := digestContentTypes[format]
+	if !ok {
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "format must be markdown, html or text")
+		return
+	}
+
+	message, err := s.Digests.Preview(r.Context(), account, frequency)
+	if err != nil {
+		s.logger.Error("Error building digest: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	body, _ := message.Body(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Digest-Subject", message.Subject)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body))
+}
+
+// SendDigestHandler sends the digest of a user through the configured notifier now
+func (s *Server) SendDigestHandler(w http.ResponseWriter, r *http.Request) {
+	account, frequency, ok := s.digestAccount(w, r)
+	if !ok {
+		return
+	}
+	message, err := s.Digests.Send(r.Context(), account, frequency)
+	if errors.Is(err, digest.ErrNoNotifier) {
+		writeErrorCode(w, http.StatusServiceUnavailable, api.CodeUnavailable, "digests are not delivered: CHERRY_DIGEST_NOTIFIER is not set")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Error sending digest: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	d := message.Digest
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":   d.UserID,
+		"frequency": d.Frequency,
+		"subject":   message.Subject,
+		"completed": len(d.Completed),
+		"due_today": len(d.DueToday),
+		"overdue":   len(d.Overdue),
+	})
+}
+
+// digestAccount reads the account and digest frequency of a digest request, writing the error
+// response if they are invalid
+func (s *Server) digestAccount(w http.ResponseWriter, r *http.Request) (*tenant.Account, string, bool) {
+	account, err := s.Accounts.Get(r.Context(), mux.Vars(r)["user_id"])
+	if err != nil {
+		s.writeAccountError(w, err)
+		return nil, "", false
+	}
+	frequency := r.URL.Query().Get("frequency")
+	if frequency == "" {
+		frequency = account.Settings.Notifications.Digest
+	}
+	switch frequency {
+	case tenant.DigestDaily, tenant.DigestWeekly:
+	case "", tenant.DigestOff:
+		frequency = tenant.DigestDaily
+	default:
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "frequency must be daily or weekly")
+		return nil, "", false
+	}
+	return account, frequency, true
+}
@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/server/servertest"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// TestDigestPreview tests rendering the digest of a user from webhooks and the mirror in every format
+func TestDigestPreview(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "1", Email: "ada@example.com", Settings: tenant.Settings{
+		Timezone:      "Europe/Berlin",
+		Notifications: tenant.Notifications{Digest: tenant.DigestDaily},
+	}})
+	ts.Clock.Set(time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC))
+	ts.Seed(t, "1", servertest.State{
+		Tasks: []api.Task{
+			{ID: "1", Content: "File taxes", ProjectID: "work", Priority: 4, Due: &api.Due{Date: "2024-03-01"}},
+			{ID: "2", Content: "Buy milk & eggs", Due: &api.Due{Date: "2024-03-04"}},
+		},
+		Projects: []api.Project{{ID: "work", Name: "Work"}},
+	})
+	ts.Webhook(t, taskWebhook(t, "item:completed", "1", api.Task{ID: "3", Content: "Write report", ProjectID: "work", CompletedAt: "2024-03-03T18:00:00Z"}))
+	ts.Webhook(t, taskWebhook(t, "item:completed", "1", api.Task{ID: "4", Content: "Two days ago", CompletedAt: "2024-03-02T18:00:00Z"}))
+
+	for _, format := range []string{"markdown", "html", "text"} {
+		resp := ts.Admin(t, http.MethodGet, "/admin/users/1/digest?format="+format, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET digest = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+		}
+		if subject := resp.Header.Get("X-Digest-Subject"); subject != "Your daily digest for Monday, March 4" {
+			t.Errorf("subject = %q", subject)
+		}
+		servertest.AssertGolden(t, "digest_"+format, []byte(servertest.Body(t, resp)))
+	}
+}
+
+// TestSendDigest tests sending a digest on demand through the configured notifier
+func TestSendDigest(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHERRY_DIGEST_NOTIFIER", "file:"+dir)
+	ts := servertest.NewTestServer(t)
+	ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "1"})
+
+	testCases := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{http.MethodGet, "/admin/users/2/digest", http.StatusNotFound},
+		{http.MethodGet, "/admin/users/1/digest?format=pdf", http.StatusBadRequest},
+		{http.MethodPost, "/admin/users/1/digest?frequency=hourly", http.StatusBadRequest},
+		{http.MethodPost, "/admin/users/1/digest?frequency=weekly", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		if resp := ts.Admin(t, tc.method, tc.path, nil); resp.StatusCode != tc.wantStatus {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, resp.StatusCode, tc.wantStatus, servertest.Body(t, resp))
+		}
+	}
+
+	name := filepath.Join(dir, "1", ts.Now().Format("2006-01-02")+"-weekly.md")
+	if _, err := os.Stat(name); err != nil {
+		t.Errorf("digest was not written: %v", err)
+	}
+
+	ts.Digests.Notifier = nil
+	if resp := ts.Admin(t, http.MethodPost, "/admin/users/1/digest", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("POST digest without notifier = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"cherry_backend/pkg/api"
+)
+
+// requestIDHeader carries the ID of a request in both directions
+const requestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// requestIDMiddleware gives every request an ID, reusing a sane X-Request-Id from the client,
+// and echoes it in the response so that errors can be matched with server logs
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID reports whether a client-supplied request ID is short and printable
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the ID assigned to a request by the server, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// writeError writes the JSON error envelope with the default code of the status
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorCode(w, status, api.ErrorCodeForStatus(status), message)
+}
+
+// writeErrorCode writes the JSON error envelope with an explicit error code
+func writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, api.ErrorEnvelope{Error: &api.APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: w.Header().Get(requestIDHeader),
+	}})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+	"cherry_backend/testing/faketodoist"
+)
+
+// TestFakeTodoistLoop tests the full loop against a fake Todoist platform: changes made through
+// the Todoist API reach the server as signed webhooks, which sync them into the mirror
+func TestFakeTodoistLoop(t *testing.T) {
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	platform := faketodoist.NewPlatform()
+	defer platform.Close()
+	user := platform.AddUser("ada@example.com")
+
+	t.Setenv("TODOIST_CLIENT_SECRET", platform.ClientSecret)
+	t.Setenv("TODOIST_API_URL", platform.APIURL())
+	t.Setenv("TODOIST_API_TOKENS", platform.Tokens())
+	s := NewServer()
+	defer s.Fanout.Close()
+	httpServer := httptest.NewServer(s.Router)
+	defer httpServer.Close()
+	platform.SetWebhookURL(httpServer.URL + "/webhooks/todoist")
+
+	tasks := api.NewTaskClient(httpServer.URL)
+	tasks.Generator.Use(api.BearerAuth(user.Token))
+	listTasks := func() []string {
+		t.Helper()
+		// The webhook has been delivered when the Todoist call returns; the sync it triggered
+		// runs in the background
+		s.Mirror.Wait()
+		response, err := tasks.ListTasks(context.Background(), &apiv1.ListTasksRequest{UserId: user.ID})
+		if err != nil {
+			t.Fatalf("ListTasks failed: %v", err)
+		}
+		return taskIDs(response)
+	}
+
+	client := platform.Client(user)
+	task, err := client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Buy milk"})
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if got := listTasks(); len(got) != 1 || got[0] != task.ID {
+		t.Fatalf("tasks after creating = %v, want [%s]", got, task.ID)
+	}
+
+	if err := client.CloseTask(context.Background(), task.ID); err != nil {
+		t.Fatalf("CloseTask failed: %v", err)
+	}
+	if got := listTasks(); len(got) != 0 {
+		t.Errorf("tasks after completing = %v, want none", got)
+	}
+
+	for _, delivery := range platform.Deliveries() {
+		if delivery.StatusCode != http.StatusOK {
+			t.Errorf("delivery %+v, want 200", delivery)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/ratelimit"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// newGRPCTestConn serves the gRPC services of a server over an in-memory listener and calls
+// them with token, unless it is empty
+func newGRPCTestConn(t *testing.T, s *Server, token string) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := s.NewGRPCServer()
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	options := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	if token != "" {
+		options = append(options, grpc.WithPerRPCCredentials(api.BearerCredentials(token, false)))
+	}
+	conn, err := grpc.NewClient("passthrough:///bufnet", options...)
+	if err != nil {
+		t.Fatalf("failed to dial gRPC server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// TestSubscribeEvents tests resuming and filtering the SubscribeEvents stream
+func TestSubscribeEvents(t *testing.T) {
+	s, _ := newStreamTestServer(t)
+	client := apiv1.NewTodoistServiceClient(newGRPCTestConn(t, s, "token-1"))
+	publish(t, s, "item:added", "1")
+	publish(t, s, "item:completed", "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.SubscribeEvents(ctx, &apiv1.SubscribeEventsRequest{
+		UserId:      "1",
+		EventNames:  []string{"item:completed", "note:*"},
+		ResumeAfter: 1,
+	})
+	if err != nil {
+		t.Fatalf("SubscribeEvents failed: %v", err)
+	}
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if event.Id != 2 || event.EventName != "item:completed" || event.ResourceId != "7" {
+		t.Errorf("first event = %v, want the stored item:completed event 2", event)
+	}
+
+	publish(t, s, "item:added", "1")
+	publish(t, s, "note:added", "2")
+	publish(t, s, "note:added", "1")
+	if event, err = stream.Recv(); err != nil || event.Id != 5 {
+		t.Errorf("live event = %v (%v), want note:added event 5", event, err)
+	}
+}
+
+// TestGRPCAuth tests that calls need credentials with the scope of their method
+func TestGRPCAuth(t *testing.T) {
+	s, _ := newStreamTestServer(t)
+	tasksOnly, _, err := s.Auth.Sessions.Issue("1", []string{auth.ScopeTasksRead})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		request *apiv1.SubscribeEventsRequest
+		want    codes.Code
+	}{
+		{"no credentials", "", &apiv1.SubscribeEventsRequest{UserId: "1"}, codes.Unauthenticated},
+		{"unknown token", "token-2", &apiv1.SubscribeEventsRequest{UserId: "1"}, codes.Unauthenticated},
+		{"missing scope", tasksOnly, &apiv1.SubscribeEventsRequest{UserId: "1"}, codes.PermissionDenied},
+		{"other user", "token-1", &apiv1.SubscribeEventsRequest{UserId: "2"}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := apiv1.NewTodoistServiceClient(newGRPCTestConn(t, s, tt.token))
+			stream, err := client.SubscribeEvents(context.Background(), tt.request)
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if status.Code(err) != tt.want {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Over gRPC there is no Todoist signature, so submitting webhooks needs webhooks:write
+	client := apiv1.NewTodoistServiceClient(newGRPCTestConn(t, s, "token-1"))
+	if _, err := client.ProcessWebhook(context.Background(), &apiv1.TodoistWebhookRequest{EventName: "item:added", UserId: "1"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ProcessWebhook error = %v, want PermissionDenied", err)
+	}
+
+	// A key with webhooks:write may only submit the webhooks of its own user
+	token, key, err := auth.NewKey("1", "webhooks", []string{auth.ScopeWebhooksWrite}, nil, time.Now())
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	if err := s.Auth.Keys.Create(context.Background(), key); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	client = apiv1.NewTodoistServiceClient(newGRPCTestConn(t, s, token))
+	if _, err := client.ProcessWebhook(context.Background(), &apiv1.TodoistWebhookRequest{EventName: "item:added", UserId: "1"}); err != nil {
+		t.Errorf("ProcessWebhook of the key's user failed: %v", err)
+	}
+	if _, err := client.ProcessWebhook(context.Background(), &apiv1.TodoistWebhookRequest{EventName: "item:added", UserId: "2"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ProcessWebhook for another user error = %v, want PermissionDenied", err)
+	}
+}
+
+// TestGRPCRateLimit tests that calls over the limit get RESOURCE_EXHAUSTED with retry-after
+func TestGRPCRateLimit(t *testing.T) {
+	s, _ := newStreamTestServer(t)
+	s.RateLimits = ratelimit.Limits{"tasks": {Events: 1, Per: time.Minute, Burst: 1}}
+	client := apiv1.NewTaskServiceClient(newGRPCTestConn(t, s, "token-1"))
+
+	if _, err := client.ListTasks(context.Background(), &apiv1.ListTasksRequest{}); err != nil {
+		t.Fatalf("first ListTasks failed: %v", err)
+	}
+	var header metadata.MD
+	_, err := client.ListTasks(context.Background(), &apiv1.ListTasksRequest{}, grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second ListTasks = %v, want ResourceExhausted", err)
+	}
+	if got := header.Get("retry-after"); len(got) != 1 || got[0] != "60" {
+		t.Errorf("retry-after = %v, want 60", got)
+	}
+}
+
+// TestGRPCRateLimitUnauthenticated tests that calls with bad credentials are limited by address
+func TestGRPCRateLimitUnauthenticated(t *testing.T) {
+	s, _ := newStreamTestServer(t)
+	s.RateLimits = ratelimit.Limits{"ip": {Events: 1, Per: time.Minute, Burst: 2}}
+	client := apiv1.NewTaskServiceClient(newGRPCTestConn(t, s, "wrong-token"))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListTasks(context.Background(), &apiv1.ListTasksRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("call %d = %v, want Unauthenticated", i, err)
+		}
+	}
+	if _, err := client.ListTasks(context.Background(), &apiv1.ListTasksRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("call over the limit = %v, want ResourceExhausted", err)
+	}
+}
+
+// TestEventSubscriberReconnects tests that the client helper resumes after the server goes away
+func TestEventSubscriberReconnects(t *testing.T) {
+	s, _ := newStreamTestServer(t)
+
+	// Serve on a listener that can be replaced to simulate a server restart
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := s.NewGRPCServer()
+	go grpcServer.Serve(listener)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(api.BearerCredentials("token-1", false)))
+	if err != nil {
+		t.Fatalf("failed to dial gRPC server: %v", err)
+	}
+	defer conn.Close()
+
+	subscriber := api.NewEventSubscriber(conn)
+	subscriber.MinBackoff = time.Millisecond
+	disconnected := make(chan struct{}, 10)
+	subscriber.OnDisconnect = func(err error) { disconnected <- struct{}{} }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan uint64, 10)
+	go subscriber.Subscribe(ctx, &apiv1.SubscribeEventsRequest{UserId: "1"}, func(event *apiv1.Event) error {
+		received <- event.Id
+		return nil
+	})
+
+	waitFor := func(want uint64) {
+		t.Helper()
+		for {
+			publish(t, s, "item:added", "1")
+			select {
+			case id := <-received:
+				if id >= want {
+					return
+				}
+			case <-time.After(50 * time.Millisecond):
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for event %d", want)
+			}
+		}
+	}
+	waitFor(1)
+
+	// Restart the server; events published while it is down are delivered after the reconnect
+	grpcServer.Stop()
+	<-disconnected
+	listener = bufconn.Listen(1 << 20)
+	grpcServer = s.NewGRPCServer()
+	defer grpcServer.Stop()
+
+	before, _ := s.Events.Store.LastID(ctx)
+	publish(t, s, "item:completed", "1")
+	go grpcServer.Serve(listener)
+
+	for {
+		select {
+		case id := <-received:
+			if id == before+1 {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatalf("event %d published while disconnected was not delivered", before+1)
+		}
+	}
+}
@@ -5,27 +5,24 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/models"
+	"cherry_backend/pkg/api"
 	apiv1 "cherry_backend/pkg/api/v1"
 )
 
 // TodoistWebhookHandler processes incoming webhook notifications from Todoist
 func (s *Server) TodoistWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	// Create a new TodoistServiceImpl with a logger
-	todoistService, err := NewTodoistServiceImpl()
-	if err != nil {
-		log.Printf("Error creating TodoistServiceImpl: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	// Use the logger for all logging
-	logger := todoistService.Logger
+	todoistService := s.todoistService()
+	logger := s.logger
 	logger.Info("Received webhook request from Todoist")
 
 	// Get the client secret from environment variables
@@ -40,7 +37,7 @@ func (s *Server) TodoistWebhookHandler(w http.ResponseWriter, r *http.Request) {
 		signature := r.Header.Get("X-Todoist-Hmac-SHA256")
 		if signature == "" {
 			logger.Error("Missing X-Todoist-Hmac-SHA256 header")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "missing X-Todoist-Hmac-SHA256 header")
 			return
 		}
 
@@ -48,7 +45,7 @@ func (s *Server) TodoistWebhookHandler(w http.ResponseWriter, r *http.Request) {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			logger.Error("Error reading request body: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, "Internal Server Error")
 			return
 		}
 
@@ -58,7 +55,7 @@ func (s *Server) TodoistWebhookHandler(w http.ResponseWriter, r *http.Request) {
 		// Verify the signature
 		if !verifyTodoistSignature(body, signature, clientSecret) {
 			logger.Error("Invalid signature")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "invalid signature")
 			return
 		}
 
@@ -68,19 +65,29 @@ func (s *Server) TodoistWebhookHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse the webhook payload
-	var request apiv1.TodoistWebhookRequest
-	err = json.NewDecoder(r.Body).Decode(&request)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("Error reading request body: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	request, err := models.ParseWebhookRequest(body)
 	if err != nil {
 		logger.Error("Error parsing webhook payload: %v", err)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
 		return
 	}
 
-	// Process the webhook
-	response, err := todoistService.ProcessWebhook(r.Context(), &request)
+	// Process the webhook, keeping the raw delivery for recording
+	ctx := events.WithDelivery(r.Context(), events.NewDelivery(r.Header, body))
+	response, err := todoistService.ProcessWebhook(ctx, request)
+	if errors.Is(err, ErrPermissionDenied) {
+		writeErrorCode(w, http.StatusForbidden, api.CodePermission, err.Error())
+		return
+	}
 	if err != nil {
 		logger.Error("Error processing webhook: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -90,7 +97,7 @@ func (s *Server) TodoistWebhookHandler(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		logger.Error("Error encoding response: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 }
@@ -112,16 +119,7 @@ func verifyTodoistSignature(payload []byte, signature, secret string) bool {
 
 // HealthCheckHandler performs a health check
 func (s *Server) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	// Create a new TodoistServiceImpl with a logger (we'll use this logger for health checks too)
-	todoistService, err := NewTodoistServiceImpl()
-	if err != nil {
-		log.Printf("Error creating TodoistServiceImpl for health check: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	// Use the logger for all logging
-	logger := todoistService.Logger
+	logger := s.logger
 	logger.Info("Received health check request")
 
 	// Create request
@@ -132,7 +130,7 @@ func (s *Server) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	response, err := healthService.Check(request)
 	if err != nil {
 		logger.Error("Error performing health check: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -143,3 +141,91 @@ func (s *Server) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// ListTasksHandler lists the mirrored tasks of a user
+func (s *Server) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received list tasks request")
+
+	// Parse the query parameters
+	request, err := parseListTasksRequest(r.URL.Query())
+	if err != nil {
+		s.logger.Warn("Invalid list tasks request: %v", err)
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+		return
+	}
+
+	// List the tasks
+	response, err := s.taskService().ListTasks(r.Context(), request)
+	if errors.Is(err, ErrPermissionDenied) {
+		s.logger.Warn("Refused list tasks request: %v", err)
+		writeErrorCode(w, http.StatusForbidden, api.CodePermission, err.Error())
+		return
+	}
+	if errors.Is(err, ErrInvalidArgument) {
+		s.logger.Warn("Invalid list tasks request: %v", err)
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("Error listing tasks: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// listTasksAliases maps the original query parameter names of /tasks to the proto field names
+var listTasksAliases = map[string]string{
+	"label":    "labels",
+	"priority": "priorities",
+	"q":        "query",
+}
+
+// parseListTasksRequest builds a ListTasksRequest from URL query parameters
+func parseListTasksRequest(query url.Values) (*apiv1.ListTasksRequest, error) {
+	request := &apiv1.ListTasksRequest{}
+	if err := api.DecodeQuery(query, request, listTasksAliases); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if request.Completion == apiv1.TaskCompletion_TASK_COMPLETION_UNSPECIFIED {
+		request.Completion = apiv1.TaskCompletion_TASK_COMPLETION_ACTIVE
+	}
+	return request, nil
+}
+
+// GetStatsHandler returns the productivity statistics of a user
+func (s *Server) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received stats request")
+
+	// Parse the query parameters
+	request := &apiv1.GetStatsRequest{}
+	if err := api.DecodeQuery(r.URL.Query(), request, nil); err != nil {
+		s.logger.Warn("Invalid stats request: %v", err)
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+		return
+	}
+
+	// Build the statistics
+	response, err := s.statsService().GetStats(r.Context(), request)
+	if errors.Is(err, ErrPermissionDenied) {
+		s.logger.Warn("Refused stats request: %v", err)
+		writeErrorCode(w, http.StatusForbidden, api.CodePermission, err.Error())
+		return
+	}
+	if errors.Is(err, ErrInvalidArgument) {
+		s.logger.Warn("Invalid stats request: %v", err)
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("Error building stats: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/scheduler"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/storage"
+)
+
+// Names of the scheduled jobs
+const (
+	jobDigests        = "digests"
+	jobReconcile      = "reconcile"
+	jobFullSync       = "full-sync"
+	jobLogRetention   = "log-retention"
+	jobTokenRefresh   = "token-refresh"
+	jobStatsDedupe    = "stats-dedupe"
+	jobEventRetention = "event-retention"
+)
+
+const (
+	// defaultReconcileInterval is how often the reconcile job re-syncs every mirrored user to
+	// heal missed webhooks
+	defaultReconcileInterval = 15 * time.Minute
+
+	// fullSyncEvery makes the full-sync job run every Nth reconcile interval
+	fullSyncEvery = 24
+
+	// tokenRefreshInterval is how often the Todoist tokens saved by logins are reloaded
+	tokenRefreshInterval = 5 * time.Minute
+)
+
+// newSchedulerFromEnv creates the job scheduler, sharing locks and run history through the
+// database when there is one so that instances do not run the same job twice
+func newSchedulerFromEnv(db *storage.DB, logger logging.Logger) *scheduler.Scheduler {
+	if db != nil {
+		jobs := db.Jobs()
+		return scheduler.New(jobs, jobs, logger)
+	}
+	return scheduler.New(scheduler.NewMemoryLocker(), scheduler.NewMemoryHistory(), logger)
+}
+
+// jobSchedulesFromEnv reads CHERRY_JOB_SCHEDULES, a semicolon-separated list of job=schedule
+// pairs overriding the schedules of jobs, where the schedule "off" disables a job
+func jobSchedulesFromEnv(logger logging.Logger) map[string]string {
+	schedules := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("CHERRY_JOB_SCHEDULES"), ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			logger.Warn("Ignoring invalid CHERRY_JOB_SCHEDULES entry %q", pair)
+			continue
+		}
+		schedules[strings.TrimSpace(name)] = strings.TrimSpace(spec)
+	}
+	return schedules
+}
+
+// addJobs schedules the periodic work of the server: sending digests, reconciling and fully
+// re-syncing the mirror, pruning old log files, stored events and stats delivery IDs, and
+// reloading Todoist tokens
+func (s *Server) addJobs(tokens *mirror.RefreshingTokens) {
+	var jobs []scheduler.Job
+	if s.Digests.Notifier != nil {
+		jobs = append(jobs, scheduler.Job{
+			Name:   jobDigests,
+			Spec:   "0 * * * *",
+			Jitter: 2 * time.Minute,
+			Run: func(ctx context.Context) error {
+				_, err := s.Digests.SendDue(ctx)
+				return err
+			},
+		})
+	}
+	if s.Mirror != nil {
+		interval := reconcileIntervalFromEnv(s.logger)
+		jobs = append(jobs, scheduler.Job{
+			Name:   jobReconcile,
+			Spec:   "@every " + interval.String(),
+			Jitter: interval / 10,
+			Local:  true,
+			Run:    func(ctx context.Context) error { return s.Mirror.Reconcile(ctx, false) },
+		}, scheduler.Job{
+			Name:   jobFullSync,
+			Spec:   "@every " + (interval * fullSyncEvery).String(),
+			Jitter: interval / 10,
+			Missed: scheduler.MissedRunOnce,
+			Local:  true,
+			Run:    func(ctx context.Context) error { return s.Mirror.Reconcile(ctx, true) },
+		})
+	}
+	if tokens.Load != nil {
+		jobs = append(jobs, scheduler.Job{
+			Name:  jobTokenRefresh,
+			Spec:  "@every " + tokenRefreshInterval.String(),
+			Local: true,
+			Run: func(ctx context.Context) error {
+				_, err := tokens.Refresh(ctx)
+				return err
+			},
+		})
+	}
+	retention := daysFromEnv(s.logger, "CHERRY_LOG_RETENTION_DAYS", logging.DefaultRetention)
+	jobs = append(jobs, scheduler.Job{
+		Name:   jobLogRetention,
+		Spec:   "30 3 * * *",
+		Jitter: 10 * time.Minute,
+		Missed: scheduler.MissedRunOnce,
+		Local:  true,
+		Run: func(ctx context.Context) error {
+			removed, err := logging.Prune(s.Now(), retention)
+			if len(removed) > 0 {
+				s.logger.Info("Removed %d log file(s) older than %s", len(removed), retention)
+			}
+			return err
+		},
+	})
+	if s.Storage != nil {
+		keep := eventRetentionFromEnv(s.logger)
+		jobs = append(jobs, scheduler.Job{
+			Name:   jobEventRetention,
+			Spec:   "40 3 * * *",
+			Jitter: 10 * time.Minute,
+			Missed: scheduler.MissedRunOnce,
+			Run: func(ctx context.Context) error {
+				deleted, err := s.Storage.Events().Prune(ctx, keep)
+				if deleted > 0 {
+					s.logger.Info("Deleted %d stored event(s) beyond the newest %d", deleted, keep)
+				}
+				return err
+			},
+		})
+	}
+	replayWindow := daysFromEnv(s.logger, "CHERRY_STATS_REPLAY_DAYS", stats.DefaultReplayWindow)
+	jobs = append(jobs, scheduler.Job{
+		Name:   jobStatsDedupe,
+		Spec:   "45 3 * * *",
+		Jitter: 10 * time.Minute,
+		Missed: scheduler.MissedRunOnce,
+		Run: func(ctx context.Context) error {
+			pruned, err := s.Stats.Prune(ctx, s.Now().Add(-replayWindow))
+			if pruned > 0 {
+				s.logger.Info("Forgot %d stats delivery ID(s) older than %s", pruned, replayWindow)
+			}
+			return err
+		},
+	})
+
+	schedules := jobSchedulesFromEnv(s.logger)
+	for _, job := range jobs {
+		if spec, ok := schedules[job.Name]; ok {
+			delete(schedules, job.Name)
+			if spec == "off" {
+				s.logger.Info("Job %s disabled", job.Name)
+				continue
+			}
+			job.Spec = spec
+		}
+		if err := s.Scheduler.Add(job); err != nil {
+			s.logger.Error("Job %s disabled: %v", job.Name, err)
+		}
+	}
+	for name := range schedules {
+		s.logger.Warn("Ignoring schedule of unknown job %s", name)
+	}
+}
+
+// reconcileIntervalFromEnv reads the interval of the reconcile job from TODOIST_RECONCILE_INTERVAL
+func reconcileIntervalFromEnv(logger logging.Logger) time.Duration {
+	value := os.Getenv("TODOIST_RECONCILE_INTERVAL")
+	if value == "" {
+		return defaultReconcileInterval
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		logger.Warn("Ignoring invalid TODOIST_RECONCILE_INTERVAL %q", value)
+		return defaultReconcileInterval
+	}
+	return d
+}
+
+// daysFromEnv reads a number of days from an environment variable, such as how many days of
+// log files CHERRY_LOG_RETENTION_DAYS keeps, or returns fallback
+func daysFromEnv(logger logging.Logger, name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		logger.Warn("Ignoring invalid %s %q", name, value)
+		return fallback
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// eventRetentionFromEnv reads how many stored events CHERRY_EVENT_RETENTION keeps
+func eventRetentionFromEnv(logger logging.Logger) int {
+	value := os.Getenv("CHERRY_EVENT_RETENTION")
+	if value == "" {
+		return events.DefaultRetention
+	}
+	keep, err := strconv.Atoi(value)
+	if err != nil || keep <= 0 {
+		logger.Warn("Ignoring invalid CHERRY_EVENT_RETENTION %q", value)
+		return events.DefaultRetention
+	}
+	return keep
+}
+
+// storedTokens loads the Todoist API tokens saved by logins from the database
+func storedTokens(db *storage.DB) func(ctx context.Context) (map[string]string, error) {
+	return func(ctx context.Context) (map[string]string, error) {
+		users, err := db.Tokens().Users(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tokens := make(map[string]string, len(users))
+		for _, userID := range users {
+			token, err := db.Tokens().Get(ctx, userID)
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read token of user %s: %w", userID, err)
+			}
+			tokens[userID] = token
+		}
+		return tokens, nil
+	}
+}
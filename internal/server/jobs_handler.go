@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/internal/scheduler"
+	"cherry_backend/pkg/api"
+)
+
+// maxJobRunsPage caps the limit of ListJobRunsHandler
+const maxJobRunsPage = 100
+
+// ListJobsHandler lists the scheduled jobs with their schedules and next runs
+func (s *Server) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"instance": s.Scheduler.Instance, "jobs": s.Scheduler.Jobs()})
+}
+
+// ListJobRunsHandler returns the run history of a job, newest first
+func (s *Server) ListJobRunsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "limit must be a positive number")
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxJobRunsPage {
+		limit = maxJobRunsPage
+	}
+
+	runs, err := s.Scheduler.Runs(r.Context(), mux.Vars(r)["name"], limit)
+	if err != nil {
+		s.writeJobError(w, err)
+		return
+	}
+	if runs == nil {
+		runs = []scheduler.Run{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"runs": runs})
+}
+
+// RunJobHandler starts a run of a job now, whatever its schedule. The run happens in the
+// background; its outcome shows up in the run history.
+func (s *Server) RunJobHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := s.Scheduler.Trigger(name); err != nil {
+		s.writeJobError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"job": name, "trigger": scheduler.TriggerManual})
+}
+
+// writeJobError maps scheduler errors to HTTP responses
+func (s *Server) writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, scheduler.ErrJobRunning):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		s.logger.Error("Error accessing jobs: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
@@ -0,0 +1,107 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/scheduler"
+	"cherry_backend/internal/server/servertest"
+	"cherry_backend/internal/stats"
+)
+
+// TestJobs tests listing the scheduled jobs, running one by hand and reading its run history
+func TestJobs(t *testing.T) {
+	t.Setenv("CHERRY_DIGEST_NOTIFIER", "file:"+t.TempDir())
+	t.Setenv("CHERRY_JOB_SCHEDULES", "digests=15 * * * *; log-retention=off; stats-dedupe=off")
+	ts := servertest.NewTestServer(t)
+
+	var list struct {
+		Jobs []scheduler.JobStatus `json:"jobs"`
+	}
+	resp := ts.Admin(t, http.MethodGet, "/admin/jobs", nil)
+	servertest.DecodeJSON(t, resp, &list)
+	if len(list.Jobs) != 1 || list.Jobs[0].Name != "digests" || list.Jobs[0].Schedule != "15 * * * *" {
+		t.Fatalf("jobs = %+v, want only digests at minute 15", list.Jobs)
+	}
+
+	if resp := ts.Admin(t, http.MethodPost, "/admin/jobs/digests/run", nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST run = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	ts.Scheduler.Wait()
+
+	var history struct {
+		Runs []scheduler.Run `json:"runs"`
+	}
+	resp = ts.Admin(t, http.MethodGet, "/admin/jobs/digests/runs?limit=5", nil)
+	servertest.DecodeJSON(t, resp, &history)
+	if len(history.Runs) != 1 || history.Runs[0].Trigger != scheduler.TriggerManual || history.Runs[0].Status != scheduler.StatusSucceeded {
+		t.Errorf("runs = %+v, want the manual run", history.Runs)
+	}
+
+	testCases := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{http.MethodPost, "/admin/jobs/log-retention/run", http.StatusNotFound},
+		{http.MethodGet, "/admin/jobs/nightly/runs", http.StatusNotFound},
+		{http.MethodGet, "/admin/jobs/digests/runs?limit=-1", http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		if resp := ts.Admin(t, tc.method, tc.path, nil); resp.StatusCode != tc.wantStatus {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, resp.StatusCode, tc.wantStatus, servertest.Body(t, resp))
+		}
+	}
+}
+
+// TestStatsDedupeJob tests that the stats-dedupe job forgets delivery IDs older than the window
+func TestStatsDedupeJob(t *testing.T) {
+	t.Setenv("CHERRY_STATS_REPLAY_DAYS", "2")
+	ts := servertest.NewTestServer(t)
+	store := ts.Stats.(*stats.MemoryStore)
+	ctx := context.Background()
+
+	store.Now = ts.Clock.Now
+	entry := stats.Entry{ID: "delivery", Date: "2024-03-01", Counts: stats.Counts{Completed: 1}}
+	store.Record(ctx, "1", entry)
+	ts.Clock.Advance(3 * 24 * time.Hour)
+	store.Record(ctx, "1", stats.Entry{ID: "recent", Date: "2024-03-01", Counts: stats.Counts{Completed: 1}})
+
+	if resp := ts.Admin(t, http.MethodPost, "/admin/jobs/stats-dedupe/run", nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST run = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	ts.Scheduler.Wait()
+
+	// The old delivery counts again, the recent one is still ignored
+	store.Record(ctx, "1", entry)
+	store.Record(ctx, "1", stats.Entry{ID: "recent", Date: "2024-03-01", Counts: stats.Counts{Completed: 1}})
+	if days, _ := store.Days(ctx, "1", "2024-03-01", "2024-03-01"); len(days) != 1 || days[0].Completed != 3 {
+		t.Errorf("days = %+v, want 3 completions", days)
+	}
+}
+
+// TestEventRetentionJob tests that the event-retention job keeps the newest stored events
+func TestEventRetentionJob(t *testing.T) {
+	t.Setenv("CHERRY_DATABASE_URL", "sqlite:"+filepath.Join(t.TempDir(), "cherry.db"))
+	t.Setenv("CHERRY_EVENT_RETENTION", "1")
+	ts := servertest.NewTestServer(t)
+	if ts.Storage == nil {
+		t.Fatalf("storage is disabled; logs:\n%s", ts.Logger)
+	}
+	defer ts.Storage.Close()
+	for _, id := range []string{"7", "8"} {
+		ts.Webhook(t, []byte(`{"event_name": "item:added", "user_id": "1", "event_data": {"id": "`+id+`"}, "version": "9"}`))
+	}
+
+	if resp := ts.Admin(t, http.MethodPost, "/admin/jobs/event-retention/run", nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST run = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	ts.Scheduler.Wait()
+
+	kept, err := ts.Storage.Events().Since(context.Background(), "", 0, 0)
+	if err != nil || len(kept) != 1 || kept[0].ResourceID != "8" {
+		t.Errorf("stored events = %v, %v, want only the newest", kept, err)
+	}
+}
@@ -1,190 +0,0 @@
-package server
-
-import (
-	"context"
-	"testing"
-)
-
-// MockTodoistWebhookRequest is a mock implementation of the TodoistWebhookRequest
-type MockTodoistWebhookRequest struct {
-	EventName string
-	UserId    string
-	EventData string
-	Version   string
-}
-
-// MockTodoistWebhookResponse is a mock implementation of the TodoistWebhookResponse
-type MockTodoistWebhookResponse struct {
-	Success bool
-	Message string
-}
-
-// MockHealthCheckRequest is a mock implementation of the HealthCheckRequest
-type MockHealthCheckRequest struct {
-	// Empty struct as the original has no fields
-}
-
-// MockHealthCheckResponse is a mock implementation of the HealthCheckResponse
-type MockHealthCheckResponse struct {
-	Status string
-}
-
-// MockTodoistService is a mock implementation of the TodoistService
-type MockTodoistService struct{}
-
-// ProcessWebhook is a mock implementation of the ProcessWebhook method
-func (s *MockTodoistService) ProcessWebhook(ctx context.Context, request *MockTodoistWebhookRequest) (*MockTodoistWebhookResponse, error) {
-	// Log the event (similar to the real implementation)
-	// In a real test, you might want to use a test logger or capture the output
-
-	// Handle different event types (similar to the real implementation)
-	switch request.EventName {
-	case "item:added":
-		// Handle item added event
-	case "item:updated":
-		// Handle item updated event
-	case "item:deleted":
-		// Handle item deleted event
-	case "item:completed":
-		// Handle item completed event
-	default:
-		// Handle unknown event type
-	}
-
-	// Return success response (similar to the real implementation)
-	return &MockTodoistWebhookResponse{
-		Success: true,
-		Message: "Webhook received",
-	}, nil
-}
-
-// MockHealthService is a mock implementation of the HealthService
-type MockHealthService struct{}
-
-// Check is a mock implementation of the Check method
-func (s *MockHealthService) Check(request *MockHealthCheckRequest) (*MockHealthCheckResponse, error) {
-	// Return success response (similar to the real implementation)
-	return &MockHealthCheckResponse{
-		Status: "OK",
-	}, nil
-}
-
-func TestMockTodoistService(t *testing.T) {
-	// Create a new MockTodoistService
-	service := &MockTodoistService{}
-
-	// Create a context for the tests
-	ctx := context.Background()
-
-	// Define test cases
-	testCases := []struct {
-		name        string
-		request     *MockTodoistWebhookRequest
-		wantSuccess bool
-		wantMessage string
-	}{
-		{
-			name: "item:added event",
-			request: &MockTodoistWebhookRequest{
-				EventName: "item:added",
-				UserId:    "123456",
-				EventData: `{"item_id": "789"}`,
-				Version:   "1.0",
-			},
-			wantSuccess: true,
-			wantMessage: "Webhook received",
-		},
-		{
-			name: "item:updated event",
-			request: &MockTodoistWebhookRequest{
-				EventName: "item:updated",
-				UserId:    "123456",
-				EventData: `{"item_id": "789"}`,
-				Version:   "1.0",
-			},
-			wantSuccess: true,
-			wantMessage: "Webhook received",
-		},
-		{
-			name: "item:deleted event",
-			request: &MockTodoistWebhookRequest{
-				EventName: "item:deleted",
-				UserId:    "123456",
-				EventData: `{"item_id": "789"}`,
-				Version:   "1.0",
-			},
-			wantSuccess: true,
-			wantMessage: "Webhook received",
-		},
-		{
-			name: "item:completed event",
-			request: &MockTodoistWebhookRequest{
-				EventName: "item:completed",
-				UserId:    "123456",
-				EventData: `{"item_id": "789"}`,
-				Version:   "1.0",
-			},
-			wantSuccess: true,
-			wantMessage: "Webhook received",
-		},
-		{
-			name: "unknown event",
-			request: &MockTodoistWebhookRequest{
-				EventName: "unknown:event",
-				UserId:    "123456",
-				EventData: `{"item_id": "789"}`,
-				Version:   "1.0",
-			},
-			wantSuccess: true,
-			wantMessage: "Webhook received",
-		},
-	}
-
-	// Run the tests
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			// Call the ProcessWebhook method
-			response, err := service.ProcessWebhook(ctx, tc.request)
-
-			// Check for errors
-			if err != nil {
-				t.Fatalf("ProcessWebhook returned an error: %v", err)
-			}
-
-			// Check the response
-			if response.Success != tc.wantSuccess {
-				t.Errorf("ProcessWebhook() success = %v, want %v", response.Success, tc.wantSuccess)
-			}
-			if response.Message != tc.wantMessage {
-				t.Errorf("ProcessWebhook() message = %s, want %s", response.Message, tc.wantMessage)
-			}
-		})
-	}
-}
-
-func TestMockHealthService(t *testing.T) {
-	// Create a new MockHealthService
-	service := &MockHealthService{}
-
-	// Create a request
-	request := &MockHealthCheckRequest{}
-
-	// Call the Check method
-	response, err := service.Check(request)
-
-	// Check for errors
-	if err != nil {
-		t.Fatalf("Check returned an error: %v", err)
-	}
-
-	// Check the response
-	if response == nil {
-		t.Fatal("Check returned a nil response")
-	}
-
-	// Check the status
-	expectedStatus := "OK"
-	if response.Status != expectedStatus {
-		t.Errorf("Check() status = %s, want %s", response.Status, expectedStatus)
-	}
-}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	"cherry_backend/pkg/api/openapi"
+)
+
+// OpenAPIHandler serves the OpenAPI 3 document generated from proto/*.proto
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Spec)
+}
+
+// SwaggerUIHandler serves a Swagger UI page for the OpenAPI document
+func (s *Server) SwaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.SwaggerUI)
+}
+
+// registerOpenAPIRoutes serves the OpenAPI document, and Swagger UI at /docs when
+// CHERRY_SWAGGER_UI is true
+func (s *Server) registerOpenAPIRoutes() {
+	s.Router.HandleFunc("/openapi.json", s.OpenAPIHandler).Methods("GET")
+
+	if enabled, _ := strconv.ParseBool(os.Getenv("CHERRY_SWAGGER_UI")); enabled {
+		s.Router.HandleFunc("/docs", s.SwaggerUIHandler).Methods("GET")
+	}
+}
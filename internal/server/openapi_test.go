@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/pkg/api/openapi"
+)
+
+// undocumentedRoutes are served by the router but deliberately left out of the OpenAPI document:
+// they are not defined in proto/*.proto. A route is undocumented if its path starts with one of
+// these prefixes.
+var undocumentedRoutes = []string{
+	"/events/",      // SSE and WebSocket streams
+	"/admin/",       // operator API, not for external consumers
+	"/auth/",        // Todoist login and API key management
+	"/openapi.json", // the document itself
+	"/docs",         // Swagger UI
+}
+
+// TestOpenAPIMatchesRouter fails when the router and the OpenAPI document disagree: every
+// documented operation must be routed, and every routed endpoint must be documented
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	// Setup test environment
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	s := NewServer()
+	defer s.Fanout.Close()
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want an OpenAPI 3 document", spec.OpenAPI)
+	}
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	routed := map[string]bool{}
+	err := s.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, prefix := range undocumentedRoutes {
+			if strings.HasPrefix(path, prefix) {
+				return nil
+			}
+		}
+		for _, method := range methods {
+			routed[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	if missing := difference(documented, routed); len(missing) > 0 {
+		t.Errorf("documented in openapi.json but not routed: %v", missing)
+	}
+	if missing := difference(routed, documented); len(missing) > 0 {
+		t.Errorf("routed but missing from openapi.json (annotate the RPC or list it in undocumentedRoutes): %v", missing)
+	}
+}
+
+// difference returns the keys of a that are not in b, sorted
+func difference(a, b map[string]bool) []string {
+	var keys []string
+	for key := range a {
+		if !b[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestOpenAPIHandler tests that the document is served and Swagger UI is only served when enabled
+func TestOpenAPIHandler(t *testing.T) {
+	// Setup test environment
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	s := NewServer()
+	defer s.Fanout.Close()
+	httpServer := httptest.NewServer(s.Router)
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("GET /openapi.json failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("GET /openapi.json = %d %s, want 200 application/json", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if string(body) != string(openapi.Spec) {
+		t.Error("GET /openapi.json did not return the embedded document")
+	}
+
+	resp, err = http.Get(httpServer.URL + "/docs")
+	if err != nil {
+		t.Fatalf("GET /docs failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /docs without CHERRY_SWAGGER_UI = %d, want 404", resp.StatusCode)
+	}
+
+	t.Setenv("CHERRY_SWAGGER_UI", "true")
+	s = NewServer()
+	defer s.Fanout.Close()
+	recorder := httptest.NewRecorder()
+	s.Router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "swagger-ui") {
+		t.Errorf("GET /docs with CHERRY_SWAGGER_UI = %d, want the Swagger UI page", recorder.Code)
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/peer"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/ratelimit"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// Rate limit classes; the routes of a class share its limit, with a bucket per client
+const (
+	rateClassWebhook = "webhook"
+	rateClassTasks   = "tasks"
+	rateClassStats   = "stats"
+	rateClassEvents  = "events"
+	rateClassAuth    = "auth"
+	rateClassIP      = "ip"
+)
+
+// defaultRateLimits apply unless CHERRY_RATE_LIMITS overrides them. Webhooks have their own
+// generous class, so user traffic never throttles Todoist. Stream connections are long-lived,
+// so events limits how often clients connect. The ip class limits every IP address before
+// authentication, so clients with missing or bad credentials are throttled too; it is generous
+// because many users may share an address.
+var defaultRateLimits = ratelimit.Limits{
+	rateClassWebhook: {Events: 100, Per: time.Second, Burst: 1000},
+	rateClassTasks:   {Events: 10, Per: time.Second, Burst: 20},
+	rateClassStats:   {Events: 5, Per: time.Second, Burst: 10},
+	rateClassEvents:  {Events: 30, Per: time.Minute, Burst: 10},
+	rateClassAuth:    {Events: 30, Per: time.Minute, Burst: 10},
+	rateClassIP:      {Events: 10, Per: time.Second, Burst: 100},
+}
+
+// methodRateClasses is the rate limit class of each RPC; the health check is not limited
+var methodRateClasses = map[string]string{
+	apiv1.TodoistService_ProcessWebhook_FullMethodName:  rateClassWebhook,
+	apiv1.TodoistService_SubscribeEvents_FullMethodName: rateClassEvents,
+	apiv1.TaskService_ListTasks_FullMethodName:          rateClassTasks,
+	apiv1.StatsService_GetStats_FullMethodName:          rateClassStats,
+}
+
+// newRateLimitsFromEnv reads the limits of each class from CHERRY_RATE_LIMITS on top of the
+// defaults; "off" disables rate limiting. Invalid limits fall back to the defaults.
+func newRateLimitsFromEnv(logger logging.Logger) ratelimit.Limits {
+	value := os.Getenv("CHERRY_RATE_LIMITS")
+	if strings.TrimSpace(value) == "off" {
+		logger.Warn("Rate limiting disabled")
+		return ratelimit.Limits{}
+	}
+	limits, err := ratelimit.ParseLimits(value, defaultRateLimits)
+	if err != nil {
+		logger.Error("%v; using the default rate limits", err)
+		return defaultRateLimits
+	}
+	return limits
+}
+
+// checkRate takes a token from the bucket of a client in a class. The client is the API key or
+// user of an authenticated request and the IP address otherwise. Limiter errors are logged and
+// let the request through, so an unavailable backend does not take the API down.
+func (s *Server) checkRate(ctx context.Context, class, ip string) ratelimit.Decision {
+	client := "ip:" + ip
+	if principal, ok := auth.FromContext(ctx); ok {
+		client = "user:" + principal.UserID
+		if principal.KeyID != "" {
+			client = "key:" + principal.KeyID
+		}
+	}
+	return s.take(ctx, class, client)
+}
+
+// take takes a token from the bucket of a client in a class
+func (s *Server) take(ctx context.Context, class, client string) ratelimit.Decision {
+	limit, ok := s.RateLimits[class]
+	if !ok || s.RateLimiter == nil {
+		return ratelimit.Decision{Allowed: true}
+	}
+	decision, err := s.RateLimiter.Allow(ctx, class+":"+client, limit)
+	if err != nil {
+		s.logger.Error("Rate limiter failed, allowing request: %v", err)
+		return ratelimit.Decision{Allowed: true}
+	}
+	if !decision.Allowed {
+		s.logger.Warn("Rate limited %s in class %s for %s", client, class, decision.RetryAfter)
+	}
+	return decision
+}
+
+// grpcCheckRate is checkRate for the gRPC interceptors
+func (s *Server) grpcCheckRate(ctx context.Context, method string) ratelimit.Decision {
+	class, ok := methodRateClasses[method]
+	if !ok {
+		return ratelimit.Decision{Allowed: true}
+	}
+	return s.checkRate(ctx, class, peerIP(ctx))
+}
+
+// grpcCheckIP takes a token from the ip bucket of the peer of a rate limited call; it runs
+// before authentication
+func (s *Server) grpcCheckIP(ctx context.Context, method string) ratelimit.Decision {
+	if _, ok := methodRateClasses[method]; !ok {
+		return ratelimit.Decision{Allowed: true}
+	}
+	return s.take(ctx, rateClassIP, "ip:"+peerIP(ctx))
+}
+
+// peerIP returns the IP address of the peer of a gRPC call
+func peerIP(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return hostOf(p.Addr.String())
+	}
+	return ""
+}
+
+// limit throttles a route with the limit of its class. Requests over the limit are answered
+// with 429 and Retry-After; all limited responses report the bucket in X-RateLimit headers.
+func (s *Server) limit(class string, next http.HandlerFunc) http.HandlerFunc {
+	return s.throttle(next, func(r *http.Request) ratelimit.Decision {
+		return s.checkRate(r.Context(), class, s.clientIP(r))
+	})
+}
+
+// limitIP throttles a route with the ip bucket of the client. It goes outside authentication,
+// so that requests with missing or bad credentials are limited too.
+func (s *Server) limitIP(next http.HandlerFunc) http.HandlerFunc {
+	return s.throttle(next, func(r *http.Request) ratelimit.Decision {
+		return s.take(r.Context(), rateClassIP, "ip:"+s.clientIP(r))
+	})
+}
+
+// throttle answers requests that check refuses with 429
+func (s *Server) throttle(next http.HandlerFunc, check func(r *http.Request) ratelimit.Decision) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decision := check(r)
+		if decision.Limit > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		}
+		if !decision.Allowed {
+			retryAfter := strconv.Itoa(decision.RetryAfterSeconds())
+			w.Header().Set("Retry-After", retryAfter)
+			writeErrorCode(w, http.StatusTooManyRequests, api.CodeRateLimited, "rate limit exceeded; retry in "+retryAfter+"s")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP returns the IP address of a client: the first X-Forwarded-For entry when
+// CHERRY_TRUST_PROXY is set, because the server then runs behind a proxy, and the peer otherwise
+func (s *Server) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	return hostOf(r.RemoteAddr)
+}
+
+// hostOf strips the port from an address
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/server/servertest"
+	"cherry_backend/pkg/api"
+)
+
+// TestRateLimits tests that clients get their own buckets and that webhooks are limited apart
+func TestRateLimits(t *testing.T) {
+	t.Setenv("CHERRY_RATE_LIMITS", "tasks=2/m")
+	ts := servertest.NewTestServer(t)
+	ada := servertest.Bearer(ts.Token(t, "1", auth.ScopeTasksRead))
+	grace := servertest.Bearer(ts.Token(t, "2", auth.ScopeTasksRead))
+
+	for i := 0; i < 2; i++ {
+		resp := ts.Do(t, http.MethodGet, "/tasks", nil, ada)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("X-RateLimit-Limit") != "2" {
+			t.Fatalf("request %d = %d with limit %q, want 200 with limit 2", i, resp.StatusCode, resp.Header.Get("X-RateLimit-Limit"))
+		}
+	}
+	resp := ts.Do(t, http.MethodGet, "/tasks", nil, ada)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "30" || resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("request over the limit = %d with Retry-After %q, want 429 after 30s", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	var envelope api.ErrorEnvelope
+	servertest.DecodeJSON(t, resp, &envelope)
+	if envelope.Error == nil || envelope.Error.Code != api.CodeRateLimited {
+		t.Errorf("error = %+v, want %s", envelope.Error, api.CodeRateLimited)
+	}
+	if !ts.Logger.Contains(servertest.LevelWarn, "Rate limited user:1 in class tasks") {
+		t.Errorf("logs do not show the refused request:\n%s", ts.Logger)
+	}
+
+	if resp := ts.Do(t, http.MethodGet, "/tasks", nil, grace); resp.StatusCode != http.StatusOK {
+		t.Errorf("request of another user = %d, want 200", resp.StatusCode)
+	}
+	if resp := ts.Webhook(t, webhookBody("1")); resp.StatusCode != http.StatusOK {
+		t.Errorf("webhook = %d, want 200", resp.StatusCode)
+	}
+	if resp := ts.Do(t, http.MethodGet, "/health", nil, nil); resp.Header.Get("X-RateLimit-Limit") != "" {
+		t.Error("the health check is rate limited")
+	}
+}
+
+// TestRateLimitsUnauthenticated tests that requests with bad credentials are limited by address
+func TestRateLimitsUnauthenticated(t *testing.T) {
+	t.Setenv("CHERRY_RATE_LIMITS", "ip=3/m")
+	ts := servertest.NewTestServer(t)
+	for _, path := range []string{"/tasks", "/stats", "/auth/keys"} {
+		if resp := ts.Do(t, http.MethodGet, path, nil, servertest.Bearer("guessed")); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("GET %s with a bad token = %d, want 401", path, resp.StatusCode)
+		}
+	}
+	resp := ts.Do(t, http.MethodGet, "/tasks", nil, servertest.Bearer("guessed"))
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("request over the limit = %d, want 429 with Retry-After", resp.StatusCode)
+	}
+	if !ts.Logger.Contains(servertest.LevelWarn, "Rate limited ip:") {
+		t.Errorf("logs do not show the refused request:\n%s", ts.Logger)
+	}
+}
+
+// TestRateLimitsFromEnv tests disabling rate limits and falling back on invalid ones
+func TestRateLimitsFromEnv(t *testing.T) {
+	t.Setenv("CHERRY_RATE_LIMITS", "off")
+	if ts := servertest.NewTestServer(t); len(ts.RateLimits) != 0 {
+		t.Errorf("limits = %s, want none", ts.RateLimits)
+	}
+
+	t.Setenv("CHERRY_RATE_LIMITS", "tasks=fast")
+	ts := servertest.NewTestServer(t)
+	if _, ok := ts.RateLimits["webhook"]; !ok || !ts.Logger.Contains(servertest.LevelError, "invalid rate limit") {
+		t.Errorf("limits = %s, want the defaults and a logged error", ts.RateLimits)
+	}
+}
@@ -1,37 +1,429 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/automation"
+	"cherry_backend/internal/digest"
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/fanout"
+	"cherry_backend/internal/logging"
+	"cherry_backend/internal/metrics"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/ratelimit"
+	"cherry_backend/internal/scheduler"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/storage"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
 )
 
 // Server represents the HTTP server for the application
 type Server struct {
 	Router *mux.Router
+
+	// MirrorStore holds the local copy of connected users' Todoist state
+	MirrorStore mirror.Store
+
+	// Mirror keeps MirrorStore in sync with Todoist; nil when no API tokens are configured
+	Mirror *mirror.Mirror
+
+	// Automation runs user-defined rules on webhooks; nil when no rules file is configured
+	Automation *automation.Engine
+
+	// Fanout re-delivers accepted webhooks to downstream subscribers
+	Fanout *fanout.Dispatcher
+
+	// Events records normalized webhooks for the event stream endpoints
+	Events *events.Log
+
+	// Stats holds the productivity statistics aggregated from webhooks, in Storage when it is configured
+	Stats stats.Store
+
+	// Digests sends the daily and weekly digests users ask for in their notification settings
+	Digests *digest.Sender
+
+	// Scheduler runs the periodic jobs: digests, mirror reconciliation, log retention and token
+	// refreshes
+	Scheduler *scheduler.Scheduler
+
+	// Storage is the database configured by CHERRY_DATABASE_URL; nil when the server keeps its state in memory
+	Storage *storage.DB
+
+	// Accounts links Todoist users to their accounts and settings, in Storage when it is configured
+	Accounts tenant.Registry
+
+	// TenantPolicy decides what happens to webhooks and requests of users without an active account
+	TenantPolicy tenant.Policy
+
+	// Quarantine holds the webhooks TenantPolicy holds back until an admin releases or drops them
+	Quarantine *tenant.QuarantineStore
+
+	// Auth resolves the bearer tokens of client requests: API keys, session tokens and the Todoist
+	// API tokens of TODOIST_API_TOKENS
+	Auth *auth.Authenticator
+
+	// OAuth runs the Todoist login that issues session tokens; nil when TODOIST_CLIENT_ID is unset
+	OAuth *api.TodoistOAuth
+
+	// RateLimiter holds the token buckets of clients; RateLimits is the limit of each route class
+	RateLimiter ratelimit.Limiter
+	RateLimits  ratelimit.Limits
+
+	// Now returns the current time for relative task filters; defaults to time.Now
+	Now func() time.Time
+
+	logger           logging.Logger
+	trustProxy       bool
+	webhookListeners []WebhookListener
+
+	// started and webhookMetrics feed GET /admin/metrics
+	started        time.Time
+	webhookMetrics metrics.Endpoint
 }
 
 // NewServer creates a new server instance
 func NewServer() *Server {
+	return NewServerWithLogger(newServerLogger())
+}
+
+// NewServerWithLogger creates a new server instance that logs to logger
+func NewServerWithLogger(logger logging.Logger) *Server {
 	s := &Server{
-		Router: mux.NewRouter(),
+		Router:      mux.NewRouter(),
+		MirrorStore: mirror.NewMemoryStore(),
+		Now:         time.Now,
+		logger:      logger,
+		started:     time.Now(),
+	}
+	s.Fanout = fanout.NewDispatcher(s.logger)
+	s.Events = events.NewLog(events.NewMemoryStore(eventRetentionFromEnv(s.logger)))
+	s.Stats = stats.NewMemoryStore()
+
+	// Keep events, statistics, the mirror and webhook subscribers in the database when one is configured
+	if databaseURL := os.Getenv("CHERRY_DATABASE_URL"); databaseURL != "" {
+		if db, err := openStorageFromEnv(databaseURL, s.logger); err != nil {
+			s.logger.Error("Storage disabled: %v", err)
+		} else {
+			s.Storage = db
+			s.Events = events.NewLog(db.Events())
+			s.Stats = db.Stats()
+			s.MirrorStore = db.Mirror()
+			s.Fanout.Store = db.Subscribers()
+			if err := s.Fanout.Load(context.Background()); err != nil {
+				s.logger.Error("%v", err)
+			}
+		}
+	}
+
+	// Link Todoist users to accounts; every user with an API token gets one
+	tokens := mirror.TokensFromEnv()
+	s.Accounts, s.TenantPolicy = newAccountsFromEnv(s.Storage, tokens, s.logger)
+	s.Quarantine = tenant.NewQuarantineStore()
+	s.Auth = newAuthFromEnv(s.Storage, tokens, s.logger)
+	s.OAuth = newOAuthFromEnv()
+
+	// Throttle clients per API key, user or IP address
+	s.RateLimiter = ratelimit.NewMemoryLimiter()
+	s.RateLimits = newRateLimitsFromEnv(s.logger)
+	s.trustProxy, _ = strconv.ParseBool(os.Getenv("CHERRY_TRUST_PROXY"))
+
+	// Act on Todoist with the API tokens of TODOIST_API_TOKENS and those saved by logins
+	todoistTokens := &mirror.RefreshingTokens{Static: tokens}
+	if s.Storage != nil {
+		todoistTokens.Load = storedTokens(s.Storage)
+		if _, err := todoistTokens.Refresh(context.Background()); err != nil {
+			s.logger.Error("%v", err)
+		}
+	}
+
+	// Mirror the Todoist state of every user with an API token and the mirror feature on
+	if len(tokens) > 0 || todoistTokens.Load != nil {
+		s.Mirror = newMirrorFromEnv(s.MirrorStore, tenant.MirroredTokens{Tokens: todoistTokens, Registry: s.Accounts, Policy: s.TenantPolicy}, s.logger)
+		s.addFeatureListener(tenant.FeatureMirror, s.Mirror)
+	}
+
+	// Run automation rules on incoming webhooks
+	if rulesFile := os.Getenv("CHERRY_RULES_FILE"); rulesFile != "" {
+		if engine, err := newRuleEngineFromEnv(rulesFile, s.MirrorStore, todoistTokens, s.logger); err != nil {
+			s.logger.Error("Automation rules disabled: %v", err)
+		} else {
+			s.Automation = engine
+			s.addFeatureListener(tenant.FeatureAutomation, engine)
+		}
 	}
 
+	// Re-deliver webhooks to subscribers registered through the admin API
+	s.addFeatureListener(tenant.FeatureFanout, s.Fanout)
+
+	// Record webhooks for clients streaming their events
+	s.addFeatureListener(tenant.FeatureEvents, s.Events)
+
+	// Count task additions and completions as they arrive
+	aggregator := stats.NewAggregator(s.Stats)
+	aggregator.Now = func() time.Time { return s.Now() }
+	s.addFeatureListener(tenant.FeatureStats, aggregator)
+
+	// Summarize completed, due and overdue tasks for users who asked for digests
+	s.Digests = newDigestsFromEnv(&digest.Builder{Events: s.Events.Store, Mirror: s.MirrorStore, Stats: s.Stats}, s.Accounts, s.logger)
+	s.Digests.Now = func() time.Time { return s.Now() }
+
+	// Schedule the periodic work
+	s.Scheduler = newSchedulerFromEnv(s.Storage, s.logger)
+	s.Scheduler.Now = func() time.Time { return s.Now() }
+	s.addJobs(todoistTokens)
+
 	// Register routes
 	s.registerRoutes()
 
 	return s
 }
 
+// newServerLogger creates the logger used by background components, falling back to the
+// standard log package if the log directory is not writable
+func newServerLogger() logging.Logger {
+	logger, err := logging.NewLogger()
+	if err != nil {
+		log.Printf("Warning: could not create file logger, using standard log package: %v", err)
+		return logging.NewStdLogger()
+	}
+	return logger
+}
+
+// openStorageFromEnv opens the database and applies pending migrations unless CHERRY_AUTO_MIGRATE
+// is false, in which case a database with pending migrations is an error
+func openStorageFromEnv(url string, logger logging.Logger) (*storage.DB, error) {
+	db, err := storage.Open(url)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if autoMigrate, err := strconv.ParseBool(os.Getenv("CHERRY_AUTO_MIGRATE")); err == nil && !autoMigrate {
+		pending, err := db.Pending(ctx)
+		if err == nil && len(pending) > 0 {
+			err = fmt.Errorf("%d pending migration(s); run `cherry_backend migrate up`", len(pending))
+		}
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+
+	applied, err := db.Migrate(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, migration := range applied {
+		logger.Info("Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	logger.Info("Using %s storage", db.Driver())
+	return db, nil
+}
+
+// newAccountsFromEnv creates the account registry, in the database if there is one, registers
+// the users with an API token and reads the tenant policy from CHERRY_TENANT_POLICY. An invalid
+// policy falls back to rejecting unknown users.
+func newAccountsFromEnv(db *storage.DB, tokens mirror.StaticTokenSource, logger logging.Logger) (tenant.Registry, tenant.Policy) {
+	var registry tenant.Registry = tenant.NewMemoryRegistry()
+	if db != nil {
+		registry = db.Accounts()
+	}
+	users, _ := tokens.Users(context.Background())
+	if err := tenant.Register(context.Background(), registry, users); err != nil {
+		logger.Error("Failed to register accounts: %v", err)
+	}
+
+	policy, err := tenant.ParsePolicy(os.Getenv("CHERRY_TENANT_POLICY"))
+	if err != nil {
+		logger.Error("%v; rejecting webhooks of unknown users", err)
+		policy = tenant.PolicyReject
+	}
+	return registry, policy
+}
+
+// newMirrorFromEnv creates a Todoist mirror configured from environment variables
+func newMirrorFromEnv(store mirror.Store, tokens mirror.TokenSource, logger logging.Logger) *mirror.Mirror {
+	m := mirror.NewMirror(store, tokens, logger)
+	if baseURL := os.Getenv("TODOIST_API_URL"); baseURL != "" {
+		m.BaseURL = baseURL
+	}
+	return m
+}
+
+// newDigestsFromEnv creates the digest sender. Digests are only delivered when
+// CHERRY_DIGEST_NOTIFIER names a notifier; invalid settings are logged and fall back to their
+// defaults.
+func newDigestsFromEnv(builder *digest.Builder, accounts tenant.Registry, logger logging.Logger) *digest.Sender {
+	sender := digest.NewSender(builder, accounts, nil, logger)
+	if spec := os.Getenv("CHERRY_DIGEST_NOTIFIER"); spec != "" {
+		if notifier, err := digest.ParseNotifier(spec, os.Stdout); err != nil {
+			logger.Error("Digests disabled: %v", err)
+		} else {
+			sender.Notifier = notifier
+		}
+	}
+	if hour := os.Getenv("CHERRY_DIGEST_HOUR"); hour != "" {
+		if h, err := strconv.Atoi(hour); err == nil && h >= 0 && h < 24 {
+			sender.Hour = h
+		} else {
+			logger.Warn("Ignoring invalid CHERRY_DIGEST_HOUR %q", hour)
+		}
+	}
+	if weekday := os.Getenv("CHERRY_DIGEST_WEEKDAY"); weekday != "" {
+		if day, ok := parseWeekday(weekday); ok {
+			sender.Weekday = day
+		} else {
+			logger.Warn("Ignoring invalid CHERRY_DIGEST_WEEKDAY %q", weekday)
+		}
+	}
+	return sender
+}
+
+// parseWeekday parses the English name of a day of the week, in any case
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// newRuleEngineFromEnv loads the automation rules file and creates an engine acting with the users' API tokens
+func newRuleEngineFromEnv(path string, store mirror.Store, tokens mirror.TokenSource, logger logging.Logger) (*automation.Engine, error) {
+	rules, err := automation.LoadRules(path)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := os.Getenv("TODOIST_API_URL")
+	if baseURL == "" {
+		baseURL = api.TodoistRESTBaseURL
+	}
+	engine := automation.NewEngine(rules, automation.TokenClientFactory(tokens, baseURL), logger)
+	engine.Store = store
+	engine.DryRun, _ = strconv.ParseBool(os.Getenv("CHERRY_RULES_DRY_RUN"))
+
+	logger.Info("Loaded %d automation rule(s) from %s (dry run: %v)", len(rules), path, engine.DryRun)
+	return engine, nil
+}
+
+// AddWebhookListener registers a listener that is notified of every processed webhook
+func (s *Server) AddWebhookListener(listener WebhookListener) {
+	s.webhookListeners = append(s.webhookListeners, listener)
+}
+
+// addFeatureListener registers a listener that is only notified of webhooks of accounts that
+// have the feature on
+func (s *Server) addFeatureListener(feature string, listener WebhookListener) {
+	s.AddWebhookListener(featureListener{feature: feature, listener: listener})
+}
+
+// todoistService returns the Todoist service backed by the server's logger, listeners and accounts
+func (s *Server) todoistService() *TodoistServiceImpl {
+	return &TodoistServiceImpl{
+		Logger:     s.logger,
+		Listeners:  s.webhookListeners,
+		Events:     s.Events,
+		Accounts:   s.Accounts,
+		Policy:     s.TenantPolicy,
+		Quarantine: s.Quarantine,
+	}
+}
+
+// taskService returns the task service over the server's mirror
+func (s *Server) taskService() *TaskServiceImpl {
+	return &TaskServiceImpl{Store: s.MirrorStore, Now: s.Now}
+}
+
+// statsService returns the stats service over the server's statistics and mirror
+func (s *Server) statsService() *StatsServiceImpl {
+	return &StatsServiceImpl{Store: s.Stats, Mirror: s.MirrorStore, Now: s.Now}
+}
+
+// NewGRPCServer creates a gRPC server for the Todoist, task and stats services. Every call is
+// rate limited by the IP address of the peer, authorized with the scope methodScopes lists for
+// its method, then rate limited by its API key or user.
+func (s *Server) NewGRPCServer() *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			ratelimit.UnaryServerInterceptor(s.grpcCheckIP),
+			auth.UnaryServerInterceptor(methodScopes, s.grpcAuthorize),
+			ratelimit.UnaryServerInterceptor(s.grpcCheckRate),
+			grpcErrors),
+		grpc.ChainStreamInterceptor(
+			ratelimit.StreamServerInterceptor(s.grpcCheckIP),
+			auth.StreamServerInterceptor(methodScopes, s.grpcAuthorize),
+			ratelimit.StreamServerInterceptor(s.grpcCheckRate)),
+	)
+	apiv1.RegisterTodoistServiceServer(grpcServer, s.todoistService())
+	apiv1.RegisterTaskServiceServer(grpcServer, s.taskService())
+	apiv1.RegisterStatsServiceServer(grpcServer, s.statsService())
+	return grpcServer
+}
+
 // registerRoutes sets up all the routes for the server
 func (s *Server) registerRoutes() {
-	// Register webhook handler
-	s.Router.HandleFunc("/webhooks/todoist", s.TodoistWebhookHandler).Methods("POST")
+	// Tag every request with an ID and answer unknown routes with the JSON error envelope
+	s.Router.Use(requestIDMiddleware)
+	s.Router.NotFoundHandler = requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no route for "+r.URL.Path)
+	}))
+	s.Router.MethodNotAllowedHandler = requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, r.Method+" not allowed for "+r.URL.Path)
+	}))
+
+	// Register the webhook, health check, task query and stats endpoints from the routes generated
+	// from the google.api.http annotations in proto/*.proto. The webhook is authenticated by its
+	// Todoist signature. Client routes are rate limited by IP address before authentication,
+	// so bad credentials are throttled too, and after it, so each API key or user has its own
+	// bucket.
+	s.registerRESTRoutes(map[string]http.HandlerFunc{
+		apiv1.TodoistService_ProcessWebhook_FullMethodName: s.webhookMetrics.Middleware(s.limit(rateClassWebhook, s.TodoistWebhookHandler)).ServeHTTP,
+		apiv1.HealthService_Check_FullMethodName:           s.HealthCheckHandler,
+		apiv1.TaskService_ListTasks_FullMethodName:         s.limitIP(s.requireScope(auth.ScopeTasksRead, s.limit(rateClassTasks, s.ListTasksHandler))),
+		apiv1.StatsService_GetStats_FullMethodName:         s.limitIP(s.requireScope(auth.ScopeStatsRead, s.limit(rateClassStats, s.GetStatsHandler))),
+	})
+
+	// Register event stream endpoints
+	s.Router.HandleFunc("/events/stream", s.limitIP(s.requireScope(auth.ScopeEventsRead, s.limit(rateClassEvents, s.EventStreamHandler)))).Methods("GET")
+	s.Router.HandleFunc("/events/ws", s.limitIP(s.requireScope(auth.ScopeEventsRead, s.limit(rateClassEvents, s.EventWebSocketHandler)))).Methods("GET")
 
-	// Add a health check endpoint
-	s.Router.HandleFunc("/health", s.HealthCheckHandler).Methods("GET")
+	// Register the Todoist login and API key endpoints
+	s.registerAuthRoutes()
+
+	// Serve the OpenAPI document of the REST API
+	s.registerOpenAPIRoutes()
+
+	// Register admin API
+	s.registerAdminRoutes()
+}
+
+// registerRESTRoutes registers a handler for every route in api.Routes. It panics when an
+// annotated RPC has no handler, so a proto change cannot silently leave a route unserved.
+func (s *Server) registerRESTRoutes(handlers map[string]http.HandlerFunc) {
+	for _, route := range api.Routes {
+		handler, ok := handlers[route.FullMethod]
+		if !ok {
+			panic("server: no REST handler for " + route.FullMethod)
+		}
+		s.Router.HandleFunc(route.Path, handler).Methods(route.Method)
+	}
 }
 
 // Run starts the HTTP server
@@ -42,6 +434,36 @@ func (s *Server) Run() error {
 		port = "8080"
 	}
 
+	// Start background work
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Scheduler.Run(ctx)
+	if s.Mirror != nil {
+		defer s.Mirror.Close()
+	}
+	defer s.Fanout.Close()
+	if s.Storage != nil {
+		defer s.Storage.Close()
+	}
+
+	// Start the gRPC server
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	listener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen on gRPC port %s: %w", grpcPort, err)
+	}
+	grpcServer := s.NewGRPCServer()
+	defer grpcServer.Stop()
+	go func() {
+		log.Printf("gRPC server starting on port %s...\n", grpcPort)
+		if err := grpcServer.Serve(listener); err != nil {
+			s.logger.Error("gRPC server stopped: %v", err)
+		}
+	}()
+
 	// Start the server
 	log.Printf("Server starting on port %s...\n", port)
 	return http.ListenAndServe(":"+port, s.Router)
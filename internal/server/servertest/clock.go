@@ -0,0 +1,43 @@
+package servertest
+
+import (
+	"sync"
+	"time"
+)
+
+// Epoch is the time a new Clock starts at
+var Epoch = time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+// Clock is a fake clock that only moves when told to. Its Now method can be used wherever
+// the code under test takes a func() time.Time.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a clock set to Epoch
+func NewClock() *Clock {
+	return &Clock{now: Epoch}
+}
+
+// Now returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to a time
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock forward by d and returns the new time
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
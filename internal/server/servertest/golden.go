@@ -0,0 +1,119 @@
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites golden files with the current output instead of comparing against them:
+//
+//	go test ./internal/server -run TestGolden -update
+var update = flag.Bool("update", false, "update golden files")
+
+// GoldenDir is the directory golden files are read from, relative to the package under test
+const GoldenDir = "testdata"
+
+// Placeholder replaces the values of volatile JSON fields in golden responses
+const Placeholder = "<ignored>"
+
+// VolatileFields are JSON fields whose values change between runs, such as generated IDs. They are
+// replaced with Placeholder wherever they appear in a golden response body.
+var VolatileFields = []string{"request_id"}
+
+// GoldenHeaders are the response headers recorded in golden responses
+var GoldenHeaders = []string{"Content-Type", "Retry-After"}
+
+// AssertGolden compares got with the golden file testdata/<name>.golden
+func AssertGolden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join(GoldenDir, name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to update %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with -update to accept it)\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+// AssertGoldenResponse compares the status, GoldenHeaders and body of a response with the
+// golden file testdata/<name>.golden. JSON bodies are indented with sorted keys, and the values
+// of VolatileFields and of the extra fields given are replaced with Placeholder.
+func AssertGoldenResponse(t testing.TB, resp *http.Response, name string, volatile ...string) {
+	t.Helper()
+	AssertGolden(t, name, FormatResponse(t, resp, volatile...))
+}
+
+// FormatResponse renders a response the way AssertGoldenResponse records it
+func FormatResponse(t testing.TB, resp *http.Response, volatile ...string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "HTTP %d\n", resp.StatusCode)
+	for _, key := range GoldenHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, value)
+		}
+	}
+	b.WriteByte('\n')
+
+	body := Body(t, resp)
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if len(bytes.TrimSpace(body)) == 0 || decoder.Decode(&value) != nil {
+		b.Write(body)
+		return b.Bytes()
+	}
+
+	scrub(value, fieldSet(append(volatile, VolatileFields...)))
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		t.Fatalf("failed to format response body: %v", err)
+	}
+	return b.Bytes()
+}
+
+// scrub replaces the values of the given fields anywhere in a decoded JSON value
+func scrub(value interface{}, fields map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if fields[key] {
+				v[key] = Placeholder
+			} else {
+				scrub(field, fields)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			scrub(item, fields)
+		}
+	}
+}
+
+// fieldSet turns a list of field names into a set
+func fieldSet(fields []string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}
@@ -0,0 +1,110 @@
+package servertest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Log levels of captured entries
+const (
+	LevelDebug = "DEBUG"
+	LevelInfo  = "INFO"
+	LevelWarn  = "WARN"
+	LevelError = "ERROR"
+)
+
+// Entry is a captured log message
+type Entry struct {
+	Level   string
+	Message string
+}
+
+// String formats an entry like the file logger does, without the timestamp
+func (e Entry) String() string {
+	return "[" + e.Level + "] " + e.Message
+}
+
+// Logger is a logging.Logger that captures its entries in memory
+type Logger struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewLogger creates a logger without entries
+func NewLogger() *Logger {
+	return &Logger{}
+}
+
+// Info captures an info message
+func (l *Logger) Info(format string, args ...interface{}) {
+	l.log(LevelInfo, format, args...)
+}
+
+// Error captures an error message
+func (l *Logger) Error(format string, args ...interface{}) {
+	l.log(LevelError, format, args...)
+}
+
+// Debug captures a debug message
+func (l *Logger) Debug(format string, args ...interface{}) {
+	l.log(LevelDebug, format, args...)
+}
+
+// Warn captures a warning message
+func (l *Logger) Warn(format string, args ...interface{}) {
+	l.log(LevelWarn, format, args...)
+}
+
+// log captures a message at a level
+func (l *Logger) log(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, Entry{Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// Entries returns the captured entries, oldest first
+func (l *Logger) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Entry(nil), l.entries...)
+}
+
+// Messages returns the messages captured at a level, or at every level for ""
+func (l *Logger) Messages(level string) []string {
+	var messages []string
+	for _, entry := range l.Entries() {
+		if level == "" || entry.Level == level {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
+
+// Contains reports whether a message containing substr was captured at a level, or at any
+// level for ""
+func (l *Logger) Contains(level, substr string) bool {
+	for _, message := range l.Messages(level) {
+		if strings.Contains(message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset discards the captured entries
+func (l *Logger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
+
+// String returns every captured entry, one per line, for failure messages
+func (l *Logger) String() string {
+	var b strings.Builder
+	for _, entry := range l.Entries() {
+		b.WriteString(entry.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+// Package servertest provides a test kit for the server: a capturing fake logger, a fake clock,
+// seeded in-memory stores, golden-file assertions for HTTP responses and NewTestServer, which
+// wires them into a running server.
+package servertest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cherry_backend/internal/server"
+	"cherry_backend/pkg/api"
+)
+
+const (
+	// ClientSecret is the Todoist client secret of test servers; webhooks are signed with it
+	ClientSecret = "test_secret"
+
+	// AdminToken is the admin API token of test servers
+	AdminToken = "test_admin_token"
+)
+
+// TestServer is a server running on a local HTTP listener with a fake logger and clock
+type TestServer struct {
+	*server.Server
+
+	// HTTP serves the server's router
+	HTTP *httptest.Server
+
+	// Logger captures everything the server logs
+	Logger *Logger
+
+	// Clock is the server's clock; it starts at the time the server was created
+	Clock *Clock
+}
+
+// NewTestServer starts a server configured for tests: logs go to a temporary directory and the
+// fake logger, webhooks must be signed with ClientSecret and the admin API accepts AdminToken.
+// Further configuration is read from the environment as usual, so set it with t.Setenv first.
+// Everything is shut down when the test ends.
+func NewTestServer(t testing.TB) *TestServer {
+	t.Helper()
+	t.Setenv("CHERRY_LOG_PATH", t.TempDir())
+	t.Setenv("CHERRY_ADMIN_TOKEN", AdminToken)
+	t.Setenv("TODOIST_CLIENT_SECRET", ClientSecret)
+
+	ts := &TestServer{Logger: NewLogger(), Clock: NewClock()}
+	ts.Server = server.NewServerWithLogger(ts.Logger)
+	ts.Server.Now = ts.Clock.Now
+	ts.HTTP = httptest.NewServer(ts.Router)
+	t.Cleanup(func() {
+		ts.HTTP.Close()
+		ts.Fanout.Close()
+		ts.Scheduler.Wait()
+		if ts.Mirror != nil {
+			ts.Mirror.Wait()
+		}
+		if ts.Storage != nil {
+			ts.Storage.Close()
+		}
+	})
+	return ts
+}
+
+// URL returns the URL of a path on the server
+func (ts *TestServer) URL(path string) string {
+	return ts.HTTP.URL + path
+}
+
+// Do sends a request with an optional JSON body to the server and returns the response.
+// The response body is read and closed, so it can be passed on to the assertions.
+func (ts *TestServer) Do(t testing.TB, method, path string, body interface{}, header http.Header) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if raw, ok := body.([]byte); ok {
+			buf.Write(raw)
+		} else if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, ts.URL(path), &buf)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := ts.HTTP.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	Body(t, resp)
+	return resp
+}
+
+// Admin sends a request to the admin API with AdminToken
+func (ts *TestServer) Admin(t testing.TB, method, path string, body interface{}) *http.Response {
+	t.Helper()
+	return ts.Do(t, method, path, body, http.Header{"Authorization": {"Bearer " + AdminToken}})
+}
+
+// Token issues a session token of a user with the given scopes
+func (ts *TestServer) Token(t testing.TB, userID string, scopes ...string) string {
+	t.Helper()
+	token, _, err := ts.Auth.Sessions.Issue(userID, scopes)
+	if err != nil {
+		t.Fatalf("failed to issue session token: %v", err)
+	}
+	return token
+}
+
+// Bearer returns the header authenticating a request with a bearer token
+func Bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// Webhook delivers a webhook body signed with ClientSecret
+func (ts *TestServer) Webhook(t testing.TB, body []byte) *http.Response {
+	t.Helper()
+	return ts.Do(t, http.MethodPost, "/webhooks/todoist", body, http.Header{api.TodoistSignatureHeader: {Sign(body)}})
+}
+
+// Sign returns the signature Todoist sends for a webhook body, keyed with ClientSecret
+func Sign(body []byte) string {
+	h := hmac.New(sha256.New, []byte(ClientSecret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DecodeJSON decodes the body of a response returned by Do into v
+func DecodeJSON(t testing.TB, resp *http.Response, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(Body(t, resp), v); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+}
+
+// Body returns the body of a response. The body stays readable, so assertions can share it.
+func Body(t testing.TB, resp *http.Response) []byte {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return body
+}
@@ -0,0 +1,126 @@
+package servertest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cherry_backend/pkg/api"
+)
+
+// TestLogger tests capturing and querying log entries
+func TestLogger(t *testing.T) {
+	logger := NewLogger()
+	logger.Info("synced user %s", "1")
+	logger.Warn("slow sync")
+	logger.Error("failed: %v", "boom")
+
+	if got := logger.Messages(LevelInfo); len(got) != 1 || got[0] != "synced user 1" {
+		t.Errorf("Messages(INFO) = %v", got)
+	}
+	if len(logger.Messages("")) != 3 {
+		t.Errorf("Messages(\"\") = %v, want every entry", logger.Messages(""))
+	}
+	if !logger.Contains(LevelError, "boom") || logger.Contains(LevelInfo, "boom") {
+		t.Error("Contains does not match on the level")
+	}
+	if got := logger.String(); got != "[INFO] synced user 1\n[WARN] slow sync\n[ERROR] failed: boom\n" {
+		t.Errorf("String() = %q", got)
+	}
+	logger.Reset()
+	if len(logger.Entries()) != 0 {
+		t.Errorf("Entries() after Reset = %v", logger.Entries())
+	}
+}
+
+// TestClock tests moving the fake clock
+func TestClock(t *testing.T) {
+	clock := NewClock()
+	if !clock.Now().Equal(Epoch) {
+		t.Errorf("Now() = %v, want %v", clock.Now(), Epoch)
+	}
+	if got := clock.Advance(time.Hour); !got.Equal(Epoch.Add(time.Hour)) || !clock.Now().Equal(got) {
+		t.Errorf("Advance() = %v, Now() = %v", got, clock.Now())
+	}
+	clock.Set(Epoch)
+	if !clock.Now().Equal(Epoch) {
+		t.Errorf("Now() after Set = %v", clock.Now())
+	}
+}
+
+// TestSeed tests that seeding replaces the state of a user
+func TestSeed(t *testing.T) {
+	store := NewMirrorStore(t, map[string]State{"1": {Tasks: []api.Task{{ID: "1"}, {ID: "2"}}}})
+	Seed(t, store, "1", State{Tasks: []api.Task{{ID: "3"}}, Projects: []api.Project{{ID: "p"}}})
+
+	snapshot, err := store.Snapshot(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if len(snapshot.Tasks) != 1 || snapshot.Tasks[0].ID != "3" || len(snapshot.Projects) != 1 {
+		t.Errorf("snapshot = %+v, want only the second state", snapshot)
+	}
+}
+
+// TestFormatResponse tests that volatile fields are scrubbed from golden responses
+func TestFormatResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	rec.Header().Set("X-Request-Id", "abc")
+	rec.WriteHeader(http.StatusTeapot)
+	rec.WriteString(`{"b": 1.50, "request_id": "abc", "items": [{"created_at": "now", "id": "<1>"}]}`)
+
+	got := string(FormatResponse(t, rec.Result(), "created_at"))
+	want := `HTTP 418
+Content-Type: application/json
+
+{
+  "b": 1.50,
+  "items": [
+    {
+      "created_at": "<ignored>",
+      "id": "<1>"
+    }
+  ],
+  "request_id": "<ignored>"
+}
+`
+	if got != want {
+		t.Errorf("FormatResponse() =\n%s\nwant\n%s", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	rec.WriteString("plain text")
+	if got := string(FormatResponse(t, rec.Result())); !strings.HasSuffix(got, "\n\nplain text") {
+		t.Errorf("FormatResponse() of a text body = %q", got)
+	}
+}
+
+// TestTestServer tests the request helpers against a running test server
+func TestTestServer(t *testing.T) {
+	ts := NewTestServer(t)
+
+	resp := ts.Webhook(t, []byte(`{"event_name": "item:added", "user_id": "1", "event_data": {"id": "7"}}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Webhook() = %d: %s", resp.StatusCode, Body(t, resp))
+	}
+
+	var recorded struct {
+		Webhooks []struct {
+			EventName string `json:"event_name"`
+		} `json:"webhooks"`
+	}
+	DecodeJSON(t, ts.Admin(t, http.MethodGet, "/admin/webhooks", nil), &recorded)
+	if len(recorded.Webhooks) != 1 || recorded.Webhooks[0].EventName != "item:added" {
+		t.Errorf("recorded webhooks = %+v, want the delivered one", recorded.Webhooks)
+	}
+	if resp := ts.Do(t, http.MethodGet, "/admin/webhooks", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("admin request without token = %d, want 401", resp.StatusCode)
+	}
+	if !ts.Logger.Contains(LevelInfo, "Processing webhook event: item:added") {
+		t.Errorf("logs:\n%s", ts.Logger)
+	}
+}
@@ -0,0 +1,55 @@
+package servertest
+
+import (
+	"context"
+	"testing"
+
+	"cherry_backend/internal/mirror"
+	"cherry_backend/pkg/api"
+)
+
+// State is the Todoist state of a user to seed a store with
+type State struct {
+	Tasks    []api.Task
+	Projects []api.Project
+	Sections []api.Section
+	Labels   []api.Label
+	Comments []api.Comment
+}
+
+// NewMirrorStore creates an in-memory mirror store holding the state of every user in users
+func NewMirrorStore(t testing.TB, users map[string]State) *mirror.MemoryStore {
+	t.Helper()
+	store := mirror.NewMemoryStore()
+	for userID, state := range users {
+		Seed(t, store, userID, state)
+	}
+	return store
+}
+
+// Seed replaces the mirrored state of a user, as a full sync would
+func Seed(t testing.TB, store mirror.Store, userID string, state State) {
+	t.Helper()
+	base, err := store.SyncToken(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("failed to read the sync token of user %s: %v", userID, err)
+	}
+	delta := &api.SyncResponse{
+		SyncToken: base + "s",
+		FullSync:  true,
+		Items:     state.Tasks,
+		Projects:  state.Projects,
+		Sections:  state.Sections,
+		Labels:    state.Labels,
+		Notes:     state.Comments,
+	}
+	if err := store.Apply(context.Background(), userID, base, delta); err != nil {
+		t.Fatalf("failed to seed user %s: %v", userID, err)
+	}
+}
+
+// Seed replaces the mirrored state of a user on the server
+func (ts *TestServer) Seed(t testing.TB, userID string, state State) {
+	t.Helper()
+	Seed(t, ts.MirrorStore, userID, state)
+}
@@ -0,0 +1,160 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/fanout"
+	"cherry_backend/internal/server/servertest"
+	"cherry_backend/pkg/api"
+)
+
+// TestWebhookEvents tests the response and logs of the webhook endpoint for each event type
+func TestWebhookEvents(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+
+	testCases := []struct {
+		name    string
+		event   string
+		wantLog string
+		level   string
+	}{
+		{name: "item added", event: "item:added", wantLog: "Item added by user 123456", level: servertest.LevelInfo},
+		{name: "item updated", event: "item:updated", wantLog: "Item updated by user 123456", level: servertest.LevelInfo},
+		{name: "item deleted", event: "item:deleted", wantLog: "Item deleted by user 123456", level: servertest.LevelInfo},
+		{name: "item completed", event: "item:completed", wantLog: "Item completed by user 123456", level: servertest.LevelInfo},
+		{name: "unknown event", event: "unknown:event", wantLog: "Unhandled event type: unknown:event", level: servertest.LevelWarn},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts.Logger.Reset()
+			body := fmt.Sprintf(`{"event_name": %q, "user_id": "123456", "event_data": {"id": "789"}, "version": "9"}`, tc.event)
+			resp := ts.Webhook(t, []byte(body))
+
+			servertest.AssertGoldenResponse(t, resp, "webhook_received")
+			if !ts.Logger.Contains(tc.level, tc.wantLog) {
+				t.Errorf("logs do not contain [%s] %q:\n%s", tc.level, tc.wantLog, ts.Logger)
+			}
+			if !ts.Logger.Contains(servertest.LevelInfo, "Webhook signature verified successfully") {
+				t.Errorf("logs do not show the verified signature:\n%s", ts.Logger)
+			}
+		})
+	}
+}
+
+// TestWebhookRejections tests the error responses of the webhook endpoint
+func TestWebhookRejections(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	body := []byte(`{"event_name": "item:added", "user_id": "1"}`)
+
+	resp := ts.Do(t, http.MethodPost, "/webhooks/todoist", body, nil)
+	servertest.AssertGoldenResponse(t, resp, "webhook_missing_signature")
+
+	resp = ts.Do(t, http.MethodPost, "/webhooks/todoist", body, http.Header{api.TodoistSignatureHeader: {strings.Repeat("0", 64)}})
+	servertest.AssertGoldenResponse(t, resp, "webhook_invalid_signature")
+	if !ts.Logger.Contains(servertest.LevelError, "Invalid signature") {
+		t.Errorf("logs do not show the invalid signature:\n%s", ts.Logger)
+	}
+
+	resp = ts.Webhook(t, []byte(`{"event_name": `))
+	servertest.AssertGoldenResponse(t, resp, "webhook_malformed")
+}
+
+// TestHealthCheck tests the health check endpoint
+func TestHealthCheck(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+
+	resp := ts.Do(t, http.MethodGet, "/health", nil, nil)
+	servertest.AssertGoldenResponse(t, resp, "health")
+	if !ts.Logger.Contains(servertest.LevelInfo, "Health check successful") {
+		t.Errorf("logs do not show the health check:\n%s", ts.Logger)
+	}
+}
+
+// TestListTasksUsesServerClock tests that relative task filters are evaluated on the server's clock
+func TestListTasksUsesServerClock(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	ts.Seed(t, "1", servertest.State{Tasks: []api.Task{
+		{ID: "1", Content: "Due on the epoch", Due: &api.Due{Date: servertest.Epoch.Format("2006-01-02")}},
+		{ID: "2", Content: "Due a day later", Due: &api.Due{Date: servertest.Epoch.AddDate(0, 0, 1).Format("2006-01-02")}},
+	}})
+
+	header := servertest.Bearer(ts.Token(t, "1", auth.ScopeTasksRead))
+	listToday := func() []string {
+		t.Helper()
+		var response struct {
+			Tasks []struct {
+				ID string `json:"id"`
+			} `json:"tasks"`
+		}
+		servertest.DecodeJSON(t, ts.Do(t, http.MethodGet, "/tasks?user_id=1&filter=today", nil, header), &response)
+		var ids []string
+		for _, task := range response.Tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	if got := listToday(); len(got) != 1 || got[0] != "1" {
+		t.Errorf("tasks due today = %v, want [1]", got)
+	}
+	ts.Clock.Advance(24 * time.Hour)
+	if got := listToday(); len(got) != 1 || got[0] != "2" {
+		t.Errorf("tasks due today a day later = %v, want [2]", got)
+	}
+}
+
+// TestNotFound tests the error envelope of unknown routes
+func TestNotFound(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	servertest.AssertGoldenResponse(t, ts.Do(t, http.MethodGet, "/missing", nil, nil), "not_found")
+}
+
+// TestStorage tests that events and the mirror outlive the server when a database is configured
+func TestStorage(t *testing.T) {
+	databaseURL := "sqlite:" + filepath.Join(t.TempDir(), "cherry.db")
+
+	t.Setenv("CHERRY_DATABASE_URL", databaseURL)
+	t.Setenv("CHERRY_AUTO_MIGRATE", "false")
+	if ts := servertest.NewTestServer(t); ts.Storage != nil || !ts.Logger.Contains(servertest.LevelError, "pending migration") {
+		t.Fatalf("server used an unmigrated database; logs:\n%s", ts.Logger)
+	}
+
+	t.Setenv("CHERRY_AUTO_MIGRATE", "true")
+	first := servertest.NewTestServer(t)
+	if first.Storage == nil {
+		t.Fatalf("storage is disabled; logs:\n%s", first.Logger)
+	}
+	first.Webhook(t, []byte(`{"event_name": "item:added", "user_id": "1", "event_data": {"id": "7"}, "version": "9"}`))
+	sync := &api.SyncResponse{SyncToken: "t1", FullSync: true, Items: []api.Task{{ID: "7", Content: "Buy milk"}}}
+	if err := first.MirrorStore.Apply(context.Background(), "1", "", sync); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	subscriber, err := first.Fanout.Subscribe(fanout.Subscriber{URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	first.Storage.Close()
+
+	second := servertest.NewTestServer(t)
+	if second.Storage == nil {
+		t.Fatalf("storage is disabled after the restart; logs:\n%s", second.Logger)
+	}
+	stored, err := second.Storage.Events().Since(context.Background(), "1", 0, 0)
+	if err != nil || len(stored) != 1 || stored[0].Name != "item:added" {
+		t.Errorf("stored events = %v, %v, want the webhook of the first server", stored, err)
+	}
+	if snapshot, err := second.MirrorStore.Snapshot(context.Background(), "1"); err != nil || snapshot.SyncToken != "t1" || len(snapshot.Tasks) != 1 {
+		t.Errorf("mirror = %+v, %v, want the task synced by the first server", snapshot, err)
+	}
+	if got, err := second.Fanout.Subscriber(subscriber.ID); err != nil || got.URL != subscriber.URL {
+		t.Errorf("subscriber = %+v, %v, want the one registered with the first server", got, err)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/tenant"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// defaultStatsDays is the number of days a stats request covers when it does not set from
+const defaultStatsDays = 30
+
+// StatsServiceImpl implements the StatsService interface over the aggregated statistics, with
+// the overdue tasks and project names taken from the local Todoist mirror
+type StatsServiceImpl struct {
+	apiv1.UnimplementedStatsServiceServer
+	Store  stats.Store
+	Mirror mirror.Store
+
+	// Now returns the current time, which decides what today and overdue are; defaults to time.Now
+	Now func() time.Time
+}
+
+// GetStats returns the completion statistics of a user over a range of days in the time zone
+// of the user's account
+func (s *StatsServiceImpl) GetStats(ctx context.Context, request *apiv1.GetStatsRequest) (*apiv1.GetStatsResponse, error) {
+	userID, err := scopeUserID(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidArgument)
+	}
+
+	location := time.UTC
+	if account, ok := tenant.FromContext(ctx); ok {
+		location = account.Location()
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	today := stats.DateOf(now(), location)
+	to := request.To
+	if to == "" {
+		to = today
+	}
+	from := request.From
+	if from == "" {
+		from = stats.AddDays(to, 1-defaultStatsDays)
+	}
+
+	report, err := stats.NewReport(ctx, s.Store, userID, from, to, today)
+	if errors.Is(err, stats.ErrInvalidDate) || errors.Is(err, stats.ErrInvalidRange) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := s.Mirror.Snapshot(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks: %w", err)
+	}
+	report.CountOverdue(snapshot.Tasks, now().In(location))
+
+	projectNames := make(map[string]string, len(snapshot.Projects))
+	for _, project := range snapshot.Projects {
+		projectNames[project.ID] = project.Name
+	}
+	return reportToProto(report, location, projectNames), nil
+}
+
+// reportToProto converts a report to its API representation
+func reportToProto(report *stats.Report, location *time.Location, projectNames map[string]string) *apiv1.GetStatsResponse {
+	response := &apiv1.GetStatsResponse{
+		UserId:   report.UserID,
+		Timezone: location.String(),
+		From:     report.From,
+		To:       report.To,
+		Totals:   countsToProto(report.Totals),
+		Streak: &apiv1.Streak{
+			Current:    int32(report.Streak.Current),
+			Longest:    int32(report.Streak.Longest),
+			LastDate:   report.Streak.Last,
+			LongestEnd: report.Streak.LongestEnd,
+		},
+		Overdue: int32(report.Overdue),
+	}
+	for _, day := range report.Days {
+		response.Days = append(response.Days, &apiv1.DayStats{Date: day.Date, Counts: countsToProto(day.Counts)})
+	}
+	for _, week := range report.Weeks {
+		response.Weeks = append(response.Weeks, &apiv1.WeekStats{Week: week.Week, Start: week.Start, Counts: countsToProto(week.Counts)})
+	}
+	for _, group := range report.Projects {
+		response.Projects = append(response.Projects, groupToProto(group, projectNames[group.Key], report.OverdueByGroup))
+	}
+	for _, group := range report.Labels {
+		response.Labels = append(response.Labels, groupToProto(group, group.Key, report.OverdueByGroup))
+	}
+	return response
+}
+
+// groupToProto converts the statistics of a project or label to their API representation
+func groupToProto(group stats.Group, name string, overdue map[string]map[string]int) *apiv1.GroupStats {
+	return &apiv1.GroupStats{
+		Id:      group.Key,
+		Name:    name,
+		Counts:  countsToProto(group.Counts),
+		Overdue: int32(overdue[group.Kind][group.Key]),
+	}
+}
+
+// countsToProto converts counts to their API representation
+func countsToProto(counts stats.Counts) *apiv1.StatsCounts {
+	return &apiv1.StatsCounts{
+		Completed:              int32(counts.Completed),
+		Added:                  int32(counts.Added),
+		CompletedLate:          int32(counts.CompletedLate),
+		AverageCompletionHours: counts.AverageCompletion().Hours(),
+	}
+}
@@ -0,0 +1,118 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/server/servertest"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// taskWebhook returns the body of a webhook about a task of a user
+func taskWebhook(t *testing.T, eventName, userID string, task api.Task) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{"event_name": eventName, "user_id": userID, "event_data": task, "version": "9"})
+	if err != nil {
+		t.Fatalf("failed to encode webhook: %v", err)
+	}
+	return body
+}
+
+// TestGetStats tests aggregating webhooks into statistics in the user's time zone
+func TestGetStats(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "1", Settings: tenant.Settings{Timezone: "America/New_York"}})
+	ts.Clock.Set(time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC))
+	ts.Seed(t, "1", servertest.State{
+		Tasks:    []api.Task{{ID: "3", ProjectID: "work", Labels: []string{"errand"}, Due: &api.Due{Date: "2024-03-04"}}},
+		Projects: []api.Project{{ID: "work", Name: "Work"}, {ID: "home", Name: "Home"}},
+	})
+
+	for _, body := range [][]byte{
+		taskWebhook(t, "item:added", "1", api.Task{ID: "1", ProjectID: "work", AddedAt: "2024-03-03T14:00:00Z"}),
+		// 02:00 UTC is still March 3 in New York
+		taskWebhook(t, "item:completed", "1", api.Task{ID: "1", ProjectID: "work", AddedAt: "2024-03-03T14:00:00Z", CompletedAt: "2024-03-04T02:00:00Z"}),
+		taskWebhook(t, "item:completed", "1", api.Task{ID: "2", ProjectID: "home", Labels: []string{"errand"}, Due: &api.Due{Date: "2024-03-04"}, CompletedAt: "2024-03-05T13:00:00Z"}),
+		taskWebhook(t, "item:updated", "1", api.Task{ID: "3", ProjectID: "work"}),
+	} {
+		if resp := ts.Webhook(t, body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("webhook = %d: %s", resp.StatusCode, servertest.Body(t, resp))
+		}
+	}
+
+	client := api.NewStatsClient(ts.URL(""))
+	client.Generator.Use(api.BearerAuth(ts.Token(t, "1", auth.ScopeStatsRead)))
+	response, err := client.GetStats(context.Background(), &apiv1.GetStatsRequest{From: "2024-03-01"})
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+
+	if response.Timezone != "America/New_York" || response.From != "2024-03-01" || response.To != "2024-03-05" || len(response.Days) != 5 {
+		t.Errorf("range = %s %s to %s with %d days, want March 1 to 5 in New York", response.Timezone, response.From, response.To, len(response.Days))
+	}
+	totals := response.Totals
+	if totals.Completed != 2 || totals.Added != 1 || totals.CompletedLate != 1 || totals.AverageCompletionHours != 12 {
+		t.Errorf("totals = %+v, want 2 completions, one late, and 1 addition taking 12h", totals)
+	}
+	if day := response.Days[2]; day.Date != "2024-03-03" || day.Counts.Completed != 1 || day.Counts.Added != 1 {
+		t.Errorf("March 3 = %+v, want the first task added and completed", day)
+	}
+	if len(response.Weeks) != 2 || response.Weeks[0].Counts.Completed != 1 || response.Weeks[1].Week != "2024-W10" {
+		t.Errorf("weeks = %+v, want W09 and W10 with one completion each", response.Weeks)
+	}
+	if streak := response.Streak; streak.Current != 1 || streak.Longest != 1 || streak.LastDate != "2024-03-05" {
+		t.Errorf("streak = %+v, want today only", streak)
+	}
+	if response.Overdue != 1 {
+		t.Errorf("overdue = %d, want 1", response.Overdue)
+	}
+	if len(response.Projects) != 2 || response.Projects[0].Id != "home" || response.Projects[1].Name != "Work" || response.Projects[1].Overdue != 1 {
+		t.Errorf("projects = %+v, want Home and Work with its overdue task", response.Projects)
+	}
+	if len(response.Labels) != 1 || response.Labels[0].Counts.CompletedLate != 1 || response.Labels[0].Overdue != 1 {
+		t.Errorf("labels = %+v, want errand with a late completion and an overdue task", response.Labels)
+	}
+}
+
+// TestGetStatsErrors tests refused and invalid stats requests
+func TestGetStatsErrors(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	token := servertest.Bearer(ts.Token(t, "1", auth.ScopeStatsRead))
+
+	testCases := []struct {
+		path       string
+		header     http.Header
+		wantStatus int
+	}{
+		{"/stats", servertest.Bearer(ts.Token(t, "1", auth.ScopeTasksRead)), http.StatusForbidden},
+		{"/stats?user_id=2", token, http.StatusForbidden},
+		{"/stats?from=2024-03-05&to=2024-03-01", token, http.StatusBadRequest},
+		{"/stats?from=2023-01-01&to=2024-03-01", token, http.StatusBadRequest},
+		{"/stats?to=tomorrow", token, http.StatusBadRequest},
+		{"/stats", token, http.StatusOK},
+	}
+	for _, tc := range testCases {
+		if resp := ts.Do(t, http.MethodGet, tc.path, nil, tc.header); resp.StatusCode != tc.wantStatus {
+			t.Errorf("GET %s = %d, want %d: %s", tc.path, resp.StatusCode, tc.wantStatus, servertest.Body(t, resp))
+		}
+	}
+}
+
+// TestStatsFeature tests that webhooks of accounts with the stats feature off are not counted
+func TestStatsFeature(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "1", Settings: tenant.Settings{Features: map[string]bool{tenant.FeatureStats: false}}})
+
+	ts.Webhook(t, taskWebhook(t, "item:completed", "1", api.Task{ID: "1"}))
+	var response apiv1.GetStatsResponse
+	servertest.DecodeJSON(t, ts.Do(t, http.MethodGet, "/stats", nil, servertest.Bearer(ts.Token(t, "1", auth.ScopeStatsRead))), &response)
+	if response.Totals.GetCompleted() != 0 || response.Streak.GetLongest() != 0 {
+		t.Errorf("stats = %+v, want nothing counted", &response)
+	}
+}
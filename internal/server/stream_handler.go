@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// streamKeepAlive is how often an idle stream sends a keep-alive
+const streamKeepAlive = 15 * time.Second
+
+// sseLineBreaks strips line breaks from SSE fields, which would otherwise let an event name forge fields
+var sseLineBreaks = strings.NewReplacer("\r", "", "\n", "")
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Clients authenticate with a token rather than cookies, so cross-origin connections are safe
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// streamRequest holds the parameters shared by the event stream endpoints
+type streamRequest struct {
+	userID string
+	after  uint64
+	filter []string
+}
+
+// parseStreamRequest reads the resume cursor and event filter of a stream request, which
+// requireScope has authorized. Without a cursor the stream starts with the next event.
+func (s *Server) parseStreamRequest(w http.ResponseWriter, r *http.Request) (*streamRequest, bool) {
+	account, _ := tenant.FromContext(r.Context())
+	if !account.HasFeature(tenant.FeatureEvents) {
+		writeErrorCode(w, http.StatusForbidden, api.CodePermission, "the event stream is off for user "+account.UserID)
+		return nil, false
+	}
+
+	request := &streamRequest{userID: account.UserID}
+	if filter := r.URL.Query().Get("events"); filter != "" {
+		request.filter = strings.Split(filter, ",")
+	}
+
+	var err error
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("last_event_id")
+	}
+	if cursor != "" {
+		if request.after, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, "Last-Event-ID must be an event ID")
+			return nil, false
+		}
+	} else if request.after, err = s.Events.Store.LastID(r.Context()); err != nil {
+		s.logger.Error("Error reading event store: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return nil, false
+	}
+	return request, true
+}
+
+// EventStreamHandler streams the events of the authenticated user as Server-Sent Events
+func (s *Server) EventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	request, ok := s.parseStreamRequest(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s.logger.Info("Event stream opened for user %s after event %d", request.userID, request.after)
+	send := func(event *events.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, sseLineBreaks.Replace(event.Name), data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	keepAlive := func() error {
+		if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	err := s.Events.Tail(r.Context(), request.userID, request.after, request.filter, streamKeepAlive, send, keepAlive)
+	s.logger.Info("Event stream closed for user %s: %v", request.userID, err)
+}
+
+// EventWebSocketHandler streams the events of the authenticated user over a WebSocket, one JSON event per message
+func (s *Server) EventWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	request, ok := s.parseStreamRequest(w, r)
+	if !ok {
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Warn("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Read in the background so that close frames are processed and a closed connection ends the stream
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	s.logger.Info("Event WebSocket opened for user %s after event %d", request.userID, request.after)
+	send := func(event *events.Event) error {
+		return conn.WriteJSON(event)
+	}
+	ping := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+	}
+	err = s.Events.Tail(ctx, request.userID, request.after, request.filter, streamKeepAlive, send, ping)
+	s.logger.Info("Event WebSocket closed for user %s: %v", request.userID, err)
+}
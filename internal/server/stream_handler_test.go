@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/mirror"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// newStreamTestServer starts a server where user "1" authenticates with "token-1"
+func newStreamTestServer(t *testing.T) (*Server, *httptest.Server) {
+	setupTestEnv(t)
+	t.Cleanup(func() { cleanupTestEnv(t) })
+
+	s := NewServer()
+	s.Auth.Todoist = mirror.StaticTokenSource{"1": "token-1"}
+	httpServer := httptest.NewServer(s.Router)
+	t.Cleanup(httpServer.Close)
+	t.Cleanup(s.Fanout.Close)
+	return s, httpServer
+}
+
+func publish(t *testing.T, s *Server, name, userID string) {
+	t.Helper()
+	request := &apiv1.TodoistWebhookRequest{EventName: name, UserId: userID, EventData: `{"id": "7"}`}
+	if err := s.Events.OnWebhook(context.Background(), request); err != nil {
+		t.Fatalf("OnWebhook failed: %v", err)
+	}
+}
+
+// readSSE reads one Server-Sent Event and returns its fields
+func readSSE(t *testing.T, reader *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok && key != "" {
+			fields[key] = value
+		}
+	}
+}
+
+// TestEventStreamResume tests that the SSE stream resumes after Last-Event-ID and only carries the user's events
+func TestEventStreamResume(t *testing.T) {
+	s, httpServer := newStreamTestServer(t)
+	publish(t, s, "item:added", "1")
+	publish(t, s, "item:added", "2")
+	publish(t, s, "item:completed", "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", httpServer.URL+"/events/stream", nil)
+	req.Header.Set("Authorization", "Bearer token-1")
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %s, want text/event-stream", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if event := readSSE(t, reader); event["id"] != "3" || event["event"] != "item:completed" {
+		t.Errorf("first event = %v, want the stored item:completed event 3", event)
+	}
+
+	publish(t, s, "item:deleted", "2")
+	publish(t, s, "item:deleted", "1")
+	if event := readSSE(t, reader); event["id"] != "5" || !strings.Contains(event["data"], `"user_id":"1"`) {
+		t.Errorf("live event = %v, want event 5 of user 1", event)
+	}
+}
+
+// TestEventStreamLineBreaks tests that line breaks in event names cannot forge SSE fields
+func TestEventStreamLineBreaks(t *testing.T) {
+	s, httpServer := newStreamTestServer(t)
+	publish(t, s, "item:added\r\nid: 99\nevent: forged", "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", httpServer.URL+"/events/stream", nil)
+	req.Header.Set("Authorization", "Bearer token-1")
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if event := readSSE(t, bufio.NewReader(resp.Body)); event["id"] != "1" || event["event"] != "item:addedid: 99event: forged" {
+		t.Errorf("event = %v, want event 1 with the line breaks stripped from its name", event)
+	}
+}
+
+// TestEventStreamUnauthorized tests that streams require a valid token
+func TestEventStreamUnauthorized(t *testing.T) {
+	_, httpServer := newStreamTestServer(t)
+
+	for _, url := range []string{"/events/stream", "/events/stream?access_token=wrong"} {
+		resp, err := http.Get(httpServer.URL + url)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("GET %s status = %d, want 401", url, resp.StatusCode)
+		}
+	}
+}
+
+// TestEventWebSocket tests live events over a WebSocket authenticated with the access_token parameter
+func TestEventWebSocket(t *testing.T) {
+	s, httpServer := newStreamTestServer(t)
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/events/ws?access_token=token-1&events=item:*"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to open WebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	// The cursor is taken before the upgrade, so events published now are not missed
+	publish(t, s, "project:added", "1")
+	publish(t, s, "item:added", "1")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event events.Event
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+	if event.Name != "item:added" || event.ResourceID != "7" {
+		t.Errorf("event = %+v, want item:added for resource 7", event)
+	}
+}
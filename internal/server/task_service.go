@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cherry_backend/internal/filter"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+const (
+	// defaultTaskPageSize is the page size used when a request does not specify one
+	defaultTaskPageSize = 50
+
+	// maxTaskPageSize is the largest page size a request may ask for
+	maxTaskPageSize = 200
+)
+
+// ErrInvalidArgument is returned for requests with malformed fields
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// TaskServiceImpl implements the TaskService interface over the local Todoist mirror
+type TaskServiceImpl struct {
+	apiv1.UnimplementedTaskServiceServer
+	Store mirror.Store
+
+	// Now returns the current time for relative filter terms such as "today"; defaults to time.Now
+	Now func() time.Time
+}
+
+// taskFilter is a parsed ListTasksRequest
+type taskFilter struct {
+	projectID  string
+	labels     []string
+	dueAfter   *time.Time
+	dueBefore  *time.Time
+	location   *time.Location
+	priorities map[int32]bool
+	completion apiv1.TaskCompletion
+	terms      []string
+	query      filter.Expr
+	env        *filter.Env
+}
+
+// ListTasks lists the tasks of a user that match the given filters
+func (s *TaskServiceImpl) ListTasks(ctx context.Context, request *apiv1.ListTasksRequest) (*apiv1.ListTasksResponse, error) {
+	userID, err := scopeUserID(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+	request.UserId = userID
+	if request.UserId == "" {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidArgument)
+	}
+
+	// Dates are in the time zone of the user's account
+	location := time.UTC
+	if account, ok := tenant.FromContext(ctx); ok {
+		location = account.Location()
+	}
+	matcher, err := newTaskFilter(request, location)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int(request.PageSize)
+	if pageSize <= 0 {
+		pageSize = defaultTaskPageSize
+	}
+	if pageSize > maxTaskPageSize {
+		pageSize = maxTaskPageSize
+	}
+
+	after, err := decodePageToken(request.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.Store.Snapshot(ctx, request.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks: %w", err)
+	}
+
+	// Filter queries resolve project and section names against the user's own state, and
+	// relative dates in the time zone of the user's account
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	matcher.env = filter.NewEnv(now().In(location), snapshot.Projects, snapshot.Sections)
+
+	tasks := snapshot.Tasks
+	sort.Slice(tasks, func(i, j int) bool {
+		return compareIDs(tasks[i].ID, tasks[j].ID) < 0
+	})
+
+	// Collect one page of matching tasks after the cursor
+	response := &apiv1.ListTasksResponse{}
+	for _, task := range tasks {
+		if after != "" && compareIDs(task.ID, after) <= 0 {
+			continue
+		}
+		if !matcher.match(&task) {
+			continue
+		}
+		if len(response.Tasks) == pageSize {
+			response.NextPageToken = encodePageToken(response.Tasks[pageSize-1].Id)
+			break
+		}
+		response.Tasks = append(response.Tasks, taskToProto(&task))
+	}
+
+	return response, nil
+}
+
+// newTaskFilter validates and parses the filters of a ListTasksRequest. Dates without a time
+// zone are in location.
+func newTaskFilter(request *apiv1.ListTasksRequest, location *time.Location) (*taskFilter, error) {
+	f := &taskFilter{
+		location:   location,
+		projectID:  request.ProjectId,
+		labels:     request.Labels,
+		completion: request.Completion,
+		terms:      strings.Fields(strings.ToLower(request.Query)),
+	}
+
+	if request.DueAfter != "" {
+		t, ok := parseDue(request.DueAfter, location)
+		if !ok {
+			return nil, fmt.Errorf("%w: due_after must be YYYY-MM-DD or RFC 3339", ErrInvalidArgument)
+		}
+		f.dueAfter = &t
+	}
+	if request.DueBefore != "" {
+		t, ok := parseDue(request.DueBefore, location)
+		if !ok {
+			return nil, fmt.Errorf("%w: due_before must be YYYY-MM-DD or RFC 3339", ErrInvalidArgument)
+		}
+		// A bare date includes the whole day
+		if len(request.DueBefore) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		f.dueBefore = &t
+	}
+
+	if len(request.Priorities) > 0 {
+		f.priorities = make(map[int32]bool)
+		for _, p := range request.Priorities {
+			if p < 1 || p > 4 {
+				return nil, fmt.Errorf("%w: priorities must be between 1 and 4", ErrInvalidArgument)
+			}
+			f.priorities[p] = true
+		}
+	}
+
+	if request.Filter != "" {
+		query, err := filter.Parse(request.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		f.query = query
+	}
+
+	return f, nil
+}
+
+// match reports whether a task passes every filter
+func (f *taskFilter) match(task *api.Task) bool {
+	switch f.completion {
+	case apiv1.TaskCompletion_TASK_COMPLETION_COMPLETED:
+		if !task.Checked {
+			return false
+		}
+	case apiv1.TaskCompletion_TASK_COMPLETION_ALL:
+	default:
+		if task.Checked {
+			return false
+		}
+	}
+
+	if f.projectID != "" && task.ProjectID != f.projectID {
+		return false
+	}
+
+	for _, label := range f.labels {
+		if !hasLabel(task, label) {
+			return false
+		}
+	}
+
+	if f.priorities != nil && !f.priorities[int32(task.Priority)] {
+		return false
+	}
+
+	if f.dueAfter != nil || f.dueBefore != nil {
+		if task.Due == nil {
+			return false
+		}
+		due, ok := parseDue(task.Due.Date, f.location)
+		if !ok ||
+			(f.dueAfter != nil && due.Before(*f.dueAfter)) ||
+			(f.dueBefore != nil && due.After(*f.dueBefore)) {
+			return false
+		}
+	}
+
+	if f.query != nil && !f.query.Match(f.env, task) {
+		return false
+	}
+
+	if len(f.terms) > 0 {
+		text := strings.ToLower(task.Content + "\n" + task.Description)
+		for _, term := range f.terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// hasLabel reports whether a task carries a label, ignoring case
+func hasLabel(task *api.Task, label string) bool {
+	for _, l := range task.Labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDue parses the date formats used by Todoist due dates; dates and times without a time
+// zone are in location
+func parseDue(value string, location *time.Location) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareIDs orders numeric IDs numerically, before any other IDs, which are ordered lexically
+func compareIDs(a, b string) int {
+	aNumeric, bNumeric := isNumericID(a), isNumericID(b)
+	switch {
+	case aNumeric && !bNumeric:
+		return -1
+	case !aNumeric && bNumeric:
+		return 1
+	case aNumeric && len(a) != len(b):
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// isNumericID reports whether an ID is a number without leading zeros
+func isNumericID(id string) bool {
+	if id == "" || (id[0] == '0' && len(id) > 1) {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// encodePageToken creates an opaque cursor pointing after the given task ID
+func encodePageToken(lastID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("after:" + lastID))
+}
+
+// decodePageToken returns the task ID a cursor points after, or "" for the first page
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(decoded), "after:") {
+		return "", fmt.Errorf("%w: malformed page_token", ErrInvalidArgument)
+	}
+	return strings.TrimPrefix(string(decoded), "after:"), nil
+}
+
+// taskToProto converts a mirrored task to its API representation
+func taskToProto(task *api.Task) *apiv1.Task {
+	result := &apiv1.Task{
+		Id:          task.ID,
+		ProjectId:   task.ProjectID,
+		SectionId:   task.SectionID,
+		ParentId:    task.ParentID,
+		Content:     task.Content,
+		Description: task.Description,
+		Labels:      task.Labels,
+		Priority:    int32(task.Priority),
+		Completed:   task.Checked,
+		AddedAt:     task.AddedAt,
+		CompletedAt: task.CompletedAt,
+		UpdatedAt:   task.UpdatedAt,
+	}
+	if task.Due != nil {
+		result.DueDate = task.Due.Date
+		result.DueString = task.Due.String
+		result.DueIsRecurring = task.Due.IsRecurring
+	}
+	return result
+}
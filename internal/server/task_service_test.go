@@ -0,0 +1,306 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// newTestTaskStore returns a mirror store holding a fixed set of tasks for user "1"
+func newTestTaskStore(t *testing.T) mirror.Store {
+	store := mirror.NewMemoryStore()
+	delta := &api.SyncResponse{
+		SyncToken: "1",
+		FullSync:  true,
+		Items: []api.Task{
+			{ID: "1", ProjectID: "work", Content: "Write report", Description: "Quarterly numbers", Labels: []string{"urgent"}, Priority: 4, Due: &api.Due{Date: "2024-03-01"}},
+			{ID: "2", ProjectID: "work", Content: "Email Bob", Labels: []string{"waiting"}, Priority: 1, Due: &api.Due{Date: "2024-03-05T10:00:00Z"}},
+			{ID: "3", ProjectID: "home", Content: "Buy milk", Priority: 2},
+			{ID: "4", ProjectID: "home", Content: "Fix the report shelf", Checked: true, Priority: 1},
+			{ID: "10", ProjectID: "work", Content: "Plan offsite", Labels: []string{"urgent", "waiting"}, Priority: 3, Due: &api.Due{Date: "2024-03-10"}},
+		},
+		Projects: []api.Project{
+			{ID: "work", Name: "Work"},
+			{ID: "home", Name: "Home"},
+		},
+	}
+	if err := store.Apply(context.Background(), "1", "", delta); err != nil {
+		t.Fatalf("Failed to seed store: %v", err)
+	}
+	return store
+}
+
+// taskIDs returns the IDs of the tasks in a response
+func taskIDs(response *apiv1.ListTasksResponse) []string {
+	ids := []string{}
+	for _, task := range response.Tasks {
+		ids = append(ids, task.Id)
+	}
+	return ids
+}
+
+// TestListTasksFilters tests each filter of ListTasks
+func TestListTasksFilters(t *testing.T) {
+	service := &TaskServiceImpl{
+		Store: newTestTaskStore(t),
+		Now:   func() time.Time { return time.Date(2024, 3, 7, 12, 0, 0, 0, time.UTC) },
+	}
+
+	testCases := []struct {
+		name    string
+		request *apiv1.ListTasksRequest
+		wantIDs []string
+	}{
+		{
+			name:    "active tasks by default",
+			request: &apiv1.ListTasksRequest{},
+			wantIDs: []string{"1", "2", "3", "10"},
+		},
+		{
+			name:    "project",
+			request: &apiv1.ListTasksRequest{ProjectId: "home"},
+			wantIDs: []string{"3"},
+		},
+		{
+			name:    "all labels must match",
+			request: &apiv1.ListTasksRequest{Labels: []string{"urgent", "waiting"}},
+			wantIDs: []string{"10"},
+		},
+		{
+			name:    "due range includes the whole last day",
+			request: &apiv1.ListTasksRequest{DueAfter: "2024-03-02", DueBefore: "2024-03-05"},
+			wantIDs: []string{"2"},
+		},
+		{
+			name:    "priorities",
+			request: &apiv1.ListTasksRequest{Priorities: []int32{3, 4}},
+			wantIDs: []string{"1", "10"},
+		},
+		{
+			name:    "completed",
+			request: &apiv1.ListTasksRequest{Completion: apiv1.TaskCompletion_TASK_COMPLETION_COMPLETED},
+			wantIDs: []string{"4"},
+		},
+		{
+			name:    "full-text search over content and description",
+			request: &apiv1.ListTasksRequest{Query: "REPORT", Completion: apiv1.TaskCompletion_TASK_COMPLETION_ALL},
+			wantIDs: []string{"1", "4"},
+		},
+		{
+			name:    "filter query",
+			request: &apiv1.ListTasksRequest{Filter: "#Work & (p1 | @waiting)"},
+			wantIDs: []string{"1", "2", "10"},
+		},
+		{
+			name:    "filter query combined with fields",
+			request: &apiv1.ListTasksRequest{Filter: "overdue", Priorities: []int32{4}},
+			wantIDs: []string{"1"},
+		},
+		{
+			name:    "every search term must match",
+			request: &apiv1.ListTasksRequest{Query: "report quarterly"},
+			wantIDs: []string{"1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.request.UserId = "1"
+			response, err := service.ListTasks(context.Background(), tc.request)
+			if err != nil {
+				t.Fatalf("ListTasks returned an error: %v", err)
+			}
+
+			got := taskIDs(response)
+			if len(got) != len(tc.wantIDs) {
+				t.Fatalf("ListTasks() = %v, want %v", got, tc.wantIDs)
+			}
+			for i := range got {
+				if got[i] != tc.wantIDs[i] {
+					t.Errorf("ListTasks() = %v, want %v", got, tc.wantIDs)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestListTasksTimeZone tests that relative dates and due date bounds follow the time zone of the
+// user's account
+func TestListTasksTimeZone(t *testing.T) {
+	// Still March 9 in Los Angeles
+	service := &TaskServiceImpl{
+		Store: newTestTaskStore(t),
+		Now:   func() time.Time { return time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC) },
+	}
+	account := &tenant.Account{UserID: "1", Settings: tenant.Settings{Timezone: "America/Los_Angeles"}}
+
+	inLA := tenant.NewContext(context.Background(), account)
+	// Task 2 is due at 10:00 UTC on March 5, which is already March 6 on Kiritimati
+	inKiritimati := tenant.NewContext(context.Background(), &tenant.Account{UserID: "1", Settings: tenant.Settings{Timezone: "Pacific/Kiritimati"}})
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		request *apiv1.ListTasksRequest
+		wantIDs []string
+	}{
+		{"today without account", context.Background(), &apiv1.ListTasksRequest{Filter: "today"}, []string{"10"}},
+		{"today in Los Angeles", inLA, &apiv1.ListTasksRequest{Filter: "today"}, []string{}},
+		{"tomorrow in Los Angeles", inLA, &apiv1.ListTasksRequest{Filter: "tomorrow"}, []string{"10"}},
+		{"due before March 5 without account", context.Background(), &apiv1.ListTasksRequest{DueBefore: "2024-03-05"}, []string{"1", "2"}},
+		{"due before March 5 on Kiritimati", inKiritimati, &apiv1.ListTasksRequest{DueBefore: "2024-03-05"}, []string{"1"}},
+		{"due after March 6 without account", context.Background(), &apiv1.ListTasksRequest{DueAfter: "2024-03-06"}, []string{"10"}},
+		{"due after March 6 on Kiritimati", inKiritimati, &apiv1.ListTasksRequest{DueAfter: "2024-03-06"}, []string{"2", "10"}},
+	}
+	for _, tc := range testCases {
+		tc.request.UserId = "1"
+		response, err := service.ListTasks(tc.ctx, tc.request)
+		if err != nil {
+			t.Fatalf("ListTasks returned an error: %v", err)
+		}
+		if got := taskIDs(response); strings.Join(got, ",") != strings.Join(tc.wantIDs, ",") {
+			t.Errorf("%s: ListTasks() = %v, want %v", tc.name, got, tc.wantIDs)
+		}
+	}
+}
+
+// TestListTasksPagination tests that page tokens walk through every task exactly once
+func TestListTasksPagination(t *testing.T) {
+	service := &TaskServiceImpl{Store: newTestTaskStore(t)}
+
+	var got []string
+	request := &apiv1.ListTasksRequest{UserId: "1", PageSize: 3, Completion: apiv1.TaskCompletion_TASK_COMPLETION_ALL}
+	for pages := 0; pages < 10; pages++ {
+		response, err := service.ListTasks(context.Background(), request)
+		if err != nil {
+			t.Fatalf("ListTasks returned an error: %v", err)
+		}
+		got = append(got, taskIDs(response)...)
+		if response.NextPageToken == "" {
+			break
+		}
+		request.PageToken = response.NextPageToken
+	}
+
+	want := []string{"1", "2", "3", "4", "10"}
+	if len(got) != len(want) {
+		t.Fatalf("paginated ListTasks() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("paginated ListTasks() = %v, want %v", got, want)
+		}
+	}
+}
+
+// TestCompareIDs tests that numeric IDs sort numerically and before every other ID
+func TestCompareIDs(t *testing.T) {
+	sorted := []string{"2", "9", "10", "123", "01", "1_", "6Jf8VQXxpwv56VQ7", "a"}
+	for i := range sorted {
+		for j := range sorted {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := compareIDs(sorted[i], sorted[j]); got != want {
+				t.Errorf("compareIDs(%q, %q) = %d, want %d", sorted[i], sorted[j], got, want)
+			}
+		}
+	}
+}
+
+// TestListTasksInvalidArguments tests that malformed requests are rejected
+func TestListTasksInvalidArguments(t *testing.T) {
+	service := &TaskServiceImpl{Store: newTestTaskStore(t)}
+
+	requests := []*apiv1.ListTasksRequest{
+		{},
+		{UserId: "1", DueAfter: "next week"},
+		{UserId: "1", Priorities: []int32{5}},
+		{UserId: "1", PageToken: "not a token"},
+		{UserId: "1", Filter: "today &"},
+	}
+	for _, request := range requests {
+		if _, err := service.ListTasks(context.Background(), request); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ListTasks(%v) error = %v, want ErrInvalidArgument", request, err)
+		}
+	}
+}
+
+// TestListTasksREST tests the /tasks endpoint through the REST client
+func TestListTasksREST(t *testing.T) {
+	// Setup test environment
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	s := NewServer()
+	s.MirrorStore = newTestTaskStore(t)
+	s.Auth.Todoist = mirror.StaticTokenSource{"1": "token-1"}
+	httpServer := httptest.NewServer(s.Router)
+	defer httpServer.Close()
+
+	client := api.NewTaskClient(httpServer.URL)
+	client.Generator.Use(api.BearerAuth("token-1"))
+	response, err := client.ListTasks(context.Background(), &apiv1.ListTasksRequest{
+		UserId:     "1",
+		Labels:     []string{"urgent"},
+		Priorities: []int32{3},
+	})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if got := taskIDs(response); len(got) != 1 || got[0] != "10" {
+		t.Errorf("ListTasks() = %v, want [10]", got)
+	}
+
+	if _, err := client.ListTasks(context.Background(), &apiv1.ListTasksRequest{UserId: "2"}); err == nil {
+		t.Error("ListTasks of another user succeeded, want an error")
+	}
+}
+
+// TestErrorEnvelope tests that REST errors use the JSON error envelope with the request ID
+func TestErrorEnvelope(t *testing.T) {
+	// Setup test environment
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	s := NewServer()
+	defer s.Fanout.Close()
+	httpServer := httptest.NewServer(s.Router)
+	defer httpServer.Close()
+
+	_, err := api.NewTaskClient(httpServer.URL).ListTasks(context.Background(), &apiv1.ListTasksRequest{})
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("ListTasks() error = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != api.CodeUnauthenticated || apiErr.RequestID == "" {
+		t.Errorf("APIError = %+v, want 401 unauthenticated with a request ID", apiErr)
+	}
+
+	resp, err := http.Get(httpServer.URL + "/missing")
+	if err != nil {
+		t.Fatalf("GET /missing failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var envelope api.ErrorEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil || envelope.Error == nil {
+		t.Fatalf("GET /missing body is not an error envelope: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound || envelope.Error.Code != api.CodeNotFound ||
+		envelope.Error.RequestID != resp.Header.Get("X-Request-Id") {
+		t.Errorf("GET /missing = %d %+v, want 404 not_found echoing X-Request-Id", resp.StatusCode, envelope.Error)
+	}
+}
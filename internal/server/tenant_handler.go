@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// ListUsersHandler lists the accounts of every Todoist user
+func (s *Server) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	accounts, err := s.Accounts.List(r.Context())
+	if err != nil {
+		s.logger.Error("Error listing accounts: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if accounts == nil {
+		accounts = []tenant.Account{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"users": accounts})
+}
+
+// GetUserHandler returns the account of a Todoist user
+func (s *Server) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	account, err := s.Accounts.Get(r.Context(), mux.Vars(r)["user_id"])
+	if err != nil {
+		s.writeAccountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+// PutUserHandler creates the account of a Todoist user or replaces its email, status and settings
+func (s *Server) PutUserHandler(w http.ResponseWriter, r *http.Request) {
+	var account tenant.Account
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		writeError(w, http.StatusBadRequest, "request body must be a JSON object")
+		return
+	}
+	account.UserID = mux.Vars(r)["user_id"]
+
+	status := http.StatusOK
+	if _, err := s.Accounts.Get(r.Context(), account.UserID); errors.Is(err, tenant.ErrUnknownUser) {
+		status = http.StatusCreated
+	}
+	if err := s.Accounts.Put(r.Context(), &account); err != nil {
+		s.writeAccountError(w, err)
+		return
+	}
+	s.logger.Info("Stored account of user %s (%s)", account.UserID, account.Status)
+	writeJSON(w, status, account)
+}
+
+// DeleteUserHandler removes the account of a Todoist user
+func (s *Server) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.Accounts.Delete(r.Context(), mux.Vars(r)["user_id"]); err != nil {
+		s.writeAccountError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DisableUserHandler disables the account of a Todoist user
+func (s *Server) DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.setUserStatus(w, r, tenant.StatusDisabled)
+}
+
+// EnableUserHandler enables the account of a Todoist user
+func (s *Server) EnableUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.setUserStatus(w, r, tenant.StatusActive)
+}
+
+func (s *Server) setUserStatus(w http.ResponseWriter, r *http.Request, status tenant.Status) {
+	account, err := s.Accounts.Get(r.Context(), mux.Vars(r)["user_id"])
+	if err != nil {
+		s.writeAccountError(w, err)
+		return
+	}
+	account.Status = status
+	if err := s.Accounts.Put(r.Context(), account); err != nil {
+		s.writeAccountError(w, err)
+		return
+	}
+	s.logger.Info("Account of user %s is now %s", account.UserID, status)
+	writeJSON(w, http.StatusOK, account)
+}
+
+// writeAccountError maps account registry errors to HTTP responses
+func (s *Server) writeAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tenant.ErrUnknownUser):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, tenant.ErrInvalidAccount):
+		writeErrorCode(w, http.StatusBadRequest, api.CodeInvalidArgument, err.Error())
+	default:
+		s.logger.Error("Error accessing accounts: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// ListQuarantineHandler lists the quarantined webhooks, optionally of one user, oldest first
+func (s *Server) ListQuarantineHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": s.Quarantine.List(r.URL.Query().Get("user_id"))})
+}
+
+// ReleaseQuarantinedHandler processes a quarantined webhook once its user has an active account; the
+// webhook stays quarantined if processing it fails
+func (s *Server) ReleaseQuarantinedHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	held, err := s.Quarantine.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if _, action, err := s.TenantPolicy.Admit(r.Context(), s.Accounts, held.UserID); action != tenant.Accept {
+		writeErrorCode(w, http.StatusConflict, api.CodeConflict, "webhook "+id+" cannot be released: "+err.Error())
+		return
+	}
+
+	if held, err = s.Quarantine.Take(id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx := r.Context()
+	if held.Delivery != nil {
+		ctx = events.WithDelivery(ctx, held.Delivery)
+	}
+	response, err := s.todoistService().ProcessWebhook(ctx, held.Request)
+	if err != nil {
+		s.logger.Error("Error processing released webhook %s: %v", id, err)
+		s.Quarantine.Return(held)
+		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	s.logger.Info("Released quarantined webhook %s of user %s", id, held.UserID)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DropQuarantinedHandler discards a quarantined webhook
+func (s *Server) DropQuarantinedHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.Quarantine.Take(mux.Vars(r)["id"]); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,296 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"cherry_backend/internal/server/servertest"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// webhookBody returns a webhook body of a user
+func webhookBody(userID string) []byte {
+	return []byte(fmt.Sprintf(`{"event_name": "item:added", "user_id": %q, "event_data": {"id": "7"}, "version": "9"}`, userID))
+}
+
+// recordedEvents returns how many events of a user the event log holds
+func recordedEvents(t *testing.T, ts *servertest.TestServer, userID string) int {
+	t.Helper()
+	stored, err := ts.Events.Store.Since(context.Background(), userID, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to read events: %v", err)
+	}
+	return len(stored)
+}
+
+// TestTenantPolicies tests what happens to webhooks of unknown users and disabled accounts
+func TestTenantPolicies(t *testing.T) {
+	testCases := []struct {
+		policy          string
+		user            string
+		wantStatus      int
+		wantMessage     string
+		wantRecorded    int
+		wantQuarantined int
+	}{
+		{"open", "active", http.StatusOK, "Webhook received", 1, 0},
+		{"open", "unknown", http.StatusOK, "Webhook received", 1, 0},
+		{"open", "disabled", http.StatusOK, "Webhook quarantined", 0, 1},
+		{"quarantine", "unknown", http.StatusOK, "Webhook quarantined", 0, 1},
+		{"reject", "unknown", http.StatusForbidden, "", 0, 0},
+		{"reject", "disabled", http.StatusForbidden, "", 0, 0},
+		{"reject", "active", http.StatusOK, "Webhook received", 1, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy+"/"+tc.user, func(t *testing.T) {
+			t.Setenv("CHERRY_TENANT_POLICY", tc.policy)
+			ts := servertest.NewTestServer(t)
+			ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "active"})
+			ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "disabled", Status: tenant.StatusDisabled})
+
+			resp := ts.Webhook(t, webhookBody(tc.user))
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tc.wantStatus, servertest.Body(t, resp))
+			}
+			if tc.wantStatus == http.StatusOK {
+				var response struct {
+					Message string `json:"message"`
+				}
+				servertest.DecodeJSON(t, resp, &response)
+				if response.Message != tc.wantMessage {
+					t.Errorf("message = %q, want %q", response.Message, tc.wantMessage)
+				}
+			} else {
+				var envelope api.ErrorEnvelope
+				servertest.DecodeJSON(t, resp, &envelope)
+				if envelope.Error == nil || envelope.Error.Code != api.CodePermission {
+					t.Errorf("error = %+v, want %s", envelope.Error, api.CodePermission)
+				}
+			}
+			if got := recordedEvents(t, ts, tc.user); got != tc.wantRecorded {
+				t.Errorf("recorded %d events, want %d", got, tc.wantRecorded)
+			}
+			if got := len(ts.Quarantine.List(tc.user)); got != tc.wantQuarantined {
+				t.Errorf("quarantined %d webhooks, want %d", got, tc.wantQuarantined)
+			}
+		})
+	}
+}
+
+// TestQuarantineAdmin tests releasing and dropping quarantined webhooks through the admin API
+func TestQuarantineAdmin(t *testing.T) {
+	t.Setenv("CHERRY_TENANT_POLICY", "quarantine")
+	ts := servertest.NewTestServer(t)
+
+	ts.Webhook(t, webhookBody("1"))
+	ts.Webhook(t, webhookBody("1"))
+	var listed struct {
+		Webhooks []tenant.Held `json:"webhooks"`
+	}
+	servertest.DecodeJSON(t, ts.Admin(t, http.MethodGet, "/admin/quarantine?user_id=1", nil), &listed)
+	if len(listed.Webhooks) != 2 || listed.Webhooks[0].EventName != "item:added" || listed.Webhooks[0].Reason == "" {
+		t.Fatalf("quarantine = %+v, want both webhooks", listed.Webhooks)
+	}
+	first, second := listed.Webhooks[0].ID, listed.Webhooks[1].ID
+
+	// The webhook stays quarantined while the user has no account
+	if resp := ts.Admin(t, http.MethodPost, "/admin/quarantine/"+first+"/release", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("release without an account: status %d, want 409", resp.StatusCode)
+	}
+
+	if resp := ts.Admin(t, http.MethodPut, "/admin/users/1", map[string]interface{}{"email": "ada@example.com"}); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("creating the account: status %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	if resp := ts.Admin(t, http.MethodPost, "/admin/quarantine/"+first+"/release", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("release: status %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	if got := recordedEvents(t, ts, "1"); got != 1 {
+		t.Errorf("recorded %d events after the release, want 1", got)
+	}
+	stored, _ := ts.Events.Store.Since(context.Background(), "1", 0, 0)
+	if len(stored) == 1 && stored[0].Delivery == nil {
+		t.Error("released event lost its raw delivery")
+	}
+
+	if resp := ts.Admin(t, http.MethodDelete, "/admin/quarantine/"+second, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("drop: status %d", resp.StatusCode)
+	}
+	if resp := ts.Admin(t, http.MethodPost, "/admin/quarantine/"+second+"/release", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("release of a dropped webhook: status %d, want 404", resp.StatusCode)
+	}
+	if held := ts.Quarantine.List(""); len(held) != 0 {
+		t.Errorf("quarantine = %+v, want it empty", held)
+	}
+}
+
+// failingRegistry is an account registry whose lookups fail once calls run out
+type failingRegistry struct {
+	tenant.Registry
+	calls int
+}
+
+func (r *failingRegistry) Get(ctx context.Context, userID string) (*tenant.Account, error) {
+	if r.calls == 0 {
+		return nil, errors.New("registry unavailable")
+	}
+	r.calls--
+	return r.Registry.Get(ctx, userID)
+}
+
+// TestQuarantineReleaseFailure tests that a webhook stays quarantined when processing it fails
+func TestQuarantineReleaseFailure(t *testing.T) {
+	t.Setenv("CHERRY_TENANT_POLICY", "quarantine")
+	ts := servertest.NewTestServer(t)
+
+	ts.Webhook(t, webhookBody("1"))
+	ts.Webhook(t, webhookBody("1"))
+	if resp := ts.Admin(t, http.MethodPut, "/admin/users/1", map[string]interface{}{"email": "ada@example.com"}); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("creating the account: status %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+
+	// The release is admitted, but the lookup while processing the webhook fails
+	ts.Accounts = &failingRegistry{Registry: ts.Accounts, calls: 1}
+	if resp := ts.Admin(t, http.MethodPost, "/admin/quarantine/1/release", nil); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("release: status %d, want 500", resp.StatusCode)
+	}
+	if held := ts.Quarantine.List(""); len(held) != 2 || held[0].ID != "1" || held[1].ID != "2" {
+		t.Fatalf("quarantine = %+v, want both webhooks in order", held)
+	}
+
+	ts.Accounts = ts.Accounts.(*failingRegistry).Registry
+	if resp := ts.Admin(t, http.MethodPost, "/admin/quarantine/1/release", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("second release: status %d: %s", resp.StatusCode, servertest.Body(t, resp))
+	}
+	if got := recordedEvents(t, ts, "1"); got != 1 {
+		t.Errorf("recorded %d events, want 1", got)
+	}
+}
+
+// TestUsersAdmin tests managing accounts through the admin API
+func TestUsersAdmin(t *testing.T) {
+	t.Setenv("TODOIST_API_TOKENS", "1:token-1")
+	t.Setenv("TODOIST_API_URL", "http://127.0.0.1:0")
+	ts := servertest.NewTestServer(t)
+
+	// Users with an API token get an account on startup
+	var listed struct {
+		Users []tenant.Account `json:"users"`
+	}
+	servertest.DecodeJSON(t, ts.Admin(t, http.MethodGet, "/admin/users", nil), &listed)
+	if len(listed.Users) != 1 || listed.Users[0].UserID != "1" || !listed.Users[0].Active() {
+		t.Fatalf("users = %+v, want the active account of user 1", listed.Users)
+	}
+
+	settings := map[string]interface{}{
+		"status": "active",
+		"settings": map[string]interface{}{
+			"timezone":      "Europe/Berlin",
+			"features":      map[string]bool{"automation": false},
+			"notifications": map[string]string{"digest": "daily"},
+		},
+	}
+	var account tenant.Account
+	resp := ts.Admin(t, http.MethodPut, "/admin/users/1", settings)
+	servertest.DecodeJSON(t, resp, &account)
+	if resp.StatusCode != http.StatusOK || account.Settings.Timezone != "Europe/Berlin" || account.HasFeature(tenant.FeatureAutomation) {
+		t.Errorf("PUT = %d %+v", resp.StatusCode, account)
+	}
+
+	invalid := map[string]interface{}{"settings": map[string]interface{}{"timezone": "Nowhere/Special"}}
+	if resp := ts.Admin(t, http.MethodPut, "/admin/users/1", invalid); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT of an invalid time zone: status %d, want 400", resp.StatusCode)
+	}
+
+	servertest.DecodeJSON(t, ts.Admin(t, http.MethodPost, "/admin/users/1/disable", nil), &account)
+	if account.Active() || account.Settings.Notifications.Digest != tenant.DigestDaily {
+		t.Errorf("disabled account = %+v, want the settings kept", account)
+	}
+	servertest.DecodeJSON(t, ts.Admin(t, http.MethodPost, "/admin/users/1/enable", nil), &account)
+	if !account.Active() {
+		t.Errorf("enabled account = %+v", account)
+	}
+
+	if resp := ts.Admin(t, http.MethodDelete, "/admin/users/1", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE: status %d", resp.StatusCode)
+	}
+	if resp := ts.Admin(t, http.MethodGet, "/admin/users/1", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET of a deleted account: status %d, want 404", resp.StatusCode)
+	}
+}
+
+// TestFeatureSwitches tests that listeners only run for accounts with their feature on
+func TestFeatureSwitches(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	ts.Accounts.Put(context.Background(), &tenant.Account{UserID: "1", Settings: tenant.Settings{
+		Features: map[string]bool{tenant.FeatureEvents: false},
+	}})
+
+	ts.Webhook(t, webhookBody("1"))
+	ts.Webhook(t, webhookBody("2"))
+	if got := recordedEvents(t, ts, "1"); got != 0 {
+		t.Errorf("recorded %d events of a user with events off, want 0", got)
+	}
+	if got := recordedEvents(t, ts, "2"); got != 1 {
+		t.Errorf("recorded %d events of a user without an account, want 1", got)
+	}
+}
+
+// TestTasksAreScopedToTheAccount tests that clients only see the tasks of their own account
+func TestTasksAreScopedToTheAccount(t *testing.T) {
+	t.Setenv("TODOIST_API_TOKENS", "1:token-1,2:token-2")
+	t.Setenv("TODOIST_API_URL", "http://127.0.0.1:0")
+	t.Setenv("CHERRY_TENANT_POLICY", "reject")
+	ts := servertest.NewTestServer(t)
+	ts.Seed(t, "1", servertest.State{Tasks: []api.Task{{ID: "a", Content: "Mine"}}})
+	ts.Seed(t, "2", servertest.State{Tasks: []api.Task{{ID: "b", Content: "Theirs"}}})
+
+	auth := func(token string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+	testCases := []struct {
+		name       string
+		path       string
+		header     http.Header
+		wantStatus int
+		wantTask   string
+	}{
+		{"own tasks by default", "/tasks", auth("token-1"), http.StatusOK, "a"},
+		{"own tasks by ID", "/tasks?user_id=2", auth("token-2"), http.StatusOK, "b"},
+		{"another user's tasks", "/tasks?user_id=2", auth("token-1"), http.StatusForbidden, ""},
+		{"no credentials", "/tasks?user_id=1", nil, http.StatusUnauthorized, ""},
+		{"unknown token", "/tasks", auth("token-3"), http.StatusUnauthorized, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := ts.Do(t, http.MethodGet, tc.path, nil, tc.header)
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tc.wantStatus, servertest.Body(t, resp))
+			}
+			if tc.wantTask == "" {
+				return
+			}
+			var response struct {
+				Tasks []struct {
+					ID string `json:"id"`
+				} `json:"tasks"`
+			}
+			servertest.DecodeJSON(t, resp, &response)
+			if len(response.Tasks) != 1 || response.Tasks[0].ID != tc.wantTask {
+				t.Errorf("tasks = %+v, want [%s]", response.Tasks, tc.wantTask)
+			}
+		})
+	}
+
+	// Disabled accounts are refused
+	ts.Admin(t, http.MethodPost, "/admin/users/1/disable", nil)
+	if resp := ts.Do(t, http.MethodGet, "/tasks", nil, auth("token-1")); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("tasks of a disabled account: status %d, want 403", resp.StatusCode)
+	}
+	if resp := ts.Do(t, http.MethodGet, "/events/stream", nil, auth("token-1")); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("event stream of a disabled account: status %d, want 403", resp.StatusCode)
+	}
+}
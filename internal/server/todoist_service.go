@@ -2,16 +2,57 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"cherry_backend/internal/events"
 	"cherry_backend/internal/logging"
+	"cherry_backend/internal/tenant"
 	apiv1 "cherry_backend/pkg/api/v1"
 )
 
+// ErrPermissionDenied is returned for requests of users without an active account and for
+// requests acting for another user than the one authenticated
+var ErrPermissionDenied = errors.New("permission denied")
+
+// WebhookListener is notified of every webhook processed by TodoistServiceImpl
+type WebhookListener interface {
+	OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error
+}
+
 // TodoistServiceImpl implements the TodoistService interface
 type TodoistServiceImpl struct {
 	apiv1.UnimplementedTodoistServiceServer
-	Logger logging.Logger
+	Logger    logging.Logger
+	Listeners []WebhookListener
+
+	// Events is the event log streamed by SubscribeEvents
+	Events *events.Log
+
+	// Accounts and Policy decide which users' webhooks are processed; without Accounts every
+	// webhook is. Webhooks the policy quarantines are held in Quarantine.
+	Accounts   tenant.Registry
+	Policy     tenant.Policy
+	Quarantine *tenant.QuarantineStore
+}
+
+// featureListener notifies a listener only of webhooks of accounts that have its feature on
+type featureListener struct {
+	feature  string
+	listener WebhookListener
+}
+
+func (l featureListener) OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error {
+	if account, ok := tenant.FromContext(ctx); ok && !account.HasFeature(l.feature) {
+		return nil
+	}
+	return l.listener.OnWebhook(ctx, request)
 }
 
 // NewTodoistServiceImpl creates a new TodoistServiceImpl with a logger
@@ -36,6 +77,45 @@ func (s *TodoistServiceImpl) ProcessWebhook(ctx context.Context, request *apiv1.
 		s.Logger.Info("Processing webhook event: %s", request.EventName)
 	}
 
+	// Clients authenticated as a user may only submit that user's webhooks
+	if _, err := scopeUserID(ctx, request.UserId); err != nil {
+		if s.Logger != nil {
+			s.Logger.Warn("Refused webhook event %s: %v", request.EventName, err)
+		} else {
+			log.Printf("Refused webhook event %s: %v", request.EventName, err)
+		}
+		return nil, err
+	}
+
+	// Only process webhooks of users the tenant policy admits
+	if s.Accounts != nil {
+		account, action, err := s.Policy.Admit(ctx, s.Accounts, request.UserId)
+		if err != nil && !errors.Is(err, tenant.ErrUnknownUser) && !errors.Is(err, tenant.ErrDisabled) {
+			return nil, fmt.Errorf("failed to look up the account of user %s: %w", request.UserId, err)
+		}
+		switch action {
+		case tenant.Reject:
+			if s.Logger != nil {
+				s.Logger.Warn("Rejected webhook event %s: %v", request.EventName, err)
+			} else {
+				log.Printf("Rejected webhook event %s: %v", request.EventName, err)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrPermissionDenied, err)
+		case tenant.Quarantine:
+			held := s.Quarantine.Hold(request, events.DeliveryFrom(ctx), err)
+			if s.Logger != nil {
+				s.Logger.Warn("Quarantined webhook event %s as %s: %v", request.EventName, held.ID, err)
+			} else {
+				log.Printf("Quarantined webhook event %s as %s: %v", request.EventName, held.ID, err)
+			}
+			return &apiv1.TodoistWebhookResponse{
+				Success: true,
+				Message: "Webhook quarantined",
+			}, nil
+		}
+		ctx = tenant.NewContext(ctx, account)
+	}
+
 	// Here you would add your business logic to handle different event types
 	// For example:
 	switch request.EventName {
@@ -75,9 +155,77 @@ func (s *TodoistServiceImpl) ProcessWebhook(ctx context.Context, request *apiv1.
 		}
 	}
 
+	// Notify listeners; a failing listener must not make Todoist redeliver the webhook
+	for _, listener := range s.Listeners {
+		if err := listener.OnWebhook(ctx, request); err != nil {
+			if s.Logger != nil {
+				s.Logger.Error("Webhook listener failed for event %s: %v", request.EventName, err)
+			} else {
+				log.Printf("Webhook listener failed for event %s: %v", request.EventName, err)
+			}
+		}
+	}
+
 	// Return success response
 	return &apiv1.TodoistWebhookResponse{
 		Success: true,
 		Message: "Webhook received",
 	}, nil
 }
+
+// SubscribeEvents streams the events of a user, first those after the resume cursor, then live ones
+func (s *TodoistServiceImpl) SubscribeEvents(request *apiv1.SubscribeEventsRequest, stream grpc.ServerStreamingServer[apiv1.Event]) error {
+	userID, err := scopeUserID(stream.Context(), request.UserId)
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	request.UserId = userID
+	if request.UserId == "" {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if s.Events == nil {
+		return status.Error(codes.Unavailable, "event log not configured")
+	}
+
+	ctx := stream.Context()
+	after := request.ResumeAfter
+	if after == 0 {
+		var err error
+		if after, err = s.Events.Store.LastID(ctx); err != nil {
+			return status.Errorf(codes.Internal, "failed to read event log: %v", err)
+		}
+	}
+
+	if s.Logger != nil {
+		s.Logger.Info("Event subscription opened for user %s after event %d", request.UserId, after)
+	} else {
+		log.Printf("Event subscription opened for user %s after event %d", request.UserId, after)
+	}
+	send := func(event *events.Event) error {
+		return stream.Send(eventToProto(event))
+	}
+	err = s.Events.Tail(ctx, request.UserId, after, request.EventNames, 0, send, nil)
+	if s.Logger != nil {
+		s.Logger.Info("Event subscription closed for user %s: %v", request.UserId, err)
+	} else {
+		log.Printf("Event subscription closed for user %s: %v", request.UserId, err)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+// eventToProto converts a normalized event to its protobuf message
+func eventToProto(event *events.Event) *apiv1.Event {
+	return &apiv1.Event{
+		Id:         event.ID,
+		EventName:  event.Name,
+		UserId:     event.UserID,
+		Resource:   event.Resource,
+		ResourceId: event.ResourceID,
+		Data:       string(event.Data),
+		Version:    event.Version,
+		ReceivedAt: event.ReceivedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
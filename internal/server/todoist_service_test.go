@@ -2,11 +2,13 @@ package server
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"cherry_backend/internal/tenant"
 	apiv1 "cherry_backend/pkg/api/v1"
 )
 
@@ -107,6 +109,68 @@ func TestProcessWebhookUnhandledEvent(t *testing.T) {
 	}
 }
 
+// recordingListener is a WebhookListener that records the events it receives
+type recordingListener struct {
+	events []string
+	err    error
+}
+
+func (l *recordingListener) OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error {
+	l.events = append(l.events, request.EventName)
+	return l.err
+}
+
+// TestProcessWebhookNotifiesListeners tests that every listener sees the event, even if one fails
+func TestProcessWebhookNotifiesListeners(t *testing.T) {
+	// Setup test environment
+	setupTestEnv(t)
+	defer cleanupTestEnv(t)
+
+	failing := &recordingListener{err: errors.New("listener failed")}
+	recording := &recordingListener{}
+
+	service, err := NewTodoistServiceImpl()
+	if err != nil {
+		t.Fatalf("Failed to create TodoistServiceImpl: %v", err)
+	}
+	service.Listeners = []WebhookListener{failing, recording}
+
+	request := &apiv1.TodoistWebhookRequest{EventName: "item:completed", UserId: "test-user"}
+	response, err := service.ProcessWebhook(context.Background(), request)
+	if err != nil {
+		t.Fatalf("ProcessWebhook failed: %v", err)
+	}
+	if !response.Success {
+		t.Errorf("Expected success=true despite a failing listener, got success=%v", response.Success)
+	}
+
+	for _, listener := range []*recordingListener{failing, recording} {
+		if len(listener.events) != 1 || listener.events[0] != "item:completed" {
+			t.Errorf("Listener received %v, want [item:completed]", listener.events)
+		}
+	}
+}
+
+// TestProcessWebhookWithoutLogger tests that rejected and quarantined webhooks are logged to the
+// standard logger when the service has no logger
+func TestProcessWebhookWithoutLogger(t *testing.T) {
+	request := &apiv1.TodoistWebhookRequest{EventName: "item:added", UserId: "unknown", EventData: `{"id": "1"}`}
+
+	rejecting := &TodoistServiceImpl{Accounts: tenant.NewMemoryRegistry(), Policy: tenant.PolicyReject}
+	if _, err := rejecting.ProcessWebhook(context.Background(), request); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("ProcessWebhook() under the reject policy = %v, want ErrPermissionDenied", err)
+	}
+
+	quarantine := tenant.NewQuarantineStore()
+	quarantining := &TodoistServiceImpl{Accounts: tenant.NewMemoryRegistry(), Policy: tenant.PolicyQuarantine, Quarantine: quarantine}
+	if response, err := quarantining.ProcessWebhook(context.Background(), request); err != nil || response.Message != "Webhook quarantined" {
+		t.Errorf("ProcessWebhook() under the quarantine policy = %+v, %v, want it quarantined", response, err)
+	}
+	if held := quarantine.List("unknown"); len(held) != 1 {
+		t.Errorf("quarantined %d webhooks, want 1", len(held))
+	}
+}
+
 // setupTestEnv sets up the test environment
 func setupTestEnv(t *testing.T) {
 	// Use a temporary directory for testing
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+const fuzzSecret = "fuzz_secret"
+
+// sign computes the signature Todoist sends for a body
+func sign(body []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FuzzVerifyTodoistSignature tests that exactly the signature of the payload with the secret is
+// accepted
+func FuzzVerifyTodoistSignature(f *testing.F) {
+	body := []byte(`{"event_name": "item:added", "user_id": "1", "event_data": {"id": "7"}}`)
+	f.Add(body, sign(body, fuzzSecret), fuzzSecret)
+	f.Add(body, strings.ToUpper(sign(body, fuzzSecret)), fuzzSecret)
+	f.Add(body, sign(body, "other"), fuzzSecret)
+	f.Add(body, sign(body, fuzzSecret)[:63], fuzzSecret)
+	f.Add(body, "", fuzzSecret)
+	f.Add([]byte{}, sign(nil, ""), "")
+	f.Add(body, "sha256="+sign(body, fuzzSecret), fuzzSecret)
+	f.Fuzz(func(t *testing.T, payload []byte, signature, secret string) {
+		want := sign(payload, secret)
+		if !verifyTodoistSignature(payload, want, secret) {
+			t.Fatalf("the correct signature of %q was rejected", payload)
+		}
+		if got := verifyTodoistSignature(payload, signature, secret); got != (signature == want) {
+			t.Fatalf("verifyTodoistSignature(%q, %q) = %v, want %v", payload, signature, got, !got)
+		}
+	})
+}
+
+// TestSignatureProperties tests that a signature is never accepted for a changed payload or
+// with another secret
+func TestSignatureProperties(t *testing.T) {
+	changedPayload := func(payload []byte, secret string, extra byte) bool {
+		tampered := append(append([]byte(nil), payload...), extra)
+		return !verifyTodoistSignature(tampered, sign(payload, secret), secret)
+	}
+	otherSecret := func(payload []byte, secret, other string) bool {
+		if secret == other {
+			return true
+		}
+		return !verifyTodoistSignature(payload, sign(payload, other), secret)
+	}
+	flippedBit := func(payload []byte, secret string, position uint8, bit uint8) bool {
+		signature := []byte(sign(payload, secret))
+		signature[int(position)%len(signature)] ^= 1 << (bit % 7)
+		return !verifyTodoistSignature(payload, string(signature), secret)
+	}
+	for name, property := range map[string]interface{}{
+		"changed payload": changedPayload,
+		"other secret":    otherSecret,
+		"flipped bit":     flippedBit,
+	} {
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// FuzzTodoistWebhookHandler tests that no body makes the webhook handler panic or fail with a
+// server error, and that a delivery without the right signature is always rejected
+func FuzzTodoistWebhookHandler(f *testing.F) {
+	f.Add([]byte(`{"event_name": "item:added", "user_id": "1", "event_data": {"id": "7", "content": "Buy milk"}}`), true)
+	f.Add([]byte(`{"event_name": "item:added", "user_id": 42, "event_data": "{\"id\": \"7\"}", "version": "9"}`), true)
+	f.Add([]byte(`{"event_name": "item:added", "event_data": {"id": 7, "labels": "x"}}`), true)
+	f.Add([]byte(`{"event_name": "note:added", "event_data": "not json"}`), true)
+	f.Add([]byte(`{"event_name": "project:deleted", "user_id": {"id": 1}}`), true)
+	f.Add([]byte(`{"event_name": "reminder:fired", "user_id": 1e400}`), true)
+	f.Add([]byte(`[1, 2, 3]`), true)
+	f.Add([]byte(`{`), true)
+	f.Add([]byte{}, true)
+	f.Add([]byte(`{"event_name": "item:added", "user_id": "1"}`), false)
+
+	f.Setenv("CHERRY_LOG_PATH", f.TempDir())
+	f.Setenv("TODOIST_CLIENT_SECRET", fuzzSecret)
+	s := NewServer()
+	f.Cleanup(s.Fanout.Close)
+
+	f.Fuzz(func(t *testing.T, body []byte, signed bool) {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/todoist", bytes.NewReader(body))
+		signature := sign(body, "wrong_secret")
+		if signed {
+			signature = sign(body, fuzzSecret)
+		}
+		req.Header.Set("X-Todoist-Hmac-SHA256", signature)
+		w := httptest.NewRecorder()
+		s.Router.ServeHTTP(w, req)
+
+		switch {
+		case !signed && w.Code != http.StatusUnauthorized:
+			t.Fatalf("unsigned body %q got %d, want 401", body, w.Code)
+		case w.Code >= http.StatusInternalServerError:
+			t.Fatalf("body %q got %d: %s", body, w.Code, w.Body.String())
+		case signed && w.Code != http.StatusOK && w.Code != http.StatusBadRequest:
+			t.Fatalf("signed body %q got %d, want 200 or 400", body, w.Code)
+		}
+	})
+}
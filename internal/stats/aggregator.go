@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"context"
+	"time"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/filter"
+	"cherry_backend/internal/models"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// Events that are aggregated
+const (
+	EventAdded     = "item:added"
+	EventCompleted = "item:completed"
+)
+
+// overdue matches the tasks that are overdue at the time of the filter environment
+var overdue, _ = filter.Parse("overdue")
+
+// Aggregator records the item:added and item:completed webhooks of users in a Store. It is a
+// webhook listener; days are counted in the time zone of the account in the webhook's context,
+// or in UTC without one. Deliveries of the same webhook, identified by the events.Delivery in
+// the context, are recorded once.
+type Aggregator struct {
+	Store Store
+
+	// Now returns the time of events whose data has no timestamp; defaults to time.Now
+	Now func() time.Time
+}
+
+// NewAggregator creates an aggregator that records into store
+func NewAggregator(store Store) *Aggregator {
+	return &Aggregator{Store: store, Now: time.Now}
+}
+
+// OnWebhook records task additions and completions; other events are ignored
+func (a *Aggregator) OnWebhook(ctx context.Context, request *apiv1.TodoistWebhookRequest) error {
+	if request.EventName != EventAdded && request.EventName != EventCompleted || request.UserId == "" {
+		return nil
+	}
+	event, err := models.DecodeWebhookEvent(request)
+	if err != nil {
+		return err
+	}
+	if event.Item == nil {
+		return nil
+	}
+
+	location := time.UTC
+	if account, ok := tenant.FromContext(ctx); ok {
+		location = account.Location()
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	entry := NewEntry(request.EventName, event.Item, now(), location)
+	entry.ID = events.DeliveryFrom(ctx).ID()
+	return a.Store.Record(ctx, request.UserId, entry)
+}
+
+// NewEntry returns what an item:added or item:completed event of a task adds to the statistics.
+// The event happened at the task's added_at or completed_at, or at now if that is missing.
+func NewEntry(eventName string, task *api.Task, now time.Time, location *time.Location) Entry {
+	entry := Entry{ProjectID: task.ProjectID, Labels: task.Labels}
+	if eventName == EventAdded {
+		at := parseTime(task.AddedAt, now)
+		entry.Date = DateOf(at, location)
+		entry.Added = 1
+		return entry
+	}
+
+	at := parseTime(task.CompletedAt, now)
+	entry.Date = DateOf(at, location)
+	entry.Completed = 1
+	if added := parseTime(task.AddedAt, time.Time{}); !added.IsZero() && !at.Before(added) {
+		entry.Timed = 1
+		entry.Duration = at.Sub(added)
+	}
+	if overdue.Match(filter.NewEnv(at.In(location), nil, nil), task) {
+		entry.CompletedLate = 1
+	}
+	return entry
+}
+
+// parseTime parses an RFC 3339 timestamp of the Todoist API, or returns fallback
+func parseTime(value string, fallback time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t
+	}
+	return fallback
+}
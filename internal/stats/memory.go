@@ -0,0 +1,173 @@
+package stats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store in memory
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[string]*userStats
+
+	// Now returns the time entries are recorded at; defaults to time.Now
+	Now func() time.Time
+}
+
+// userStats are the statistics of a single user
+type userStats struct {
+	days   map[string]*Counts
+	groups map[groupKey]map[string]*Counts
+	streak Streak
+
+	// recorded holds when the entries with an ID were recorded, by ID
+	recorded map[string]time.Time
+}
+
+// groupKey identifies a project or label
+type groupKey struct {
+	kind, key string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[string]*userStats), Now: time.Now}
+}
+
+// Record adds an entry to the statistics of a user
+func (s *MemoryStore) Record(ctx context.Context, userID string, entry Entry) error {
+	if _, err := ParseDate(entry.Date); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		user = &userStats{days: make(map[string]*Counts), groups: make(map[groupKey]map[string]*Counts), recorded: make(map[string]time.Time)}
+		s.users[userID] = user
+	}
+	if entry.ID != "" {
+		if _, ok := user.recorded[entry.ID]; ok {
+			return nil
+		}
+		now := time.Now
+		if s.Now != nil {
+			now = s.Now
+		}
+		user.recorded[entry.ID] = now()
+	}
+	add(user.days, entry.Date, entry.Counts)
+	for _, group := range entryGroups(entry) {
+		days, ok := user.groups[group]
+		if !ok {
+			days = make(map[string]*Counts)
+			user.groups[group] = days
+		}
+		add(days, entry.Date, entry.Counts)
+	}
+
+	if entry.Completed > 0 && !user.streak.Extend(entry.Date) {
+		var dates []string
+		for date, counts := range user.days {
+			if counts.Completed > 0 {
+				dates = append(dates, date)
+			}
+		}
+		sort.Strings(dates)
+		user.streak = StreakOf(dates)
+	}
+	return nil
+}
+
+// Prune forgets the IDs of the entries recorded before a time
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pruned := 0
+	for _, user := range s.users {
+		for id, at := range user.recorded {
+			if at.Before(before) {
+				delete(user.recorded, id)
+				pruned++
+			}
+		}
+	}
+	return pruned, nil
+}
+
+// add adds counts to those of a day
+func add(days map[string]*Counts, date string, counts Counts) {
+	if _, ok := days[date]; !ok {
+		days[date] = &Counts{}
+	}
+	days[date].Add(counts)
+}
+
+// entryGroups returns the project and labels an entry counts towards
+func entryGroups(entry Entry) []groupKey {
+	var groups []groupKey
+	if entry.ProjectID != "" {
+		groups = append(groups, groupKey{GroupProject, entry.ProjectID})
+	}
+	for _, label := range entry.Labels {
+		groups = append(groups, groupKey{GroupLabel, label})
+	}
+	return groups
+}
+
+// Days returns the days of a user in a range that have counts
+func (s *MemoryStore) Days(ctx context.Context, userID, from, to string) ([]Day, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var days []Day
+	if user, ok := s.users[userID]; ok {
+		for date, counts := range user.days {
+			if date >= from && date <= to {
+				days = append(days, Day{Date: date, Counts: *counts})
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+// Groups returns the counts of a user per project or label in a range
+func (s *MemoryStore) Groups(ctx context.Context, userID, kind, from, to string) ([]Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var groups []Group
+	if user, ok := s.users[userID]; ok {
+		for key, days := range user.groups {
+			if key.kind != kind {
+				continue
+			}
+			group := Group{Kind: kind, Key: key.key}
+			for date, counts := range days {
+				if date >= from && date <= to {
+					group.Add(*counts)
+				}
+			}
+			if group.Counts != (Counts{}) {
+				groups = append(groups, group)
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups, nil
+}
+
+// Streak returns the completion streak of a user
+func (s *MemoryStore) Streak(ctx context.Context, userID string) (Streak, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user, ok := s.users[userID]; ok {
+		return user.streak, nil
+	}
+	return Streak{}, nil
+}
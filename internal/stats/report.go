@@ -0,0 +1,161 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cherry_backend/internal/filter"
+	"cherry_backend/pkg/api"
+)
+
+// MaxRangeDays is the longest range of days a report covers
+const MaxRangeDays = 366
+
+// ErrInvalidRange is returned for ranges that end before they start or are too long
+var ErrInvalidRange = errors.New("invalid range")
+
+// Week is the statistics of an ISO week, counting only the days within a report's range
+type Week struct {
+	// Week is the ISO week, e.g. "2024-W09", and Start the Monday it starts on
+	Week  string `json:"week"`
+	Start string `json:"start"`
+	Counts
+}
+
+// Report is the statistics of a user over a range of days
+type Report struct {
+	UserID string
+
+	// From and To are the first and last day of the range
+	From, To string
+
+	// Totals are the counts of the whole range, Days those of every day of the range and Weeks
+	// those of every week overlapping it, oldest first
+	Totals Counts
+	Days   []Day
+	Weeks  []Week
+
+	// Streak is the user's streak as of the day the report was made
+	Streak Streak
+
+	// Projects and Labels are the counts of the range per project and label, most completions first
+	Projects []Group
+	Labels   []Group
+
+	// Overdue counts the open tasks that are overdue, in total and per project and label; set by
+	// CountOverdue
+	Overdue        int
+	OverdueByGroup map[string]map[string]int
+}
+
+// NewReport reads the statistics of a user from from to to, inclusive, with the streak as seen
+// on today
+func NewReport(ctx context.Context, store Store, userID, from, to, today string) (*Report, error) {
+	start, err := ParseDate(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := ParseDate(to)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("%w: %s is before %s", ErrInvalidRange, to, from)
+	}
+	if end.Sub(start) >= MaxRangeDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: ranges are at most %d days", ErrInvalidRange, MaxRangeDays)
+	}
+
+	report := &Report{UserID: userID, From: from, To: to}
+	stored, err := store.Days(ctx, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the days of user %s: %w", userID, err)
+	}
+	counts := make(map[string]Counts, len(stored))
+	for _, day := range stored {
+		counts[day.Date] = day.Counts
+	}
+
+	// Fill in every day, so that clients can chart the range as is
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(DateLayout)
+		report.Days = append(report.Days, Day{Date: date, Counts: counts[date]})
+		report.Totals.Add(counts[date])
+
+		year, number := day.ISOWeek()
+		name := fmt.Sprintf("%04d-W%02d", year, number)
+		if len(report.Weeks) == 0 || report.Weeks[len(report.Weeks)-1].Week != name {
+			monday := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+			report.Weeks = append(report.Weeks, Week{Week: name, Start: monday.Format(DateLayout)})
+		}
+		report.Weeks[len(report.Weeks)-1].Add(counts[date])
+	}
+
+	if report.Projects, err = store.Groups(ctx, userID, GroupProject, from, to); err != nil {
+		return nil, fmt.Errorf("failed to read the projects of user %s: %w", userID, err)
+	}
+	if report.Labels, err = store.Groups(ctx, userID, GroupLabel, from, to); err != nil {
+		return nil, fmt.Errorf("failed to read the labels of user %s: %w", userID, err)
+	}
+	sortGroups(report.Projects)
+	sortGroups(report.Labels)
+
+	streak, err := store.Streak(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the streak of user %s: %w", userID, err)
+	}
+	report.Streak = streak.On(today)
+	return report, nil
+}
+
+// CountOverdue counts the open tasks that are overdue at now, in now's location
+func (r *Report) CountOverdue(tasks []api.Task, now time.Time) {
+	env := filter.NewEnv(now, nil, nil)
+	r.Overdue = 0
+	r.OverdueByGroup = map[string]map[string]int{GroupProject: {}, GroupLabel: {}}
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Checked || task.IsDeleted || !overdue.Match(env, task) {
+			continue
+		}
+		r.Overdue++
+		if task.ProjectID != "" {
+			r.OverdueByGroup[GroupProject][task.ProjectID]++
+		}
+		for _, label := range task.Labels {
+			r.OverdueByGroup[GroupLabel][label]++
+		}
+	}
+
+	// Groups with overdue tasks are listed even without counts in the range
+	r.Projects = withGroups(r.Projects, GroupProject, r.OverdueByGroup[GroupProject])
+	r.Labels = withGroups(r.Labels, GroupLabel, r.OverdueByGroup[GroupLabel])
+}
+
+// withGroups appends a group for every key that is not listed yet
+func withGroups(groups []Group, kind string, keys map[string]int) []Group {
+	listed := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		listed[group.Key] = true
+	}
+	for key := range keys {
+		if !listed[key] {
+			groups = append(groups, Group{Kind: kind, Key: key})
+		}
+	}
+	sortGroups(groups)
+	return groups
+}
+
+// sortGroups orders groups by completions, most first, then by key
+func sortGroups(groups []Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Completed != groups[j].Completed {
+			return groups[i].Completed > groups[j].Completed
+		}
+		return groups[i].Key < groups[j].Key
+	})
+}
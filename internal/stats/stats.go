@@ -0,0 +1,190 @@
+// Package stats aggregates productivity statistics from the item:added and item:completed
+// webhooks of each user. Every webhook adds to the counts of the day it happened on, in the time
+// zone of the user's account, and of the task's project and labels on that day, and extends the
+// user's completion streak. Reading statistics sums stored days and never replays events.
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the layout of the calendar dates statistics are kept by
+const DateLayout = "2006-01-02"
+
+// DefaultReplayWindow is how long the IDs of recorded entries are kept. A retry or replay of a
+// webhook within the window is ignored; one after it counts again.
+const DefaultReplayWindow = 7 * 24 * time.Hour
+
+// ErrInvalidDate is returned for dates that are not YYYY-MM-DD
+var ErrInvalidDate = errors.New("invalid date")
+
+// Group kinds of Store.Groups
+const (
+	GroupProject = "project"
+	GroupLabel   = "label"
+)
+
+// Counts are the statistics of a day, project or label
+type Counts struct {
+	// Completed and Added count item:completed and item:added events
+	Completed int `json:"completed"`
+	Added     int `json:"added"`
+
+	// CompletedLate counts the completions of tasks that were overdue when completed
+	CompletedLate int `json:"completed_late"`
+
+	// Timed counts the completions of tasks whose creation time is known, and Duration is the
+	// total time those tasks took from being added to being completed
+	Timed    int           `json:"timed"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Add adds other to the counts
+func (c *Counts) Add(other Counts) {
+	c.Completed += other.Completed
+	c.Added += other.Added
+	c.CompletedLate += other.CompletedLate
+	c.Timed += other.Timed
+	c.Duration += other.Duration
+}
+
+// AverageCompletion returns the average time from adding to completing a task, or 0 if no
+// completion was timed
+func (c Counts) AverageCompletion() time.Duration {
+	if c.Timed == 0 {
+		return 0
+	}
+	return c.Duration / time.Duration(c.Timed)
+}
+
+// Day is the statistics of a user on one calendar day
+type Day struct {
+	// Date is the day (YYYY-MM-DD) in the user's time zone when the events happened
+	Date string `json:"date"`
+	Counts
+}
+
+// Group is the statistics of a user in a project or with a label
+type Group struct {
+	// Kind is GroupProject or GroupLabel, and Key the project ID or label name
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	Counts
+}
+
+// Entry is what one event adds to the statistics of a user
+type Entry struct {
+	// ID identifies the webhook the entry comes from. An entry whose ID was recorded for the
+	// user and not yet pruned is ignored, so retried and replayed webhooks count once; entries
+	// without an ID are always recorded.
+	ID string
+
+	// Date is the day the event happened on in the user's time zone
+	Date string
+
+	// ProjectID and Labels are the groups of the task
+	ProjectID string
+	Labels    []string
+
+	Counts
+}
+
+// Store keeps the aggregated statistics of every user
+type Store interface {
+	// Record adds an entry to the day, project and label counts of a user and extends the
+	// user's streak if the entry has completions. An entry with an ID already recorded for the
+	// user is ignored.
+	Record(ctx context.Context, userID string, entry Entry) error
+
+	// Prune forgets the IDs of the entries recorded before a time, so that they are not kept
+	// forever, and returns how many it forgot. The counts of the entries are kept.
+	Prune(ctx context.Context, before time.Time) (int, error)
+
+	// Days returns the days of a user from from to to, inclusive, that have counts, by date
+	Days(ctx context.Context, userID, from, to string) ([]Day, error)
+
+	// Groups returns the counts of a user per project or per label from from to to, inclusive,
+	// ordered by key
+	Groups(ctx context.Context, userID, kind, from, to string) ([]Group, error)
+
+	// Streak returns the completion streak of a user, as of the last day with a completion
+	Streak(ctx context.Context, userID string) (Streak, error)
+}
+
+// Streak is the run of consecutive days on which a user completed tasks
+type Streak struct {
+	// Current is the length of the run ending on Last, the last day with a completion
+	Current int    `json:"current"`
+	Last    string `json:"last_date,omitempty"`
+
+	// Longest is the length of the longest run ever, which ended on LongestEnd
+	Longest    int    `json:"longest"`
+	LongestEnd string `json:"longest_end,omitempty"`
+}
+
+// Extend adds a day with completions to the streak. It returns false, leaving the streak
+// unchanged, for a day before Last: such a day may join two runs, so the streak has to be
+// rebuilt with StreakOf.
+func (s *Streak) Extend(date string) bool {
+	switch {
+	case s.Last == "":
+		s.Current = 1
+	case date == s.Last:
+		return true
+	case date < s.Last:
+		return false
+	case date == AddDays(s.Last, 1):
+		s.Current++
+	default:
+		s.Current = 1
+	}
+	s.Last = date
+	if s.Current > s.Longest {
+		s.Longest, s.LongestEnd = s.Current, date
+	}
+	return true
+}
+
+// On returns the streak as seen on a day: the current run is broken once a day without
+// completions has passed since Last
+func (s Streak) On(today string) Streak {
+	if s.Last != today && AddDays(s.Last, 1) != today {
+		s.Current = 0
+	}
+	return s
+}
+
+// StreakOf builds the streak of the days with completions, given in ascending order
+func StreakOf(dates []string) Streak {
+	var streak Streak
+	for _, date := range dates {
+		streak.Extend(date)
+	}
+	return streak
+}
+
+// ParseDate parses a YYYY-MM-DD date as midnight UTC
+func ParseDate(value string) (time.Time, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w %q: want YYYY-MM-DD", ErrInvalidDate, value)
+	}
+	return t, nil
+}
+
+// DateOf returns the calendar date of a time in a location
+func DateOf(t time.Time, location *time.Location) string {
+	return t.In(location).Format(DateLayout)
+}
+
+// AddDays returns the date n days after a date; invalid dates are returned unchanged
+func AddDays(date string, n int) string {
+	t, err := ParseDate(date)
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, n).Format(DateLayout)
+}
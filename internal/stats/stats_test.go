@@ -0,0 +1,217 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// TestStreak tests extending streaks day by day and seeing them on later days
+func TestStreak(t *testing.T) {
+	var streak Streak
+	for _, date := range []string{"2024-02-28", "2024-02-29", "2024-02-29", "2024-03-01", "2024-03-04"} {
+		if !streak.Extend(date) {
+			t.Fatalf("Extend(%s) refused an ordered day", date)
+		}
+	}
+	if want := (Streak{Current: 1, Last: "2024-03-04", Longest: 3, LongestEnd: "2024-03-01"}); streak != want {
+		t.Errorf("streak = %+v, want %+v", streak, want)
+	}
+	if streak.Extend("2024-03-02") {
+		t.Errorf("Extend() accepted a day before the last one")
+	}
+	if got := StreakOf([]string{"2024-02-28", "2024-02-29", "2024-03-01", "2024-03-02", "2024-03-04"}); got.Longest != 4 || got.LongestEnd != "2024-03-02" {
+		t.Errorf("StreakOf() = %+v, want the longest run of 4 days to end on March 2", got)
+	}
+
+	for today, current := range map[string]int{"2024-03-04": 1, "2024-03-05": 1, "2024-03-06": 0} {
+		if got := streak.On(today); got.Current != current || got.Longest != 3 {
+			t.Errorf("On(%s) = %+v, want a current run of %d", today, got, current)
+		}
+	}
+}
+
+// TestNewEntry tests dating, timing and lateness of events in the user's time zone
+func TestNewEntry(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	task := &api.Task{
+		ID: "1", ProjectID: "p", Labels: []string{"home"},
+		AddedAt:     "2024-03-01T20:00:00.000000Z",
+		CompletedAt: "2024-03-02T23:30:00.000000Z",
+		Due:         &api.Due{Date: "2024-03-02"},
+	}
+
+	added := NewEntry(EventAdded, task, now, berlin)
+	if added.Date != "2024-03-01" || added.Counts != (Counts{Added: 1}) || added.ProjectID != "p" {
+		t.Errorf("added entry = %+v", added)
+	}
+
+	// 23:30 UTC is already March 3 in Berlin, after the due date
+	completed := NewEntry(EventCompleted, task, now, berlin)
+	want := Counts{Completed: 1, CompletedLate: 1, Timed: 1, Duration: 27*time.Hour + 30*time.Minute}
+	if completed.Date != "2024-03-03" || completed.Counts != want {
+		t.Errorf("completed entry = %+v, want March 3 with %+v", completed, want)
+	}
+	if utc := NewEntry(EventCompleted, task, now, time.UTC); utc.Date != "2024-03-02" || utc.CompletedLate != 0 {
+		t.Errorf("completed entry in UTC = %+v, want on time on March 2", utc)
+	}
+
+	// Without timestamps the event happened now and cannot be timed
+	bare := NewEntry(EventCompleted, &api.Task{ID: "2"}, now, berlin)
+	if bare.Date != "2024-03-10" || bare.Counts != (Counts{Completed: 1}) {
+		t.Errorf("entry without timestamps = %+v", bare)
+	}
+}
+
+// TestAggregator tests recording webhooks in the time zone of the account in the context
+func TestAggregator(t *testing.T) {
+	store := NewMemoryStore()
+	aggregator := NewAggregator(store)
+	aggregator.Now = func() time.Time { return time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC) }
+	ctx := tenant.NewContext(context.Background(), &tenant.Account{UserID: "1", Settings: tenant.Settings{Timezone: "Asia/Tokyo"}})
+
+	webhook := func(name string, task api.Task) *apiv1.TodoistWebhookRequest {
+		data, _ := json.Marshal(task)
+		return &apiv1.TodoistWebhookRequest{EventName: name, UserId: "1", EventData: string(data)}
+	}
+	for _, request := range []*apiv1.TodoistWebhookRequest{
+		webhook(EventAdded, api.Task{ID: "1", ProjectID: "p"}),
+		webhook(EventCompleted, api.Task{ID: "1", ProjectID: "p"}),
+		webhook("item:updated", api.Task{ID: "1", ProjectID: "p"}),
+		webhook("project:added", api.Task{ID: "p"}),
+	} {
+		if err := aggregator.OnWebhook(ctx, request); err != nil {
+			t.Fatalf("OnWebhook(%s) failed: %v", request.EventName, err)
+		}
+	}
+	if err := aggregator.OnWebhook(ctx, &apiv1.TodoistWebhookRequest{EventName: EventAdded, UserId: "1", EventData: "{"}); err == nil {
+		t.Errorf("OnWebhook() with malformed data succeeded")
+	}
+
+	days, _ := store.Days(ctx, "1", "2024-03-01", "2024-03-02")
+	if len(days) != 1 || days[0].Date != "2024-03-02" || days[0].Counts != (Counts{Completed: 1, Added: 1}) {
+		t.Errorf("days = %+v, want one addition and completion on March 2 in Tokyo", days)
+	}
+	if streak, _ := store.Streak(ctx, "1"); streak.Current != 1 || streak.Last != "2024-03-02" {
+		t.Errorf("streak = %+v, want a day on March 2", streak)
+	}
+}
+
+// TestAggregatorDeliveries tests that retried and replayed deliveries of a webhook count once
+func TestAggregatorDeliveries(t *testing.T) {
+	store := NewMemoryStore()
+	aggregator := NewAggregator(store)
+	data, _ := json.Marshal(api.Task{ID: "1", CompletedAt: "2024-03-01T12:00:00Z"})
+	request := &apiv1.TodoistWebhookRequest{EventName: EventCompleted, UserId: "1", EventData: string(data)}
+
+	delivery := func(id string) context.Context {
+		header := http.Header{}
+		if id != "" {
+			header.Set(events.DeliveryIDHeader, id)
+		}
+		return events.WithDelivery(context.Background(), events.NewDelivery(header, []byte(id+request.EventData)))
+	}
+	for _, ctx := range []context.Context{
+		delivery("a"), delivery("a"), // a retry
+		delivery("b"),
+		delivery(""), delivery(""), // the same body without an ID
+		context.Background(), context.Background(), // no delivery at all
+	} {
+		if err := aggregator.OnWebhook(ctx, request); err != nil {
+			t.Fatalf("OnWebhook() failed: %v", err)
+		}
+	}
+
+	days, _ := store.Days(context.Background(), "1", "2024-03-01", "2024-03-01")
+	if len(days) != 1 || days[0].Completed != 5 {
+		t.Errorf("days = %+v, want 5 completions: a, b, the body and two without a delivery", days)
+	}
+
+	// Pruned IDs count again
+	store.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	if pruned, err := store.Prune(context.Background(), time.Now()); err != nil || pruned != 3 {
+		t.Errorf("Prune() = %d, %v, want the IDs of a, b and the body", pruned, err)
+	}
+	if err := aggregator.OnWebhook(delivery("a"), request); err != nil {
+		t.Fatalf("OnWebhook() after pruning failed: %v", err)
+	}
+	if pruned, _ := store.Prune(context.Background(), time.Now()); pruned != 0 {
+		t.Errorf("Prune() = %d, want the ID recorded after the time kept", pruned)
+	}
+	if days, _ := store.Days(context.Background(), "1", "2024-03-01", "2024-03-01"); len(days) != 1 || days[0].Completed != 6 {
+		t.Errorf("days after pruning = %+v, want 6 completions", days)
+	}
+
+	// Delivery IDs are kept per user
+	other := &apiv1.TodoistWebhookRequest{EventName: EventCompleted, UserId: "2", EventData: string(data)}
+	if err := aggregator.OnWebhook(delivery("a"), other); err != nil {
+		t.Fatalf("OnWebhook() of another user failed: %v", err)
+	}
+	if days, _ := store.Days(context.Background(), "2", "2024-03-01", "2024-03-01"); len(days) != 1 || days[0].Completed != 1 {
+		t.Errorf("days of another user = %+v, want a completion", days)
+	}
+}
+
+// TestReport tests filling in days and weeks, ordering groups and counting overdue tasks
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, entry := range []Entry{
+		{Date: "2024-02-26", ProjectID: "p", Counts: Counts{Completed: 1}},
+		{Date: "2024-03-02", ProjectID: "q", Labels: []string{"home"}, Counts: Counts{Completed: 1, Timed: 1, Duration: time.Hour}},
+		{Date: "2024-03-03", ProjectID: "q", Counts: Counts{Completed: 1, Added: 1}},
+		{Date: "2024-03-04", ProjectID: "p", Counts: Counts{Added: 2}},
+		{Date: "2024-03-05", ProjectID: "p", Counts: Counts{Completed: 5}},
+	} {
+		store.Record(ctx, "1", entry)
+	}
+
+	report, err := NewReport(ctx, store, "1", "2024-02-29", "2024-03-04", "2024-03-04")
+	if err != nil {
+		t.Fatalf("NewReport failed: %v", err)
+	}
+	if len(report.Days) != 5 || report.Days[0].Date != "2024-02-29" || report.Days[1].Counts != (Counts{}) {
+		t.Errorf("days = %+v, want every day from February 29 to March 4", report.Days)
+	}
+	if want := (Counts{Completed: 2, Added: 3, Timed: 1, Duration: time.Hour}); report.Totals != want {
+		t.Errorf("totals = %+v, want %+v", report.Totals, want)
+	}
+	if len(report.Weeks) != 2 || report.Weeks[0].Week != "2024-W09" || report.Weeks[0].Start != "2024-02-26" ||
+		report.Weeks[0].Completed != 2 || report.Weeks[1].Week != "2024-W10" || report.Weeks[1].Added != 2 {
+		t.Errorf("weeks = %+v, want W09 from February 26 with the weekend and W10 with Monday", report.Weeks)
+	}
+	if len(report.Projects) != 2 || report.Projects[0].Key != "q" || report.Projects[0].Completed != 2 {
+		t.Errorf("projects = %+v, want q with 2 completions first", report.Projects)
+	}
+	if report.Streak.Current != 0 || report.Streak.Longest != 2 {
+		t.Errorf("streak = %+v, want the 2 day run broken by March 4", report.Streak)
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	report.CountOverdue([]api.Task{
+		{ID: "1", ProjectID: "p", Labels: []string{"work"}, Due: &api.Due{Date: "2024-03-03"}},
+		{ID: "2", ProjectID: "p", Due: &api.Due{Date: "2024-03-04T09:00:00"}},
+		{ID: "3", ProjectID: "p", Due: &api.Due{Date: "2024-03-04"}},
+		{ID: "4", ProjectID: "r", Due: &api.Due{Date: "2024-03-01"}, Checked: true},
+	}, time.Date(2024, 3, 4, 9, 30, 0, 0, berlin))
+	if report.Overdue != 2 || report.OverdueByGroup[GroupProject]["p"] != 2 || report.OverdueByGroup[GroupProject]["r"] != 0 {
+		t.Errorf("overdue = %d, %v, want 2 in project p", report.Overdue, report.OverdueByGroup)
+	}
+	if len(report.Labels) != 2 || report.Labels[1].Key != "work" {
+		t.Errorf("labels = %+v, want work listed for its overdue task", report.Labels)
+	}
+
+	for _, r := range [][2]string{{"2024-03-04", "2024-03-01"}, {"2023-01-01", "2024-03-01"}, {"yesterday", "2024-03-01"}} {
+		if _, err := NewReport(ctx, store, "1", r[0], r[1], r[1]); err == nil {
+			t.Errorf("NewReport(%s, %s) succeeded", r[0], r[1])
+		}
+	}
+}
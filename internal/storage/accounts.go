@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cherry_backend/internal/tenant"
+)
+
+// accountRepository implements tenant.Registry on the users table. The timezone is kept in its
+// own column, shared with User, and the other settings as JSON.
+type accountRepository struct {
+	*conn
+}
+
+// accountSettings are the settings stored in the settings column
+type accountSettings struct {
+	Features      map[string]bool      `json:"features,omitempty"`
+	Notifications tenant.Notifications `json:"notifications"`
+}
+
+const accountColumns = `id, email, status, timezone, settings, created_at, updated_at`
+
+func (r *accountRepository) Get(ctx context.Context, userID string) (*tenant.Account, error) {
+	account, err := scanAccount(r.queryRow(ctx, `SELECT `+accountColumns+` FROM users WHERE id = ?`, userID))
+	if err != nil {
+		return nil, accountNotFound(err, userID)
+	}
+	return account, nil
+}
+
+func (r *accountRepository) Put(ctx context.Context, account *tenant.Account) error {
+	if err := account.Validate(); err != nil {
+		return err
+	}
+	settings, err := json.Marshal(accountSettings{
+		Features:      account.Settings.Features,
+		Notifications: account.Settings.Notifications,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode settings of user %s: %w", account.UserID, err)
+	}
+
+	now := r.timestamp()
+	err = r.queryRow(ctx, `
+		INSERT INTO users (id, email, status, timezone, settings, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			email = excluded.email, status = excluded.status, timezone = excluded.timezone,
+			settings = excluded.settings, updated_at = excluded.updated_at
+		RETURNING created_at, updated_at`,
+		account.UserID, account.Email, string(account.Status), account.Settings.Timezone, string(settings), now, now).
+		Scan(&account.CreatedAt, &account.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store account of user %s: %w", account.UserID, err)
+	}
+	return nil
+}
+
+func (r *accountRepository) List(ctx context.Context) ([]tenant.Account, error) {
+	rows, err := r.query(ctx, `SELECT `+accountColumns+` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []tenant.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+func (r *accountRepository) Delete(ctx context.Context, userID string) error {
+	err := deleteOne(ctx, r.conn, `DELETE FROM users WHERE id = ?`, "user "+userID, userID)
+	return accountNotFound(err, userID)
+}
+
+// scanAccount reads an account selected with accountColumns
+func scanAccount(row scanner) (*tenant.Account, error) {
+	var (
+		account  tenant.Account
+		status   string
+		settings string
+	)
+	if err := row.Scan(&account.UserID, &account.Email, &status, &account.Settings.Timezone, &settings,
+		&account.CreatedAt, &account.UpdatedAt); err != nil {
+		return nil, err
+	}
+	account.Status = tenant.Status(status)
+
+	var stored accountSettings
+	if err := json.Unmarshal([]byte(settings), &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode settings of user %s: %w", account.UserID, err)
+	}
+	account.Settings.Features = stored.Features
+	account.Settings.Notifications = stored.Notifications
+	return &account, nil
+}
+
+// accountNotFound maps missing users to tenant.ErrUnknownUser
+func accountNotFound(err error, userID string) error {
+	if err == sql.ErrNoRows || errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("user %s: %w", userID, tenant.ErrUnknownUser)
+	}
+	return err
+}
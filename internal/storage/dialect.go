@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/fanout"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+
+	// Database drivers, both pure Go
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// dialect holds what differs between the supported databases
+type dialect struct {
+	// name is the kind of database and the directory of its migrations
+	name string
+
+	// driver is the database/sql driver name
+	driver string
+
+	// numbered reports whether placeholders are written $1, $2... instead of ?
+	numbered bool
+
+	// configure adjusts the connection pool
+	configure func(db *sql.DB)
+}
+
+var sqlite = &dialect{
+	name:   "sqlite",
+	driver: "sqlite",
+	configure: func(db *sql.DB) {
+		// SQLite allows one writer at a time, and every connection to :memory: is a new
+		// database, so all work goes through a single connection
+		db.SetMaxOpenConns(1)
+	},
+}
+
+var postgres = &dialect{
+	name:      "postgres",
+	driver:    "postgres",
+	numbered:  true,
+	configure: func(db *sql.DB) {},
+}
+
+// dialectOf returns the dialect and driver DSN of a database URL
+func dialectOf(url string) (*dialect, string, error) {
+	switch {
+	case strings.HasPrefix(url, "sqlite:"):
+		path := strings.TrimPrefix(url, "sqlite:")
+		if path == "" {
+			return nil, "", fmt.Errorf("%w: %q has no path", ErrUnsupportedURL, url)
+		}
+		// Times are written in the format SQLite's date functions read, as they compare as text
+		dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate&_time_format=sqlite"
+		return sqlite, dsn, nil
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return postgres, url, nil
+	}
+	return nil, "", fmt.Errorf("%w: %q (want sqlite:PATH or postgres://...)", ErrUnsupportedURL, url)
+}
+
+// rebind rewrites the ? placeholders of a query for the dialect
+func (d *dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// querier is the part of *sql.DB and *sql.Tx the repositories use
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanner is a *sql.Row or *sql.Rows positioned on a row
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// conn runs queries written with ? placeholders on a database or transaction
+type conn struct {
+	q       querier
+	dialect *dialect
+	now     func() time.Time
+}
+
+func (c *conn) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.q.ExecContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c *conn) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.q.QueryContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c *conn) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.q.QueryRowContext(ctx, c.dialect.rebind(query), args...)
+}
+
+// inTx runs fn on a transaction, which is committed if fn returns nil. A connection that is
+// already a transaction runs fn on itself.
+func (c *conn) inTx(ctx context.Context, fn func(c *conn) error) error {
+	db, ok := c.q.(*sql.DB)
+	if !ok {
+		return fn(c)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(&conn{q: tx, dialect: c.dialect, now: c.now}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// timestamp returns the current time as stored by the repositories
+func (c *conn) timestamp() time.Time {
+	return c.now().UTC().Truncate(time.Microsecond)
+}
+
+// Users returns the user repository on the connection
+func (c *conn) Users() UserRepository { return &userRepository{c} }
+
+// Accounts returns the account registry on the connection
+func (c *conn) Accounts() tenant.Registry { return &accountRepository{c} }
+
+// APIKeys returns the API key store on the connection
+func (c *conn) APIKeys() auth.KeyStore { return &keyRepository{c} }
+
+// Tokens returns the token repository on the connection
+func (c *conn) Tokens() TokenRepository { return &tokenRepository{c} }
+
+// Tasks returns the task repository on the connection
+func (c *conn) Tasks() TaskRepository {
+	return &taskRepository{resources: resources[api.Task]{c, "tasks", taskColumns}}
+}
+
+// Projects returns the project repository on the connection
+func (c *conn) Projects() ProjectRepository {
+	return &projectRepository{resources: resources[api.Project]{c, "projects", projectColumns}}
+}
+
+// Mirror returns the mirror store on the connection
+func (c *conn) Mirror() mirror.Store { return &mirrorRepository{c} }
+
+// Subscribers returns the webhook subscriber store on the connection
+func (c *conn) Subscribers() fanout.Store { return &subscriberRepository{c} }
+
+// Events returns the event repository on the connection
+func (c *conn) Events() EventRepository { return &eventRepository{c} }
+
+// Stats returns the statistics store on the connection
+func (c *conn) Stats() stats.Store { return &statsRepository{c} }
+
+// Jobs returns the job lock and history repository on the connection
+func (c *conn) Jobs() JobRepository { return &jobRepository{c} }
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error, what string) error {
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"cherry_backend/internal/events"
+)
+
+// eventRepository implements EventRepository. IDs are assigned by the database, so they keep
+// increasing across restarts.
+type eventRepository struct {
+	*conn
+}
+
+func (r *eventRepository) Append(ctx context.Context, event *events.Event) error {
+	var header, body interface{}
+	if event.Delivery != nil {
+		encoded, err := json.Marshal(event.Delivery.Header)
+		if err != nil {
+			return fmt.Errorf("failed to encode delivery header: %w", err)
+		}
+		header, body = string(encoded), event.Delivery.Body
+	}
+	var data interface{}
+	if len(event.Data) > 0 {
+		data = string(event.Data)
+	}
+
+	var id int64
+	err := r.queryRow(ctx, `
+		INSERT INTO events (name, user_id, resource, resource_id, data, version, received_at, delivery_header, delivery_body)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		event.Name, event.UserID, event.Resource, event.ResourceID, data, event.Version,
+		event.ReceivedAt.UTC(), header, body).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to store event: %w", err)
+	}
+	event.ID = uint64(id)
+	return nil
+}
+
+func (r *eventRepository) Since(ctx context.Context, userID string, after uint64, limit int) ([]*events.Event, error) {
+	query := `SELECT id, name, user_id, resource, resource_id, data, version, received_at, delivery_header, delivery_body
+		FROM events WHERE id > ?`
+	args := []interface{}{int64(after)}
+	if userID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY id`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*events.Event
+	for rows.Next() {
+		var (
+			event  events.Event
+			id     int64
+			data   sql.NullString
+			header sql.NullString
+			body   []byte
+		)
+		if err := rows.Scan(&id, &event.Name, &event.UserID, &event.Resource, &event.ResourceID, &data,
+			&event.Version, &event.ReceivedAt, &header, &body); err != nil {
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		event.ID = uint64(id)
+		if data.Valid {
+			event.Data = json.RawMessage(data.String)
+		}
+		if header.Valid {
+			event.Delivery = &events.Delivery{Body: body}
+			if err := json.Unmarshal([]byte(header.String), &event.Delivery.Header); err != nil {
+				return nil, fmt.Errorf("failed to decode delivery header of event %d: %w", id, err)
+			}
+		}
+		result = append(result, &event)
+	}
+	return result, rows.Err()
+}
+
+func (r *eventRepository) Prune(ctx context.Context, keep int) (int, error) {
+	result, err := r.exec(ctx, `
+		DELETE FROM events WHERE id < (SELECT id FROM events ORDER BY id DESC LIMIT 1 OFFSET ?)`, keep-1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	return int(deleted), nil
+}
+
+func (r *eventRepository) LastID(ctx context.Context) (uint64, error) {
+	var id int64
+	if err := r.queryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM events`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read the last event ID: %w", err)
+	}
+	return uint64(id), nil
+}
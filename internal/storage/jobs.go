@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"cherry_backend/internal/scheduler"
+)
+
+// jobHistorySize is how many runs of each job the job repository keeps
+const jobHistorySize = 1000
+
+const jobRunColumns = `id, job, trigger_type, instance, slot, started_at, finished_at, status, error`
+
+// jobRepository implements JobRepository. Locks are leases whose expiry is set by the clock of
+// the instance taking them and checked by the clocks of the others, so instances sharing the
+// database need synchronized clocks: one running ahead by d takes over another's lock d early.
+// Scheduled runs are claimed by their slot, which does not depend on the clocks, so skew never
+// runs a slot twice.
+type jobRepository struct {
+	*conn
+}
+
+func (r *jobRepository) Lock(ctx context.Context, job, owner string, slot time.Time, ttl time.Duration) (bool, error) {
+	if _, err := r.exec(ctx, `INSERT INTO job_locks (job) VALUES (?) ON CONFLICT (job) DO NOTHING`, job); err != nil {
+		return false, fmt.Errorf("failed to lock job %s: %w", job, err)
+	}
+
+	now := r.timestamp()
+	query := `UPDATE job_locks SET owner = ?, locked_until = ?`
+	args := []interface{}{owner, now.Add(ttl)}
+	if !slot.IsZero() {
+		query += `, slot = ?`
+		args = append(args, slot.UTC())
+	}
+	query += ` WHERE job = ? AND (locked_until IS NULL OR locked_until <= ?)`
+	args = append(args, job, now)
+	if !slot.IsZero() {
+		query += ` AND (slot IS NULL OR slot < ?)`
+		args = append(args, slot.UTC())
+	}
+
+	result, err := r.exec(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock job %s: %w", job, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to lock job %s: %w", job, err)
+	}
+	return n == 1, nil
+}
+
+func (r *jobRepository) Unlock(ctx context.Context, job, owner string) error {
+	if _, err := r.exec(ctx, `UPDATE job_locks SET locked_until = NULL WHERE job = ? AND owner = ?`, job, owner); err != nil {
+		return fmt.Errorf("failed to unlock job %s: %w", job, err)
+	}
+	return nil
+}
+
+func (r *jobRepository) Record(ctx context.Context, run *scheduler.Run) error {
+	var slot interface{}
+	if !run.Slot.IsZero() {
+		slot = run.Slot.UTC()
+	}
+	return r.inTx(ctx, func(c *conn) error {
+		var id int64
+		err := c.queryRow(ctx, `
+			INSERT INTO job_runs (job, trigger_type, instance, slot, started_at, finished_at, status, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			run.Job, run.Trigger, run.Instance, slot, run.StartedAt.UTC(), run.FinishedAt.UTC(), run.Status, run.Error).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to record run of job %s: %w", run.Job, err)
+		}
+		run.ID = uint64(id)
+
+		_, err = c.exec(ctx, `
+			DELETE FROM job_runs WHERE job = ? AND id <= (
+				SELECT id FROM job_runs WHERE job = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`,
+			run.Job, run.Job, jobHistorySize)
+		if err != nil {
+			return fmt.Errorf("failed to trim runs of job %s: %w", run.Job, err)
+		}
+		return nil
+	})
+}
+
+func (r *jobRepository) List(ctx context.Context, job string, limit int) ([]scheduler.Run, error) {
+	query := `SELECT ` + jobRunColumns + ` FROM job_runs`
+	var args []interface{}
+	if job != "" {
+		query += ` WHERE job = ?`
+		args = append(args, job)
+	}
+	query += ` ORDER BY id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []scheduler.Run
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *jobRepository) Last(ctx context.Context, job string) (*scheduler.Run, error) {
+	run, err := scanJobRun(r.queryRow(ctx,
+		`SELECT `+jobRunColumns+` FROM job_runs WHERE job = ? AND slot IS NOT NULL ORDER BY id DESC LIMIT 1`, job))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// scanJobRun reads a row of jobRunColumns
+func scanJobRun(row scanner) (*scheduler.Run, error) {
+	var (
+		run  scheduler.Run
+		id   int64
+		slot sql.NullTime
+	)
+	if err := row.Scan(&id, &run.Job, &run.Trigger, &run.Instance, &slot, &run.StartedAt, &run.FinishedAt, &run.Status, &run.Error); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read job run: %w", err)
+	}
+	run.ID = uint64(id)
+	if slot.Valid {
+		run.Slot = slot.Time.In(time.UTC)
+	}
+	run.StartedAt = run.StartedAt.In(time.UTC)
+	run.FinishedAt = run.FinishedAt.In(time.UTC)
+	return &run, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"cherry_backend/internal/auth"
+)
+
+// keyRepository implements auth.KeyStore. Scopes are stored space-separated.
+type keyRepository struct {
+	*conn
+}
+
+const keyColumns = `id, user_id, name, scopes, hash, created_at, expires_at`
+
+func (r *keyRepository) Create(ctx context.Context, key *auth.Key) error {
+	var expires interface{}
+	if key.ExpiresAt != nil {
+		expires = key.ExpiresAt.UTC()
+	}
+	_, err := r.exec(ctx, `INSERT INTO api_keys (`+keyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.UserID, key.Name, strings.Join(key.Scopes, " "), key.Hash, key.CreatedAt.UTC(), expires)
+	if err != nil {
+		return fmt.Errorf("failed to store API key %s: %w", key.ID, err)
+	}
+	return nil
+}
+
+func (r *keyRepository) Get(ctx context.Context, id string) (*auth.Key, error) {
+	key, err := scanKey(r.queryRow(ctx, `SELECT `+keyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, auth.ErrKeyNotFound
+	}
+	return key, err
+}
+
+func (r *keyRepository) List(ctx context.Context, userID string) ([]auth.Key, error) {
+	rows, err := r.query(ctx, `SELECT `+keyColumns+` FROM api_keys WHERE user_id = ? ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []auth.Key{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *keyRepository) Delete(ctx context.Context, userID, id string) error {
+	result, err := r.exec(ctx, `DELETE FROM api_keys WHERE user_id = ? AND id = ?`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key %s: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return auth.ErrKeyNotFound
+	}
+	return nil
+}
+
+// scanKey reads a key selected with keyColumns
+func scanKey(row scanner) (*auth.Key, error) {
+	var (
+		key     auth.Key
+		scopes  string
+		expires sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.UserID, &key.Name, &scopes, &key.Hash, &key.CreatedAt, &expires); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read API key: %w", err)
+	}
+	key.Scopes = strings.Fields(scopes)
+	if expires.Valid {
+		at := expires.Time.In(time.UTC)
+		key.ExpiresAt = &at
+	}
+	return &key, nil
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the schema migrations of every dialect, named NNNN_description.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+
+	// AppliedAt is when the migration was applied, zero if it is pending
+	AppliedAt time.Time
+}
+
+// migrationLockID serializes migrations of concurrent PostgreSQL clients through an advisory lock
+const migrationLockID = 7_316_052_901
+
+// Migrations returns every migration of the database, with the time it was applied if it was
+func (db *DB) Migrations(ctx context.Context) ([]Migration, error) {
+	migrations, err := db.dialect.migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.ensureMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range migrations {
+		migrations[i].AppliedAt = applied[migrations[i].Version]
+	}
+	return migrations, nil
+}
+
+// Pending returns the migrations that have not been applied yet, oldest first
+func (db *DB) Pending(ctx context.Context) ([]Migration, error) {
+	migrations, err := db.Migrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.AppliedAt.IsZero() {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in version order and returns them. Each migration
+// runs in its own transaction, so a failing migration leaves the schema at the previous version.
+func (db *DB) Migrate(ctx context.Context) ([]Migration, error) {
+	pending, err := db.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range pending {
+		ok, err := db.apply(ctx, migration)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// apply runs a migration unless another client applied it meanwhile
+func (db *DB) apply(ctx context.Context, migration Migration) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if db.dialect == postgres {
+		if _, err := tx.exec(ctx, `SELECT pg_advisory_xact_lock(?)`, migrationLockID); err != nil {
+			return false, fmt.Errorf("failed to lock migrations: %w", err)
+		}
+	}
+	var count int
+	if err := tx.queryRow(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, migration.Version).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", migration.Version, err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	// Migrations run without arguments, which lets both drivers execute several statements
+	if _, err := tx.tx.ExecContext(ctx, migration.SQL); err != nil {
+		return false, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.exec(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		migration.Version, migration.Name, tx.timestamp()); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
+	return true, nil
+}
+
+// ensureMigrationTable creates the table recording applied migrations
+func (db *DB) ensureMigrationTable(ctx context.Context) error {
+	_, err := db.sql.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create the migration table: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns when each applied migration was applied, by version
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := db.sql.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to read applied migration: %w", err)
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// migrations reads the migrations of the dialect in version order
+func (d *dialect) migrations() ([]Migration, error) {
+	dir := path.Join("migrations", d.name)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s migrations: %w", d.name, err)
+	}
+
+	var migrations []Migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		number, description, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %s/%s, want NNNN_description.sql", dir, name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, name)
+		}
+		seen[version] = name
+
+		sql, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: description, SQL: string(sql)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
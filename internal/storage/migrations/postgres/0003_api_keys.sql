@@ -0,0 +1,13 @@
+-- API keys of users; only the hash of each key's secret is stored
+
+CREATE TABLE api_keys (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL DEFAULT '',
+    scopes     TEXT NOT NULL,
+    hash       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_user ON api_keys (user_id, created_at);
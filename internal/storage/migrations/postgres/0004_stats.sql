@@ -0,0 +1,37 @@
+-- Productivity statistics aggregated from task webhooks: the counts of each user per day and
+-- per project or label and day, and each user's completion streak. Days are YYYY-MM-DD in the
+-- user's time zone.
+
+CREATE TABLE stats_days (
+    user_id            TEXT NOT NULL,
+    day                TEXT NOT NULL,
+    completed          INTEGER NOT NULL DEFAULT 0,
+    added              INTEGER NOT NULL DEFAULT 0,
+    completed_late     INTEGER NOT NULL DEFAULT 0,
+    timed              INTEGER NOT NULL DEFAULT 0,
+    completion_seconds BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE TABLE stats_groups (
+    user_id            TEXT NOT NULL,
+    kind               TEXT NOT NULL,
+    group_key          TEXT NOT NULL,
+    day                TEXT NOT NULL,
+    completed          INTEGER NOT NULL DEFAULT 0,
+    added              INTEGER NOT NULL DEFAULT 0,
+    completed_late     INTEGER NOT NULL DEFAULT 0,
+    timed              INTEGER NOT NULL DEFAULT 0,
+    completion_seconds BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, kind, group_key, day)
+);
+
+CREATE INDEX stats_groups_day ON stats_groups (user_id, kind, day);
+
+CREATE TABLE stats_streaks (
+    user_id      TEXT PRIMARY KEY,
+    current_days INTEGER NOT NULL,
+    last_day     TEXT NOT NULL,
+    longest_days INTEGER NOT NULL,
+    longest_end  TEXT NOT NULL
+);
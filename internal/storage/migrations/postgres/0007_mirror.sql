@@ -0,0 +1,32 @@
+-- The rest of the mirrored Todoist state next to the tasks and projects tables: the sync token
+-- of every mirrored user, and their sections, labels and comments
+
+CREATE TABLE mirror_users (
+    user_id    TEXT PRIMARY KEY,
+    sync_token TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE sections (
+    user_id    TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    data       TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE TABLE labels (
+    user_id    TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    data       TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE TABLE comments (
+    user_id    TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    data       TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, id)
+);
@@ -0,0 +1,54 @@
+-- Users, their Todoist tokens, mirrored tasks and projects, and the event log
+
+CREATE TABLE users (
+    id         TEXT PRIMARY KEY,
+    email      TEXT NOT NULL DEFAULT '',
+    full_name  TEXT NOT NULL DEFAULT '',
+    timezone   TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE tokens (
+    user_id    TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    token      TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE tasks (
+    user_id    TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    project_id TEXT NOT NULL DEFAULT '',
+    checked    BOOLEAN NOT NULL DEFAULT FALSE,
+    due_date   TEXT NOT NULL DEFAULT '',
+    data       TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE INDEX tasks_user_project ON tasks (user_id, project_id);
+
+CREATE TABLE projects (
+    user_id     TEXT NOT NULL,
+    id          TEXT NOT NULL,
+    name        TEXT NOT NULL DEFAULT '',
+    is_archived BOOLEAN NOT NULL DEFAULT FALSE,
+    data        TEXT NOT NULL,
+    updated_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE TABLE events (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    name            TEXT NOT NULL,
+    user_id         TEXT NOT NULL,
+    resource        TEXT NOT NULL DEFAULT '',
+    resource_id     TEXT NOT NULL DEFAULT '',
+    data            TEXT,
+    version         TEXT NOT NULL DEFAULT '',
+    received_at     TIMESTAMP NOT NULL,
+    delivery_header TEXT,
+    delivery_body   BLOB
+);
+
+CREATE INDEX events_user ON events (user_id, id);
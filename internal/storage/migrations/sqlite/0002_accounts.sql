@@ -0,0 +1,4 @@
+-- Account status and settings of each user
+
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN settings TEXT NOT NULL DEFAULT '{}';
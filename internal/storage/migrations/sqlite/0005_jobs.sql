@@ -0,0 +1,23 @@
+-- Locks and run history of the scheduled jobs. A lock is held until locked_until; slot is the
+-- last scheduled time claimed, so each scheduled run is claimed by one instance only.
+
+CREATE TABLE job_locks (
+    job          TEXT PRIMARY KEY,
+    owner        TEXT NOT NULL DEFAULT '',
+    slot         TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+CREATE TABLE job_runs (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    job          TEXT NOT NULL,
+    trigger_type TEXT NOT NULL,
+    instance     TEXT NOT NULL DEFAULT '',
+    slot         TIMESTAMP,
+    started_at   TIMESTAMP NOT NULL,
+    finished_at  TIMESTAMP NOT NULL,
+    status       TEXT NOT NULL,
+    error        TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX job_runs_job ON job_runs (job, id);
@@ -0,0 +1,11 @@
+-- IDs of the webhooks counted in the statistics of each user, so that retried and replayed
+-- webhooks are counted once. IDs are pruned once they are older than the replay window.
+
+CREATE TABLE stats_entries (
+    user_id     TEXT NOT NULL,
+    entry_id    TEXT NOT NULL,
+    recorded_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, entry_id)
+);
+
+CREATE INDEX stats_entries_recorded_at ON stats_entries (recorded_at);
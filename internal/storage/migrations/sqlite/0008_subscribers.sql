@@ -0,0 +1,11 @@
+-- Webhook subscribers registered through the admin API. The secret signs deliveries, so it is
+-- stored as given rather than hashed. Events are stored space-separated.
+
+CREATE TABLE subscribers (
+    id         TEXT PRIMARY KEY,
+    url        TEXT NOT NULL,
+    events     TEXT NOT NULL DEFAULT '',
+    secret     TEXT NOT NULL,
+    paused     BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL
+);
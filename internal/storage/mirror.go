@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"cherry_backend/internal/mirror"
+	"cherry_backend/pkg/api"
+)
+
+// mirrorTables are the tables of the mirrored resources of a user
+var mirrorTables = []string{"tasks", "projects", "sections", "labels", "comments"}
+
+// mirrorRepository implements mirror.Store on the tasks, projects, sections, labels and comments
+// tables. The sync token of a user is moved on with a compare-and-set in the transaction that
+// applies a sync, so a sync that lost the race to another one writes nothing.
+type mirrorRepository struct {
+	*conn
+}
+
+func (r *mirrorRepository) SyncToken(ctx context.Context, userID string) (string, error) {
+	var token string
+	err := r.queryRow(ctx, `SELECT sync_token FROM mirror_users WHERE user_id = ?`, userID).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the sync token of user %s: %w", userID, err)
+	}
+	return token, nil
+}
+
+func (r *mirrorRepository) Apply(ctx context.Context, userID, base string, delta *api.SyncResponse) error {
+	return r.inTx(ctx, func(tx *conn) error {
+		if err := tx.moveSyncToken(ctx, userID, base, delta.SyncToken); err != nil {
+			return err
+		}
+
+		// A full sync is authoritative: start from an empty state
+		if delta.FullSync {
+			for _, table := range mirrorTables {
+				if _, err := tx.exec(ctx, `DELETE FROM `+table+` WHERE user_id = ?`, userID); err != nil {
+					return fmt.Errorf("failed to clear the %s of user %s: %w", table, userID, err)
+				}
+			}
+		}
+
+		tasks := resources[api.Task]{tx, "tasks", taskColumns}
+		for _, task := range delta.Items {
+			if err := applyResource(ctx, &tasks, userID, task.ID, task, task.IsDeleted); err != nil {
+				return err
+			}
+		}
+		projects := resources[api.Project]{tx, "projects", projectColumns}
+		for _, project := range delta.Projects {
+			if err := applyResource(ctx, &projects, userID, project.ID, project, project.IsDeleted); err != nil {
+				return err
+			}
+		}
+		sections := resources[api.Section]{tx, "sections", sectionColumns}
+		for _, section := range delta.Sections {
+			if err := applyResource(ctx, &sections, userID, section.ID, section, section.IsDeleted); err != nil {
+				return err
+			}
+		}
+		labels := resources[api.Label]{tx, "labels", labelColumns}
+		for _, label := range delta.Labels {
+			if err := applyResource(ctx, &labels, userID, label.ID, label, label.IsDeleted); err != nil {
+				return err
+			}
+		}
+		comments := resources[api.Comment]{tx, "comments", commentColumns}
+		for _, note := range delta.Notes {
+			if err := applyResource(ctx, &comments, userID, note.ID, note, note.IsDeleted); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// moveSyncToken sets the sync token of a user to token if it still is base, or returns
+// mirror.ErrSyncTokenConflict
+func (c *conn) moveSyncToken(ctx context.Context, userID, base, token string) error {
+	now := c.timestamp()
+	result, err := c.exec(ctx, `UPDATE mirror_users SET sync_token = ?, updated_at = ? WHERE user_id = ? AND sync_token = ?`,
+		token, now, userID, base)
+	if err != nil {
+		return fmt.Errorf("failed to move the sync token of user %s: %w", userID, err)
+	}
+	if moved, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to move the sync token of user %s: %w", userID, err)
+	} else if moved == 1 {
+		return nil
+	}
+	if base != "" {
+		return mirror.ErrSyncTokenConflict
+	}
+
+	// A user that was never synced has no row yet
+	result, err = c.exec(ctx, `INSERT INTO mirror_users (user_id, sync_token, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO NOTHING`, userID, token, now)
+	if err != nil {
+		return fmt.Errorf("failed to store the sync token of user %s: %w", userID, err)
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to store the sync token of user %s: %w", userID, err)
+	} else if inserted == 0 {
+		return mirror.ErrSyncTokenConflict
+	}
+	return nil
+}
+
+// applyResource stores or removes a single resource
+func applyResource[T any](ctx context.Context, r *resources[T], userID, id string, resource T, deleted bool) error {
+	if deleted {
+		return r.delete(ctx, userID, []string{id})
+	}
+	return r.put(ctx, userID, []T{resource})
+}
+
+func (r *mirrorRepository) Snapshot(ctx context.Context, userID string) (*mirror.Snapshot, error) {
+	snapshot := &mirror.Snapshot{}
+	err := r.inTx(ctx, func(tx *conn) error {
+		var err error
+		if snapshot.SyncToken, err = (&mirrorRepository{tx}).SyncToken(ctx, userID); err != nil {
+			return err
+		}
+		if snapshot.Tasks, err = (&resources[api.Task]{tx, "tasks", taskColumns}).list(ctx, userID); err != nil {
+			return err
+		}
+		if snapshot.Projects, err = (&resources[api.Project]{tx, "projects", projectColumns}).list(ctx, userID); err != nil {
+			return err
+		}
+		if snapshot.Sections, err = (&resources[api.Section]{tx, "sections", sectionColumns}).list(ctx, userID); err != nil {
+			return err
+		}
+		if snapshot.Labels, err = (&resources[api.Label]{tx, "labels", labelColumns}).list(ctx, userID); err != nil {
+			return err
+		}
+		snapshot.Notes, err = (&resources[api.Comment]{tx, "comments", commentColumns}).list(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (r *mirrorRepository) Users(ctx context.Context) ([]string, error) {
+	rows, err := r.query(ctx, `SELECT user_id FROM mirror_users ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mirrored users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to read mirrored user: %w", err)
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cherry_backend/pkg/api"
+)
+
+// resources stores Todoist resources of one type as JSON, next to a few columns extracted for
+// querying. The table has the columns user_id, id, data and updated_at plus the extracted ones.
+type resources[T any] struct {
+	*conn
+	table string
+
+	// columns are the extracted columns
+	columns resourceColumns[T]
+}
+
+// resourceColumns names the extracted columns of a resource type and reads them from a resource
+type resourceColumns[T any] struct {
+	names  []string
+	values func(resource *T) (id string, values []interface{})
+}
+
+var taskColumns = resourceColumns[api.Task]{
+	names: []string{"project_id", "checked", "due_date"},
+	values: func(task *api.Task) (string, []interface{}) {
+		due := ""
+		if task.Due != nil {
+			due = task.Due.Date
+		}
+		return task.ID, []interface{}{task.ProjectID, task.Checked, due}
+	},
+}
+
+var projectColumns = resourceColumns[api.Project]{
+	names: []string{"name", "is_archived"},
+	values: func(project *api.Project) (string, []interface{}) {
+		return project.ID, []interface{}{project.Name, project.IsArchived}
+	},
+}
+
+// sectionColumns, labelColumns and commentColumns extract only the ID; the rest of the mirror
+// is only read per user
+var sectionColumns = resourceColumns[api.Section]{
+	values: func(section *api.Section) (string, []interface{}) { return section.ID, nil },
+}
+
+var labelColumns = resourceColumns[api.Label]{
+	values: func(label *api.Label) (string, []interface{}) { return label.ID, nil },
+}
+
+var commentColumns = resourceColumns[api.Comment]{
+	values: func(comment *api.Comment) (string, []interface{}) { return comment.ID, nil },
+}
+
+// get returns a resource of a user, or ErrNotFound
+func (r *resources[T]) get(ctx context.Context, userID, id string) (*T, error) {
+	var data []byte
+	err := r.queryRow(ctx, `SELECT data FROM `+r.table+` WHERE user_id = ? AND id = ?`, userID, id).Scan(&data)
+	if err != nil {
+		return nil, notFound(err, strings.TrimSuffix(r.table, "s")+" "+id)
+	}
+	var resource T
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s: %w", r.table, id, err)
+	}
+	return &resource, nil
+}
+
+// list returns all resources of a user ordered by ID
+func (r *resources[T]) list(ctx context.Context, userID string) ([]T, error) {
+	rows, err := r.query(ctx, `SELECT data FROM `+r.table+` WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.table, err)
+	}
+	defer rows.Close()
+
+	var result []T
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", r.table, err)
+		}
+		var resource T
+		if err := json.Unmarshal(data, &resource); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", r.table, err)
+		}
+		result = append(result, resource)
+	}
+	return result, rows.Err()
+}
+
+// put creates or replaces resources of a user
+func (r *resources[T]) put(ctx context.Context, userID string, items []T) error {
+	names := append([]string{"user_id", "id", "data", "updated_at"}, r.columns.names...)
+	updates := make([]string, 0, len(names)-2)
+	for _, name := range names[2:] {
+		updates = append(updates, name+" = excluded."+name)
+	}
+	query := `INSERT INTO ` + r.table + ` (` + strings.Join(names, ", ") + `) VALUES (?` + strings.Repeat(", ?", len(names)-1) + `)
+		ON CONFLICT (user_id, id) DO UPDATE SET ` + strings.Join(updates, ", ")
+
+	now := r.timestamp()
+	for i := range items {
+		data, err := json.Marshal(&items[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", r.table, err)
+		}
+		id, values := r.columns.values(&items[i])
+		args := append([]interface{}{userID, id, string(data), now}, values...)
+		if _, err := r.exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to store %s %s: %w", strings.TrimSuffix(r.table, "s"), id, err)
+		}
+	}
+	return nil
+}
+
+// delete removes resources of a user
+func (r *resources[T]) delete(ctx context.Context, userID string, ids []string) error {
+	for _, id := range ids {
+		if _, err := r.exec(ctx, `DELETE FROM `+r.table+` WHERE user_id = ? AND id = ?`, userID, id); err != nil {
+			return fmt.Errorf("failed to delete %s %s: %w", strings.TrimSuffix(r.table, "s"), id, err)
+		}
+	}
+	return nil
+}
+
+// taskRepository implements TaskRepository
+type taskRepository struct {
+	resources resources[api.Task]
+}
+
+func (r *taskRepository) Get(ctx context.Context, userID, id string) (*api.Task, error) {
+	return r.resources.get(ctx, userID, id)
+}
+
+func (r *taskRepository) List(ctx context.Context, userID string) ([]api.Task, error) {
+	return r.resources.list(ctx, userID)
+}
+
+func (r *taskRepository) Put(ctx context.Context, userID string, tasks ...api.Task) error {
+	return r.resources.put(ctx, userID, tasks)
+}
+
+func (r *taskRepository) Delete(ctx context.Context, userID string, ids ...string) error {
+	return r.resources.delete(ctx, userID, ids)
+}
+
+// projectRepository implements ProjectRepository
+type projectRepository struct {
+	resources resources[api.Project]
+}
+
+func (r *projectRepository) Get(ctx context.Context, userID, id string) (*api.Project, error) {
+	return r.resources.get(ctx, userID, id)
+}
+
+func (r *projectRepository) List(ctx context.Context, userID string) ([]api.Project, error) {
+	return r.resources.list(ctx, userID)
+}
+
+func (r *projectRepository) Put(ctx context.Context, userID string, projects ...api.Project) error {
+	return r.resources.put(ctx, userID, projects)
+}
+
+func (r *projectRepository) Delete(ctx context.Context, userID string, ids ...string) error {
+	return r.resources.delete(ctx, userID, ids)
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"cherry_backend/internal/stats"
+)
+
+// statsRepository implements stats.Store. Counts are added to their rows in place, so
+// concurrent webhooks never lose each other's counts; durations are kept in whole seconds.
+// Entry IDs are claimed in stats_entries in the same transaction, so an entry counts once, and
+// kept there until they are pruned.
+type statsRepository struct {
+	*conn
+}
+
+const statsCounts = `completed, added, completed_late, timed, completion_seconds`
+
+func (r *statsRepository) Record(ctx context.Context, userID string, entry stats.Entry) error {
+	if _, err := stats.ParseDate(entry.Date); err != nil {
+		return err
+	}
+	c := entry.Counts
+	counts := []interface{}{c.Completed, c.Added, c.CompletedLate, c.Timed, int64(c.Duration / time.Second)}
+
+	return r.inTx(ctx, func(tx *conn) error {
+		if entry.ID != "" {
+			result, err := tx.exec(ctx, `
+				INSERT INTO stats_entries (user_id, entry_id, recorded_at) VALUES (?, ?, ?)
+				ON CONFLICT (user_id, entry_id) DO NOTHING`, userID, entry.ID, tx.timestamp())
+			if err != nil {
+				return fmt.Errorf("failed to record stats entry %s of user %s: %w", entry.ID, userID, err)
+			}
+			if inserted, err := result.RowsAffected(); err != nil {
+				return fmt.Errorf("failed to record stats entry %s of user %s: %w", entry.ID, userID, err)
+			} else if inserted == 0 {
+				return nil
+			}
+		}
+
+		_, err := tx.exec(ctx, `
+			INSERT INTO stats_days (user_id, day, `+statsCounts+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (user_id, day) DO UPDATE SET `+addCounts("stats_days"),
+			append([]interface{}{userID, entry.Date}, counts...)...)
+		if err != nil {
+			return fmt.Errorf("failed to record stats of user %s: %w", userID, err)
+		}
+
+		groups := map[string][]string{stats.GroupLabel: entry.Labels}
+		if entry.ProjectID != "" {
+			groups[stats.GroupProject] = []string{entry.ProjectID}
+		}
+		for kind, keys := range groups {
+			for _, key := range keys {
+				_, err := tx.exec(ctx, `
+					INSERT INTO stats_groups (user_id, kind, group_key, day, `+statsCounts+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+					ON CONFLICT (user_id, kind, group_key, day) DO UPDATE SET `+addCounts("stats_groups"),
+					append([]interface{}{userID, kind, key, entry.Date}, counts...)...)
+				if err != nil {
+					return fmt.Errorf("failed to record %s stats of user %s: %w", kind, userID, err)
+				}
+			}
+		}
+
+		if entry.Completed == 0 {
+			return nil
+		}
+		return (&statsRepository{tx}).extendStreak(ctx, userID, entry.Date)
+	})
+}
+
+func (r *statsRepository) Prune(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.exec(ctx, `DELETE FROM stats_entries WHERE recorded_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune stats entries: %w", err)
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune stats entries: %w", err)
+	}
+	return int(pruned), nil
+}
+
+// addCounts returns the assignments that add the excluded counts to those of a row
+func addCounts(table string) string {
+	return `
+		completed = ` + table + `.completed + excluded.completed,
+		added = ` + table + `.added + excluded.added,
+		completed_late = ` + table + `.completed_late + excluded.completed_late,
+		timed = ` + table + `.timed + excluded.timed,
+		completion_seconds = ` + table + `.completion_seconds + excluded.completion_seconds`
+}
+
+// extendStreak extends the streak of a user with a day with completions, rebuilding it from
+// the stored days when the day is before the last one
+func (r *statsRepository) extendStreak(ctx context.Context, userID, date string) error {
+	streak, err := r.Streak(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !streak.Extend(date) {
+		rows, err := r.query(ctx, `SELECT day FROM stats_days WHERE user_id = ? AND completed > 0 ORDER BY day`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to list the days of user %s: %w", userID, err)
+		}
+		defer rows.Close()
+		var dates []string
+		for rows.Next() {
+			var day string
+			if err := rows.Scan(&day); err != nil {
+				return fmt.Errorf("failed to read day: %w", err)
+			}
+			dates = append(dates, day)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		streak = stats.StreakOf(dates)
+	}
+
+	_, err = r.exec(ctx, `
+		INSERT INTO stats_streaks (user_id, current_days, last_day, longest_days, longest_end) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			current_days = excluded.current_days, last_day = excluded.last_day,
+			longest_days = excluded.longest_days, longest_end = excluded.longest_end`,
+		userID, streak.Current, streak.Last, streak.Longest, streak.LongestEnd)
+	if err != nil {
+		return fmt.Errorf("failed to store the streak of user %s: %w", userID, err)
+	}
+	return nil
+}
+
+func (r *statsRepository) Days(ctx context.Context, userID, from, to string) ([]stats.Day, error) {
+	rows, err := r.query(ctx, `SELECT day, `+statsCounts+` FROM stats_days
+		WHERE user_id = ? AND day >= ? AND day <= ? ORDER BY day`, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the days of user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var days []stats.Day
+	for rows.Next() {
+		var day stats.Day
+		if day.Counts, err = scanCounts(rows, &day.Date); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (r *statsRepository) Groups(ctx context.Context, userID, kind, from, to string) ([]stats.Group, error) {
+	rows, err := r.query(ctx, `
+		SELECT group_key, SUM(completed), SUM(added), SUM(completed_late), SUM(timed), SUM(completion_seconds)
+		FROM stats_groups WHERE user_id = ? AND kind = ? AND day >= ? AND day <= ?
+		GROUP BY group_key ORDER BY group_key`, userID, kind, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the %s stats of user %s: %w", kind, userID, err)
+	}
+	defer rows.Close()
+
+	var groups []stats.Group
+	for rows.Next() {
+		group := stats.Group{Kind: kind}
+		if group.Counts, err = scanCounts(rows, &group.Key); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func (r *statsRepository) Streak(ctx context.Context, userID string) (stats.Streak, error) {
+	var streak stats.Streak
+	err := r.queryRow(ctx, `SELECT current_days, last_day, longest_days, longest_end FROM stats_streaks WHERE user_id = ?`, userID).
+		Scan(&streak.Current, &streak.Last, &streak.Longest, &streak.LongestEnd)
+	if err == sql.ErrNoRows {
+		return stats.Streak{}, nil
+	}
+	if err != nil {
+		return stats.Streak{}, fmt.Errorf("failed to read the streak of user %s: %w", userID, err)
+	}
+	return streak, nil
+}
+
+// scanCounts reads a key column followed by the statsCounts columns
+func scanCounts(row scanner, key *string) (stats.Counts, error) {
+	var (
+		counts  stats.Counts
+		seconds int64
+	)
+	if err := row.Scan(key, &counts.Completed, &counts.Added, &counts.CompletedLate, &counts.Timed, &seconds); err != nil {
+		return stats.Counts{}, fmt.Errorf("failed to read stats: %w", err)
+	}
+	counts.Duration = time.Duration(seconds) * time.Second
+	return counts, nil
+}
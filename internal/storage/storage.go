@@ -0,0 +1,263 @@
+// Package storage persists users and their accounts, API keys and Todoist tokens, mirrored
+// Todoist state, webhook subscribers, the event log, productivity statistics and the locks and
+// runs of scheduled jobs in SQLite or PostgreSQL. Every repository works on the database
+// directly or within a transaction opened with DB.InTx. The schema is versioned by the
+// migrations in migrations/, which DB.Migrate applies.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/fanout"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/scheduler"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrUnsupportedURL is returned for database URLs of an unknown kind
+var ErrUnsupportedURL = errors.New("unsupported database URL")
+
+// User is a Todoist user known to the backend
+type User struct {
+	ID       string
+	Email    string
+	FullName string
+	Timezone string
+
+	// CreatedAt and UpdatedAt are set by the repository
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// UserRepository stores users by their Todoist user ID
+type UserRepository interface {
+	// Get returns a user, or ErrNotFound
+	Get(ctx context.Context, id string) (*User, error)
+
+	// Put creates a user or updates all its fields
+	Put(ctx context.Context, user *User) error
+
+	// List returns every user ordered by ID
+	List(ctx context.Context) ([]User, error)
+
+	// Delete removes a user and its token, or returns ErrNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+// TokenRepository stores the Todoist API token of each user. A user must exist to have a token.
+type TokenRepository interface {
+	// Get returns the token of a user, or ErrNotFound
+	Get(ctx context.Context, userID string) (string, error)
+
+	// Put sets the token of a user
+	Put(ctx context.Context, userID, token string) error
+
+	// Delete removes the token of a user, or returns ErrNotFound
+	Delete(ctx context.Context, userID string) error
+
+	// Users returns the IDs of all users with a token, ordered by ID
+	Users(ctx context.Context) ([]string, error)
+}
+
+// TaskRepository stores the mirrored tasks of each user
+type TaskRepository interface {
+	// Get returns a task of a user, or ErrNotFound
+	Get(ctx context.Context, userID, id string) (*api.Task, error)
+
+	// List returns all tasks of a user ordered by ID
+	List(ctx context.Context, userID string) ([]api.Task, error)
+
+	// Put creates or replaces tasks of a user
+	Put(ctx context.Context, userID string, tasks ...api.Task) error
+
+	// Delete removes tasks of a user; unknown IDs are ignored
+	Delete(ctx context.Context, userID string, ids ...string) error
+}
+
+// ProjectRepository stores the mirrored projects of each user
+type ProjectRepository interface {
+	// Get returns a project of a user, or ErrNotFound
+	Get(ctx context.Context, userID, id string) (*api.Project, error)
+
+	// List returns all projects of a user ordered by ID
+	List(ctx context.Context, userID string) ([]api.Project, error)
+
+	// Put creates or replaces projects of a user
+	Put(ctx context.Context, userID string, projects ...api.Project) error
+
+	// Delete removes projects of a user; unknown IDs are ignored
+	Delete(ctx context.Context, userID string, ids ...string) error
+}
+
+// EventRepository stores the event log, including the raw deliveries; it backs events.Log
+type EventRepository interface {
+	events.Store
+
+	// Prune deletes all but the newest keep events and returns how many it deleted
+	Prune(ctx context.Context, keep int) (int, error)
+}
+
+// JobRepository stores the locks and run history of the scheduled jobs; it backs the scheduler
+// of every instance sharing the database
+type JobRepository interface {
+	scheduler.Locker
+	scheduler.History
+}
+
+// Repositories gives access to every repository, on the database or within a transaction
+type Repositories interface {
+	Users() UserRepository
+	Accounts() tenant.Registry
+	APIKeys() auth.KeyStore
+	Tokens() TokenRepository
+	Tasks() TaskRepository
+	Projects() ProjectRepository
+	Mirror() mirror.Store
+	Subscribers() fanout.Store
+	Events() EventRepository
+	Stats() stats.Store
+	Jobs() JobRepository
+}
+
+// DB is an open SQLite or PostgreSQL database
+type DB struct {
+	sql     *sql.DB
+	dialect *dialect
+	now     func() time.Time
+}
+
+// Open connects to a database. URLs of the form sqlite:PATH open a SQLite file, where
+// sqlite::memory: is a private in-memory database; postgres:// and postgresql:// URLs open a
+// PostgreSQL database. Open does not apply migrations.
+func Open(url string) (*DB, error) {
+	d, dsn, err := dialectOf(url)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", d.name, err)
+	}
+	d.configure(conn)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s database: %w", d.name, err)
+	}
+	return &DB{sql: conn, dialect: d, now: time.Now}, nil
+}
+
+// Close closes the database
+func (db *DB) Close() error {
+	return db.sql.Close()
+}
+
+// Driver returns the kind of database, "sqlite" or "postgres"
+func (db *DB) Driver() string {
+	return db.dialect.name
+}
+
+// Users returns the user repository
+func (db *DB) Users() UserRepository { return db.conn(db.sql).Users() }
+
+// Accounts returns the account registry
+func (db *DB) Accounts() tenant.Registry { return db.conn(db.sql).Accounts() }
+
+// APIKeys returns the API key store
+func (db *DB) APIKeys() auth.KeyStore { return db.conn(db.sql).APIKeys() }
+
+// Tokens returns the token repository
+func (db *DB) Tokens() TokenRepository { return db.conn(db.sql).Tokens() }
+
+// Tasks returns the task repository
+func (db *DB) Tasks() TaskRepository { return db.conn(db.sql).Tasks() }
+
+// Projects returns the project repository
+func (db *DB) Projects() ProjectRepository { return db.conn(db.sql).Projects() }
+
+// Mirror returns the mirror store, which keeps its tasks and projects in the tables of Tasks
+// and Projects
+func (db *DB) Mirror() mirror.Store { return db.conn(db.sql).Mirror() }
+
+// Subscribers returns the webhook subscriber store
+func (db *DB) Subscribers() fanout.Store { return db.conn(db.sql).Subscribers() }
+
+// Events returns the event repository
+func (db *DB) Events() EventRepository { return db.conn(db.sql).Events() }
+
+// Stats returns the statistics store
+func (db *DB) Stats() stats.Store { return db.conn(db.sql).Stats() }
+
+// Jobs returns the job lock and history repository
+func (db *DB) Jobs() JobRepository { return db.conn(db.sql).Jobs() }
+
+// conn binds the repositories to a connection or transaction
+func (db *DB) conn(q querier) *conn {
+	return &conn{q: q, dialect: db.dialect, now: db.now}
+}
+
+// Tx is an open transaction. Its repositories see and make changes within the transaction.
+type Tx struct {
+	*conn
+	tx *sql.Tx
+}
+
+// Begin starts a transaction, which must be ended with Commit or Rollback
+func (db *DB) Begin(ctx context.Context) (*Tx, error) {
+	tx, err := db.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Tx{conn: db.conn(tx), tx: tx}, nil
+}
+
+// Commit commits the transaction
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback aborts the transaction; it does nothing once the transaction was committed
+func (tx *Tx) Rollback() error {
+	err := tx.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+// InTx runs fn in a transaction, which is committed if fn returns nil and rolled back if it
+// returns an error or panics. fn must only use the repositories it is given: SQLite allows a
+// single connection, so using the DB's own repositories inside fn blocks.
+func (db *DB) InTx(ctx context.Context, fn func(tx Repositories) error) (err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
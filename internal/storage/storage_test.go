@@ -0,0 +1,736 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/auth"
+	"cherry_backend/internal/events"
+	"cherry_backend/internal/fanout"
+	"cherry_backend/internal/mirror"
+	"cherry_backend/internal/scheduler"
+	"cherry_backend/internal/stats"
+	"cherry_backend/internal/tenant"
+	"cherry_backend/pkg/api"
+)
+
+// postgresURLEnv names the PostgreSQL server the tests also run against; without it the
+// PostgreSQL variants are skipped, except in CI, where they fail
+const postgresURLEnv = "CHERRY_TEST_POSTGRES_URL"
+
+// forEachBackend runs a test against a freshly migrated database of every available backend
+func forEachBackend(t *testing.T, test func(t *testing.T, db *DB)) {
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, openTestDB(t, "sqlite:"+filepath.Join(t.TempDir(), "cherry.db")))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, openTestDB(t, postgresTestURL(t)))
+	})
+}
+
+// openTestDB opens and migrates a database that is closed when the test ends
+func openTestDB(t *testing.T, url string) *DB {
+	t.Helper()
+	db, err := Open(url)
+	if err != nil {
+		t.Fatalf("Open(%q) failed: %v", url, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	return db
+}
+
+// postgresTestURL creates a schema of its own for the test on the server named by
+// CHERRY_TEST_POSTGRES_URL and returns a URL using it; the schema is dropped when the test ends
+func postgresTestURL(t *testing.T) string {
+	t.Helper()
+	base := os.Getenv(postgresURLEnv)
+	if base == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s must be set in CI", postgresURLEnv)
+		}
+		t.Skipf("%s is not set", postgresURLEnv)
+	}
+	admin, err := sql.Open("postgres", base)
+	if err != nil {
+		t.Fatalf("failed to connect to PostgreSQL: %v", err)
+	}
+	defer admin.Close()
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "cherry_test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if admin, err := sql.Open("postgres", base); err == nil {
+			admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+			admin.Close()
+		}
+	})
+
+	parsed, err := url.Parse(base)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", postgresURLEnv, err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// TestOpen tests recognizing database URLs
+func TestOpen(t *testing.T) {
+	for _, url := range []string{"", "mysql://localhost/cherry", "sqlite:", "cherry.db"} {
+		if _, err := Open(url); !errors.Is(err, ErrUnsupportedURL) {
+			t.Errorf("Open(%q) = %v, want ErrUnsupportedURL", url, err)
+		}
+	}
+	db, err := Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf("Open(sqlite::memory:) failed: %v", err)
+	}
+	defer db.Close()
+	if db.Driver() != "sqlite" {
+		t.Errorf("Driver() = %q, want sqlite", db.Driver())
+	}
+}
+
+// TestRebind tests rewriting placeholders for PostgreSQL
+func TestRebind(t *testing.T) {
+	query := `SELECT a FROM t WHERE b = ? AND c IN (?, ?)`
+	if got := sqlite.rebind(query); got != query {
+		t.Errorf("sqlite rebind = %q", got)
+	}
+	if got := postgres.rebind(query); got != `SELECT a FROM t WHERE b = $1 AND c IN ($2, $3)` {
+		t.Errorf("postgres rebind = %q", got)
+	}
+}
+
+// TestMigrate tests that migrations are applied once and recorded
+func TestMigrate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		migrations, err := db.Migrations(ctx)
+		if err != nil {
+			t.Fatalf("Migrations failed: %v", err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != 1 {
+			t.Fatalf("Migrations() = %+v, want the initial schema first", migrations)
+		}
+		for _, migration := range migrations {
+			if migration.AppliedAt.IsZero() {
+				t.Errorf("migration %d is not applied", migration.Version)
+			}
+		}
+
+		// Migrating again applies nothing
+		if applied, err := db.Migrate(ctx); err != nil || len(applied) != 0 {
+			t.Errorf("second Migrate() = %v, %v, want nothing applied", applied, err)
+		}
+		if pending, err := db.Pending(ctx); err != nil || len(pending) != 0 {
+			t.Errorf("Pending() = %v, %v, want none", pending, err)
+		}
+	})
+}
+
+// TestMigrationFiles tests that every dialect has the same migration versions
+func TestMigrationFiles(t *testing.T) {
+	sqliteMigrations, err := sqlite.migrations()
+	if err != nil {
+		t.Fatalf("sqlite migrations: %v", err)
+	}
+	postgresMigrations, err := postgres.migrations()
+	if err != nil {
+		t.Fatalf("postgres migrations: %v", err)
+	}
+	if len(sqliteMigrations) != len(postgresMigrations) {
+		t.Fatalf("%d sqlite migrations, %d postgres migrations", len(sqliteMigrations), len(postgresMigrations))
+	}
+	for i := range sqliteMigrations {
+		if sqliteMigrations[i].Version != postgresMigrations[i].Version || sqliteMigrations[i].Name != postgresMigrations[i].Name {
+			t.Errorf("migration %d differs: %s vs %s", i, sqliteMigrations[i].Name, postgresMigrations[i].Name)
+		}
+	}
+}
+
+// TestUsersAndTokens tests storing users and their tokens
+func TestUsersAndTokens(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		users, tokens := db.Users(), db.Tokens()
+
+		if _, err := users.Get(ctx, "1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get() of an unknown user = %v, want ErrNotFound", err)
+		}
+		if err := tokens.Put(ctx, "1", "secret"); err == nil {
+			t.Error("Put() of a token for an unknown user succeeded")
+		}
+
+		if err := users.Put(ctx, &User{ID: "1", Email: "ada@example.com", Timezone: "Europe/London"}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		created, err := users.Get(ctx, "1")
+		if err != nil || created.Email != "ada@example.com" || created.CreatedAt.IsZero() {
+			t.Fatalf("Get() = %+v, %v", created, err)
+		}
+		if err := users.Put(ctx, &User{ID: "1", Email: "ada@example.org", FullName: "Ada"}); err != nil {
+			t.Fatalf("second Put failed: %v", err)
+		}
+		updated, _ := users.Get(ctx, "1")
+		if updated.Email != "ada@example.org" || updated.FullName != "Ada" || !updated.CreatedAt.Equal(created.CreatedAt) {
+			t.Errorf("updated user = %+v, want new fields and the original creation time", updated)
+		}
+		users.Put(ctx, &User{ID: "2"})
+		if list, err := users.List(ctx); err != nil || len(list) != 2 || list[0].ID != "1" {
+			t.Errorf("List() = %+v, %v", list, err)
+		}
+
+		if err := tokens.Put(ctx, "1", "secret"); err != nil {
+			t.Fatalf("Put token failed: %v", err)
+		}
+		tokens.Put(ctx, "1", "rotated")
+		if token, err := tokens.Get(ctx, "1"); err != nil || token != "rotated" {
+			t.Errorf("Get token = %q, %v, want the rotated token", token, err)
+		}
+		if ids, err := tokens.Users(ctx); err != nil || len(ids) != 1 || ids[0] != "1" {
+			t.Errorf("Users() = %v, %v", ids, err)
+		}
+
+		// Deleting a user deletes its token
+		if err := users.Delete(ctx, "1"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := tokens.Get(ctx, "1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("token of a deleted user = %v, want ErrNotFound", err)
+		}
+		if err := users.Delete(ctx, "1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second Delete() = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// TestAccounts tests the account registry on the users table
+func TestAccounts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		accounts := db.Accounts()
+
+		if _, err := accounts.Get(ctx, "1"); !errors.Is(err, tenant.ErrUnknownUser) {
+			t.Errorf("Get() of an unknown user = %v, want ErrUnknownUser", err)
+		}
+		if err := accounts.Put(ctx, &tenant.Account{UserID: "1", Status: "bogus"}); !errors.Is(err, tenant.ErrInvalidAccount) {
+			t.Errorf("Put() of an invalid account = %v, want ErrInvalidAccount", err)
+		}
+
+		// Users stored before accounts existed are active with default settings
+		db.Users().Put(ctx, &User{ID: "1", Email: "ada@example.com", Timezone: "Europe/London"})
+		account, err := accounts.Get(ctx, "1")
+		if err != nil || !account.Active() || account.Email != "ada@example.com" || account.Settings.Timezone != "Europe/London" {
+			t.Fatalf("Get() of a plain user = %+v, %v", account, err)
+		}
+
+		account.Status = tenant.StatusDisabled
+		account.Settings.Features = map[string]bool{tenant.FeatureAutomation: false}
+		account.Settings.Notifications.Digest = tenant.DigestWeekly
+		if err := accounts.Put(ctx, account); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		stored, _ := accounts.Get(ctx, "1")
+		if stored.Active() || stored.HasFeature(tenant.FeatureAutomation) || stored.Settings.Notifications.Digest != tenant.DigestWeekly {
+			t.Errorf("stored account = %+v", stored)
+		}
+		if !stored.CreatedAt.Equal(account.CreatedAt) || stored.UpdatedAt.IsZero() {
+			t.Errorf("timestamps = %v, %v, want those returned by Put", stored.CreatedAt, stored.UpdatedAt)
+		}
+
+		// Updating the user keeps the account's status and settings
+		db.Users().Put(ctx, &User{ID: "1", Email: "ada@example.org"})
+		if stored, _ := accounts.Get(ctx, "1"); stored.Active() || stored.Email != "ada@example.org" {
+			t.Errorf("account after a user update = %+v", stored)
+		}
+
+		accounts.Put(ctx, &tenant.Account{UserID: "2"})
+		if list, err := accounts.List(ctx); err != nil || len(list) != 2 || list[1].UserID != "2" {
+			t.Errorf("List() = %+v, %v", list, err)
+		}
+		if err := accounts.Delete(ctx, "2"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := accounts.Delete(ctx, "2"); !errors.Is(err, tenant.ErrUnknownUser) {
+			t.Errorf("second Delete() = %v, want ErrUnknownUser", err)
+		}
+	})
+}
+
+// TestAPIKeys tests storing API keys, which are removed with their user
+func TestAPIKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		keys := db.APIKeys()
+		db.Users().Put(ctx, &User{ID: "1"})
+
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		expires := now.Add(time.Hour)
+		plaintext, key, err := auth.NewKey("1", "ci", []string{auth.ScopeTasksRead, auth.ScopeEventsRead}, &expires, now)
+		if err != nil {
+			t.Fatalf("NewKey failed: %v", err)
+		}
+		if err := keys.Create(ctx, key); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := keys.Get(ctx, "missing"); !errors.Is(err, auth.ErrKeyNotFound) {
+			t.Errorf("Get() of a missing key = %v, want ErrKeyNotFound", err)
+		}
+		stored, err := keys.Get(ctx, key.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if stored.Name != "ci" || len(stored.Scopes) != 2 || stored.Hash != key.Hash || !stored.CreatedAt.Equal(now) || stored.ExpiresAt == nil || !stored.ExpiresAt.Equal(expires) {
+			t.Errorf("stored key = %+v, want %+v", stored, key)
+		}
+		authenticator := &auth.Authenticator{Keys: keys, Now: func() time.Time { return now }}
+		if principal, err := authenticator.Authenticate(ctx, plaintext); err != nil || principal.UserID != "1" {
+			t.Errorf("Authenticate() = %+v, %v, want user 1", principal, err)
+		}
+
+		_, forever, _ := auth.NewKey("1", "", []string{auth.ScopeKeysWrite}, nil, now.Add(time.Minute))
+		keys.Create(ctx, forever)
+		if list, err := keys.List(ctx, "1"); err != nil || len(list) != 2 || list[1].ID != forever.ID || list[1].ExpiresAt != nil {
+			t.Errorf("List() = %+v, %v", list, err)
+		}
+
+		if err := keys.Delete(ctx, "2", key.ID); !errors.Is(err, auth.ErrKeyNotFound) {
+			t.Errorf("Delete() of another user's key = %v, want ErrKeyNotFound", err)
+		}
+		if err := keys.Delete(ctx, "1", key.ID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		db.Users().Delete(ctx, "1")
+		if list, _ := keys.List(ctx, "1"); len(list) != 0 {
+			t.Errorf("keys after deleting the user = %+v, want none", list)
+		}
+	})
+}
+
+// TestTasksAndProjects tests storing mirrored resources per user
+func TestTasksAndProjects(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		tasks := db.Tasks()
+
+		err := tasks.Put(ctx, "1",
+			api.Task{ID: "b", ProjectID: "p", Content: "Second", Labels: []string{"x"}, Due: &api.Due{Date: "2024-03-01"}},
+			api.Task{ID: "a", Content: "First", Priority: 4})
+		if err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		tasks.Put(ctx, "2", api.Task{ID: "a", Content: "Someone else's"})
+		tasks.Put(ctx, "1", api.Task{ID: "a", Content: "First, edited", Checked: true})
+
+		list, err := tasks.List(ctx, "1")
+		if err != nil || len(list) != 2 || list[0].Content != "First, edited" || !list[0].Checked || list[1].Due.Date != "2024-03-01" {
+			t.Fatalf("List() = %+v, %v", list, err)
+		}
+		if task, err := tasks.Get(ctx, "1", "b"); err != nil || len(task.Labels) != 1 {
+			t.Errorf("Get() = %+v, %v", task, err)
+		}
+		if err := tasks.Delete(ctx, "1", "a", "missing"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := tasks.Get(ctx, "1", "a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get() of a deleted task = %v, want ErrNotFound", err)
+		}
+		if other, _ := tasks.List(ctx, "2"); len(other) != 1 {
+			t.Errorf("tasks of user 2 = %+v, want them untouched", other)
+		}
+
+		projects := db.Projects()
+		projects.Put(ctx, "1", api.Project{ID: "p", Name: "Work", IsArchived: true})
+		if project, err := projects.Get(ctx, "1", "p"); err != nil || project.Name != "Work" || !project.IsArchived {
+			t.Errorf("Get() project = %+v, %v", project, err)
+		}
+		if list, _ := projects.List(ctx, "2"); len(list) != 0 {
+			t.Errorf("projects of user 2 = %+v, want none", list)
+		}
+	})
+}
+
+// TestMirror tests applying syncs to the stored mirror and refusing those that lost a race
+func TestMirror(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		store := db.Mirror()
+
+		full := &api.SyncResponse{SyncToken: "t1", FullSync: true,
+			Items:    []api.Task{{ID: "1", Content: "Write report", ProjectID: "p"}, {ID: "2", Content: "Buy milk"}},
+			Projects: []api.Project{{ID: "p", Name: "Work"}},
+			Sections: []api.Section{{ID: "s", ProjectID: "p", Name: "Doing"}},
+			Labels:   []api.Label{{ID: "l", Name: "urgent"}},
+			Notes:    []api.Comment{{ID: "n", ItemID: "1", Content: "Draft attached"}},
+		}
+		if err := store.Apply(ctx, "1", "", full); err != nil {
+			t.Fatalf("Apply(full) failed: %v", err)
+		}
+		if err := store.Apply(ctx, "1", "", full); !errors.Is(err, mirror.ErrSyncTokenConflict) {
+			t.Errorf("second first sync = %v, want ErrSyncTokenConflict", err)
+		}
+
+		delta := &api.SyncResponse{SyncToken: "t2",
+			Items: []api.Task{{ID: "1", Content: "Write the report", ProjectID: "p"}, {ID: "2", IsDeleted: true}},
+		}
+		if err := store.Apply(ctx, "1", "t1", delta); err != nil {
+			t.Fatalf("Apply(delta) failed: %v", err)
+		}
+		if err := store.Apply(ctx, "1", "t1", delta); !errors.Is(err, mirror.ErrSyncTokenConflict) {
+			t.Errorf("Apply() with a stale token = %v, want ErrSyncTokenConflict", err)
+		}
+
+		snapshot, err := store.Snapshot(ctx, "1")
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		if snapshot.SyncToken != "t2" || len(snapshot.Tasks) != 1 || snapshot.Tasks[0].Content != "Write the report" {
+			t.Errorf("snapshot = %+v, want the updated task at t2", snapshot)
+		}
+		if len(snapshot.Projects) != 1 || len(snapshot.Sections) != 1 || len(snapshot.Labels) != 1 || len(snapshot.Notes) != 1 || snapshot.Notes[0].Content != "Draft attached" {
+			t.Errorf("snapshot = %+v, want the project, section, label and note", snapshot)
+		}
+		if tasks, _ := db.Tasks().List(ctx, "1"); len(tasks) != 1 {
+			t.Errorf("task repository has %d tasks, want the mirrored one", len(tasks))
+		}
+
+		// A full sync replaces the state of the user only
+		if err := store.Apply(ctx, "2", "", &api.SyncResponse{SyncToken: "u1", FullSync: true, Items: []api.Task{{ID: "9"}}}); err != nil {
+			t.Fatalf("Apply(user 2) failed: %v", err)
+		}
+		if err := store.Apply(ctx, "1", "t2", &api.SyncResponse{SyncToken: "t3", FullSync: true, Labels: []api.Label{{ID: "l", Name: "urgent"}}}); err != nil {
+			t.Fatalf("Apply(full) failed: %v", err)
+		}
+		if snapshot, _ := store.Snapshot(ctx, "1"); len(snapshot.Tasks) != 0 || len(snapshot.Projects) != 0 || len(snapshot.Labels) != 1 {
+			t.Errorf("snapshot after a full sync = %+v, want only the label", snapshot)
+		}
+		if snapshot, _ := store.Snapshot(ctx, "2"); len(snapshot.Tasks) != 1 {
+			t.Errorf("snapshot of user 2 = %+v, want its task", snapshot)
+		}
+
+		if users, err := store.Users(ctx); err != nil || len(users) != 2 || users[0] != "1" || users[1] != "2" {
+			t.Errorf("Users() = %v, %v, want [1 2]", users, err)
+		}
+		if token, err := store.SyncToken(ctx, "3"); err != nil || token != "" {
+			t.Errorf("SyncToken() of an unknown user = %q, %v", token, err)
+		}
+		if snapshot, err := store.Snapshot(ctx, "3"); err != nil || snapshot.SyncToken != "" || len(snapshot.Tasks) != 0 {
+			t.Errorf("Snapshot() of an unknown user = %+v, %v", snapshot, err)
+		}
+	})
+}
+
+// TestSubscribers tests storing, updating and deleting webhook subscribers
+func TestSubscribers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		subscribers := db.Subscribers()
+
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		all := &fanout.Subscriber{ID: "a", URL: "https://a.example.com/hook", Secret: "secret-a", CreatedAt: now}
+		some := &fanout.Subscriber{ID: "b", URL: "https://b.example.com/hook", Events: []string{"item:*", "note:added"}, Secret: "secret-b", CreatedAt: now.Add(time.Minute)}
+		for _, subscriber := range []*fanout.Subscriber{some, all} {
+			if err := subscribers.Put(ctx, subscriber); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		some.Paused = true
+		if err := subscribers.Put(ctx, some); err != nil {
+			t.Fatalf("second Put failed: %v", err)
+		}
+
+		list, err := subscribers.List(ctx)
+		if err != nil || len(list) != 2 {
+			t.Fatalf("List() = %+v, %v, want both subscribers", list, err)
+		}
+		if got := list[0]; got.ID != "a" || len(got.Events) != 0 || got.Secret != "secret-a" || got.Paused || !got.CreatedAt.Equal(now) {
+			t.Errorf("first subscriber = %+v, want %+v", got, all)
+		}
+		if got := list[1]; got.ID != "b" || len(got.Events) != 2 || got.Events[0] != "item:*" || !got.Paused {
+			t.Errorf("second subscriber = %+v, want %+v", got, some)
+		}
+
+		if err := subscribers.Delete(ctx, "a"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := subscribers.Delete(ctx, "a"); !errors.Is(err, fanout.ErrNotFound) {
+			t.Errorf("second Delete() = %v, want ErrNotFound", err)
+		}
+		if list, _ := subscribers.List(ctx); len(list) != 1 || list[0].ID != "b" {
+			t.Errorf("List() after Delete = %+v, want only b", list)
+		}
+	})
+}
+
+// TestEvents tests the event log and that it keeps the raw deliveries
+func TestEvents(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		store := db.Events()
+		received := time.Date(2024, 3, 1, 9, 30, 0, 123456000, time.UTC)
+
+		if id, err := store.LastID(ctx); err != nil || id != 0 {
+			t.Errorf("LastID() of an empty log = %d, %v", id, err)
+		}
+		first := &events.Event{Name: "item:added", UserID: "1", Resource: "item", ResourceID: "7",
+			Data: json.RawMessage(`{"id":"7"}`), Version: "9", ReceivedAt: received,
+			Delivery: &events.Delivery{Header: map[string]string{"User-Agent": "Todoist-Webhooks"}, Body: []byte(`{"raw": true}`)}}
+		second := &events.Event{Name: "item:added", UserID: "2", Resource: "item", ReceivedAt: received}
+		third := &events.Event{Name: "item:completed", UserID: "1", Resource: "item", ReceivedAt: received}
+		for _, event := range []*events.Event{first, second, third} {
+			if err := store.Append(ctx, event); err != nil {
+				t.Fatalf("Append failed: %v", err)
+			}
+		}
+		if first.ID == 0 || second.ID <= first.ID || third.ID <= second.ID {
+			t.Fatalf("IDs = %d, %d, %d, want increasing", first.ID, second.ID, third.ID)
+		}
+
+		all, err := store.Since(ctx, "", 0, 0)
+		if err != nil || len(all) != 3 {
+			t.Fatalf("Since() = %v, %v", all, err)
+		}
+		got := all[0]
+		if got.Name != "item:added" || got.ResourceID != "7" || string(got.Data) != `{"id":"7"}` || !got.ReceivedAt.Equal(received) {
+			t.Errorf("first event = %+v", got)
+		}
+		if got.Delivery == nil || got.Delivery.Header["User-Agent"] != "Todoist-Webhooks" || string(got.Delivery.Body) != `{"raw": true}` {
+			t.Errorf("delivery = %+v, want the raw request", got.Delivery)
+		}
+		if all[1].Delivery != nil || all[1].Data != nil {
+			t.Errorf("second event = %+v, want no data or delivery", all[1])
+		}
+
+		if user1, _ := store.Since(ctx, "1", first.ID, 10); len(user1) != 1 || user1[0].ID != third.ID {
+			t.Errorf("Since(user 1, after first) = %v", user1)
+		}
+		if limited, _ := store.Since(ctx, "", 0, 2); len(limited) != 2 {
+			t.Errorf("Since(limit 2) returned %d events", len(limited))
+		}
+		if id, _ := store.LastID(ctx); id != third.ID {
+			t.Errorf("LastID() = %d, want %d", id, third.ID)
+		}
+
+		// Pruning keeps the newest events and the IDs keep increasing
+		if deleted, err := store.Prune(ctx, 5); err != nil || deleted != 0 {
+			t.Errorf("Prune(5) of 3 events = %d, %v, want none deleted", deleted, err)
+		}
+		if deleted, err := store.Prune(ctx, 2); err != nil || deleted != 1 {
+			t.Errorf("Prune(2) = %d, %v, want the first event deleted", deleted, err)
+		}
+		if kept, _ := store.Since(ctx, "", 0, 0); len(kept) != 2 || kept[0].ID != second.ID {
+			t.Errorf("events after pruning = %v, want the second and third", kept)
+		}
+		if id, _ := store.LastID(ctx); id != third.ID {
+			t.Errorf("LastID() after pruning = %d, want %d", id, third.ID)
+		}
+	})
+}
+
+// TestStats tests adding to the stored statistics and rebuilding streaks from them
+func TestStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		store := db.Stats()
+
+		entries := []stats.Entry{
+			{Date: "2024-03-01", ProjectID: "p", Labels: []string{"home"}, Counts: stats.Counts{Added: 1}},
+			{Date: "2024-03-01", ProjectID: "p", Labels: []string{"home", "work"}, Counts: stats.Counts{Completed: 1, Timed: 1, Duration: 2 * time.Hour}},
+			{ID: "a", Date: "2024-03-03", ProjectID: "q", Counts: stats.Counts{Completed: 1, CompletedLate: 1}},
+			// A retry of the same webhook is ignored
+			{ID: "a", Date: "2024-03-03", ProjectID: "q", Counts: stats.Counts{Completed: 1, CompletedLate: 1}},
+			// A late webhook for a day before the last one joins the two runs
+			{Date: "2024-03-02", Labels: []string{"work"}, Counts: stats.Counts{Completed: 1, Timed: 1, Duration: time.Hour}},
+		}
+		for _, entry := range entries {
+			if err := store.Record(ctx, "1", entry); err != nil {
+				t.Fatalf("Record(%+v) failed: %v", entry, err)
+			}
+		}
+		if err := store.Record(ctx, "1", stats.Entry{Date: "March 1"}); !errors.Is(err, stats.ErrInvalidDate) {
+			t.Errorf("Record() with an invalid date = %v, want ErrInvalidDate", err)
+		}
+
+		days, err := store.Days(ctx, "1", "2024-03-01", "2024-03-02")
+		if err != nil || len(days) != 2 {
+			t.Fatalf("Days() = %+v, %v, want 2 days", days, err)
+		}
+		if want := (stats.Counts{Completed: 1, Added: 1, Timed: 1, Duration: 2 * time.Hour}); days[0].Date != "2024-03-01" || days[0].Counts != want {
+			t.Errorf("first day = %+v, want %+v", days[0], want)
+		}
+
+		labels, err := store.Groups(ctx, "1", stats.GroupLabel, "2024-03-01", "2024-03-31")
+		if err != nil || len(labels) != 2 {
+			t.Fatalf("Groups(label) = %+v, %v", labels, err)
+		}
+		if labels[1].Key != "work" || labels[1].Completed != 2 || labels[1].AverageCompletion() != 90*time.Minute {
+			t.Errorf("work label = %+v, want 2 completions taking 90m on average", labels[1])
+		}
+		if projects, _ := store.Groups(ctx, "1", stats.GroupProject, "2024-03-02", "2024-03-31"); len(projects) != 1 || projects[0].Key != "q" || projects[0].Completed != 1 {
+			t.Errorf("Groups(project) from March 2 = %+v, want only q with a completion", projects)
+		}
+
+		want := stats.Streak{Current: 3, Last: "2024-03-03", Longest: 3, LongestEnd: "2024-03-03"}
+		if streak, err := store.Streak(ctx, "1"); err != nil || streak != want {
+			t.Errorf("Streak() = %+v, %v, want %+v", streak, err, want)
+		}
+		if streak, err := store.Streak(ctx, "2"); err != nil || streak != (stats.Streak{}) {
+			t.Errorf("Streak() of another user = %+v, %v, want none", streak, err)
+		}
+
+		// Pruning forgets the IDs recorded before a time, so those webhooks count again
+		db.now = func() time.Time { return time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC) }
+		old := stats.Entry{ID: "b", Date: "2024-03-04", Counts: stats.Counts{Added: 1}}
+		if err := db.Stats().Record(ctx, "1", old); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+		if pruned, err := db.Stats().Prune(ctx, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)); err != nil || pruned != 1 {
+			t.Errorf("Prune() = %d, %v, want the ID recorded on March 10", pruned, err)
+		}
+		for _, entry := range []stats.Entry{old, entries[2]} {
+			if err := db.Stats().Record(ctx, "1", entry); err != nil {
+				t.Fatalf("Record() after pruning failed: %v", err)
+			}
+		}
+		days, _ = db.Stats().Days(ctx, "1", "2024-03-03", "2024-03-04")
+		if len(days) != 2 || days[0].Completed != 1 || days[1].Added != 2 {
+			t.Errorf("days after pruning = %+v, want the pruned webhook counted again and the other not", days)
+		}
+	})
+}
+
+// TestJobs tests job leases, claiming each slot once and the run history
+func TestJobs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		db.now = func() time.Time { return now }
+		jobs := db.Jobs()
+
+		lock := func(owner string, slot time.Time) bool {
+			t.Helper()
+			ok, err := jobs.Lock(ctx, "digests", owner, slot, time.Minute)
+			if err != nil {
+				t.Fatalf("Lock() failed: %v", err)
+			}
+			return ok
+		}
+		if !lock("a", now) {
+			t.Fatalf("first Lock() failed")
+		}
+		if lock("b", now.Add(time.Hour)) || lock("b", time.Time{}) {
+			t.Errorf("Lock() succeeded while a holds the lock")
+		}
+		if err := jobs.Unlock(ctx, "digests", "a"); err != nil {
+			t.Fatalf("Unlock() failed: %v", err)
+		}
+		if lock("b", now) {
+			t.Errorf("Lock() claimed the same slot twice")
+		}
+		if !lock("b", time.Time{}) {
+			t.Errorf("manual Lock() failed after unlocking")
+		}
+		// The lease of b expires
+		now = now.Add(2 * time.Minute)
+		if !lock("a", now) {
+			t.Errorf("Lock() failed after the lease expired")
+		}
+
+		slot := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		runs := []scheduler.Run{
+			{Job: "digests", Trigger: scheduler.TriggerSchedule, Instance: "a", Slot: slot, Status: scheduler.StatusSucceeded},
+			{Job: "digests", Trigger: scheduler.TriggerManual, Instance: "b", Status: scheduler.StatusFailed, Error: "boom"},
+			{Job: "retention", Trigger: scheduler.TriggerMissed, Instance: "a", Slot: slot, Status: scheduler.StatusSucceeded},
+		}
+		for i := range runs {
+			runs[i].StartedAt, runs[i].FinishedAt = now, now.Add(time.Second)
+			if err := jobs.Record(ctx, &runs[i]); err != nil || runs[i].ID == 0 {
+				t.Fatalf("Record() = %v, ID %d", err, runs[i].ID)
+			}
+		}
+
+		listed, err := jobs.List(ctx, "digests", 10)
+		if err != nil || len(listed) != 2 {
+			t.Fatalf("List() = %+v, %v, want 2 runs", listed, err)
+		}
+		if listed[0] != runs[1] || listed[1] != runs[0] {
+			t.Errorf("List() = %+v, want %+v newest first", listed, runs[:2])
+		}
+		if all, _ := jobs.List(ctx, "", 0); len(all) != 3 {
+			t.Errorf("List() of all jobs = %d runs, want 3", len(all))
+		}
+		if last, err := jobs.Last(ctx, "digests"); err != nil || last == nil || last.ID != runs[0].ID {
+			t.Errorf("Last() = %+v, %v, want the scheduled run", last, err)
+		}
+		if last, err := jobs.Last(ctx, "nightly"); err != nil || last != nil {
+			t.Errorf("Last() of a job that never ran = %+v, %v", last, err)
+		}
+	})
+}
+
+// TestInTx tests that transactions commit on success and roll back on errors and panics
+func TestInTx(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		failure := errors.New("failure")
+
+		err := db.InTx(ctx, func(tx Repositories) error {
+			if err := tx.Users().Put(ctx, &User{ID: "rolled-back"}); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("InTx() = %v, want the returned error", err)
+		}
+		if _, err := db.Users().Get(ctx, "rolled-back"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("user of a failed transaction = %v, want ErrNotFound", err)
+		}
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("InTx swallowed a panic")
+				}
+			}()
+			db.InTx(ctx, func(tx Repositories) error {
+				tx.Users().Put(ctx, &User{ID: "panicked"})
+				panic("boom")
+			})
+		}()
+		if _, err := db.Users().Get(ctx, "panicked"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("user of a panicked transaction = %v, want ErrNotFound", err)
+		}
+
+		err = db.InTx(ctx, func(tx Repositories) error {
+			if err := tx.Users().Put(ctx, &User{ID: "1"}); err != nil {
+				return err
+			}
+			return tx.Tokens().Put(ctx, "1", "secret")
+		})
+		if err != nil {
+			t.Fatalf("InTx failed: %v", err)
+		}
+		if token, err := db.Tokens().Get(ctx, "1"); err != nil || token != "secret" {
+			t.Errorf("token of a committed transaction = %q, %v", token, err)
+		}
+	})
+}
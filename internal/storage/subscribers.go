@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cherry_backend/internal/fanout"
+)
+
+// subscriberRepository implements fanout.Store. Event names are stored space-separated.
+type subscriberRepository struct {
+	*conn
+}
+
+const subscriberColumns = `id, url, events, secret, paused, created_at`
+
+func (r *subscriberRepository) List(ctx context.Context) ([]fanout.Subscriber, error) {
+	rows, err := r.query(ctx, `SELECT `+subscriberColumns+` FROM subscribers ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	subscribers := []fanout.Subscriber{}
+	for rows.Next() {
+		var (
+			subscriber fanout.Subscriber
+			events     string
+		)
+		if err := rows.Scan(&subscriber.ID, &subscriber.URL, &events, &subscriber.Secret, &subscriber.Paused, &subscriber.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read subscriber: %w", err)
+		}
+		subscriber.Events = strings.Fields(events)
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers, rows.Err()
+}
+
+func (r *subscriberRepository) Put(ctx context.Context, subscriber *fanout.Subscriber) error {
+	_, err := r.exec(ctx, `
+		INSERT INTO subscribers (`+subscriberColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			url = excluded.url, events = excluded.events, secret = excluded.secret, paused = excluded.paused`,
+		subscriber.ID, subscriber.URL, strings.Join(subscriber.Events, " "), subscriber.Secret, subscriber.Paused,
+		subscriber.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to store subscriber %s: %w", subscriber.ID, err)
+	}
+	return nil
+}
+
+func (r *subscriberRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.exec(ctx, `DELETE FROM subscribers WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscriber %s: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fanout.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// userRepository implements UserRepository
+type userRepository struct {
+	*conn
+}
+
+func (r *userRepository) Get(ctx context.Context, id string) (*User, error) {
+	var user User
+	err := r.queryRow(ctx, `SELECT id, email, full_name, timezone, created_at, updated_at FROM users WHERE id = ?`, id).
+		Scan(&user.ID, &user.Email, &user.FullName, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err, "user "+id)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Put(ctx context.Context, user *User) error {
+	now := r.timestamp()
+	_, err := r.exec(ctx, `
+		INSERT INTO users (id, email, full_name, timezone, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			email = excluded.email, full_name = excluded.full_name, timezone = excluded.timezone, updated_at = excluded.updated_at`,
+		user.ID, user.Email, user.FullName, user.Timezone, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to store user %s: %w", user.ID, err)
+	}
+	return nil
+}
+
+func (r *userRepository) List(ctx context.Context) ([]User, error) {
+	rows, err := r.query(ctx, `SELECT id, email, full_name, timezone, created_at, updated_at FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Email, &user.FullName, &user.Timezone, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *userRepository) Delete(ctx context.Context, id string) error {
+	return deleteOne(ctx, r.conn, `DELETE FROM users WHERE id = ?`, "user "+id, id)
+}
+
+// tokenRepository implements TokenRepository
+type tokenRepository struct {
+	*conn
+}
+
+func (r *tokenRepository) Get(ctx context.Context, userID string) (string, error) {
+	var token string
+	if err := r.queryRow(ctx, `SELECT token FROM tokens WHERE user_id = ?`, userID).Scan(&token); err != nil {
+		return "", notFound(err, "token of user "+userID)
+	}
+	return token, nil
+}
+
+func (r *tokenRepository) Put(ctx context.Context, userID, token string) error {
+	_, err := r.exec(ctx, `
+		INSERT INTO tokens (user_id, token, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token = excluded.token, updated_at = excluded.updated_at`,
+		userID, token, r.timestamp())
+	if err != nil {
+		return fmt.Errorf("failed to store token of user %s: %w", userID, err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) Delete(ctx context.Context, userID string) error {
+	return deleteOne(ctx, r.conn, `DELETE FROM tokens WHERE user_id = ?`, "token of user "+userID, userID)
+}
+
+func (r *tokenRepository) Users(ctx context.Context) ([]string, error) {
+	rows, err := r.query(ctx, `SELECT user_id FROM tokens ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token users: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read token user: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteOne runs a delete statement and returns ErrNotFound if it removed nothing
+func deleteOne(ctx context.Context, c *conn, query, what string, args ...interface{}) error {
+	result, err := c.exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", what, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRegistry implements Registry in memory
+type MemoryRegistry struct {
+	// Now returns the current time for the account timestamps; defaults to time.Now
+	Now func() time.Time
+
+	mu       sync.RWMutex
+	accounts map[string]Account
+}
+
+// NewMemoryRegistry creates an empty in-memory registry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{Now: time.Now, accounts: make(map[string]Account)}
+}
+
+// Get returns the account of a Todoist user
+func (r *MemoryRegistry) Get(ctx context.Context, userID string) (*Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	account, ok := r.accounts[userID]
+	if !ok {
+		return nil, fmt.Errorf("user %s: %w", userID, ErrUnknownUser)
+	}
+	return copyAccount(account), nil
+}
+
+// Put creates or updates an account
+func (r *MemoryRegistry) Put(ctx context.Context, account *Account) error {
+	if err := account.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *copyAccount(*account)
+	stored.UpdatedAt = r.Now().UTC()
+	stored.CreatedAt = stored.UpdatedAt
+	if existing, ok := r.accounts[account.UserID]; ok {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	r.accounts[account.UserID] = stored
+	account.CreatedAt, account.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+	return nil
+}
+
+// List returns every account ordered by user ID
+func (r *MemoryRegistry) List(ctx context.Context) ([]Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	accounts := make([]Account, 0, len(r.accounts))
+	for _, account := range r.accounts {
+		accounts = append(accounts, *copyAccount(account))
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].UserID < accounts[j].UserID })
+	return accounts, nil
+}
+
+// Delete removes an account
+func (r *MemoryRegistry) Delete(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.accounts[userID]; !ok {
+		return fmt.Errorf("user %s: %w", userID, ErrUnknownUser)
+	}
+	delete(r.accounts, userID)
+	return nil
+}
+
+// copyAccount copies an account so that callers cannot change the stored feature map
+func copyAccount(account Account) *Account {
+	if account.Settings.Features != nil {
+		features := make(map[string]bool, len(account.Settings.Features))
+		for feature, enabled := range account.Settings.Features {
+			features[feature] = enabled
+		}
+		account.Settings.Features = features
+	}
+	return &account
+}
@@ -0,0 +1,95 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Policy decides what happens to webhooks of users without an active account
+type Policy string
+
+const (
+	// PolicyOpen accepts webhooks of unknown users and quarantines those of disabled accounts.
+	// It suits single-tenant deployments, where every webhook belongs to the operator.
+	PolicyOpen Policy = "open"
+
+	// PolicyQuarantine quarantines webhooks of unknown users and disabled accounts
+	PolicyQuarantine Policy = "quarantine"
+
+	// PolicyReject refuses webhooks of unknown users and disabled accounts
+	PolicyReject Policy = "reject"
+)
+
+// ParsePolicy parses a policy name; the empty name is PolicyOpen
+func ParsePolicy(name string) (Policy, error) {
+	switch policy := Policy(name); policy {
+	case "":
+		return PolicyOpen, nil
+	case PolicyOpen, PolicyQuarantine, PolicyReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown tenant policy %q (want %s, %s or %s)", name, PolicyOpen, PolicyQuarantine, PolicyReject)
+}
+
+// Enforced reports whether the policy requires every request to belong to an account
+func (p Policy) Enforced() bool {
+	return p != PolicyOpen
+}
+
+// Action is what to do with a webhook
+type Action int
+
+const (
+	// Accept processes the webhook
+	Accept Action = iota
+
+	// Quarantine holds the webhook back until an operator releases or drops it
+	Quarantine
+
+	// Reject refuses the webhook
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Accept:
+		return "accept"
+	case Quarantine:
+		return "quarantine"
+	case Reject:
+		return "reject"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Admit looks up the account of a webhook's user and decides what to do with the webhook.
+// Accepted webhooks of unknown users get an implicit active account with default settings.
+// For any other action the returned error is ErrUnknownUser or ErrDisabled and says why.
+func (p Policy) Admit(ctx context.Context, registry Registry, userID string) (*Account, Action, error) {
+	account, err := registry.Get(ctx, userID)
+	switch {
+	case errors.Is(err, ErrUnknownUser):
+		if p == PolicyOpen {
+			return &Account{UserID: userID, Status: StatusActive}, Accept, nil
+		}
+		return nil, p.action(), err
+	case err != nil:
+		return nil, Reject, err
+	case !account.Active():
+		action := p.action()
+		if p == PolicyOpen {
+			action = Quarantine
+		}
+		return account, action, fmt.Errorf("user %s: %w", userID, ErrDisabled)
+	}
+	return account, Accept, nil
+}
+
+// action is what the policy does with webhooks it does not accept
+func (p Policy) action() Action {
+	if p == PolicyReject {
+		return Reject
+	}
+	return Quarantine
+}
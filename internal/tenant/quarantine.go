@@ -0,0 +1,125 @@
+package tenant
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"cherry_backend/internal/events"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// DefaultQuarantineSize is how many webhooks a Quarantine holds before dropping the oldest
+const DefaultQuarantineSize = 1000
+
+// ErrNotQuarantined is returned for webhooks that are not in the quarantine
+var ErrNotQuarantined = errors.New("webhook not in quarantine")
+
+// Held is a webhook held back by the tenant policy
+type Held struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	EventName  string    `json:"event_name"`
+	Reason     string    `json:"reason"`
+	ReceivedAt time.Time `json:"received_at"`
+
+	// Request and Delivery are what the webhook is processed with when it is released
+	Request  *apiv1.TodoistWebhookRequest `json:"-"`
+	Delivery *events.Delivery             `json:"-"`
+}
+
+// QuarantineStore holds webhooks of unknown users and disabled accounts in memory until an
+// operator releases or drops them
+type QuarantineStore struct {
+	// Size is how many webhooks are held before the oldest is dropped
+	Size int
+
+	// Now returns the current time for ReceivedAt; defaults to time.Now
+	Now func() time.Time
+
+	mu     sync.Mutex
+	held   []*Held
+	nextID uint64
+}
+
+// NewQuarantineStore creates an empty quarantine holding DefaultQuarantineSize webhooks
+func NewQuarantineStore() *QuarantineStore {
+	return &QuarantineStore{Size: DefaultQuarantineSize, Now: time.Now}
+}
+
+// Hold adds a webhook to the quarantine and returns its entry
+func (q *QuarantineStore) Hold(request *apiv1.TodoistWebhookRequest, delivery *events.Delivery, reason error) *Held {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	held := &Held{
+		ID:         strconv.FormatUint(q.nextID, 10),
+		UserID:     request.UserId,
+		EventName:  request.EventName,
+		Reason:     reason.Error(),
+		ReceivedAt: q.Now().UTC(),
+		Request:    request,
+		Delivery:   delivery,
+	}
+	q.held = append(q.held, held)
+	if q.Size > 0 && len(q.held) > q.Size {
+		q.held = append(q.held[:0:0], q.held[len(q.held)-q.Size:]...)
+	}
+	return held
+}
+
+// List returns the held webhooks of a user, oldest first; an empty userID lists every user's
+func (q *QuarantineStore) List(userID string) []Held {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := []Held{}
+	for _, held := range q.held {
+		if userID == "" || held.UserID == userID {
+			result = append(result, *held)
+		}
+	}
+	return result
+}
+
+// Get returns a held webhook
+func (q *QuarantineStore) Get(id string) (*Held, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, held := range q.held {
+		if held.ID == id {
+			copied := *held
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotQuarantined
+}
+
+// Take removes a held webhook from the quarantine and returns it
+func (q *QuarantineStore) Take(id string) (*Held, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, held := range q.held {
+		if held.ID == id {
+			q.held = append(q.held[:i], q.held[i+1:]...)
+			return held, nil
+		}
+	}
+	return nil, ErrNotQuarantined
+}
+
+// Return puts a taken webhook back in its place in the quarantine, e.g. when releasing it failed
+func (q *QuarantineStore) Return(held *Held) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id, _ := strconv.ParseUint(held.ID, 10, 64)
+	i := len(q.held)
+	for i > 0 {
+		if other, _ := strconv.ParseUint(q.held[i-1].ID, 10, 64); other < id {
+			break
+		}
+		i--
+	}
+	q.held = append(q.held[:i], append([]*Held{held}, q.held[i:]...)...)
+}
@@ -0,0 +1,191 @@
+// Package tenant links Todoist user IDs to Cherry accounts. Every webhook and client request
+// belongs to the account of one Todoist user; the Registry says whether that account exists and
+// is enabled, and its settings decide which features run for it. Handlers carry the account in
+// the request context, so everything downstream can scope its data access to it.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrUnknownUser is returned for Todoist users without an account
+	ErrUnknownUser = errors.New("unknown user")
+
+	// ErrDisabled is returned for accounts that are disabled
+	ErrDisabled = errors.New("account disabled")
+
+	// ErrInvalidAccount is returned when an account or its settings are malformed
+	ErrInvalidAccount = errors.New("invalid account")
+)
+
+// Status is the state of an account
+type Status string
+
+const (
+	// StatusActive accounts are served normally
+	StatusActive Status = "active"
+
+	// StatusDisabled accounts have their webhooks held back and their client requests refused
+	StatusDisabled Status = "disabled"
+)
+
+// Features that can be switched off per account
+const (
+	// FeatureMirror keeps a local copy of the user's Todoist state
+	FeatureMirror = "mirror"
+
+	// FeatureAutomation runs automation rules on the user's webhooks
+	FeatureAutomation = "automation"
+
+	// FeatureFanout re-delivers the user's webhooks to subscribers
+	FeatureFanout = "fanout"
+
+	// FeatureEvents records the user's webhooks for the event stream
+	FeatureEvents = "events"
+
+	// FeatureStats aggregates the user's productivity statistics
+	FeatureStats = "stats"
+)
+
+// Features lists every feature an account can switch
+var Features = []string{FeatureMirror, FeatureAutomation, FeatureFanout, FeatureEvents, FeatureStats}
+
+// Digest frequencies of Notifications.Digest
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Account is the Cherry account of a Todoist user
+type Account struct {
+	// UserID is the Todoist user ID, as sent in webhooks
+	UserID string `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+	Status Status `json:"status"`
+
+	Settings Settings `json:"settings"`
+
+	// CreatedAt and UpdatedAt are set by the registry
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Settings are the per-user preferences of an account
+type Settings struct {
+	// Timezone is an IANA time zone name such as "Europe/Berlin"; empty means UTC
+	Timezone string `json:"timezone,omitempty"`
+
+	// Features switches features on or off; features that are not listed are on
+	Features map[string]bool `json:"features,omitempty"`
+
+	Notifications Notifications `json:"notifications"`
+}
+
+// Notifications are the notification preferences of an account
+type Notifications struct {
+	// Digest is how often the user gets a summary of their tasks: off, daily or weekly
+	Digest string `json:"digest,omitempty"`
+}
+
+// Active reports whether the account is served
+func (a *Account) Active() bool {
+	return a.Status == StatusActive
+}
+
+// HasFeature reports whether a feature is on for the account
+func (a *Account) HasFeature(feature string) bool {
+	enabled, ok := a.Settings.Features[feature]
+	return !ok || enabled
+}
+
+// Location returns the time zone of the account
+func (a *Account) Location() *time.Location {
+	if location, err := time.LoadLocation(a.Settings.Timezone); err == nil {
+		return location
+	}
+	return time.UTC
+}
+
+// Validate checks the fields of an account, defaulting its status to active
+func (a *Account) Validate() error {
+	if a.UserID == "" {
+		return fmt.Errorf("%w: user_id is required", ErrInvalidAccount)
+	}
+	switch a.Status {
+	case "":
+		a.Status = StatusActive
+	case StatusActive, StatusDisabled:
+	default:
+		return fmt.Errorf("%w: status must be %s or %s", ErrInvalidAccount, StatusActive, StatusDisabled)
+	}
+	if _, err := time.LoadLocation(a.Settings.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidAccount, a.Settings.Timezone)
+	}
+	for feature := range a.Settings.Features {
+		if !knownFeature(feature) {
+			return fmt.Errorf("%w: unknown feature %q", ErrInvalidAccount, feature)
+		}
+	}
+	switch a.Settings.Notifications.Digest {
+	case "", DigestOff, DigestDaily, DigestWeekly:
+	default:
+		return fmt.Errorf("%w: digest must be %s, %s or %s", ErrInvalidAccount, DigestOff, DigestDaily, DigestWeekly)
+	}
+	return nil
+}
+
+func knownFeature(feature string) bool {
+	for _, known := range Features {
+		if feature == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry stores the accounts of Todoist users
+type Registry interface {
+	// Get returns the account of a Todoist user, or ErrUnknownUser
+	Get(ctx context.Context, userID string) (*Account, error)
+
+	// Put creates an account or replaces its email, status and settings
+	Put(ctx context.Context, account *Account) error
+
+	// List returns every account ordered by user ID
+	List(ctx context.Context) ([]Account, error)
+
+	// Delete removes an account, or returns ErrUnknownUser
+	Delete(ctx context.Context, userID string) error
+}
+
+// Register creates active accounts for the users that have none yet, leaving existing accounts alone
+func Register(ctx context.Context, registry Registry, userIDs []string) error {
+	for _, userID := range userIDs {
+		_, err := registry.Get(ctx, userID)
+		if errors.Is(err, ErrUnknownUser) {
+			err = registry.Put(ctx, &Account{UserID: userID, Status: StatusActive})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type accountKey struct{}
+
+// NewContext returns a context carrying the account a request acts for
+func NewContext(ctx context.Context, account *Account) context.Context {
+	return context.WithValue(ctx, accountKey{}, account)
+}
+
+// FromContext returns the account a request acts for, if any
+func FromContext(ctx context.Context) (*Account, bool) {
+	account, ok := ctx.Value(accountKey{}).(*Account)
+	return account, ok
+}
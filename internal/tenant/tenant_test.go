@@ -0,0 +1,210 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cherry_backend/internal/mirror"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// TestValidate tests checking accounts and defaulting their status
+func TestValidate(t *testing.T) {
+	account := &Account{UserID: "1"}
+	if err := account.Validate(); err != nil || account.Status != StatusActive {
+		t.Errorf("Validate() = %v with status %q, want no error and active", err, account.Status)
+	}
+
+	invalid := []Account{
+		{},
+		{UserID: "1", Status: "paused"},
+		{UserID: "1", Settings: Settings{Timezone: "Mars/Olympus_Mons"}},
+		{UserID: "1", Settings: Settings{Features: map[string]bool{"teleport": true}}},
+		{UserID: "1", Settings: Settings{Notifications: Notifications{Digest: "hourly"}}},
+	}
+	for _, account := range invalid {
+		if err := account.Validate(); !errors.Is(err, ErrInvalidAccount) {
+			t.Errorf("Validate(%+v) = %v, want ErrInvalidAccount", account, err)
+		}
+	}
+}
+
+// TestSettings tests the feature switches and time zone of an account
+func TestSettings(t *testing.T) {
+	account := &Account{UserID: "1", Settings: Settings{
+		Timezone: "Asia/Tokyo",
+		Features: map[string]bool{FeatureAutomation: false, FeatureFanout: true},
+	}}
+	if account.HasFeature(FeatureAutomation) || !account.HasFeature(FeatureFanout) || !account.HasFeature(FeatureMirror) {
+		t.Errorf("features = automation %v, fanout %v, mirror %v; want false, true, true",
+			account.HasFeature(FeatureAutomation), account.HasFeature(FeatureFanout), account.HasFeature(FeatureMirror))
+	}
+	if got := account.Location().String(); got != "Asia/Tokyo" {
+		t.Errorf("Location() = %s, want Asia/Tokyo", got)
+	}
+	if got := (&Account{}).Location(); got != time.UTC {
+		t.Errorf("Location() without a time zone = %s, want UTC", got)
+	}
+}
+
+// TestAdmit tests what each policy does with webhooks of active, disabled and unknown users
+func TestAdmit(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryRegistry()
+	registry.Put(ctx, &Account{UserID: "active"})
+	registry.Put(ctx, &Account{UserID: "disabled", Status: StatusDisabled})
+
+	testCases := []struct {
+		policy  Policy
+		userID  string
+		want    Action
+		wantErr error
+	}{
+		{PolicyOpen, "active", Accept, nil},
+		{PolicyOpen, "disabled", Quarantine, ErrDisabled},
+		{PolicyOpen, "unknown", Accept, nil},
+		{PolicyQuarantine, "active", Accept, nil},
+		{PolicyQuarantine, "disabled", Quarantine, ErrDisabled},
+		{PolicyQuarantine, "unknown", Quarantine, ErrUnknownUser},
+		{PolicyReject, "active", Accept, nil},
+		{PolicyReject, "disabled", Reject, ErrDisabled},
+		{PolicyReject, "unknown", Reject, ErrUnknownUser},
+	}
+	for _, tc := range testCases {
+		account, action, err := tc.policy.Admit(ctx, registry, tc.userID)
+		if action != tc.want || !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s policy, user %s: got %v, %v; want %v, %v", tc.policy, tc.userID, action, err, tc.want, tc.wantErr)
+		}
+		if action == Accept && (account == nil || account.UserID != tc.userID || !account.Active()) {
+			t.Errorf("%s policy, user %s: accepted with account %+v", tc.policy, tc.userID, account)
+		}
+	}
+}
+
+// TestParsePolicy tests parsing policy names
+func TestParsePolicy(t *testing.T) {
+	if policy, err := ParsePolicy(""); err != nil || policy != PolicyOpen {
+		t.Errorf("ParsePolicy(\"\") = %v, %v, want open", policy, err)
+	}
+	if policy, err := ParsePolicy("reject"); err != nil || policy != PolicyReject || !policy.Enforced() {
+		t.Errorf("ParsePolicy(reject) = %v, %v, want an enforced reject policy", policy, err)
+	}
+	if _, err := ParsePolicy("strict"); err == nil {
+		t.Error("ParsePolicy(strict) succeeded")
+	}
+}
+
+// TestMemoryRegistry tests storing accounts in memory
+func TestMemoryRegistry(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryRegistry()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	registry.Now = func() time.Time { return now }
+
+	if _, err := registry.Get(ctx, "1"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Get() of an unknown user = %v, want ErrUnknownUser", err)
+	}
+	if err := registry.Put(ctx, &Account{UserID: "1", Status: "bogus"}); !errors.Is(err, ErrInvalidAccount) {
+		t.Errorf("Put() of an invalid account = %v, want ErrInvalidAccount", err)
+	}
+
+	account := &Account{UserID: "1", Settings: Settings{Features: map[string]bool{FeatureFanout: false}}}
+	if err := registry.Put(ctx, account); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	account.Settings.Features[FeatureFanout] = true
+	stored, _ := registry.Get(ctx, "1")
+	if stored.HasFeature(FeatureFanout) || !stored.CreatedAt.Equal(now) {
+		t.Errorf("stored account = %+v, want it unaffected by later changes of the caller", stored)
+	}
+
+	now = now.Add(time.Hour)
+	stored.Status = StatusDisabled
+	registry.Put(ctx, stored)
+	updated, _ := registry.Get(ctx, "1")
+	if updated.Active() || !updated.CreatedAt.Equal(now.Add(-time.Hour)) || !updated.UpdatedAt.Equal(now) {
+		t.Errorf("updated account = %+v", updated)
+	}
+
+	if err := Register(ctx, registry, []string{"1", "2"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	accounts, _ := registry.List(ctx)
+	if len(accounts) != 2 || accounts[0].Active() || !accounts[1].Active() {
+		t.Errorf("accounts after Register = %+v, want account 1 untouched and account 2 active", accounts)
+	}
+
+	if err := registry.Delete(ctx, "2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := registry.Delete(ctx, "2"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("second Delete() = %v, want ErrUnknownUser", err)
+	}
+}
+
+// TestQuarantineStore tests holding, listing, taking and returning webhooks
+func TestQuarantineStore(t *testing.T) {
+	q := NewQuarantineStore()
+	q.Size = 2
+	for _, userID := range []string{"1", "2", "1"} {
+		q.Hold(&apiv1.TodoistWebhookRequest{EventName: "item:added", UserId: userID}, nil, ErrUnknownUser)
+	}
+
+	all := q.List("")
+	if len(all) != 2 || all[0].ID != "2" || all[1].ID != "3" {
+		t.Fatalf("List() = %+v, want the newest two webhooks", all)
+	}
+	if held := q.List("1"); len(held) != 1 || held[0].Reason != ErrUnknownUser.Error() {
+		t.Errorf("List(1) = %+v", held)
+	}
+	if _, err := q.Get("1"); !errors.Is(err, ErrNotQuarantined) {
+		t.Errorf("Get() of a dropped webhook = %v, want ErrNotQuarantined", err)
+	}
+	if held, err := q.Take("3"); err != nil || held.Request.UserId != "1" {
+		t.Errorf("Take(3) = %+v, %v", held, err)
+	}
+	if _, err := q.Take("3"); !errors.Is(err, ErrNotQuarantined) {
+		t.Errorf("second Take(3) = %v, want ErrNotQuarantined", err)
+	}
+
+	// A returned webhook goes back before the ones held after it
+	taken, _ := q.Take("2")
+	q.Hold(&apiv1.TodoistWebhookRequest{EventName: "item:added", UserId: "1"}, nil, ErrUnknownUser)
+	q.Return(taken)
+	if all := q.List(""); len(all) != 2 || all[0].ID != "2" || all[1].ID != "4" {
+		t.Errorf("List() after Return(2) = %+v, want webhooks 2 and 4", all)
+	}
+}
+
+// TestMirroredTokens tests that only active accounts with the mirror feature on are mirrored
+func TestMirroredTokens(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryRegistry()
+	registry.Put(ctx, &Account{UserID: "active"})
+	registry.Put(ctx, &Account{UserID: "disabled", Status: StatusDisabled})
+	registry.Put(ctx, &Account{UserID: "unmirrored", Settings: Settings{Features: map[string]bool{FeatureMirror: false}}})
+	tokens := mirror.StaticTokenSource{"active": "a", "disabled": "d", "unmirrored": "m", "unknown": "u"}
+
+	testCases := []struct {
+		policy Policy
+		want   []string
+	}{
+		{PolicyOpen, []string{"active", "unknown"}},
+		{PolicyReject, []string{"active"}},
+	}
+	for _, tc := range testCases {
+		source := MirroredTokens{Tokens: tokens, Registry: registry, Policy: tc.policy}
+		users, err := source.Users(ctx)
+		if err != nil || len(users) != len(tc.want) || users[0] != tc.want[0] || users[len(users)-1] != tc.want[len(tc.want)-1] {
+			t.Errorf("%s policy: Users() = %v, %v, want %v", tc.policy, users, err, tc.want)
+		}
+		if _, err := source.Token(ctx, "disabled"); !errors.Is(err, mirror.ErrNoToken) {
+			t.Errorf("%s policy: Token(disabled) = %v, want ErrNoToken", tc.policy, err)
+		}
+		if token, err := source.Token(ctx, "active"); err != nil || token != "a" {
+			t.Errorf("%s policy: Token(active) = %q, %v", tc.policy, token, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cherry_backend/internal/mirror"
+)
+
+// MirroredTokens limits a TokenSource to the users whose Todoist state may be mirrored: users
+// with an active account that has the mirror feature on. Under an open policy users without an
+// account are mirrored too.
+type MirroredTokens struct {
+	Tokens   mirror.TokenSource
+	Registry Registry
+	Policy   Policy
+}
+
+// Token returns the API token of a user that may be mirrored
+func (t MirroredTokens) Token(ctx context.Context, userID string) (string, error) {
+	ok, err := t.mirrored(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("user %s is not mirrored: %w", userID, mirror.ErrNoToken)
+	}
+	return t.Tokens.Token(ctx, userID)
+}
+
+// Users returns the users with a token that may be mirrored
+func (t MirroredTokens) Users(ctx context.Context) ([]string, error) {
+	users, err := t.Tokens.Users(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var mirrored []string
+	for _, userID := range users {
+		ok, err := t.mirrored(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			mirrored = append(mirrored, userID)
+		}
+	}
+	return mirrored, nil
+}
+
+func (t MirroredTokens) mirrored(ctx context.Context, userID string) (bool, error) {
+	account, action, err := t.Policy.Admit(ctx, t.Registry, userID)
+	if action == Accept {
+		return account.HasFeature(FeatureMirror), nil
+	}
+	if errors.Is(err, ErrUnknownUser) || errors.Is(err, ErrDisabled) {
+		return false, nil
+	}
+	return false, err
+}
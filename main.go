@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
 	"github.com/joho/godotenv"
 
 	"cherry_backend/internal/server"
+	"cherry_backend/internal/storage"
 )
 
 func main() {
@@ -15,6 +20,14 @@ func main() {
 		log.Println("Warning: configs/local.env file not found. Using environment variables.")
 	}
 
+	// Run a subcommand instead of the server if one is given
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Create a new server
 	s := server.NewServer()
 
@@ -23,3 +36,55 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// migrate runs `cherry_backend migrate [-database URL] [up|status]`, which applies the pending
+// migrations or lists every migration with its state
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	databaseURL := flags.String("database", os.Getenv("CHERRY_DATABASE_URL"), "database URL (sqlite:PATH or postgres://...)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cherry_backend migrate [-database URL] [up|status]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *databaseURL == "" {
+		return fmt.Errorf("no database: set CHERRY_DATABASE_URL or pass -database")
+	}
+
+	db, err := storage.Open(*databaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	switch command := flags.Arg(0); command {
+	case "", "up":
+		applied, err := db.Migrate(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "status":
+		migrations, err := db.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			state := "pending"
+			if !migration.AppliedAt.IsZero() {
+				state = "applied " + migration.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", migration.Version, migration.Name, state)
+		}
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+	return nil
+}
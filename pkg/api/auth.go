@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// BearerAuth authenticates every request with a bearer token: an API key or session token of
+// the backend. Pass it to ClientGenerator.Use.
+func BearerAuth(token string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			authenticated := req.Clone(req.Context())
+			authenticated.Header.Set("Authorization", "Bearer "+token)
+			return next.RoundTrip(authenticated)
+		})
+	}
+}
+
+// BearerCredentials authenticates gRPC calls with a bearer token. Pass it to
+// grpc.WithPerRPCCredentials.
+func BearerCredentials(token string, requireTLS bool) credentials.PerRPCCredentials {
+	return bearerCredentials{token: token, requireTLS: requireTLS}
+}
+
+type bearerCredentials struct {
+	token      string
+	requireTLS bool
+}
+
+func (c bearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c bearerCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
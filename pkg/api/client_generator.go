@@ -2,10 +2,16 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,18 +19,98 @@ import (
 type ClientGenerator struct {
 	BaseURL    string
 	HTTPClient *http.Client
+
+	// Timeout bounds each attempt of a request; zero leaves only the context deadline
+	Timeout time.Duration
+
+	// Retry is the retry policy of requests that do not set their own
+	Retry RetryPolicy
+
+	// sleep waits between attempts, returning early if the context is done; replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
 }
 
 // NewClientGenerator creates a new client generator
 func NewClientGenerator(baseURL string) *ClientGenerator {
 	return &ClientGenerator{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: time.Second * 10,
-		},
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{},
+		Timeout:    time.Second * 10,
+		Retry:      DefaultRetryPolicy,
+		sleep:      sleepContext,
+	}
+}
+
+// Middleware wraps the transport of a ClientGenerator, e.g. to add headers, logging or metrics
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to an http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Use wraps the HTTP transport with middleware. The first middleware given is the outermost,
+// and every attempt of a retried request passes through it.
+func (c *ClientGenerator) Use(middleware ...Middleware) {
+	transport := c.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
 	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		transport = middleware[i](transport)
+	}
+	c.HTTPClient.Transport = transport
+}
+
+// RetryPolicy decides whether and when a failed request is retried.
+// Transport errors, 429 and 5xx responses of idempotent requests are retried with jittered
+// exponential backoff, waiting for Retry-After instead when the server sends it.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; zero disables retries
+	MaxRetries int
+
+	// InitialBackoff is the wait before the first retry; it doubles with every retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the computed backoff and the wait asked for by Retry-After, so a server
+	// cannot stall the caller for longer; zero leaves both uncapped
+	MaxBackoff time.Duration
+
+	// Jitter randomly shortens each backoff by up to this fraction (0 to 1) to spread out retries
+	Jitter float64
+
+	// RetryNonIdempotent also retries POST and PATCH requests without an idempotency key,
+	// which may apply them twice
+	RetryNonIdempotent bool
 }
 
+// DefaultRetryPolicy is used by NewClientGenerator
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
+}
+
+// NoRetry disables retries
+var NoRetry = RetryPolicy{}
+
+// idempotentMethods may be repeated without changing the result beyond the first request
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// idempotencyHeaders mark a request as safe to repeat: the server deduplicates it by this key
+var idempotencyHeaders = []string{"Idempotency-Key", "X-Request-Id"}
+
 // Request represents a REST request
 type Request struct {
 	Method string
@@ -32,6 +118,12 @@ type Request struct {
 	Body   interface{}
 	Query  map[string]string
 	Header map[string]string
+
+	// QueryValues holds query parameters that may repeat, in addition to Query
+	QueryValues url.Values
+
+	// Retry overrides the retry policy of the generator for this request
+	Retry *RetryPolicy
 }
 
 // Response represents a REST response
@@ -43,21 +135,77 @@ type Response struct {
 
 // Do executes a REST request
 func (c *ClientGenerator) Do(req *Request) (*Response, error) {
-	// Prepare URL
-	url := fmt.Sprintf("%s%s", c.BaseURL, req.Path)
+	return c.DoContext(context.Background(), req)
+}
 
+// DoContext executes a REST request, retrying it according to the retry policy until it
+// succeeds, the retries run out or the context is done. Once retries run out the last
+// response is returned as is, whatever its status code.
+func (c *ClientGenerator) DoContext(ctx context.Context, req *Request) (*Response, error) {
 	// Prepare body
-	var bodyReader io.Reader
+	var body []byte
 	if req.Body != nil {
-		bodyBytes, err := json.Marshal(req.Body)
-		if err != nil {
+		var err error
+		if body, err = json.Marshal(req.Body); err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	policy := c.Retry
+	if req.Retry != nil {
+		policy = *req.Retry
+	}
+	idempotent := policy.RetryNonIdempotent || isIdempotent(req)
+
+	backoff := policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req, body)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to execute HTTP request: %w", ctx.Err())
+		}
+		if attempt >= policy.MaxRetries || !shouldRetry(resp, err, idempotent) {
+			return resp, err
+		}
+
+		wait := jitter(backoff, policy.Jitter)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+				if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+					wait = policy.MaxBackoff
+				}
+			}
+		}
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
+		}
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// attempt sends a request once
+func (c *ClientGenerator) attempt(ctx context.Context, req *Request, body []byte) (*Response, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	// Prepare URL
+	url := fmt.Sprintf("%s%s", c.BaseURL, req.Path)
+
+	// Prepare body
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequest(req.Method, url, bodyReader)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, url, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -73,6 +221,11 @@ func (c *ClientGenerator) Do(req *Request) (*Response, error) {
 	for k, v := range req.Query {
 		q.Add(k, v)
 	}
+	for k, values := range req.QueryValues {
+		for _, v := range values {
+			q.Add(k, v)
+		}
+	}
 	httpReq.URL.RawQuery = q.Encode()
 
 	// Execute request
@@ -97,3 +250,79 @@ func (c *ClientGenerator) Do(req *Request) (*Response, error) {
 
 	return resp, nil
 }
+
+// isIdempotent reports whether a request may safely be sent more than once
+func isIdempotent(req *Request) bool {
+	if idempotentMethods[strings.ToUpper(req.Method)] {
+		return true
+	}
+	for _, header := range idempotencyHeaders {
+		for k, v := range req.Header {
+			if strings.EqualFold(k, header) && v != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shouldRetry decides whether an attempt is retried. Only idempotent requests are, after
+// transport errors, 429 and 5xx responses.
+func shouldRetry(resp *Response, err error, idempotent bool) bool {
+	if !idempotent {
+		return false
+	}
+	if err != nil {
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// jitter shortens a backoff by a random fraction of up to factor
+func jitter(d time.Duration, factor float64) time.Duration {
+	if factor <= 0 || d <= 0 {
+		return d
+	}
+	if factor > 1 {
+		factor = 1
+	}
+	return d - time.Duration(rand.Float64()*factor*float64(d))
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,173 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cherry_backend/pkg/api"
+)
+
+// newTestGenerator returns a generator with fast retries pointed at handler, and a counter of received requests
+func newTestGenerator(t *testing.T, handler http.HandlerFunc) (*api.ClientGenerator, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	generator := api.NewClientGenerator(server.URL)
+	generator.Retry = api.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Jitter: 0.5}
+	return generator, &requests
+}
+
+// failFirst responds with status to the first n requests and 200 afterwards
+func failFirst(n int32, status int) http.HandlerFunc {
+	var seen int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&seen, 1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}
+}
+
+// TestRetryIdempotentRequests tests that GET requests are retried on server errors until they succeed
+func TestRetryIdempotentRequests(t *testing.T) {
+	generator, requests := newTestGenerator(t, failFirst(2, http.StatusBadGateway))
+
+	resp, err := generator.Do(&api.Request{Method: http.MethodGet, Path: "/tasks"})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || *requests != 3 {
+		t.Errorf("got status %d after %d requests, want 200 after 3", resp.StatusCode, *requests)
+	}
+}
+
+// TestRetryNonIdempotentRequests tests the retry rules of POST requests
+func TestRetryNonIdempotentRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		header   map[string]string
+		policy   *api.RetryPolicy
+		requests int32
+	}{
+		{"server error is not retried", http.StatusInternalServerError, nil, nil, 1},
+		{"rate limit is not retried", http.StatusTooManyRequests, nil, nil, 1},
+		{"idempotency key allows retry after rate limit", http.StatusTooManyRequests, map[string]string{"X-Request-Id": "abc"}, nil, 2},
+		{"idempotency key allows retry", http.StatusInternalServerError, map[string]string{"Idempotency-Key": "abc"}, nil, 2},
+		{"policy allows retry", http.StatusInternalServerError, nil, &api.RetryPolicy{MaxRetries: 1, RetryNonIdempotent: true}, 2},
+		{"no retry policy", http.StatusTooManyRequests, map[string]string{"X-Request-Id": "abc"}, &api.NoRetry, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, requests := newTestGenerator(t, failFirst(1, tt.status))
+			generator.Do(&api.Request{Method: http.MethodPost, Path: "/tasks", Body: map[string]string{"content": "x"}, Header: tt.header, Retry: tt.policy})
+			if *requests != tt.requests {
+				t.Errorf("server received %d requests, want %d", *requests, tt.requests)
+			}
+		})
+	}
+}
+
+// TestRetryAfterHonoured tests that Retry-After replaces the backoff and that the context interrupts the wait
+func TestRetryAfterHonoured(t *testing.T) {
+	generator, requests := newTestGenerator(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	generator.Retry.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := generator.DoContext(ctx, &api.Request{Method: http.MethodGet, Path: "/tasks"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DoContext() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("DoContext() returned after %v, want it to stop at the context deadline", elapsed)
+	}
+	if *requests != 1 {
+		t.Errorf("server received %d requests, want 1 before waiting for Retry-After", *requests)
+	}
+}
+
+// TestRetryAfterCapped tests that a Retry-After longer than MaxBackoff only waits MaxBackoff
+func TestRetryAfterCapped(t *testing.T) {
+	var seen int32
+	generator, requests := newTestGenerator(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&seen, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	start := time.Now()
+	resp, err := generator.Do(&api.Request{Method: http.MethodGet, Path: "/tasks"})
+	if err != nil || resp.StatusCode != http.StatusOK || *requests != 2 {
+		t.Errorf("Do() = %v, %v after %d requests, want 200 after 2", resp, err, *requests)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() returned after %v, want the wait capped at MaxBackoff", elapsed)
+	}
+}
+
+// TestRetryTransportErrors tests that connection failures are retried and reported once retries run out
+func TestRetryTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	generator := api.NewClientGenerator(server.URL)
+	generator.Retry = api.RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}
+	var attempts int32
+	generator.Use(func(next http.RoundTripper) http.RoundTripper {
+		return api.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&attempts, 1)
+			return next.RoundTrip(req)
+		})
+	})
+
+	if _, err := generator.Do(&api.Request{Method: http.MethodGet, Path: "/"}); err == nil {
+		t.Fatal("Do succeeded against a closed server")
+	}
+	if attempts != 3 {
+		t.Errorf("transport saw %d attempts, want 3", attempts)
+	}
+}
+
+// TestMiddlewareOrder tests that middleware wraps the transport outermost first
+func TestMiddlewareOrder(t *testing.T) {
+	var got string
+	generator, _ := newTestGenerator(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Trace")
+	})
+
+	appendTrace := func(name string) api.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return api.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("X-Trace", req.Header.Get("X-Trace")+name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	generator.Use(appendTrace("a"), appendTrace("b"))
+
+	if _, err := generator.Do(&api.Request{Method: http.MethodGet, Path: "/"}); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if got != "ab" {
+		t.Errorf("X-Trace = %q, want %q", got, "ab")
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error codes sent in the error envelope of the Cherry API
+const (
+	CodeBadRequest      = "bad_request"
+	CodeInvalidArgument = "invalid_argument"
+	CodeUnauthenticated = "unauthenticated"
+	CodePermission      = "permission_denied"
+	CodeNotFound        = "not_found"
+	CodeMethod          = "method_not_allowed"
+	CodeConflict        = "conflict"
+	CodeRateLimited     = "rate_limited"
+	CodeInternal        = "internal"
+	CodeUnavailable     = "unavailable"
+	CodeUnknown         = "unknown"
+)
+
+// maxErrorBodyMessage is how much of a non-JSON error body is kept as the message
+const maxErrorBodyMessage = 512
+
+// APIError is a non-2xx response from an API. Use errors.As to inspect it:
+//
+//	var apiErr *api.APIError
+//	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound { ... }
+type APIError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int `json:"-"`
+
+	// Code is a machine-readable error code such as "invalid_argument"
+	Code string `json:"code"`
+
+	// Message is a human-readable description of the error
+	Message string `json:"message"`
+
+	// RequestID identifies the request in the server logs
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorEnvelope is the JSON body of every error response of the Cherry API
+type ErrorEnvelope struct {
+	Error *APIError `json:"error"`
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("api error %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// ErrorCodeForStatus returns the default error code of an HTTP status
+func ErrorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermission
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethod
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeUnknown
+}
+
+// NewAPIError builds an APIError from an error response. The Cherry error envelope is parsed
+// when present; otherwise the status decides the code and the body, e.g. plain text or HTML
+// from a proxy, becomes the message.
+func NewAPIError(resp *Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var envelope ErrorEnvelope
+	if err := json.Unmarshal(resp.Body, &envelope); err == nil && envelope.Error != nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.RequestID = envelope.Error.RequestID
+	} else {
+		apiErr.Message = bodySnippet(resp.Body)
+	}
+
+	if apiErr.Code == "" {
+		apiErr.Code = ErrorCodeForStatus(resp.StatusCode)
+	}
+	if apiErr.RequestID == "" && resp.Header != nil {
+		apiErr.RequestID = resp.Header.Get("X-Request-Id")
+	}
+	return apiErr
+}
+
+// bodySnippet returns the start of a response body as a single trimmed line
+func bodySnippet(body []byte) string {
+	text := strings.Join(strings.Fields(string(body)), " ")
+	if len(text) > maxErrorBodyMessage {
+		text = text[:maxErrorBodyMessage]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+		text += "..."
+	}
+	return text
+}
+
+// decodeJSON decodes a successful response body, reporting bodies that are not JSON
+func decodeJSON(resp *Response, out interface{}) error {
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		contentType := resp.Header.Get("Content-Type")
+		return fmt.Errorf("failed to parse response (Content-Type %q): %w: %s", contentType, err, bodySnippet(resp.Body))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// TestAPIErrorEnvelope tests that clients parse the JSON error envelope into an APIError
+func TestAPIErrorEnvelope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"code": "invalid_argument", "message": "user_id is required", "request_id": "req-1"}}`))
+	}))
+	defer server.Close()
+
+	_, err := api.NewTaskClient(server.URL).ListTasks(context.Background(), &apiv1.ListTasksRequest{})
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("ListTasks() error = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != api.CodeInvalidArgument ||
+		apiErr.Message != "user_id is required" || apiErr.RequestID != "req-1" {
+		t.Errorf("APIError = %+v, want the decoded envelope", apiErr)
+	}
+}
+
+// TestAPIErrorNonJSON tests that error bodies which are not JSON still produce an APIError
+func TestAPIErrorNonJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("X-Request-Id", "proxy-7")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<html>\n  <body>Page not found</body>\n</html>"))
+	}))
+	defer server.Close()
+
+	client := api.NewHealthClient(server.URL)
+	_, err := client.Check(context.Background(), &apiv1.HealthCheckRequest{})
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Check() error = %v, want an *APIError", err)
+	}
+	if apiErr.Code != api.CodeNotFound || apiErr.RequestID != "proxy-7" || !strings.Contains(apiErr.Message, "Page not found") {
+		t.Errorf("APIError = %+v, want code not_found, the header request ID and the body as message", apiErr)
+	}
+}
+
+// TestHealthCheckParsesJSON tests that HealthClient.Check decodes the status field and rejects non-JSON bodies
+func TestHealthCheckParsesJSON(t *testing.T) {
+	body := `{"status":"OK"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := api.NewHealthClient(server.URL)
+	response, err := client.Check(context.Background(), &apiv1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if response.Status != "OK" {
+		t.Errorf("Status = %q, want OK", response.Status)
+	}
+
+	body = "OK"
+	if _, err := client.Check(context.Background(), &apiv1.HealthCheckRequest{}); err == nil {
+		t.Error("Check succeeded with a non-JSON body, want an error")
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// EventSubscriber consumes the SubscribeEvents stream, reconnecting after errors and
+// resuming after the last event it handled, so no retained event is lost or repeated
+type EventSubscriber struct {
+	Client apiv1.TodoistServiceClient
+
+	// MinBackoff and MaxBackoff bound the wait between reconnects; it doubles after each failed attempt
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnDisconnect is called with the error of every broken stream before reconnecting; optional
+	OnDisconnect func(err error)
+}
+
+// NewEventSubscriber creates an event subscriber on a gRPC connection
+func NewEventSubscriber(conn grpc.ClientConnInterface) *EventSubscriber {
+	return &EventSubscriber{
+		Client:     apiv1.NewTodoistServiceClient(conn),
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// Subscribe calls handle for every event until the context is cancelled, handle returns an error,
+// or the server rejects the request. The request's ResumeAfter is advanced as events are handled.
+func (s *EventSubscriber) Subscribe(ctx context.Context, request *apiv1.SubscribeEventsRequest, handle func(*apiv1.Event) error) error {
+	backoff := s.MinBackoff
+	for {
+		err := s.stream(ctx, request, handle, &backoff)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if handlerErr, ok := err.(*handlerError); ok {
+			return handlerErr.err
+		}
+		if !reconnectable(err) {
+			return err
+		}
+		if s.OnDisconnect != nil {
+			s.OnDisconnect(err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// stream runs a single SubscribeEvents call, resetting the backoff once events flow
+func (s *EventSubscriber) stream(ctx context.Context, request *apiv1.SubscribeEventsRequest, handle func(*apiv1.Event) error, backoff *time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.Client.SubscribeEvents(ctx, request)
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		*backoff = s.MinBackoff
+		if err := handle(event); err != nil {
+			return &handlerError{err}
+		}
+		request.ResumeAfter = event.Id
+	}
+}
+
+// handlerError marks errors returned by the event handler, which end the subscription
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string { return e.err.Error() }
+
+// reconnectable reports whether a stream error is worth reconnecting for
+func reconnectable(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied, codes.Unimplemented, codes.NotFound:
+		return false
+	}
+	return true
+}
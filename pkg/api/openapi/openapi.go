@@ -0,0 +1,16 @@
+// Package openapi embeds the OpenAPI 3 document of the REST API. openapi.json is generated from
+// proto/*.proto by `make proto`; do not edit it by hand.
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document in JSON
+//
+//go:embed openapi.json
+var Spec []byte
+
+// SwaggerUI is an HTML page that renders the document served at /openapi.json with Swagger UI.
+// The Swagger UI scripts and styles are loaded from the unpkg CDN.
+//
+//go:embed swagger.html
+var SwaggerUI []byte
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Route is the REST mapping of an RPC, generated from its google.api.http annotation
+type Route struct {
+	// FullMethod is the gRPC name of the RPC, e.g. /cherry.api.v1.TaskService/ListTasks
+	FullMethod string
+
+	// Method is the HTTP method
+	Method string
+
+	// Path is the path template; {field} segments are taken from the request message
+	Path string
+
+	// Body is "*" when the whole request message is the JSON body, the name of the field sent
+	// as the body, or empty when the request is encoded as query parameters
+	Body string
+}
+
+// invoke sends a request and decodes a successful JSON response into out
+func (c *ClientGenerator) invoke(ctx context.Context, req *Request, out interface{}) error {
+	resp, err := c.DoContext(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", req.Method, req.Path, err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return NewAPIError(resp)
+	}
+	return decodeJSON(resp, out)
+}
+
+// EncodeQuery encodes the populated fields of a message as query parameters named after the
+// proto fields, leaving out the excluded fields. Repeated fields repeat the parameter and enum
+// values are sent by their short lower-case name, e.g. TASK_COMPLETION_ACTIVE as "active".
+func EncodeQuery(msg proto.Message, exclude ...string) url.Values {
+	values := url.Values{}
+	m := msg.ProtoReflect()
+	m.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		name := string(field.Name())
+		for _, excluded := range exclude {
+			if name == excluded {
+				return true
+			}
+		}
+		if field.Message() != nil {
+			return true
+		}
+		if field.IsList() {
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				values.Add(name, formatScalar(field, list.Get(i)))
+			}
+			return true
+		}
+		values.Set(name, formatScalar(field, value))
+		return true
+	})
+	return values
+}
+
+// DecodeQuery sets the fields of a message from query parameters named after the proto fields.
+// aliases maps additional parameter names to field names. Parameters that do not name a field
+// are ignored; enum values may be given by short name, full name or number.
+func DecodeQuery(values url.Values, msg proto.Message, aliases map[string]string) error {
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for key, params := range values {
+		name := key
+		if alias, ok := aliases[key]; ok {
+			name = alias
+		}
+		field := fields.ByName(protoreflect.Name(name))
+		if field == nil || field.Message() != nil || len(params) == 0 {
+			continue
+		}
+		if field.IsList() {
+			list := m.Mutable(field).List()
+			for _, param := range params {
+				value, err := parseScalar(field, param)
+				if err != nil {
+					return fmt.Errorf("%s %s", key, err)
+				}
+				list.Append(value)
+			}
+			continue
+		}
+		value, err := parseScalar(field, params[0])
+		if err != nil {
+			return fmt.Errorf("%s %s", key, err)
+		}
+		m.Set(field, value)
+	}
+	return nil
+}
+
+// formatScalar formats a single field value as a query parameter
+func formatScalar(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch field.Kind() {
+	case protoreflect.EnumKind:
+		number := value.Enum()
+		enumValue := field.Enum().Values().ByNumber(number)
+		if enumValue == nil {
+			return strconv.Itoa(int(number))
+		}
+		return shortEnumName(field.Enum(), enumValue)
+	case protoreflect.BytesKind:
+		return base64.URLEncoding.EncodeToString(value.Bytes())
+	default:
+		return value.String()
+	}
+}
+
+// parseScalar parses a query parameter as a single field value
+func parseScalar(field protoreflect.FieldDescriptor, param string) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(param), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(param)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be true or false")
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(param, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a non-negative number")
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a non-negative number")
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(param, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.BytesKind:
+		b, err := base64.URLEncoding.DecodeString(param)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be URL-safe base64")
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case protoreflect.EnumKind:
+		return parseEnum(field.Enum(), param)
+	}
+	return protoreflect.Value{}, fmt.Errorf("has an unsupported type")
+}
+
+// parseEnum parses an enum value by short name, full name or number
+func parseEnum(enum protoreflect.EnumDescriptor, param string) (protoreflect.Value, error) {
+	values := enum.Values()
+	if value := values.ByName(protoreflect.Name(param)); value != nil {
+		return protoreflect.ValueOfEnum(value.Number()), nil
+	}
+	var names []string
+	for i := 0; i < values.Len(); i++ {
+		value := values.Get(i)
+		if value.Number() == 0 {
+			continue
+		}
+		short := shortEnumName(enum, value)
+		if strings.EqualFold(short, param) {
+			return protoreflect.ValueOfEnum(value.Number()), nil
+		}
+		names = append(names, short)
+	}
+	if n, err := strconv.ParseInt(param, 10, 32); err == nil && values.ByNumber(protoreflect.EnumNumber(n)) != nil {
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("must be one of %s", strings.Join(names, ", "))
+}
+
+// shortEnumName strips the prefix derived from the enum type name and lower-cases the rest:
+// TASK_COMPLETION_ACTIVE of TaskCompletion becomes "active"
+func shortEnumName(enum protoreflect.EnumDescriptor, value protoreflect.EnumValueDescriptor) string {
+	name := string(value.Name())
+	if prefix := enumPrefix(string(enum.Name())); strings.HasPrefix(name, prefix) {
+		name = name[len(prefix):]
+	}
+	return strings.ToLower(name)
+}
+
+// enumPrefix converts an enum type name to the upper snake case prefix of its values
+func enumPrefix(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String()) + "_"
+}
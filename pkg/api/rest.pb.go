@@ -0,0 +1,142 @@
+// Code generated by protoc-gen-cherry-rest. DO NOT EDIT.
+// sources: health.proto, stats.proto, task.proto, todoist.proto
+
+package api
+
+import (
+	v1 "cherry_backend/pkg/api/v1"
+	context "context"
+)
+
+// HealthClient is the REST client of HealthService
+type HealthClient struct {
+	// Generator sends the requests; configure retries, timeouts and middleware on it
+	Generator *ClientGenerator
+}
+
+// NewHealthClient creates a REST client of HealthService
+func NewHealthClient(baseURL string) *HealthClient {
+	return &HealthClient{Generator: NewClientGenerator(baseURL)}
+}
+
+// Check performs a health check
+//
+// GET /health
+func (c *HealthClient) Check(ctx context.Context, in *v1.HealthCheckRequest) (*v1.HealthCheckResponse, error) {
+	request := &Request{
+		Method:      "GET",
+		Path:        "/health",
+		QueryValues: EncodeQuery(in),
+	}
+	out := new(v1.HealthCheckResponse)
+	if err := c.Generator.invoke(ctx, request, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StatsClient is the REST client of StatsService
+type StatsClient struct {
+	// Generator sends the requests; configure retries, timeouts and middleware on it
+	Generator *ClientGenerator
+}
+
+// NewStatsClient creates a REST client of StatsService
+func NewStatsClient(baseURL string) *StatsClient {
+	return &StatsClient{Generator: NewClientGenerator(baseURL)}
+}
+
+// GetStats returns the completion statistics of a user over a range of days
+//
+// GET /stats
+func (c *StatsClient) GetStats(ctx context.Context, in *v1.GetStatsRequest) (*v1.GetStatsResponse, error) {
+	request := &Request{
+		Method:      "GET",
+		Path:        "/stats",
+		QueryValues: EncodeQuery(in),
+	}
+	out := new(v1.GetStatsResponse)
+	if err := c.Generator.invoke(ctx, request, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TaskClient is the REST client of TaskService
+type TaskClient struct {
+	// Generator sends the requests; configure retries, timeouts and middleware on it
+	Generator *ClientGenerator
+}
+
+// NewTaskClient creates a REST client of TaskService
+func NewTaskClient(baseURL string) *TaskClient {
+	return &TaskClient{Generator: NewClientGenerator(baseURL)}
+}
+
+// ListTasks lists the tasks of a user that match the given filters
+//
+// GET /tasks
+func (c *TaskClient) ListTasks(ctx context.Context, in *v1.ListTasksRequest) (*v1.ListTasksResponse, error) {
+	request := &Request{
+		Method:      "GET",
+		Path:        "/tasks",
+		QueryValues: EncodeQuery(in),
+	}
+	out := new(v1.ListTasksResponse)
+	if err := c.Generator.invoke(ctx, request, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TodoistClient is the REST client of TodoistService
+type TodoistClient struct {
+	// Generator sends the requests; configure retries, timeouts and middleware on it
+	Generator *ClientGenerator
+}
+
+// NewTodoistClient creates a REST client of TodoistService
+func NewTodoistClient(baseURL string) *TodoistClient {
+	return &TodoistClient{Generator: NewClientGenerator(baseURL)}
+}
+
+// ProcessWebhook processes incoming webhook notifications from Todoist
+//
+// POST /webhooks/todoist
+func (c *TodoistClient) ProcessWebhook(ctx context.Context, in *v1.TodoistWebhookRequest) (*v1.TodoistWebhookResponse, error) {
+	request := &Request{
+		Method: "POST",
+		Path:   "/webhooks/todoist",
+		Body:   in,
+	}
+	out := new(v1.TodoistWebhookResponse)
+	if err := c.Generator.invoke(ctx, request, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Routes lists the REST routes of all services, in the order they are declared
+var Routes = []Route{
+	{
+		FullMethod: v1.HealthService_Check_FullMethodName,
+		Method:     "GET",
+		Path:       "/health",
+	},
+	{
+		FullMethod: v1.StatsService_GetStats_FullMethodName,
+		Method:     "GET",
+		Path:       "/stats",
+	},
+	{
+		FullMethod: v1.TaskService_ListTasks_FullMethodName,
+		Method:     "GET",
+		Path:       "/tasks",
+	},
+	{
+		FullMethod: v1.TodoistService_ProcessWebhook_FullMethodName,
+		Method:     "POST",
+		Path:       "/webhooks/todoist",
+		Body:       "*",
+	},
+}
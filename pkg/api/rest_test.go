@@ -0,0 +1,108 @@
+package api_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
+)
+
+// TestQueryRoundTrip tests that DecodeQuery reads back what EncodeQuery writes
+func TestQueryRoundTrip(t *testing.T) {
+	request := &apiv1.ListTasksRequest{
+		UserId:     "1",
+		Labels:     []string{"urgent", "home"},
+		Priorities: []int32{3, 4},
+		Completion: apiv1.TaskCompletion_TASK_COMPLETION_ALL,
+		PageSize:   20,
+	}
+	values := api.EncodeQuery(request)
+	if got := values.Get("completion"); got != "all" {
+		t.Errorf("completion = %q, want all", got)
+	}
+	if got := values["labels"]; len(got) != 2 {
+		t.Errorf("labels = %v, want two values", got)
+	}
+
+	decoded := &apiv1.ListTasksRequest{}
+	if err := api.DecodeQuery(values, decoded, nil); err != nil {
+		t.Fatalf("DecodeQuery failed: %v", err)
+	}
+	if !proto.Equal(request, decoded) {
+		t.Errorf("DecodeQuery() = %v, want %v", decoded, request)
+	}
+}
+
+// TestDecodeQuery tests aliases, enum spellings and invalid values
+func TestDecodeQuery(t *testing.T) {
+	aliases := map[string]string{"label": "labels", "priority": "priorities"}
+	values := url.Values{
+		"label":      {"urgent"},
+		"priority":   {"4"},
+		"completion": {"TASK_COMPLETION_COMPLETED"},
+		"unknown":    {"ignored"},
+	}
+	request := &apiv1.ListTasksRequest{}
+	if err := api.DecodeQuery(values, request, aliases); err != nil {
+		t.Fatalf("DecodeQuery failed: %v", err)
+	}
+	if len(request.Labels) != 1 || len(request.Priorities) != 1 ||
+		request.Completion != apiv1.TaskCompletion_TASK_COMPLETION_COMPLETED {
+		t.Errorf("DecodeQuery() = %v", request)
+	}
+
+	invalid := []url.Values{
+		{"priority": {"high"}},
+		{"page_size": {"many"}},
+		{"completion": {"done"}},
+	}
+	for _, values := range invalid {
+		if err := api.DecodeQuery(values, &apiv1.ListTasksRequest{}, aliases); err == nil {
+			t.Errorf("DecodeQuery(%v) succeeded, want an error", values)
+		}
+	}
+}
+
+// TestTodoistClientSignature tests that SetSecret signs the exact webhook body
+func TestTodoistClientSignature(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		h := hmac.New(sha256.New, []byte("secret"))
+		h.Write(body)
+		if r.Header.Get(api.TodoistSignatureHeader) != hex.EncodeToString(h.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var request apiv1.TodoistWebhookRequest
+		if err := json.Unmarshal(body, &request); err != nil || request.EventName != "item:added" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(&apiv1.TodoistWebhookResponse{Success: true})
+	}))
+	defer server.Close()
+
+	client := api.NewTodoistClient(server.URL)
+	client.SetSecret("secret")
+	response, err := client.ProcessWebhook(context.Background(), &apiv1.TodoistWebhookRequest{
+		EventName: "item:added",
+		UserId:    "1",
+	})
+	if err != nil {
+		t.Fatalf("ProcessWebhook failed: %v", err)
+	}
+	if !response.Success {
+		t.Error("ProcessWebhook() success = false, want true")
+	}
+}
@@ -1,90 +1,48 @@
 package api
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 )
 
-// TodoistClient is a client for the Todoist webhook API
-type TodoistClient struct {
-	generator *ClientGenerator
-	secret    string
-}
+// TodoistSignatureHeader carries the HMAC-SHA256 signature of a webhook body
+const TodoistSignatureHeader = "X-Todoist-Hmac-SHA256"
 
-// NewTodoistClient creates a new Todoist client
-func NewTodoistClient(baseURL string) *TodoistClient {
-	return &TodoistClient{
-		generator: NewClientGenerator(baseURL),
-		secret:    "",
-	}
-}
-
-// SetSecret sets the client secret for HMAC signature calculation
+// SetSecret signs the webhook requests of the client with the client secret, the way Todoist
+// signs its webhooks. Call it once, before sending requests.
 func (c *TodoistClient) SetSecret(secret string) {
-	c.secret = secret
-}
-
-// TodoistWebhookRequest represents a request to the Todoist webhook endpoint
-type TodoistWebhookRequest struct {
-	EventName string `json:"event_name"`
-	UserID    string `json:"user_id"`
-	EventData string `json:"event_data"`
-	Version   string `json:"version"`
-}
-
-// TodoistWebhookResponse represents a response from the Todoist webhook endpoint
-type TodoistWebhookResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-}
-
-// ProcessWebhook sends a webhook notification to the Todoist webhook endpoint
-func (c *TodoistClient) ProcessWebhook(req *TodoistWebhookRequest) (*TodoistWebhookResponse, error) {
-	// Create request
-	request := &Request{
-		Method: http.MethodPost,
-		Path:   "/webhooks/todoist",
-		Body:   req,
-		Header: make(map[string]string),
-	}
-
-	// Calculate and set HMAC signature if secret is provided
-	if c.secret != "" {
-		// Marshal the request body to JSON
-		bodyBytes, err := json.Marshal(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-
-		// Calculate HMAC signature
-		h := hmac.New(sha256.New, []byte(c.secret))
-		h.Write(bodyBytes)
-		signature := hex.EncodeToString(h.Sum(nil))
-
-		// Set the signature header
-		request.Header["X-Todoist-Hmac-SHA256"] = signature
-	}
-
-	// Execute request
-	resp, err := c.generator.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to process webhook: %w", err)
-	}
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if secret == "" {
+		return
 	}
+	c.Generator.Use(signTodoistWebhook(secret))
+}
 
-	// Parse response
-	var response TodoistWebhookResponse
-	if err := json.Unmarshal(resp.Body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// signTodoistWebhook sets the signature header of every request with a body
+func signTodoistWebhook(secret string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Body == nil || req.Body == http.NoBody {
+				return next.RoundTrip(req)
+			}
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read request body: %w", err)
+			}
+
+			// Calculate the HMAC signature over the exact bytes sent
+			h := hmac.New(sha256.New, []byte(secret))
+			h.Write(body)
+
+			signed := req.Clone(req.Context())
+			signed.Body = io.NopCloser(bytes.NewReader(body))
+			signed.Header.Set(TodoistSignatureHeader, hex.EncodeToString(h.Sum(nil)))
+			return next.RoundTrip(signed)
+		})
 	}
-
-	return &response, nil
 }
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TodoistOAuthBaseURL is the base URL of the Todoist OAuth endpoints
+const TodoistOAuthBaseURL = "https://todoist.com/oauth"
+
+// TodoistOAuth runs the OAuth authorization code flow of a Todoist integration
+type TodoistOAuth struct {
+	ClientID     string
+	ClientSecret string
+
+	// BaseURL is the base URL of the OAuth endpoints
+	BaseURL string
+
+	// Scope is the comma-separated list of Todoist permissions requested, e.g. "data:read"
+	Scope string
+
+	// RedirectURI is where Todoist sends the user back to; empty means the URL configured for the
+	// integration in the Todoist App Console
+	RedirectURI string
+
+	HTTPClient *http.Client
+}
+
+// NewTodoistOAuth creates an OAuth client of the integration with the given credentials
+func NewTodoistOAuth(clientID, clientSecret string) *TodoistOAuth {
+	return &TodoistOAuth{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		BaseURL:      TodoistOAuthBaseURL,
+		Scope:        "data:read",
+		HTTPClient:   &http.Client{},
+	}
+}
+
+// AuthorizeURL returns the URL that asks the user to grant the integration access. Todoist
+// redirects back to RedirectURI with a code and the state.
+func (o *TodoistOAuth) AuthorizeURL(state string) string {
+	query := url.Values{
+		"client_id": {o.ClientID},
+		"scope":     {o.Scope},
+		"state":     {state},
+	}
+	if o.RedirectURI != "" {
+		query.Set("redirect_uri", o.RedirectURI)
+	}
+	return strings.TrimSuffix(o.BaseURL, "/") + "/authorize?" + query.Encode()
+}
+
+// Exchange trades an authorization code for the user's access token
+func (o *TodoistOAuth) Exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"client_id":     {o.ClientID},
+		"client_secret": {o.ClientSecret},
+		"code":          {code},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(o.BaseURL, "/")+"/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.Unmarshal(body, &token)
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		if token.Error != "" {
+			return "", fmt.Errorf("todoist oauth: %s: %s", token.Error, token.ErrorDescription)
+		}
+		return "", fmt.Errorf("todoist oauth: status %d: %s", resp.StatusCode, bodySnippet(body))
+	}
+	return token.AccessToken, nil
+}
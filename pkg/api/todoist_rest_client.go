@@ -0,0 +1,426 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TodoistRESTBaseURL is the base URL of the public Todoist REST API
+const TodoistRESTBaseURL = "https://api.todoist.com/api/v1"
+
+const (
+	// defaultRESTMaxRetries is how many times a rate-limited or failed request is retried
+	defaultRESTMaxRetries = 3
+
+	// defaultRESTBackoff is the initial wait before retrying a failed request
+	defaultRESTBackoff = 500 * time.Millisecond
+
+	// defaultRESTMaxBackoff caps the wait between retries when the server gives no Retry-After hint
+	defaultRESTMaxBackoff = 30 * time.Second
+
+	// defaultRESTPageSize is the page size requested when listing resources
+	defaultRESTPageSize = 200
+)
+
+// TodoistRESTClient is an outbound client for the Todoist REST API
+type TodoistRESTClient struct {
+	generator *ClientGenerator
+	token     string
+
+	// MaxRetries is the number of retries for rate-limited (429) or 5xx responses
+	MaxRetries int
+
+	// Backoff is the initial wait between retries when the server gives no Retry-After hint
+	Backoff time.Duration
+
+	// PageSize is the number of results requested per page when listing resources
+	PageSize int
+}
+
+// NewTodoistRESTClient creates a new Todoist REST client authenticated with the given API token
+func NewTodoistRESTClient(baseURL, token string) *TodoistRESTClient {
+	return &TodoistRESTClient{
+		generator:  NewClientGenerator(strings.TrimSuffix(baseURL, "/")),
+		token:      token,
+		MaxRetries: defaultRESTMaxRetries,
+		Backoff:    defaultRESTBackoff,
+		PageSize:   defaultRESTPageSize,
+	}
+}
+
+// do executes a request against the Todoist API, retrying on rate limits and server errors,
+// and decodes the JSON response into out if it is not nil
+func (c *TodoistRESTClient) do(ctx context.Context, method, path string, query map[string]string, body interface{}, out interface{}) error {
+	request := &Request{
+		Method: method,
+		Path:   path,
+		Body:   body,
+		Query:  query,
+		Header: map[string]string{
+			"Authorization": "Bearer " + c.token,
+		},
+		Retry: &RetryPolicy{
+			MaxRetries:     c.MaxRetries,
+			InitialBackoff: c.Backoff,
+			MaxBackoff:     defaultRESTMaxBackoff,
+			Jitter:         DefaultRetryPolicy.Jitter,
+		},
+	}
+
+	// Todoist deduplicates writes by request ID, which makes them safe to retry
+	if method == http.MethodPost {
+		request.Header["X-Request-Id"] = newRequestID()
+	}
+
+	// Execute request
+	resp, err := c.generator.DoContext(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	// Check status code
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return NewAPIError(resp)
+	}
+
+	// Parse response
+	if out != nil && len(resp.Body) > 0 {
+		if err := decodeJSON(resp, out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newRequestID returns a random UUID for the X-Request-Id header
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// listAll follows next_cursor until every page of a list endpoint has been read
+func listAll[T any](ctx context.Context, c *TodoistRESTClient, path string, query map[string]string) ([]T, error) {
+	params := map[string]string{"limit": strconv.Itoa(c.PageSize)}
+	for k, v := range query {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	results := []T{}
+	for {
+		var page Page[T]
+		if err := c.do(ctx, http.MethodGet, path, params, nil, &page); err != nil {
+			return nil, err
+		}
+		results = append(results, page.Results...)
+
+		if page.NextCursor == nil || *page.NextCursor == "" {
+			return results, nil
+		}
+		params["cursor"] = *page.NextCursor
+	}
+}
+
+// resourcePath joins a collection path and an escaped resource ID, plus an optional action
+func resourcePath(collection, id string, action ...string) string {
+	parts := append([]string{collection, url.PathEscape(id)}, action...)
+	return strings.Join(parts, "/")
+}
+
+// GetUser returns the user the client's token belongs to
+func (c *TodoistRESTClient) GetUser(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, "/user", nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListTasks returns all active tasks matching the given parameters
+func (c *TodoistRESTClient) ListTasks(ctx context.Context, params *ListTasksParams) ([]Task, error) {
+	query := map[string]string{}
+	if params != nil {
+		query["project_id"] = params.ProjectID
+		query["section_id"] = params.SectionID
+		query["parent_id"] = params.ParentID
+		query["label"] = params.Label
+		query["ids"] = strings.Join(params.IDs, ",")
+	}
+
+	tasks, err := listAll[Task](ctx, c, "/tasks", query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// GetTask returns a single task
+func (c *TodoistRESTClient) GetTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, resourcePath("/tasks", id), nil, nil, &task); err != nil {
+		return nil, fmt.Errorf("failed to get task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+// CreateTask creates a new task
+func (c *TodoistRESTClient) CreateTask(ctx context.Context, args *CreateTaskArgs) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPost, "/tasks", nil, args, &task); err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return &task, nil
+}
+
+// UpdateTask updates an existing task
+func (c *TodoistRESTClient) UpdateTask(ctx context.Context, id string, args *UpdateTaskArgs) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPost, resourcePath("/tasks", id), nil, args, &task); err != nil {
+		return nil, fmt.Errorf("failed to update task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+// CloseTask marks a task as completed
+func (c *TodoistRESTClient) CloseTask(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, resourcePath("/tasks", id, "close"), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to close task %s: %w", id, err)
+	}
+	return nil
+}
+
+// ReopenTask marks a completed task as active again
+func (c *TodoistRESTClient) ReopenTask(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, resourcePath("/tasks", id, "reopen"), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to reopen task %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteTask deletes a task
+func (c *TodoistRESTClient) DeleteTask(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, resourcePath("/tasks", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete task %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListProjects returns all projects
+func (c *TodoistRESTClient) ListProjects(ctx context.Context) ([]Project, error) {
+	projects, err := listAll[Project](ctx, c, "/projects", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	return projects, nil
+}
+
+// GetProject returns a single project
+func (c *TodoistRESTClient) GetProject(ctx context.Context, id string) (*Project, error) {
+	var project Project
+	if err := c.do(ctx, http.MethodGet, resourcePath("/projects", id), nil, nil, &project); err != nil {
+		return nil, fmt.Errorf("failed to get project %s: %w", id, err)
+	}
+	return &project, nil
+}
+
+// CreateProject creates a new project
+func (c *TodoistRESTClient) CreateProject(ctx context.Context, args *CreateProjectArgs) (*Project, error) {
+	var project Project
+	if err := c.do(ctx, http.MethodPost, "/projects", nil, args, &project); err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+	return &project, nil
+}
+
+// UpdateProject updates an existing project
+func (c *TodoistRESTClient) UpdateProject(ctx context.Context, id string, args *UpdateProjectArgs) (*Project, error) {
+	var project Project
+	if err := c.do(ctx, http.MethodPost, resourcePath("/projects", id), nil, args, &project); err != nil {
+		return nil, fmt.Errorf("failed to update project %s: %w", id, err)
+	}
+	return &project, nil
+}
+
+// ArchiveProject closes a project by archiving it
+func (c *TodoistRESTClient) ArchiveProject(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, resourcePath("/projects", id, "archive"), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to archive project %s: %w", id, err)
+	}
+	return nil
+}
+
+// UnarchiveProject reopens an archived project
+func (c *TodoistRESTClient) UnarchiveProject(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, resourcePath("/projects", id, "unarchive"), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to unarchive project %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteProject deletes a project and everything in it
+func (c *TodoistRESTClient) DeleteProject(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, resourcePath("/projects", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete project %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListSections returns all sections, optionally restricted to a project
+func (c *TodoistRESTClient) ListSections(ctx context.Context, projectID string) ([]Section, error) {
+	sections, err := listAll[Section](ctx, c, "/sections", map[string]string{"project_id": projectID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sections: %w", err)
+	}
+	return sections, nil
+}
+
+// GetSection returns a single section
+func (c *TodoistRESTClient) GetSection(ctx context.Context, id string) (*Section, error) {
+	var section Section
+	if err := c.do(ctx, http.MethodGet, resourcePath("/sections", id), nil, nil, &section); err != nil {
+		return nil, fmt.Errorf("failed to get section %s: %w", id, err)
+	}
+	return &section, nil
+}
+
+// CreateSection creates a new section
+func (c *TodoistRESTClient) CreateSection(ctx context.Context, args *CreateSectionArgs) (*Section, error) {
+	var section Section
+	if err := c.do(ctx, http.MethodPost, "/sections", nil, args, &section); err != nil {
+		return nil, fmt.Errorf("failed to create section: %w", err)
+	}
+	return &section, nil
+}
+
+// UpdateSection updates an existing section
+func (c *TodoistRESTClient) UpdateSection(ctx context.Context, id string, args *UpdateSectionArgs) (*Section, error) {
+	var section Section
+	if err := c.do(ctx, http.MethodPost, resourcePath("/sections", id), nil, args, &section); err != nil {
+		return nil, fmt.Errorf("failed to update section %s: %w", id, err)
+	}
+	return &section, nil
+}
+
+// ArchiveSection closes a section by archiving it
+func (c *TodoistRESTClient) ArchiveSection(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, resourcePath("/sections", id, "archive"), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to archive section %s: %w", id, err)
+	}
+	return nil
+}
+
+// UnarchiveSection reopens an archived section
+func (c *TodoistRESTClient) UnarchiveSection(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, resourcePath("/sections", id, "unarchive"), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to unarchive section %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteSection deletes a section and its tasks
+func (c *TodoistRESTClient) DeleteSection(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, resourcePath("/sections", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete section %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListLabels returns all personal labels
+func (c *TodoistRESTClient) ListLabels(ctx context.Context) ([]Label, error) {
+	labels, err := listAll[Label](ctx, c, "/labels", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+	return labels, nil
+}
+
+// GetLabel returns a single personal label
+func (c *TodoistRESTClient) GetLabel(ctx context.Context, id string) (*Label, error) {
+	var label Label
+	if err := c.do(ctx, http.MethodGet, resourcePath("/labels", id), nil, nil, &label); err != nil {
+		return nil, fmt.Errorf("failed to get label %s: %w", id, err)
+	}
+	return &label, nil
+}
+
+// CreateLabel creates a new personal label
+func (c *TodoistRESTClient) CreateLabel(ctx context.Context, args *CreateLabelArgs) (*Label, error) {
+	var label Label
+	if err := c.do(ctx, http.MethodPost, "/labels", nil, args, &label); err != nil {
+		return nil, fmt.Errorf("failed to create label: %w", err)
+	}
+	return &label, nil
+}
+
+// UpdateLabel updates an existing personal label
+func (c *TodoistRESTClient) UpdateLabel(ctx context.Context, id string, args *UpdateLabelArgs) (*Label, error) {
+	var label Label
+	if err := c.do(ctx, http.MethodPost, resourcePath("/labels", id), nil, args, &label); err != nil {
+		return nil, fmt.Errorf("failed to update label %s: %w", id, err)
+	}
+	return &label, nil
+}
+
+// DeleteLabel deletes a personal label
+func (c *TodoistRESTClient) DeleteLabel(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, resourcePath("/labels", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete label %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListComments returns all comments on a task or a project
+func (c *TodoistRESTClient) ListComments(ctx context.Context, taskID, projectID string) ([]Comment, error) {
+	query := map[string]string{"task_id": taskID, "project_id": projectID}
+	comments, err := listAll[Comment](ctx, c, "/comments", query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	return comments, nil
+}
+
+// GetComment returns a single comment
+func (c *TodoistRESTClient) GetComment(ctx context.Context, id string) (*Comment, error) {
+	var comment Comment
+	if err := c.do(ctx, http.MethodGet, resourcePath("/comments", id), nil, nil, &comment); err != nil {
+		return nil, fmt.Errorf("failed to get comment %s: %w", id, err)
+	}
+	return &comment, nil
+}
+
+// CreateComment adds a comment to a task or a project
+func (c *TodoistRESTClient) CreateComment(ctx context.Context, args *CreateCommentArgs) (*Comment, error) {
+	var comment Comment
+	if err := c.do(ctx, http.MethodPost, "/comments", nil, args, &comment); err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	return &comment, nil
+}
+
+// UpdateComment updates an existing comment
+func (c *TodoistRESTClient) UpdateComment(ctx context.Context, id string, args *UpdateCommentArgs) (*Comment, error) {
+	var comment Comment
+	if err := c.do(ctx, http.MethodPost, resourcePath("/comments", id), nil, args, &comment); err != nil {
+		return nil, fmt.Errorf("failed to update comment %s: %w", id, err)
+	}
+	return &comment, nil
+}
+
+// DeleteComment deletes a comment
+func (c *TodoistRESTClient) DeleteComment(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, resourcePath("/comments", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete comment %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"cherry_backend/pkg/api"
+	"cherry_backend/pkg/api/todoisttest"
+)
+
+// newTestRESTClient starts a fake Todoist server and returns a client pointed at it
+func newTestRESTClient(t *testing.T) (*api.TodoistRESTClient, *todoisttest.Server) {
+	server := todoisttest.NewServer("test-token")
+	t.Cleanup(server.Close)
+
+	client := api.NewTodoistRESTClient(server.URL, "test-token")
+	client.Backoff = time.Millisecond
+	return client, server
+}
+
+// TestTaskLifecycle tests creating, updating, closing, reopening and deleting a task
+func TestTaskLifecycle(t *testing.T) {
+	client, server := newTestRESTClient(t)
+
+	project, err := client.CreateProject(context.Background(), &api.CreateProjectArgs{Name: "Work"})
+	if err != nil {
+		t.Fatalf("CreateProject failed: %v", err)
+	}
+
+	task, err := client.CreateTask(context.Background(), &api.CreateTaskArgs{
+		Content:   "Write report",
+		ProjectID: project.ID,
+		Labels:    []string{"waiting"},
+		Priority:  4,
+		DueString: "tomorrow",
+	})
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if task.Content != "Write report" || task.ProjectID != project.ID || task.Priority != 4 {
+		t.Errorf("CreateTask() = %+v, want content, project and priority set", task)
+	}
+
+	content := "Write the quarterly report"
+	updated, err := client.UpdateTask(context.Background(), task.ID, &api.UpdateTaskArgs{Content: &content})
+	if err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if updated.Content != content {
+		t.Errorf("UpdateTask() content = %s, want %s", updated.Content, content)
+	}
+	if len(updated.Labels) != 1 || updated.Labels[0] != "waiting" {
+		t.Errorf("UpdateTask() labels = %v, want untouched labels", updated.Labels)
+	}
+
+	if err := client.CloseTask(context.Background(), task.ID); err != nil {
+		t.Fatalf("CloseTask failed: %v", err)
+	}
+	if !server.Task(task.ID).Checked {
+		t.Error("CloseTask did not complete the task")
+	}
+
+	if err := client.ReopenTask(context.Background(), task.ID); err != nil {
+		t.Fatalf("ReopenTask failed: %v", err)
+	}
+	if server.Task(task.ID).Checked {
+		t.Error("ReopenTask did not reopen the task")
+	}
+
+	if err := client.DeleteTask(context.Background(), task.ID); err != nil {
+		t.Fatalf("DeleteTask failed: %v", err)
+	}
+	if _, err := client.GetTask(context.Background(), task.ID); err == nil {
+		t.Error("GetTask succeeded after DeleteTask")
+	}
+}
+
+// TestListTasksPagination tests that ListTasks follows cursors across pages
+func TestListTasksPagination(t *testing.T) {
+	client, _ := newTestRESTClient(t)
+	client.PageSize = 2
+
+	for i := 0; i < 5; i++ {
+		if _, err := client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Task " + strconv.Itoa(i)}); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+	}
+
+	tasks, err := client.ListTasks(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(tasks) != 5 {
+		t.Fatalf("ListTasks() returned %d tasks, want 5", len(tasks))
+	}
+	for i, task := range tasks {
+		if want := "Task " + strconv.Itoa(i); task.Content != want {
+			t.Errorf("ListTasks()[%d] = %s, want %s", i, task.Content, want)
+		}
+	}
+}
+
+// TestListTasksFilters tests the project and label filters of ListTasks
+func TestListTasksFilters(t *testing.T) {
+	client, _ := newTestRESTClient(t)
+
+	home, _ := client.CreateProject(context.Background(), &api.CreateProjectArgs{Name: "Home"})
+	work, _ := client.CreateProject(context.Background(), &api.CreateProjectArgs{Name: "Work"})
+	client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Dishes", ProjectID: home.ID})
+	client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Email", ProjectID: work.ID, Labels: []string{"urgent"}})
+	client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Review", ProjectID: work.ID})
+
+	tasks, err := client.ListTasks(context.Background(), &api.ListTasksParams{ProjectID: work.ID})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("ListTasks(project) returned %d tasks, want 2", len(tasks))
+	}
+
+	tasks, err = client.ListTasks(context.Background(), &api.ListTasksParams{Label: "urgent"})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Content != "Email" {
+		t.Errorf("ListTasks(label) = %+v, want only Email", tasks)
+	}
+}
+
+// TestResourceCRUD tests the section, label and comment endpoints
+func TestResourceCRUD(t *testing.T) {
+	client, _ := newTestRESTClient(t)
+
+	project, _ := client.CreateProject(context.Background(), &api.CreateProjectArgs{Name: "Work"})
+	if err := client.ArchiveProject(context.Background(), project.ID); err != nil {
+		t.Fatalf("ArchiveProject failed: %v", err)
+	}
+	archived, err := client.GetProject(context.Background(), project.ID)
+	if err != nil {
+		t.Fatalf("GetProject failed: %v", err)
+	}
+	if !archived.IsArchived {
+		t.Error("ArchiveProject did not archive the project")
+	}
+
+	section, err := client.CreateSection(context.Background(), &api.CreateSectionArgs{Name: "Backlog", ProjectID: project.ID})
+	if err != nil {
+		t.Fatalf("CreateSection failed: %v", err)
+	}
+	if _, err := client.UpdateSection(context.Background(), section.ID, &api.UpdateSectionArgs{Name: "Later"}); err != nil {
+		t.Fatalf("UpdateSection failed: %v", err)
+	}
+	sections, err := client.ListSections(context.Background(), project.ID)
+	if err != nil {
+		t.Fatalf("ListSections failed: %v", err)
+	}
+	if len(sections) != 1 || sections[0].Name != "Later" {
+		t.Errorf("ListSections() = %+v, want the renamed section", sections)
+	}
+
+	label, err := client.CreateLabel(context.Background(), &api.CreateLabelArgs{Name: "waiting"})
+	if err != nil {
+		t.Fatalf("CreateLabel failed: %v", err)
+	}
+	if err := client.DeleteLabel(context.Background(), label.ID); err != nil {
+		t.Fatalf("DeleteLabel failed: %v", err)
+	}
+	labels, err := client.ListLabels(context.Background())
+	if err != nil {
+		t.Fatalf("ListLabels failed: %v", err)
+	}
+	if len(labels) != 0 {
+		t.Errorf("ListLabels() returned %d labels after delete, want 0", len(labels))
+	}
+
+	task, _ := client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Call Bob"})
+	comment, err := client.CreateComment(context.Background(), &api.CreateCommentArgs{Content: "Left a voicemail", TaskID: task.ID})
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	comments, err := client.ListComments(context.Background(), task.ID, "")
+	if err != nil {
+		t.Fatalf("ListComments failed: %v", err)
+	}
+	if len(comments) != 1 || comments[0].ID != comment.ID {
+		t.Errorf("ListComments() = %+v, want the created comment", comments)
+	}
+}
+
+// TestRateLimitRetry tests that rate-limited requests are retried after Retry-After
+func TestRateLimitRetry(t *testing.T) {
+	client, server := newTestRESTClient(t)
+
+	server.RateLimitNext(2, 0)
+	if _, err := client.CreateProject(context.Background(), &api.CreateProjectArgs{Name: "Work"}); err != nil {
+		t.Fatalf("CreateProject failed after rate limiting: %v", err)
+	}
+	if got := server.Requests(); got != 3 {
+		t.Errorf("server received %d requests, want 3", got)
+	}
+}
+
+// TestRetryExhausted tests that an error is returned once retries run out
+func TestRetryExhausted(t *testing.T) {
+	client, server := newTestRESTClient(t)
+	client.MaxRetries = 1
+
+	server.FailNext(2, http.StatusServiceUnavailable)
+	if _, err := client.ListProjects(context.Background()); err == nil {
+		t.Fatal("ListProjects succeeded, want an error after retries are exhausted")
+	}
+	if got := server.Requests(); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+// TestUnauthorized tests that a wrong token is reported as an error
+func TestUnauthorized(t *testing.T) {
+	server := todoisttest.NewServer("test-token")
+	defer server.Close()
+
+	client := api.NewTodoistRESTClient(server.URL, "wrong-token")
+	if _, err := client.ListProjects(context.Background()); err == nil {
+		t.Fatal("ListProjects succeeded with a wrong token")
+	}
+}
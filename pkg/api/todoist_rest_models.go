@@ -0,0 +1,204 @@
+package api
+
+// Due represents the due date of a Todoist task
+type Due struct {
+	Date        string `json:"date"`
+	String      string `json:"string,omitempty"`
+	Lang        string `json:"lang,omitempty"`
+	IsRecurring bool   `json:"is_recurring"`
+	Timezone    string `json:"timezone,omitempty"`
+}
+
+// Duration represents the estimated duration of a Todoist task
+type Duration struct {
+	Amount int    `json:"amount"`
+	Unit   string `json:"unit"`
+}
+
+// Task represents a Todoist task
+type Task struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id,omitempty"`
+	ProjectID      string    `json:"project_id"`
+	SectionID      string    `json:"section_id,omitempty"`
+	ParentID       string    `json:"parent_id,omitempty"`
+	Content        string    `json:"content"`
+	Description    string    `json:"description"`
+	Labels         []string  `json:"labels"`
+	Priority       int       `json:"priority"`
+	Due            *Due      `json:"due,omitempty"`
+	Duration       *Duration `json:"duration,omitempty"`
+	ChildOrder     int       `json:"child_order"`
+	Checked        bool      `json:"checked"`
+	IsDeleted      bool      `json:"is_deleted"`
+	AddedByUID     string    `json:"added_by_uid,omitempty"`
+	AssignedByUID  string    `json:"assigned_by_uid,omitempty"`
+	ResponsibleUID string    `json:"responsible_uid,omitempty"`
+	NoteCount      int       `json:"note_count"`
+	AddedAt        string    `json:"added_at,omitempty"`
+	CompletedAt    string    `json:"completed_at,omitempty"`
+	UpdatedAt      string    `json:"updated_at,omitempty"`
+}
+
+// Project represents a Todoist project
+type Project struct {
+	ID           string `json:"id"`
+	ParentID     string `json:"parent_id,omitempty"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Color        string `json:"color"`
+	ChildOrder   int    `json:"child_order"`
+	IsFavorite   bool   `json:"is_favorite"`
+	IsArchived   bool   `json:"is_archived"`
+	IsDeleted    bool   `json:"is_deleted"`
+	InboxProject bool   `json:"inbox_project"`
+	ViewStyle    string `json:"view_style"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+}
+
+// Section represents a section within a Todoist project
+type Section struct {
+	ID           string `json:"id"`
+	ProjectID    string `json:"project_id"`
+	Name         string `json:"name"`
+	SectionOrder int    `json:"section_order"`
+	IsArchived   bool   `json:"is_archived"`
+	IsDeleted    bool   `json:"is_deleted"`
+	AddedAt      string `json:"added_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+}
+
+// Label represents a personal Todoist label
+type Label struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Color      string `json:"color"`
+	Order      int    `json:"order"`
+	IsFavorite bool   `json:"is_favorite"`
+	IsDeleted  bool   `json:"is_deleted,omitempty"`
+}
+
+// Comment represents a comment on a Todoist task or project
+type Comment struct {
+	ID        string `json:"id"`
+	ItemID    string `json:"item_id,omitempty"`
+	ProjectID string `json:"project_id,omitempty"`
+	Content   string `json:"content"`
+	PostedUID string `json:"posted_uid,omitempty"`
+	PostedAt  string `json:"posted_at,omitempty"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// User represents the Todoist user a token belongs to
+type User struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+	Timezone string `json:"tz,omitempty"`
+}
+
+// Page represents a single page of a paginated Todoist list response
+type Page[T any] struct {
+	Results    []T     `json:"results"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// ListTasksParams filters the tasks returned by ListTasks
+type ListTasksParams struct {
+	ProjectID string
+	SectionID string
+	ParentID  string
+	Label     string
+	IDs       []string
+}
+
+// CreateTaskArgs holds the fields for a new task
+type CreateTaskArgs struct {
+	Content      string   `json:"content"`
+	Description  string   `json:"description,omitempty"`
+	ProjectID    string   `json:"project_id,omitempty"`
+	SectionID    string   `json:"section_id,omitempty"`
+	ParentID     string   `json:"parent_id,omitempty"`
+	Labels       []string `json:"labels,omitempty"`
+	Priority     int      `json:"priority,omitempty"`
+	DueString    string   `json:"due_string,omitempty"`
+	DueDate      string   `json:"due_date,omitempty"`
+	DueDatetime  string   `json:"due_datetime,omitempty"`
+	DueLang      string   `json:"due_lang,omitempty"`
+	AssigneeID   string   `json:"assignee_id,omitempty"`
+	Duration     int      `json:"duration,omitempty"`
+	DurationUnit string   `json:"duration_unit,omitempty"`
+}
+
+// UpdateTaskArgs holds the fields to change on an existing task. Nil fields are left untouched.
+type UpdateTaskArgs struct {
+	Content     *string   `json:"content,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Labels      *[]string `json:"labels,omitempty"`
+	Priority    *int      `json:"priority,omitempty"`
+	DueString   *string   `json:"due_string,omitempty"`
+	DueDate     *string   `json:"due_date,omitempty"`
+	DueDatetime *string   `json:"due_datetime,omitempty"`
+	DueLang     *string   `json:"due_lang,omitempty"`
+	AssigneeID  *string   `json:"assignee_id,omitempty"`
+}
+
+// CreateProjectArgs holds the fields for a new project
+type CreateProjectArgs struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	ParentID    string `json:"parent_id,omitempty"`
+	Color       string `json:"color,omitempty"`
+	IsFavorite  bool   `json:"is_favorite,omitempty"`
+	ViewStyle   string `json:"view_style,omitempty"`
+}
+
+// UpdateProjectArgs holds the fields to change on an existing project. Nil fields are left untouched.
+type UpdateProjectArgs struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	IsFavorite  *bool   `json:"is_favorite,omitempty"`
+	ViewStyle   *string `json:"view_style,omitempty"`
+}
+
+// CreateSectionArgs holds the fields for a new section
+type CreateSectionArgs struct {
+	Name      string `json:"name"`
+	ProjectID string `json:"project_id"`
+	Order     int    `json:"order,omitempty"`
+}
+
+// UpdateSectionArgs holds the fields to change on an existing section
+type UpdateSectionArgs struct {
+	Name string `json:"name"`
+}
+
+// CreateLabelArgs holds the fields for a new label
+type CreateLabelArgs struct {
+	Name       string `json:"name"`
+	Color      string `json:"color,omitempty"`
+	Order      int    `json:"order,omitempty"`
+	IsFavorite bool   `json:"is_favorite,omitempty"`
+}
+
+// UpdateLabelArgs holds the fields to change on an existing label. Nil fields are left untouched.
+type UpdateLabelArgs struct {
+	Name       *string `json:"name,omitempty"`
+	Color      *string `json:"color,omitempty"`
+	Order      *int    `json:"order,omitempty"`
+	IsFavorite *bool   `json:"is_favorite,omitempty"`
+}
+
+// CreateCommentArgs holds the fields for a new comment. Exactly one of TaskID or ProjectID must be set.
+type CreateCommentArgs struct {
+	Content   string `json:"content"`
+	TaskID    string `json:"task_id,omitempty"`
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// UpdateCommentArgs holds the fields to change on an existing comment
+type UpdateCommentArgs struct {
+	Content string `json:"content"`
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// FullSyncToken is the sync token that requests a full sync
+const FullSyncToken = "*"
+
+// DefaultSyncResourceTypes are the resource types requested when none are specified
+var DefaultSyncResourceTypes = []string{"items", "projects", "sections", "labels", "notes"}
+
+// SyncRequest represents a request to the Todoist Sync API
+type SyncRequest struct {
+	SyncToken     string   `json:"sync_token"`
+	ResourceTypes []string `json:"resource_types"`
+}
+
+// SyncResponse represents a response from the Todoist Sync API. On an incremental sync only
+// resources changed since the given sync token are returned; deleted resources have IsDeleted set.
+type SyncResponse struct {
+	SyncToken string    `json:"sync_token"`
+	FullSync  bool      `json:"full_sync"`
+	Items     []Task    `json:"items"`
+	Projects  []Project `json:"projects"`
+	Sections  []Section `json:"sections"`
+	Labels    []Label   `json:"labels"`
+	Notes     []Comment `json:"notes"`
+}
+
+// Sync reads the resources changed since syncToken from the Todoist Sync API.
+// Pass FullSyncToken to read every resource.
+func (c *TodoistRESTClient) Sync(ctx context.Context, syncToken string, resourceTypes ...string) (*SyncResponse, error) {
+	if len(resourceTypes) == 0 {
+		resourceTypes = DefaultSyncResourceTypes
+	}
+	if syncToken == "" {
+		syncToken = FullSyncToken
+	}
+
+	request := &SyncRequest{
+		SyncToken:     syncToken,
+		ResourceTypes: resourceTypes,
+	}
+
+	var response SyncResponse
+	if err := c.do(ctx, http.MethodPost, "/sync", nil, request, &response); err != nil {
+		return nil, fmt.Errorf("failed to sync: %w", err)
+	}
+	return &response, nil
+}
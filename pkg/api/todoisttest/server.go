@@ -0,0 +1,924 @@
+// Package todoisttest provides an in-memory fake of the Todoist REST API for offline tests
+package todoisttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/pkg/api"
+)
+
+// Server is a fake Todoist REST API server backed by in-memory state
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	token    string
+	nextID   int
+	tasks    map[string]*api.Task
+	projects map[string]*api.Project
+	sections map[string]*api.Section
+	labels   map[string]*api.Label
+	comments map[string]*api.Comment
+
+	// sync state: every change bumps rev; revs and tombstones are keyed by "resource/id"
+	rev        int
+	revs       map[string]int
+	tombstones map[string]tombstone
+
+	// injected failures: the next len(failures) requests fail with the given status
+	failures   []int
+	retryAfter string
+	requests   int
+
+	// changes made by the current request, passed to onChange once it has been handled
+	changes  []Change
+	onChange func(Change)
+}
+
+// NewServer starts a fake Todoist server that accepts the given API token
+func NewServer(token string) *Server {
+	s := NewUnstartedServer(token)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer creates a fake Todoist server without starting it, to serve its API from
+// another handler through ServeHTTP
+func NewUnstartedServer(token string) *Server {
+	s := &Server{
+		token:    token,
+		tasks:    make(map[string]*api.Task),
+		projects: make(map[string]*api.Project),
+		sections: make(map[string]*api.Section),
+		labels:   make(map[string]*api.Label),
+		comments: make(map[string]*api.Comment),
+
+		revs:       make(map[string]int),
+		tombstones: make(map[string]tombstone),
+	}
+	s.Server = httptest.NewUnstartedServer(s.router())
+	return s
+}
+
+// ServeHTTP serves the fake API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Config.Handler.ServeHTTP(w, r)
+}
+
+// router sets up all the routes of the fake API
+func (s *Server) router() http.Handler {
+	r := mux.NewRouter()
+	r.Use(s.middleware)
+
+	r.HandleFunc("/tasks", s.listTasks).Methods("GET")
+	r.HandleFunc("/tasks", s.createTask).Methods("POST")
+	r.HandleFunc("/tasks/{id}", s.getTask).Methods("GET")
+	r.HandleFunc("/tasks/{id}", s.updateTask).Methods("POST")
+	r.HandleFunc("/tasks/{id}", s.deleteTask).Methods("DELETE")
+	r.HandleFunc("/tasks/{id}/close", s.setTaskChecked(true)).Methods("POST")
+	r.HandleFunc("/tasks/{id}/reopen", s.setTaskChecked(false)).Methods("POST")
+
+	r.HandleFunc("/projects", s.listProjects).Methods("GET")
+	r.HandleFunc("/projects", s.createProject).Methods("POST")
+	r.HandleFunc("/projects/{id}", s.getProject).Methods("GET")
+	r.HandleFunc("/projects/{id}", s.updateProject).Methods("POST")
+	r.HandleFunc("/projects/{id}", s.deleteProject).Methods("DELETE")
+	r.HandleFunc("/projects/{id}/archive", s.setProjectArchived(true)).Methods("POST")
+	r.HandleFunc("/projects/{id}/unarchive", s.setProjectArchived(false)).Methods("POST")
+
+	r.HandleFunc("/sections", s.listSections).Methods("GET")
+	r.HandleFunc("/sections", s.createSection).Methods("POST")
+	r.HandleFunc("/sections/{id}", s.getSection).Methods("GET")
+	r.HandleFunc("/sections/{id}", s.updateSection).Methods("POST")
+	r.HandleFunc("/sections/{id}", s.deleteSection).Methods("DELETE")
+	r.HandleFunc("/sections/{id}/archive", s.setSectionArchived(true)).Methods("POST")
+	r.HandleFunc("/sections/{id}/unarchive", s.setSectionArchived(false)).Methods("POST")
+
+	r.HandleFunc("/labels", s.listLabels).Methods("GET")
+	r.HandleFunc("/labels", s.createLabel).Methods("POST")
+	r.HandleFunc("/labels/{id}", s.getLabel).Methods("GET")
+	r.HandleFunc("/labels/{id}", s.updateLabel).Methods("POST")
+	r.HandleFunc("/labels/{id}", s.deleteLabel).Methods("DELETE")
+
+	r.HandleFunc("/comments", s.listComments).Methods("GET")
+	r.HandleFunc("/comments", s.createComment).Methods("POST")
+	r.HandleFunc("/comments/{id}", s.getComment).Methods("GET")
+	r.HandleFunc("/comments/{id}", s.updateComment).Methods("POST")
+	r.HandleFunc("/comments/{id}", s.deleteComment).Methods("DELETE")
+
+	r.HandleFunc("/sync", s.sync).Methods("POST")
+
+	return r
+}
+
+// middleware counts requests, applies injected failures and checks the bearer token
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		if len(s.failures) > 0 {
+			status := s.failures[0]
+			s.failures = s.failures[1:]
+			retryAfter := s.retryAfter
+			s.mu.Unlock()
+
+			if status == http.StatusTooManyRequests && retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		s.mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer "+s.token {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		s.mu.Lock()
+		onChange := s.onChange
+		s.mu.Unlock()
+		if onChange == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Hold the response back until the changes have been reported, so that a client sees
+		// their effects, e.g. webhooks, as soon as its request returns
+		recorder := httptest.NewRecorder()
+		next.ServeHTTP(recorder, r)
+		s.mu.Lock()
+		changes := s.changes
+		s.changes = nil
+		s.mu.Unlock()
+		for _, change := range changes {
+			onChange(change)
+		}
+
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	})
+}
+
+// Change is a change to the state of the server, named after the webhook event Todoist sends for it
+type Change struct {
+	// Event is the webhook event name, e.g. item:added or project:archived
+	Event string
+
+	// Data is a copy of the resource after the change, the event_data of the webhook
+	Data interface{}
+}
+
+// OnChange registers a function called with every change made through the API, in order, after
+// the request has been handled and before its response is sent
+func (s *Server) OnChange(f func(Change)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = f
+}
+
+// emit records a change made by the current request; callers must hold s.mu
+func (s *Server) emit(event string, data interface{}) {
+	if s.onChange != nil {
+		s.changes = append(s.changes, Change{Event: event, Data: data})
+	}
+}
+
+// RateLimitNext makes the next n requests fail with 429 and the given Retry-After seconds
+func (s *Server) RateLimitNext(n int, retryAfterSeconds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, http.StatusTooManyRequests)
+	}
+	s.retryAfter = strconv.Itoa(retryAfterSeconds)
+}
+
+// FailNext makes the next n requests fail with the given status code
+func (s *Server) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// Requests returns the number of requests the server has received
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Task returns a copy of the stored task with the given ID, or nil if there is none
+func (s *Server) Task(id string) *api.Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil
+	}
+	copied := *task
+	return &copied
+}
+
+// newID returns the next resource ID; callers must hold s.mu
+func (s *Server) newID() string {
+	s.nextID++
+	return strconv.Itoa(s.nextID)
+}
+
+// now returns the current time in the format used by the Todoist API
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(v)
+}
+
+// decode decodes the JSON request body into v, writing a 400 response on failure
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writePage writes one page of results, honouring the limit and cursor query parameters.
+// The cursor is the offset of the first result of the page.
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+	if offset < 0 || offset > len(items) {
+		offset = len(items)
+	}
+
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	page := api.Page[T]{Results: items[offset:end]}
+	if end < len(items) {
+		next := strconv.Itoa(end)
+		page.NextCursor = &next
+	}
+	writeJSON(w, page)
+}
+
+// sortedValues returns the values of a map ordered by numeric ID
+func sortedValues[T any](m map[string]*T) []T {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(keys[i])
+		b, _ := strconv.Atoi(keys[j])
+		return a < b
+	})
+
+	values := make([]T, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, *m[k])
+	}
+	return values
+}
+
+// merge applies a JSON patch object onto v by round-tripping it through JSON
+func merge(v interface{}, patch map[string]interface{}) {
+	encoded, _ := json.Marshal(v)
+	var current map[string]interface{}
+	json.Unmarshal(encoded, &current)
+	for k, val := range patch {
+		current[k] = val
+	}
+	encoded, _ = json.Marshal(current)
+	json.Unmarshal(encoded, v)
+}
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := r.URL.Query()
+	var ids map[string]bool
+	if q.Get("ids") != "" {
+		ids = make(map[string]bool)
+		for _, id := range strings.Split(q.Get("ids"), ",") {
+			ids[id] = true
+		}
+	}
+
+	var tasks []api.Task
+	for _, task := range sortedValues(s.tasks) {
+		if task.Checked ||
+			(q.Get("project_id") != "" && task.ProjectID != q.Get("project_id")) ||
+			(q.Get("section_id") != "" && task.SectionID != q.Get("section_id")) ||
+			(q.Get("parent_id") != "" && task.ParentID != q.Get("parent_id")) ||
+			(q.Get("label") != "" && !contains(task.Labels, q.Get("label"))) ||
+			(ids != nil && !ids[task.ID]) {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	writePage(w, r, tasks)
+}
+
+// archiveEvent names the event of archiving or unarchiving a resource
+func archiveEvent(resource string, archived bool) string {
+	if archived {
+		return resource + ":archived"
+	}
+	return resource + ":unarchived"
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	var args api.CreateTaskArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	if args.Content == "" {
+		http.Error(w, "content is required", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task := &api.Task{
+		ID:          s.newID(),
+		ProjectID:   args.ProjectID,
+		SectionID:   args.SectionID,
+		ParentID:    args.ParentID,
+		Content:     args.Content,
+		Description: args.Description,
+		Labels:      args.Labels,
+		Priority:    args.Priority,
+		AddedAt:     now(),
+		UpdatedAt:   now(),
+	}
+	if task.Labels == nil {
+		task.Labels = []string{}
+	}
+	if task.Priority == 0 {
+		task.Priority = 1
+	}
+	if args.DueDate != "" || args.DueString != "" || args.DueDatetime != "" {
+		task.Due = &api.Due{Date: args.DueDate, String: args.DueString, Lang: args.DueLang}
+		if args.DueDatetime != "" {
+			task.Due.Date = args.DueDatetime
+		}
+	}
+	s.tasks[task.ID] = task
+	s.touch("items", task.ID)
+	s.emit("item:added", *task)
+	writeJSON(w, task)
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, task)
+}
+
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]interface{}
+	if !decode(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Due fields are write-only aliases for the due object
+	for _, key := range []string{"due_string", "due_date", "due_datetime", "due_lang"} {
+		value, ok := patch[key].(string)
+		delete(patch, key)
+		if !ok {
+			continue
+		}
+		if task.Due == nil {
+			task.Due = &api.Due{}
+		}
+		switch key {
+		case "due_string":
+			task.Due.String = value
+		case "due_lang":
+			task.Due.Lang = value
+		default:
+			task.Due.Date = value
+		}
+	}
+	merge(task, patch)
+	task.UpdatedAt = now()
+	s.touch("items", task.ID)
+	s.emit("item:updated", *task)
+	writeJSON(w, task)
+}
+
+func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := mux.Vars(r)["id"]
+	task, ok := s.tasks[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	deleted := *task
+	deleted.IsDeleted = true
+	s.deleteTaskLocked(id)
+	s.emit("item:deleted", deleted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setTaskChecked(checked bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		task, ok := s.tasks[mux.Vars(r)["id"]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		task.Checked = checked
+		task.CompletedAt = ""
+		if checked {
+			task.CompletedAt = now()
+		}
+		task.UpdatedAt = now()
+		s.touch("items", task.ID)
+		if checked {
+			s.emit("item:completed", *task)
+		} else {
+			s.emit("item:uncompleted", *task)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writePage(w, r, sortedValues(s.projects))
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	var args api.CreateProjectArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	if args.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := &api.Project{
+		ID:          s.newID(),
+		ParentID:    args.ParentID,
+		Name:        args.Name,
+		Description: args.Description,
+		Color:       args.Color,
+		IsFavorite:  args.IsFavorite,
+		ViewStyle:   args.ViewStyle,
+		CreatedAt:   now(),
+		UpdatedAt:   now(),
+	}
+	if project.Color == "" {
+		project.Color = "charcoal"
+	}
+	if project.ViewStyle == "" {
+		project.ViewStyle = "list"
+	}
+	s.projects[project.ID] = project
+	s.touch("projects", project.ID)
+	s.emit("project:added", *project)
+	writeJSON(w, project)
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, ok := s.projects[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, project)
+}
+
+func (s *Server) updateProject(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]interface{}
+	if !decode(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, ok := s.projects[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	merge(project, patch)
+	project.UpdatedAt = now()
+	s.touch("projects", project.ID)
+	s.emit("project:updated", *project)
+	writeJSON(w, project)
+}
+
+func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := mux.Vars(r)["id"]
+	if _, ok := s.projects[id]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	project := *s.projects[id]
+	project.IsDeleted = true
+	delete(s.projects, id)
+	s.bury("projects", id, project)
+	s.emit("project:deleted", project)
+
+	// Deleting a project removes everything inside it
+	for taskID, task := range s.tasks {
+		if task.ProjectID == id {
+			s.deleteTaskLocked(taskID)
+		}
+	}
+	for sectionID, section := range s.sections {
+		if section.ProjectID == id {
+			s.deleteSectionLocked(sectionID)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setProjectArchived(archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		project, ok := s.projects[mux.Vars(r)["id"]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		project.IsArchived = archived
+		project.UpdatedAt = now()
+		s.touch("projects", project.ID)
+		s.emit(archiveEvent("project", archived), *project)
+		writeJSON(w, project)
+	}
+}
+
+func (s *Server) listSections(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	projectID := r.URL.Query().Get("project_id")
+	var sections []api.Section
+	for _, section := range sortedValues(s.sections) {
+		if projectID != "" && section.ProjectID != projectID {
+			continue
+		}
+		sections = append(sections, section)
+	}
+	writePage(w, r, sections)
+}
+
+func (s *Server) createSection(w http.ResponseWriter, r *http.Request) {
+	var args api.CreateSectionArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	if args.Name == "" || args.ProjectID == "" {
+		http.Error(w, "name and project_id are required", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	section := &api.Section{
+		ID:           s.newID(),
+		ProjectID:    args.ProjectID,
+		Name:         args.Name,
+		SectionOrder: args.Order,
+		AddedAt:      now(),
+		UpdatedAt:    now(),
+	}
+	s.sections[section.ID] = section
+	s.touch("sections", section.ID)
+	s.emit("section:added", *section)
+	writeJSON(w, section)
+}
+
+func (s *Server) getSection(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	section, ok := s.sections[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, section)
+}
+
+func (s *Server) updateSection(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]interface{}
+	if !decode(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	section, ok := s.sections[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	merge(section, patch)
+	section.UpdatedAt = now()
+	s.touch("sections", section.ID)
+	s.emit("section:updated", *section)
+	writeJSON(w, section)
+}
+
+func (s *Server) deleteSection(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := mux.Vars(r)["id"]
+	section, ok := s.sections[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	deleted := *section
+	deleted.IsDeleted = true
+	s.deleteSectionLocked(id)
+	s.emit("section:deleted", deleted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setSectionArchived(archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		section, ok := s.sections[mux.Vars(r)["id"]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		section.IsArchived = archived
+		section.UpdatedAt = now()
+		s.touch("sections", section.ID)
+		s.emit(archiveEvent("section", archived), *section)
+		writeJSON(w, section)
+	}
+}
+
+func (s *Server) listLabels(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writePage(w, r, sortedValues(s.labels))
+}
+
+func (s *Server) createLabel(w http.ResponseWriter, r *http.Request) {
+	var args api.CreateLabelArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	if args.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	label := &api.Label{
+		ID:         s.newID(),
+		Name:       args.Name,
+		Color:      args.Color,
+		Order:      args.Order,
+		IsFavorite: args.IsFavorite,
+	}
+	if label.Color == "" {
+		label.Color = "charcoal"
+	}
+	s.labels[label.ID] = label
+	s.touch("labels", label.ID)
+	s.emit("label:added", *label)
+	writeJSON(w, label)
+}
+
+func (s *Server) getLabel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	label, ok := s.labels[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, label)
+}
+
+func (s *Server) updateLabel(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]interface{}
+	if !decode(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	label, ok := s.labels[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	merge(label, patch)
+	s.touch("labels", label.ID)
+	s.emit("label:updated", *label)
+	writeJSON(w, label)
+}
+
+func (s *Server) deleteLabel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := mux.Vars(r)["id"]
+	label, ok := s.labels[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	deleted := *label
+	deleted.IsDeleted = true
+	delete(s.labels, id)
+	s.bury("labels", id, deleted)
+	s.emit("label:deleted", deleted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taskID := r.URL.Query().Get("task_id")
+	projectID := r.URL.Query().Get("project_id")
+	if taskID == "" && projectID == "" {
+		http.Error(w, "task_id or project_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var comments []api.Comment
+	for _, comment := range sortedValues(s.comments) {
+		if (taskID != "" && comment.ItemID != taskID) || (projectID != "" && comment.ProjectID != projectID) {
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	writePage(w, r, comments)
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request) {
+	var args api.CreateCommentArgs
+	if !decode(w, r, &args) {
+		return
+	}
+	if args.Content == "" || (args.TaskID == "") == (args.ProjectID == "") {
+		http.Error(w, "content and exactly one of task_id or project_id are required", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment := &api.Comment{
+		ID:        s.newID(),
+		ItemID:    args.TaskID,
+		ProjectID: args.ProjectID,
+		Content:   args.Content,
+		PostedAt:  now(),
+	}
+	s.comments[comment.ID] = comment
+	s.touch("notes", comment.ID)
+	s.emit("note:added", *comment)
+	if task, ok := s.tasks[args.TaskID]; ok {
+		task.NoteCount++
+		s.touch("items", task.ID)
+	}
+	writeJSON(w, comment)
+}
+
+func (s *Server) getComment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, comment)
+}
+
+func (s *Server) updateComment(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]interface{}
+	if !decode(w, r, &patch) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[mux.Vars(r)["id"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	merge(comment, patch)
+	s.touch("notes", comment.ID)
+	s.emit("note:updated", *comment)
+	writeJSON(w, comment)
+}
+
+func (s *Server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := mux.Vars(r)["id"]
+	comment, ok := s.comments[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	deleted := *comment
+	deleted.IsDeleted = true
+	delete(s.comments, id)
+	s.bury("notes", id, deleted)
+	s.emit("note:deleted", deleted)
+	if task, ok := s.tasks[comment.ItemID]; ok {
+		task.NoteCount--
+		s.touch("items", task.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
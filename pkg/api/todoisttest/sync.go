@@ -0,0 +1,122 @@
+package todoisttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cherry_backend/pkg/api"
+)
+
+// tombstone remembers a deleted resource so incremental syncs can report it
+type tombstone struct {
+	rev      int
+	resource interface{}
+}
+
+// touch records a change to a resource; callers must hold s.mu
+func (s *Server) touch(resource, id string) {
+	s.rev++
+	s.revs[resource+"/"+id] = s.rev
+}
+
+// bury records the deletion of a resource; callers must hold s.mu
+func (s *Server) bury(resource, id string, deleted interface{}) {
+	s.rev++
+	delete(s.revs, resource+"/"+id)
+	s.tombstones[resource+"/"+id] = tombstone{rev: s.rev, resource: deleted}
+}
+
+// deleteTaskLocked deletes a task and its comments; callers must hold s.mu
+func (s *Server) deleteTaskLocked(id string) {
+	task := *s.tasks[id]
+	task.IsDeleted = true
+	delete(s.tasks, id)
+	s.bury("items", id, task)
+
+	for commentID, comment := range s.comments {
+		if comment.ItemID == id {
+			deleted := *comment
+			deleted.IsDeleted = true
+			delete(s.comments, commentID)
+			s.bury("notes", commentID, deleted)
+		}
+	}
+}
+
+// deleteSectionLocked deletes a section and its tasks; callers must hold s.mu
+func (s *Server) deleteSectionLocked(id string) {
+	section := *s.sections[id]
+	section.IsDeleted = true
+	delete(s.sections, id)
+	s.bury("sections", id, section)
+
+	for taskID, task := range s.tasks {
+		if task.SectionID == id {
+			s.deleteTaskLocked(taskID)
+		}
+	}
+}
+
+// SyncToken returns the sync token describing the current state of the server
+func (s *Server) SyncToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strconv.Itoa(s.rev)
+}
+
+// sync implements the Sync API. Sync tokens are the revision counter of the server, so an
+// incremental sync returns every resource touched or deleted after that revision.
+func (s *Server) sync(w http.ResponseWriter, r *http.Request) {
+	var request api.SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	since := -1
+	if request.SyncToken != api.FullSyncToken {
+		parsed, err := strconv.Atoi(request.SyncToken)
+		if err != nil || parsed < 0 {
+			http.Error(w, "invalid sync_token", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := api.SyncResponse{
+		SyncToken: strconv.Itoa(s.rev),
+		FullSync:  since < 0,
+		Items:     changed(s, "items", s.tasks, since),
+		Projects:  changed(s, "projects", s.projects, since),
+		Sections:  changed(s, "sections", s.sections, since),
+		Labels:    changed(s, "labels", s.labels, since),
+		Notes:     changed(s, "notes", s.comments, since),
+	}
+	writeJSON(w, response)
+}
+
+// changed returns the live and deleted resources of one type changed after revision since.
+// A negative since returns every live resource and no deletions.
+func changed[T any](s *Server, resource string, live map[string]*T, since int) []T {
+	if since < 0 {
+		return sortedValues(live)
+	}
+
+	results := []T{}
+	for id, value := range live {
+		if s.revs[resource+"/"+id] > since {
+			results = append(results, *value)
+		}
+	}
+	for key, t := range s.tombstones {
+		if t.rev > since && strings.HasPrefix(key, resource+"/") {
+			results = append(results, t.resource.(T))
+		}
+	}
+	return results
+}
@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	TodoistService_ProcessWebhook_FullMethodName = "/cherry.api.v1.TodoistService/ProcessWebhook"
+	TodoistService_ProcessWebhook_FullMethodName  = "/cherry.api.v1.TodoistService/ProcessWebhook"
+	TodoistService_SubscribeEvents_FullMethodName = "/cherry.api.v1.TodoistService/SubscribeEvents"
 )
 
 // TodoistServiceClient is the client API for TodoistService service.
@@ -30,6 +31,8 @@ const (
 type TodoistServiceClient interface {
 	// ProcessWebhook processes incoming webhook notifications from Todoist
 	ProcessWebhook(ctx context.Context, in *TodoistWebhookRequest, opts ...grpc.CallOption) (*TodoistWebhookResponse, error)
+	// SubscribeEvents streams the webhook events of a user as they are received
+	SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
 }
 
 type todoistServiceClient struct {
@@ -50,6 +53,25 @@ func (c *todoistServiceClient) ProcessWebhook(ctx context.Context, in *TodoistWe
 	return out, nil
 }
 
+func (c *todoistServiceClient) SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TodoistService_ServiceDesc.Streams[0], TodoistService_SubscribeEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoistService_SubscribeEventsClient = grpc.ServerStreamingClient[Event]
+
 // TodoistServiceServer is the server API for TodoistService service.
 // All implementations must embed UnimplementedTodoistServiceServer
 // for forward compatibility.
@@ -58,6 +80,8 @@ func (c *todoistServiceClient) ProcessWebhook(ctx context.Context, in *TodoistWe
 type TodoistServiceServer interface {
 	// ProcessWebhook processes incoming webhook notifications from Todoist
 	ProcessWebhook(context.Context, *TodoistWebhookRequest) (*TodoistWebhookResponse, error)
+	// SubscribeEvents streams the webhook events of a user as they are received
+	SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error
 	mustEmbedUnimplementedTodoistServiceServer()
 }
 
@@ -71,6 +95,9 @@ type UnimplementedTodoistServiceServer struct{}
 func (UnimplementedTodoistServiceServer) ProcessWebhook(context.Context, *TodoistWebhookRequest) (*TodoistWebhookResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ProcessWebhook not implemented")
 }
+func (UnimplementedTodoistServiceServer) SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeEvents not implemented")
+}
 func (UnimplementedTodoistServiceServer) mustEmbedUnimplementedTodoistServiceServer() {}
 func (UnimplementedTodoistServiceServer) testEmbeddedByValue()                        {}
 
@@ -110,6 +137,17 @@ func _TodoistService_ProcessWebhook_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TodoistService_SubscribeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TodoistServiceServer).SubscribeEvents(m, &grpc.GenericServerStream[SubscribeEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoistService_SubscribeEventsServer = grpc.ServerStreamingServer[Event]
+
 // TodoistService_ServiceDesc is the grpc.ServiceDesc for TodoistService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -122,6 +160,12 @@ var TodoistService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _TodoistService_ProcessWebhook_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeEvents",
+			Handler:       _TodoistService_SubscribeEvents_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "todoist.proto",
 }
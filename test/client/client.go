@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"cherry_backend/pkg/api"
+	apiv1 "cherry_backend/pkg/api/v1"
 )
 
 func main() {
@@ -26,15 +28,15 @@ func testTodoistClient() {
 	todoistClient.SetSecret("test_secret")
 
 	// Create a webhook request
-	request := &api.TodoistWebhookRequest{
+	request := &apiv1.TodoistWebhookRequest{
 		EventName: "item:added",
-		UserID:    "123456",
+		UserId:    "123456",
 		EventData: `{"item_id": "789"}`,
 		Version:   "1.0",
 	}
 
 	// Process the webhook
-	response, err := todoistClient.ProcessWebhook(request)
+	response, err := todoistClient.ProcessWebhook(context.Background(), request)
 	if err != nil {
 		log.Fatalf("Failed to process webhook: %v", err)
 	}
@@ -50,7 +52,7 @@ func testHealthClient() {
 	healthClient := api.NewHealthClient("http://localhost:8080")
 
 	// Perform a health check
-	response, err := healthClient.Check()
+	response, err := healthClient.Check(context.Background(), &apiv1.HealthCheckRequest{})
 	if err != nil {
 		log.Fatalf("Failed to perform health check: %v", err)
 	}
@@ -0,0 +1,103 @@
+// Command load sends webhook load to a running server and reports latency percentiles, error
+// rates and the server's own metrics. With -soak it fails if the server keeps goroutines or
+// file descriptors once the load stops.
+//
+//	go run ./test/load -url http://localhost:8080/webhooks/todoist -secret $TODOIST_CLIENT_SECRET \
+//	    -token $CHERRY_ADMIN_TOKEN -concurrency 50 -duration 1m
+//	go run ./test/load -secret $TODOIST_CLIENT_SECRET -token $CHERRY_ADMIN_TOKEN -soak -duration 30m
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"os/signal"
+
+	"cherry_backend/internal/loadtest"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/webhooks/todoist", "Webhook URL to send the load to")
+	concurrency := flag.Int("concurrency", loadtest.DefaultConcurrency, "Number of deliveries in flight")
+	duration := flag.Duration("duration", loadtest.DefaultDuration, "How long to send load")
+	mix := flag.String("mix", "", "Event mix, e.g. item:added=3,item:completed=1 (default: a typical account's mix)")
+	secret := flag.String("secret", "", "Todoist client secret to sign the deliveries with")
+	unsigned := flag.Bool("unsigned", false, "Send the deliveries without a signature to load the rejection path")
+	users := flag.Int("users", loadtest.DefaultUsers, "Number of distinct user IDs")
+	timeout := flag.Duration("timeout", loadtest.DefaultTimeout, "Timeout of every delivery")
+	metricsURL := flag.String("metrics", "", "Metrics endpoint of the server (default: /admin/metrics on the host of -url when -token is set)")
+	token := flag.String("token", os.Getenv("CHERRY_ADMIN_TOKEN"), "Admin token of the server, to read its metrics (default $CHERRY_ADMIN_TOKEN)")
+	sample := flag.Duration("sample", loadtest.DefaultSampleInterval, "How often the server metrics are sampled")
+	soak := flag.Bool("soak", false, "Watch the server for goroutine and file descriptor leaks")
+	settle := flag.Duration("settle", loadtest.DefaultSettleTime, "How long to let the server settle before checking for leaks")
+	maxErrorRate := flag.Float64("max-error-rate", 0.01, "Fail when a larger share of deliveries gets no 2xx response")
+	out := flag.String("out", "", "Write the report as JSON to this file")
+	flag.Parse()
+
+	config := loadtest.Config{
+		URL:            *url,
+		Concurrency:    *concurrency,
+		Duration:       *duration,
+		Sign:           !*unsigned,
+		Secret:         *secret,
+		Users:          *users,
+		Timeout:        *timeout,
+		MetricsURL:     *metricsURL,
+		AdminToken:     *token,
+		SampleInterval: *sample,
+		Soak:           *soak,
+		SettleTime:     *settle,
+	}
+	if *mix != "" {
+		parsed, err := loadtest.ParseMix(*mix)
+		if err != nil {
+			fail(err)
+		}
+		config.Mix = parsed
+	}
+	if config.MetricsURL == "" && *token != "" {
+		config.MetricsURL = metricsURLOf(*url)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("Sending webhooks to %s for %v with %d workers (signed: %v)\n", config.URL, config.Duration, config.Concurrency, config.Sign)
+	report, err := loadtest.Run(ctx, config)
+	if err != nil {
+		fail(err)
+	}
+	report.Print(os.Stdout)
+
+	if *out != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if err := os.WriteFile(*out, data, 0644); err != nil {
+			fail(err)
+		}
+	}
+	if report.Server != nil && len(report.Server.Leaks) > 0 {
+		fail(fmt.Errorf("the server leaked resources"))
+	}
+	if report.ErrorRate > *maxErrorRate {
+		fail(fmt.Errorf("error rate %.2f%% above %.2f%%", report.ErrorRate*100, *maxErrorRate*100))
+	}
+}
+
+// metricsURLOf returns the admin metrics endpoint on the host of the webhook URL
+func metricsURLOf(webhookURL string) string {
+	parsed, err := neturl.Parse(webhookURL)
+	if err != nil {
+		return ""
+	}
+	parsed.Path, parsed.RawQuery = "/admin/metrics", ""
+	return parsed.String()
+}
+
+// fail prints an error and exits
+func fail(err error) {
+	fmt.Printf("Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,138 @@
+// Command replay records real webhook deliveries from a running server into fixture files and
+// replays them against any server.
+//
+//	go run ./test/replay record -server http://localhost:8080 -token $CHERRY_ADMIN_TOKEN -o fixture.json
+//	go run ./test/replay replay -fixture fixture.json -url http://localhost:8080/webhooks/todoist \
+//	    -secret $TODOIST_CLIENT_SECRET -speed 10 -out results.json -baseline baseline.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"cherry_backend/internal/replay"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = record(ctx, os.Args[2:])
+	case "replay":
+		err = replayFixture(ctx, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the subcommands and exits
+func usage() {
+	fmt.Println("Usage: replay record|replay [flags]")
+	fmt.Println("  record  fetch the deliveries kept by a server into a fixture file")
+	fmt.Println("  replay  send the deliveries of a fixture file to a webhook URL")
+	os.Exit(2)
+}
+
+// record fetches the deliveries kept in the event store of a server into a fixture file
+func record(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	server := flags.String("server", "http://localhost:8080", "Base URL of the server to record from")
+	token := flags.String("token", os.Getenv("CHERRY_ADMIN_TOKEN"), "Admin token of the server (default $CHERRY_ADMIN_TOKEN)")
+	userID := flags.String("user", "", "Only record the webhooks of this user")
+	after := flags.Uint64("after", 0, "Only record the webhooks after this event ID")
+	limit := flags.Int("limit", 0, "Record at most this many webhooks (0 for all)")
+	out := flags.String("o", "fixture.json", "Fixture file to write")
+	flags.Parse(args)
+
+	fixture, err := replay.NewRecorder(*server, *token).Record(ctx, *userID, *after, *limit)
+	if err != nil {
+		return err
+	}
+	if err := fixture.Save(*out); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded %d webhook(s) to %s\n", len(fixture.Deliveries), *out)
+	return nil
+}
+
+// replayFixture sends the deliveries of a fixture file and optionally compares the responses
+// with a baseline
+func replayFixture(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	fixturePath := flags.String("fixture", "fixture.json", "Fixture file to replay")
+	url := flags.String("url", "http://localhost:8080/webhooks/todoist", "Webhook URL to replay against")
+	secret := flags.String("secret", "", "Re-sign every body with this client secret (default: keep the recorded signature)")
+	speed := flags.Float64("speed", 1, "Replay speed: 1 preserves the recorded timing, 10 is ten times faster, 0 sends back to back")
+	maxGap := flags.Duration("max-gap", 0, "Cap the wait between two deliveries (0 for no cap)")
+	out := flags.String("out", "", "Write the responses to this results file, e.g. to use as a baseline")
+	baselinePath := flags.String("baseline", "", "Compare the responses with this results file and fail on differences")
+	ignore := flags.String("ignore", strings.Join(replay.DefaultIgnoredFields, ","), "Comma-separated JSON fields ignored when comparing bodies")
+	flags.Parse(args)
+
+	fixture, err := replay.LoadFixture(*fixturePath)
+	if err != nil {
+		return err
+	}
+
+	replayer := replay.NewReplayer(*url)
+	replayer.Secret = *secret
+	replayer.Speed = *speed
+	replayer.MaxGap = *maxGap
+
+	fmt.Printf("Replaying %d webhook(s) to %s\n", len(fixture.Deliveries), *url)
+	start := time.Now()
+	results, err := replayer.Replay(ctx, fixture)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, result := range results.Results {
+		if result.Error != "" || result.StatusCode >= 400 {
+			failed++
+		}
+	}
+	fmt.Printf("Sent %d webhook(s) in %v, %d failed\n", len(results.Results), time.Since(start).Round(time.Millisecond), failed)
+
+	if *out != "" {
+		if err := results.Save(*out); err != nil {
+			return err
+		}
+	}
+	if *baselinePath == "" {
+		return nil
+	}
+
+	baseline, err := replay.LoadResults(*baselinePath)
+	if err != nil {
+		return err
+	}
+	var ignored []string
+	if *ignore != "" {
+		ignored = strings.Split(*ignore, ",")
+	}
+	differences := replay.Diff(baseline, results, ignored)
+	for _, difference := range differences {
+		fmt.Println(difference)
+	}
+	if len(differences) > 0 {
+		return fmt.Errorf("%d difference(s) from %s", len(differences), *baselinePath)
+	}
+	fmt.Printf("No differences from %s\n", *baselinePath)
+	return nil
+}
@@ -2,15 +2,23 @@
 #
 # This script runs the Cherry Backend server and test client concurrently.
 # It handles setting up the correct ports and ensures proper cleanup.
+#
+# With -RunScenarios it also runs the user journeys of test\scenarios (or of the
+# comma-separated files and directories given with -ScenarioPaths) through the
+# webhook simulator, and fails if any of them fails.
 
 param (
     [int]$ServerPort = 8080,
     [string]$ClientHost = "localhost",
     [switch]$RunWebhookSimulator = $false,
     [string]$WebhookEvent = "item:added",
-    [string]$Secret = ""
+    [string]$Secret = "",
+    [switch]$RunScenarios = $false,
+    [string]$ScenarioPaths = "test\scenarios"
 )
 
+$adminToken = "test_admin_token"
+
 # Display script banner
 Write-Host "========================================"
 Write-Host "Cherry Backend Test Runner"
@@ -26,6 +34,10 @@ if ($RunWebhookSimulator) {
         Write-Host "Secret: [NONE]"
     }
 }
+Write-Host "Run Scenarios: $RunScenarios"
+if ($RunScenarios) {
+    Write-Host "Scenarios: $ScenarioPaths"
+}
 Write-Host "========================================"
 Write-Host ""
 
@@ -54,12 +66,13 @@ Pop-Location
 # Start the server in a background job
 Write-Host "Starting server on port $ServerPort..."
 $serverJob = Start-Job -ScriptBlock {
-    param($port)
+    param($port, $token)
     $env:PORT = $port
     $env:TODOIST_CLIENT_SECRET = "test_secret"
+    $env:CHERRY_ADMIN_TOKEN = $token
     Set-Location $using:PWD
     go run main.go
-} -ArgumentList $ServerPort
+} -ArgumentList $ServerPort, $adminToken
 
 # Wait for the server to start
 Write-Host "Waiting for server to start..."
@@ -103,6 +116,21 @@ if ($RunWebhookSimulator) {
     Pop-Location
 }
 
+# Run the scenarios if requested
+$scenarioExitCode = 0
+if ($RunScenarios) {
+    Write-Host "Building webhook simulator for scenarios..."
+    go build -o test\webhook\simulate_webhook.exe test\webhook\simulate_webhook.go
+    if (-not $?) {
+        Write-Host "Failed to build webhook simulator" -ForegroundColor Red
+        $scenarioExitCode = 1
+    } else {
+        Write-Host "Running scenarios..."
+        .\test\webhook\simulate_webhook.exe -server="$serverUrl" -secret="test_secret" -admin-token="$adminToken" -scenario="$ScenarioPaths"
+        $scenarioExitCode = $LASTEXITCODE
+    }
+}
+
 # Clean up
 Write-Host "Cleaning up..."
 Remove-Item -Path "test\client\temp_client.exe" -ErrorAction SilentlyContinue
@@ -123,6 +151,16 @@ if ($clientExitCode -eq 0) {
 } else {
     Write-Host "Client tests: FAILED (Exit code: $clientExitCode)" -ForegroundColor Red
 }
+if ($RunScenarios) {
+    if ($scenarioExitCode -eq 0) {
+        Write-Host "Scenarios: SUCCESS" -ForegroundColor Green
+    } else {
+        Write-Host "Scenarios: FAILED (Exit code: $scenarioExitCode)" -ForegroundColor Red
+    }
+}
 Write-Host "========================================"
 
-exit $clientExitCode
+if ($clientExitCode -ne 0) {
+    exit $clientExitCode
+}
+exit $scenarioExitCode
@@ -3,6 +3,10 @@
 #
 # This script runs the Cherry Backend server and test client concurrently.
 # It handles setting up the correct ports and ensures proper cleanup.
+#
+# With --scenarios it also runs the user journeys of test/scenarios (or of the
+# comma-separated files and directories given with --scenarios=PATHS) through
+# the webhook simulator, and fails if any of them fails.
 
 # Default values
 SERVER_PORT=8080
@@ -10,6 +14,9 @@ CLIENT_HOST="localhost"
 RUN_WEBHOOK_SIMULATOR=false
 WEBHOOK_EVENT="item:added"
 SECRET=""
+RUN_SCENARIOS=false
+SCENARIO_PATHS="test/scenarios"
+ADMIN_TOKEN="test_admin_token"
 
 # Parse command line arguments
 while [[ $# -gt 0 ]]; do
@@ -34,6 +41,15 @@ while [[ $# -gt 0 ]]; do
       SECRET="${1#*=}"
       shift
       ;;
+    --scenarios)
+      RUN_SCENARIOS=true
+      shift
+      ;;
+    --scenarios=*)
+      RUN_SCENARIOS=true
+      SCENARIO_PATHS="${1#*=}"
+      shift
+      ;;
     *)
       echo "Unknown option: $1"
       exit 1
@@ -56,6 +72,10 @@ if [ "$RUN_WEBHOOK_SIMULATOR" = true ]; then
         echo "Secret: [NONE]"
     fi
 fi
+echo "Run Scenarios: $RUN_SCENARIOS"
+if [ "$RUN_SCENARIOS" = true ]; then
+    echo "Scenarios: $SCENARIO_PATHS"
+fi
 echo "========================================"
 echo ""
 
@@ -85,6 +105,7 @@ popd > /dev/null
 echo "Starting server on port $SERVER_PORT..."
 export PORT=$SERVER_PORT
 export TODOIST_CLIENT_SECRET="test_secret"
+export CHERRY_ADMIN_TOKEN="$ADMIN_TOKEN"
 go run main.go &
 SERVER_PID=$!
 
@@ -129,6 +150,22 @@ if [ "$RUN_WEBHOOK_SIMULATOR" = true ]; then
     popd > /dev/null
 fi
 
+# Run the scenarios if requested
+SCENARIO_EXIT_CODE=0
+if [ "$RUN_SCENARIOS" = true ]; then
+    echo "Building webhook simulator for scenarios..."
+    go build -o test/webhook/simulate_webhook test/webhook/simulate_webhook.go
+    if [ $? -ne 0 ]; then
+        echo "Failed to build webhook simulator"
+        SCENARIO_EXIT_CODE=1
+    else
+        echo "Running scenarios..."
+        ./test/webhook/simulate_webhook -server="$SERVER_URL" -secret="test_secret" \
+            -admin-token="$ADMIN_TOKEN" -scenario="$SCENARIO_PATHS"
+        SCENARIO_EXIT_CODE=$?
+    fi
+fi
+
 # Clean up
 echo "Cleaning up..."
 rm -f "test/client/temp_client"
@@ -148,6 +185,16 @@ if [ $CLIENT_EXIT_CODE -eq 0 ]; then
 else
     echo "Client tests: FAILED (Exit code: $CLIENT_EXIT_CODE)"
 fi
+if [ "$RUN_SCENARIOS" = true ]; then
+    if [ $SCENARIO_EXIT_CODE -eq 0 ]; then
+        echo "Scenarios: SUCCESS"
+    else
+        echo "Scenarios: FAILED (Exit code: $SCENARIO_EXIT_CODE)"
+    fi
+fi
 echo "========================================"
 
-exit $CLIENT_EXIT_CODE
+if [ $CLIENT_EXIT_CODE -ne 0 ]; then
+    exit $CLIENT_EXIT_CODE
+fi
+exit $SCENARIO_EXIT_CODE
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -9,9 +10,14 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	"cherry_backend/internal/models"
+	"cherry_backend/internal/scenario"
 )
 
 func main() {
@@ -20,8 +26,22 @@ func main() {
 	secret := flag.String("secret", "", "Todoist client secret for signature verification")
 	eventType := flag.String("event", "item:added", "Event type (item:added, item:updated, item:deleted, item:completed)")
 	userID := flag.String("user", "12345", "User ID")
+	scenarios := flag.String("scenario", "", "Comma-separated scenario files or directories to run instead of sending a single webhook")
+	server := flag.String("server", "", "Base URL of the server for scenarios (default: derived from -url)")
+	adminToken := flag.String("admin-token", os.Getenv("CHERRY_ADMIN_TOKEN"), "Admin token for scenario steps that query the admin API (default $CHERRY_ADMIN_TOKEN)")
 	flag.Parse()
 
+	// Run scenarios if requested
+	if *scenarios != "" {
+		if *server == "" {
+			*server = baseURL(*url)
+		}
+		if !runScenarios(*server, *secret, *adminToken, strings.Split(*scenarios, ",")) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Create a sample event data
 	eventData := map[string]interface{}{
 		"id":          "123456789",
@@ -93,3 +113,50 @@ func main() {
 	// Print success message
 	fmt.Printf("Successfully sent %s webhook to %s\n", *eventType, *url)
 }
+
+// baseURL strips the path of the webhook URL
+func baseURL(webhookURL string) string {
+	parsed, err := neturl.Parse(webhookURL)
+	if err != nil {
+		return webhookURL
+	}
+	parsed.Path, parsed.RawQuery = "", ""
+	return parsed.String()
+}
+
+// runScenarios runs scenario files against the server and reports whether all of them passed
+func runScenarios(server, secret, adminToken string, paths []string) bool {
+	scenarios, err := scenario.LoadAll(paths...)
+	if err != nil {
+		fmt.Printf("Error loading scenarios: %v\n", err)
+		return false
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := scenario.NewRunner(server)
+	runner.Secret = secret
+	runner.AdminToken = adminToken
+
+	failed := 0
+	for _, s := range scenarios {
+		fmt.Printf("Scenario: %s\n", s.Name)
+		report := runner.Run(ctx, s)
+		for _, step := range report.Steps {
+			if step.Err != nil {
+				fmt.Printf("  FAIL %s: %v\n", step.Name, step.Err)
+			} else {
+				fmt.Printf("  PASS %s (%v)\n", step.Name, step.Duration.Round(time.Millisecond))
+			}
+		}
+		if skipped := len(s.Steps) - len(report.Steps); skipped > 0 {
+			fmt.Printf("  SKIP %d remaining step(s)\n", skipped)
+		}
+		if !report.Passed() {
+			failed++
+		}
+	}
+	fmt.Printf("%d of %d scenario(s) passed\n", len(scenarios)-failed, len(scenarios))
+	return failed == 0
+}
@@ -0,0 +1,206 @@
+// Package faketodoist is an in-process fake of the Todoist platform for integration tests. It
+// serves the REST and Sync APIs of every user over in-memory state, the OAuth endpoints that
+// issue their tokens, and sends correctly signed webhooks to a backend whenever a user's state
+// changes, so a server under test can be exercised end to end through httptest.
+//
+//	platform := faketodoist.NewPlatform()
+//	defer platform.Close()
+//	user := platform.AddUser("ada@example.com")
+//	platform.SetWebhookURL(backend.URL + "/webhooks/todoist")
+//	platform.Client(user).CreateTask(&api.CreateTaskArgs{Content: "Buy milk"})
+package faketodoist
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"cherry_backend/pkg/api"
+	"cherry_backend/pkg/api/todoisttest"
+)
+
+// APIPath is the path of the REST and Sync APIs on the platform, as in api.TodoistRESTBaseURL
+const APIPath = "/api/v1"
+
+// WebhookVersion is the version of the webhook payloads sent by the platform
+const WebhookVersion = "9"
+
+// Platform is a fake Todoist platform serving the APIs of any number of users
+type Platform struct {
+	*httptest.Server
+
+	// ClientID and ClientSecret identify the integration of the server under test: OAuth
+	// requests must carry them and webhooks are signed with the secret
+	ClientID     string
+	ClientSecret string
+
+	mu         sync.Mutex
+	users      map[string]*User
+	tokens     map[string]*User
+	codes      map[string]*User
+	nextUserID int
+	webhookURL string
+	deliveries []Delivery
+	client     *http.Client
+}
+
+// User is an account on the platform
+type User struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+	Timezone string `json:"-"`
+
+	// Token is the API token of the user; OAuth issues the same token
+	Token string `json:"-"`
+
+	// API is the state of the user, which tests can inspect or make fail with FailNext
+	API *todoisttest.Server `json:"-"`
+}
+
+// NewPlatform starts a fake platform without users
+func NewPlatform() *Platform {
+	p := &Platform{
+		ClientID:     "fake-client-id",
+		ClientSecret: "fake-client-secret",
+		users:        make(map[string]*User),
+		tokens:       make(map[string]*User),
+		codes:        make(map[string]*User),
+		client:       &http.Client{},
+	}
+	p.Server = httptest.NewServer(p.router())
+	return p
+}
+
+// router sets up the OAuth endpoints and routes API requests to the state of their user
+func (p *Platform) router() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/oauth/authorize", p.authorize).Methods("GET")
+	r.HandleFunc("/oauth/access_token", p.accessToken).Methods("POST")
+	r.HandleFunc(APIPath+"/user", p.currentUser).Methods("GET")
+	r.PathPrefix(APIPath + "/").Handler(http.StripPrefix(APIPath, http.HandlerFunc(p.serveAPI)))
+	return r
+}
+
+// Close shuts down the platform
+func (p *Platform) Close() {
+	p.Server.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, user := range p.users {
+		user.API.Close()
+	}
+}
+
+// APIURL is the base URL of the REST and Sync APIs, e.g. for TODOIST_API_URL
+func (p *Platform) APIURL() string {
+	return p.URL + APIPath
+}
+
+// AddUser creates a user with an empty Todoist account
+func (p *Platform) AddUser(email string) *User {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nextUserID++
+	user := &User{
+		ID:       strconv.Itoa(1000 + p.nextUserID),
+		Email:    email,
+		FullName: strings.SplitN(email, "@", 2)[0],
+		Timezone: "UTC",
+		Token:    randomHex(20),
+	}
+	user.API = todoisttest.NewUnstartedServer(user.Token)
+	user.API.OnChange(func(change todoisttest.Change) {
+		p.sendWebhook(user, change)
+	})
+	p.users[user.ID] = user
+	p.tokens[user.Token] = user
+	return user
+}
+
+// User returns the user with the given ID, or nil
+func (p *Platform) User(id string) *User {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.users[id]
+}
+
+// Tokens maps the ID of every user to their API token, in the format of TODOIST_API_TOKENS
+func (p *Platform) Tokens() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pairs := make([]string, 0, len(p.users))
+	for id, user := range p.users {
+		pairs = append(pairs, id+":"+user.Token)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Client returns a REST client acting as a user
+func (p *Platform) Client(user *User) *api.TodoistRESTClient {
+	return api.NewTodoistRESTClient(p.APIURL(), user.Token)
+}
+
+// serveAPI passes an API request to the state of the user whose token it carries
+func (p *Platform) serveAPI(w http.ResponseWriter, r *http.Request) {
+	user := p.userOf(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user.API.ServeHTTP(w, r)
+}
+
+// currentUser returns the account of the user whose token the request carries
+func (p *Platform) currentUser(w http.ResponseWriter, r *http.Request) {
+	user := p.userOf(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"id":        user.ID,
+		"email":     user.Email,
+		"full_name": user.FullName,
+		"tz":        user.Timezone,
+	})
+}
+
+// userOf returns the user of the bearer token of a request, or nil
+func (p *Platform) userOf(r *http.Request) *User {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokens[token]
+}
+
+// signature computes the webhook signature of a body, hex HMAC-SHA256 with the client secret
+func (p *Platform) signature(body []byte) string {
+	h := hmac.New(sha256.New, []byte(p.ClientSecret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// errorf writes a JSON OAuth error
+func errorf(w http.ResponseWriter, status int, code, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": fmt.Sprintf(format, args...)})
+}
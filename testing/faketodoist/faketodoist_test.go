@@ -0,0 +1,165 @@
+package faketodoist
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"cherry_backend/pkg/api"
+)
+
+// TestOAuthFlow tests that authorizing and exchanging the code yields a working token
+func TestOAuthFlow(t *testing.T) {
+	platform := NewPlatform()
+	defer platform.Close()
+	user := platform.AddUser("ada@example.com")
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(platform.AuthorizeURL(user, "data:read_write", "xyz", "http://backend/callback"))
+	if err != nil {
+		t.Fatalf("authorize failed: %v", err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil || location.Host != "backend" {
+		t.Fatalf("authorize = %d %q, want a redirect to the callback", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if location.Query().Get("state") != "xyz" || location.Query().Get("code") == "" {
+		t.Fatalf("redirect query = %v, want the state and a code", location.Query())
+	}
+
+	exchange := func(code string) (int, map[string]string) {
+		resp, err := http.PostForm(platform.URL+"/oauth/access_token", url.Values{
+			"client_id":     {platform.ClientID},
+			"client_secret": {platform.ClientSecret},
+			"code":          {code},
+		})
+		if err != nil {
+			t.Fatalf("access_token failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	code := location.Query().Get("code")
+	status, body := exchange(code)
+	if status != http.StatusOK || body["access_token"] != user.Token {
+		t.Fatalf("access_token = %d %v, want the user's token", status, body)
+	}
+	if status, body := exchange(code); status != http.StatusBadRequest || body["error"] != "bad_authorization_code" {
+		t.Errorf("reused code = %d %v, want bad_authorization_code", status, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, platform.APIURL()+"/user", nil)
+	req.Header.Set("Authorization", "Bearer "+body["access_token"])
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /user failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var account map[string]string
+	json.NewDecoder(resp.Body).Decode(&account)
+	if account["id"] != user.ID || account["email"] != "ada@example.com" {
+		t.Errorf("GET /user = %v, want the authorized user", account)
+	}
+}
+
+// TestUsersAreIsolated tests that every token sees only its own user's state
+func TestUsersAreIsolated(t *testing.T) {
+	platform := NewPlatform()
+	defer platform.Close()
+	ada := platform.AddUser("ada@example.com")
+	bob := platform.AddUser("bob@example.com")
+
+	if _, err := platform.Client(ada).CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Ada's task"}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	tasks, err := platform.Client(bob).ListTasks(context.Background(), nil)
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("Bob's tasks = %v, %v, want none", tasks, err)
+	}
+	if tasks, _ := platform.Client(ada).ListTasks(context.Background(), nil); len(tasks) != 1 {
+		t.Errorf("Ada's tasks = %v, want her task", tasks)
+	}
+
+	_, err = api.NewTodoistRESTClient(platform.APIURL(), "unknown").ListTasks(context.Background(), nil)
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unknown token error = %v, want 401", err)
+	}
+	if got := platform.Tokens(); got != ada.ID+":"+ada.Token+","+bob.ID+":"+bob.Token {
+		t.Errorf("Tokens() = %q", got)
+	}
+}
+
+// TestWebhooks tests that changes are delivered as signed webhooks before the API call returns
+func TestWebhooks(t *testing.T) {
+	platform := NewPlatform()
+	defer platform.Close()
+	user := platform.AddUser("ada@example.com")
+
+	var mu sync.Mutex
+	var received []map[string]interface{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(platform.ClientSecret))
+		mac.Write(body)
+		if r.Header.Get(SignatureHeader) != hex.EncodeToString(mac.Sum(nil)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var payload map[string]interface{}
+		json.Unmarshal(body, &payload)
+		mu.Lock()
+		received = append(received, payload)
+		mu.Unlock()
+	}))
+	defer backend.Close()
+
+	// Changes made before a webhook URL is set are not delivered
+	client := platform.Client(user)
+	if _, err := client.CreateProject(context.Background(), &api.CreateProjectArgs{Name: "Inbox"}); err != nil {
+		t.Fatalf("CreateProject failed: %v", err)
+	}
+	platform.SetWebhookURL(backend.URL)
+
+	task, err := client.CreateTask(context.Background(), &api.CreateTaskArgs{Content: "Buy milk"})
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if err := client.CloseTask(context.Background(), task.ID); err != nil {
+		t.Fatalf("CloseTask failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("received %d webhooks, want 2", len(received))
+	}
+	first := received[0]
+	data, _ := first["event_data"].(map[string]interface{})
+	content, _ := data["content"].(string)
+	if first["event_name"] != "item:added" || first["user_id"] != user.ID || first["version"] != WebhookVersion ||
+		data["id"] != task.ID || !strings.Contains(content, "milk") {
+		t.Errorf("first webhook = %v, want item:added of the task", first)
+	}
+	if received[1]["event_name"] != "item:completed" {
+		t.Errorf("second webhook = %v, want item:completed", received[1]["event_name"])
+	}
+
+	deliveries := platform.Deliveries()
+	if len(deliveries) != 2 || deliveries[0].StatusCode != http.StatusOK || deliveries[1].Event != "item:completed" {
+		t.Errorf("Deliveries() = %+v, want two successful deliveries", deliveries)
+	}
+}
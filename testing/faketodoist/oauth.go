@@ -0,0 +1,93 @@
+package faketodoist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// DefaultRedirectURI is where authorize redirects to when the request names no redirect_uri
+const DefaultRedirectURI = "http://localhost/oauth/callback"
+
+// AuthorizeURL returns the URL a user opens to grant the integration access to their account.
+// The fake approves at once: following it redirects to redirectURI with a code and the state.
+func (p *Platform) AuthorizeURL(user *User, scope, state, redirectURI string) string {
+	query := url.Values{
+		"client_id": {p.ClientID},
+		"scope":     {scope},
+		"state":     {state},
+		"user_id":   {user.ID},
+	}
+	if redirectURI != "" {
+		query.Set("redirect_uri", redirectURI)
+	}
+	return p.URL + "/oauth/authorize?" + query.Encode()
+}
+
+// authorize approves an authorization request on behalf of the user named by user_id, which
+// stands in for the login page of the real platform
+func (p *Platform) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != p.ClientID {
+		errorf(w, http.StatusBadRequest, "invalid_client", "unknown client_id %q", query.Get("client_id"))
+		return
+	}
+	if query.Get("scope") == "" {
+		errorf(w, http.StatusBadRequest, "invalid_scope", "scope is required")
+		return
+	}
+	if query.Get("state") == "" {
+		errorf(w, http.StatusBadRequest, "invalid_request", "state is required")
+		return
+	}
+	user := p.User(query.Get("user_id"))
+	if user == nil {
+		errorf(w, http.StatusBadRequest, "access_denied", "unknown user %q", query.Get("user_id"))
+		return
+	}
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || query.Get("redirect_uri") == "" {
+		redirect, _ = url.Parse(DefaultRedirectURI)
+	}
+	code := randomHex(16)
+	p.mu.Lock()
+	p.codes[code] = user
+	p.mu.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// accessToken exchanges an authorization code for the token of its user. Codes are single use.
+func (p *Platform) accessToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errorf(w, http.StatusBadRequest, "invalid_request", "%v", err)
+		return
+	}
+	if r.PostForm.Get("client_id") != p.ClientID || r.PostForm.Get("client_secret") != p.ClientSecret {
+		errorf(w, http.StatusBadRequest, "invalid_client", "bad client credentials")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	user := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if user == nil {
+		errorf(w, http.StatusBadRequest, "bad_authorization_code", "unknown or used code")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": user.Token, "token_type": "Bearer"})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
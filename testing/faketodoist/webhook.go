@@ -0,0 +1,90 @@
+package faketodoist
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"cherry_backend/pkg/api/todoisttest"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed with the client secret
+const SignatureHeader = "X-Todoist-Hmac-SHA256"
+
+// Delivery records a webhook sent by the platform
+type Delivery struct {
+	Event  string `json:"event"`
+	UserID string `json:"user_id"`
+
+	// StatusCode is the status the backend answered with, zero when the request failed
+	StatusCode int `json:"status_code"`
+
+	// Err is why the request failed
+	Err error `json:"-"`
+}
+
+// SetWebhookURL sets where the platform sends webhooks; empty stops them
+func (p *Platform) SetWebhookURL(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.webhookURL = url
+}
+
+// Deliveries returns the webhooks sent so far, oldest first
+func (p *Platform) Deliveries() []Delivery {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Delivery(nil), p.deliveries...)
+}
+
+// sendWebhook delivers a change of a user's state to the backend. It runs before the API
+// response that caused the change is written, so a client sees the webhook delivered by the
+// time its call returns.
+func (p *Platform) sendWebhook(user *User, change todoisttest.Change) {
+	p.mu.Lock()
+	url := p.webhookURL
+	p.mu.Unlock()
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"event_name": change.Event,
+		"user_id":    user.ID,
+		"event_data": change.Data,
+		"initiator": map[string]string{
+			"id":        user.ID,
+			"email":     user.Email,
+			"full_name": user.FullName,
+		},
+		"version": WebhookVersion,
+	})
+	delivery := Delivery{Event: change.Event, UserID: user.ID}
+	if err == nil {
+		delivery.StatusCode, err = p.post(url, body)
+	}
+	delivery.Err = err
+
+	p.mu.Lock()
+	p.deliveries = append(p.deliveries, delivery)
+	p.mu.Unlock()
+}
+
+// post sends a signed webhook body and returns the status of the response
+func (p *Platform) post(url string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Todoist-Webhooks")
+	req.Header.Set(SignatureHeader, p.signature(body))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}